@@ -1,5 +1,23 @@
 #!/bin/bash
 
+# Parts of lnd an app doesn't need can be stripped from the framework to cut
+# its size, by passing a space separated list of build tags through TAGS:
+#
+#   noautopilot  strips the autopilot agent
+#   noremoterpc  strips the TLS gRPC listeners and lndconnect pairing
+#
+# Optional features are added the same way:
+#
+#   aezeed       adds the aezeed cipher seed bindings, which the default
+#                build leaves out
+#
+# e.g. TAGS="noautopilot noremoterpc" ./bind_objc.sh
+
 pushd ios
-  gomobile bind -v -target=ios github.com/mandelmonkey/lndmobile/lightning
+  gomobile bind -v -target=ios -tags="$TAGS" github.com/mandelmonkey/lndmobile/lightning
 popd
+
+# Generate the completion handler wrappers of the bindings, built with the same
+# tags.
+go run cmd/genwrappers/main.go -lang=swift -tags="$TAGS" \
+  -out=ios/LightningAsync.swift
@@ -0,0 +1,385 @@
+// genwrappers generates asynchronous wrappers around the blocking functions of
+// the lightning bindings, so apps don't have to hand-roll adapters for every
+// call: completion handler based ones for Swift, and suspend functions for
+// Kotlin coroutines.
+//
+// Usage:
+//
+//	go run cmd/genwrappers/main.go -lang=swift -out=ios/LightningAsync.swift
+//	go run cmd/genwrappers/main.go -lang=kotlin -out=LightningAsync.kt
+//
+// The bindings are read as built with the space separated build tags given by
+// -tags, which should match those passed to gomobile bind, so functions of
+// files left out of the build aren't wrapped, nor those of files replacing
+// them wrapped twice.
+//
+// Only functions taking basic types and returning an error, optionally along
+// with a string or bound struct, are wrapped. Streaming calls and functions
+// taking callbacks are left as they are.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// param is a parameter of a wrapped function.
+type param struct {
+	name string
+	typ  string
+}
+
+// function describes a wrapped function of the bindings.
+type function struct {
+	name   string
+	params []param
+
+	// result is the Go type returned along with the error, or the empty
+	// string if only an error is returned.
+	result string
+}
+
+// basicTypes are the Go types that can be passed to a wrapped function, in
+// addition to pointers to the exported structs of the bindings.
+var basicTypes = map[string]struct{}{
+	"string": {},
+	"bool":   {},
+	"int":    {},
+	"int32":  {},
+	"int64":  {},
+}
+
+// typeName returns the name of the type expression, prefixing pointers with a
+// star.
+func typeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + typeName(t.X)
+	default:
+		return ""
+	}
+}
+
+// parseFunctions returns the wrappable functions of the bindings package in
+// dir, built with the tags, sorted by name.
+func parseFunctions(dir string, tags []string) ([]*function, error) {
+	buildCtx := build.Default
+	buildCtx.BuildTags = tags
+
+	// Test files aren't part of the bindings, and neither are the files
+	// whose build constraints the tags don't satisfy.
+	var matchErr error
+	filter := func(info os.FileInfo) bool {
+		name := info.Name()
+		if strings.HasSuffix(name, "_test.go") {
+			return false
+		}
+
+		match, err := buildCtx.MatchFile(dir, name)
+		if err != nil && matchErr == nil {
+			matchErr = err
+		}
+		return match
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if matchErr != nil {
+		return nil, matchErr
+	}
+
+	pkg, ok := pkgs["lightning"]
+	if !ok {
+		return nil, fmt.Errorf("package lightning not found in %v", dir)
+	}
+
+	// Collect the exported structs first, so pointers to them can be
+	// recognized as bound types.
+	structs := make(map[string]struct{})
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				_, isStruct := ts.Type.(*ast.StructType)
+				if isStruct && ts.Name.IsExported() {
+					structs["*"+ts.Name.Name] = struct{}{}
+				}
+			}
+		}
+	}
+
+	supported := func(typ string) bool {
+		_, basic := basicTypes[typ]
+		_, bound := structs[typ]
+		return basic || bound
+	}
+
+	var funcs []*function
+	for _, file := range pkg.Files {
+	nextDecl:
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() {
+				continue
+			}
+
+			results := fn.Type.Results
+			if results == nil || len(results.List) == 0 ||
+				len(results.List) > 2 {
+				continue
+			}
+			last := results.List[len(results.List)-1]
+			if typeName(last.Type) != "error" {
+				continue
+			}
+
+			f := &function{name: fn.Name.Name}
+			if len(results.List) == 2 {
+				f.result = typeName(results.List[0].Type)
+				if f.result != "string" {
+					if _, ok := structs[f.result]; !ok {
+						continue
+					}
+				}
+			}
+
+			for _, field := range fn.Type.Params.List {
+				typ := typeName(field.Type)
+				if !supported(typ) {
+					continue nextDecl
+				}
+				for _, name := range field.Names {
+					f.params = append(f.params, param{
+						name: name.Name,
+						typ:  typ,
+					})
+				}
+			}
+
+			funcs = append(funcs, f)
+		}
+	}
+
+	sort.Slice(funcs, func(i, j int) bool {
+		return funcs[i].name < funcs[j].name
+	})
+
+	return funcs, nil
+}
+
+// lowerFirst lower cases the first letter of the name, as gomobile does for
+// Java methods.
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// swiftTypes maps Go types to the Swift types gomobile imports them as.
+var swiftTypes = map[string]string{
+	"string": "String",
+	"bool":   "Bool",
+	"int":    "Int",
+	"int32":  "Int32",
+	"int64":  "Int64",
+}
+
+// swiftType returns the Swift type of the Go type.
+func swiftType(typ string) string {
+	if t, ok := swiftTypes[typ]; ok {
+		return t
+	}
+
+	// Bound structs are prefixed with the package name.
+	return "Lightning" + strings.TrimPrefix(typ, "*")
+}
+
+// genSwift generates completion handler based wrappers.
+func genSwift(funcs []*function) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintln(&b, "// Code generated by genwrappers. DO NOT EDIT.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "import Foundation")
+	fmt.Fprintln(&b, "import Lightning")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "/// Asynchronous wrappers of the Lightning bindings. Every call runs on a")
+	fmt.Fprintln(&b, "/// background queue, and its completion handler is invoked on the main queue.")
+	fmt.Fprintln(&b, "public enum LightningAsync {")
+	fmt.Fprintln(&b, "    static let queue = DispatchQueue(label: \"lndmobile\", qos: .userInitiated, attributes: .concurrent)")
+
+	for _, f := range funcs {
+		var params, args []string
+		for _, p := range f.params {
+			typ := swiftType(p.typ)
+			if strings.HasPrefix(p.typ, "*") {
+				typ += "?"
+			}
+			params = append(params, fmt.Sprintf("%s: %s", p.name, typ))
+			args = append(args, p.name)
+		}
+
+		result := "Void"
+		if f.result != "" {
+			result = swiftType(f.result)
+			if strings.HasPrefix(f.result, "*") {
+				result += "?"
+			}
+		}
+		params = append(params, fmt.Sprintf("completion: @escaping "+
+			"(Result<%s, Error>) -> Void", result))
+		args = append(args, "&error")
+
+		call := fmt.Sprintf("Lightning%s(%s)", f.name,
+			strings.Join(args, ", "))
+
+		fmt.Fprintln(&b)
+		fmt.Fprintf(&b, "    public static func %s(%s) {\n",
+			lowerFirst(f.name), strings.Join(params, ", "))
+		fmt.Fprintln(&b, "        queue.async {")
+		fmt.Fprintln(&b, "            var error: NSError?")
+		if f.result != "" {
+			fmt.Fprintf(&b, "            let result = %s\n", call)
+		} else {
+			fmt.Fprintf(&b, "            _ = %s\n", call)
+		}
+		fmt.Fprintln(&b, "            DispatchQueue.main.async {")
+		fmt.Fprintln(&b, "                if let error = error {")
+		fmt.Fprintln(&b, "                    completion(.failure(error))")
+		fmt.Fprintln(&b, "                    return")
+		fmt.Fprintln(&b, "                }")
+		if f.result != "" {
+			fmt.Fprintln(&b, "                completion(.success(result))")
+		} else {
+			fmt.Fprintln(&b, "                completion(.success(()))")
+		}
+		fmt.Fprintln(&b, "            }")
+		fmt.Fprintln(&b, "        }")
+		fmt.Fprintln(&b, "    }")
+	}
+
+	fmt.Fprintln(&b, "}")
+
+	return b.Bytes()
+}
+
+// kotlinTypes maps Go types to the Kotlin types gomobile exposes them as.
+var kotlinTypes = map[string]string{
+	"string": "String",
+	"bool":   "Boolean",
+	"int":    "Long",
+	"int32":  "Int",
+	"int64":  "Long",
+}
+
+// kotlinType returns the Kotlin type of the Go type.
+func kotlinType(typ string) string {
+	if t, ok := kotlinTypes[typ]; ok {
+		return t
+	}
+
+	return strings.TrimPrefix(typ, "*") + "?"
+}
+
+// genKotlin generates suspend function wrappers.
+func genKotlin(funcs []*function) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintln(&b, "// Code generated by genwrappers. DO NOT EDIT.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "package lightning")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "import kotlinx.coroutines.Dispatchers")
+	fmt.Fprintln(&b, "import kotlinx.coroutines.withContext")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "/**")
+	fmt.Fprintln(&b, " * Suspending wrappers of the Lightning bindings. Every call runs on the IO")
+	fmt.Fprintln(&b, " * dispatcher, and failures are thrown as exceptions.")
+	fmt.Fprintln(&b, " */")
+	fmt.Fprintln(&b, "object LightningAsync {")
+
+	for i, f := range funcs {
+		var params, args []string
+		for _, p := range f.params {
+			params = append(params, fmt.Sprintf("%s: %s", p.name,
+				kotlinType(p.typ)))
+			args = append(args, p.name)
+		}
+
+		result := "Unit"
+		if f.result != "" {
+			result = kotlinType(f.result)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(&b)
+		}
+		fmt.Fprintf(&b, "    suspend fun %s(%s): %s =\n",
+			lowerFirst(f.name), strings.Join(params, ", "), result)
+		fmt.Fprintf(&b, "        withContext(Dispatchers.IO) { "+
+			"Lightning.%s(%s) }\n", lowerFirst(f.name),
+			strings.Join(args, ", "))
+	}
+
+	fmt.Fprintln(&b, "}")
+
+	return b.Bytes()
+}
+
+func main() {
+	lang := flag.String("lang", "swift", "the language to generate "+
+		"wrappers for, swift or kotlin")
+	pkgDir := flag.String("pkg", "lightning", "the directory of the "+
+		"bindings package")
+	out := flag.String("out", "", "the file to write the wrappers to, "+
+		"instead of stdout")
+	tags := flag.String("tags", "", "the space separated build tags the "+
+		"bindings are built with")
+	flag.Parse()
+
+	funcs, err := parseFunctions(*pkgDir, strings.Fields(*tags))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var src []byte
+	switch *lang {
+	case "swift":
+		src = genSwift(funcs)
+	case "kotlin":
+		src = genKotlin(funcs)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown language %q\n", *lang)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+
+	if err := ioutil.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,44 @@
+package lightning
+
+import (
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// AbandonToken returns a token that lets AbandonChannel abandon the pending
+// channel at channelPoint, in the format txid:index, within the next minute.
+// It requires the node to run with UnsafeAbandonChannel set.
+func AbandonToken(channelPoint string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	chanPoint, err := parseOutPoint(channelPoint)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := lnd.AbandonToken(*chanPoint)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return token, nil
+}
+
+// AbandonChannel deletes the pending channel at channelPoint from the channel
+// database, given a token from AbandonToken, so a channel wedged by a failed
+// funding flow no longer shows as pending. If its funding transaction
+// confirms after all, the node no longer knows of the channel, and its funds
+// are lost unless the peer closes it.
+func AbandonChannel(channelPoint, token string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	chanPoint, err := parseOutPoint(channelPoint)
+	if err != nil {
+		return err
+	}
+
+	return wrapError(lnd.AbandonChannel(*chanPoint, token))
+}
@@ -0,0 +1,100 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcutil"
+)
+
+// accountList is the JSON result of ListAccounts.
+type accountList struct {
+	Accounts []*lnd.Account `json:"accounts"`
+}
+
+// CreateAccount creates an on-chain account with the given unique name, such
+// as savings. Only the outputs of the default account fund channels, so those
+// of this account stay put until spent through SendFromAccount. The account is returned JSON encoded.
+func CreateAccount(name string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	account, err := lnd.CreateAccount(name)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return "", err
+	}
+
+	return string(accountJSON), nil
+}
+
+// ListAccounts returns the JSON encoded on-chain accounts of the wallet, the
+// default one first, along with their confirmed and unconfirmed balances.
+func ListAccounts() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	accounts, err := lnd.ListAccounts()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	listJSON, err := json.Marshal(&accountList{Accounts: accounts})
+	if err != nil {
+		return "", err
+	}
+
+	return string(listJSON), nil
+}
+
+// NewAccountAddress returns a new native segwit address receiving to the named
+// account.
+func NewAccountAddress(account string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	addr, err := lnd.AccountAddress(account)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return addr.EncodeAddress(), nil
+}
+
+// SendFromAccount pays amount satoshis to the address out of the named
+// account, at the given fee rate in satoshis per byte, and returns the id of
+// the transaction. The default account's outputs are reserved for funding
+// channels, and can't be sent from through this call.
+func SendFromAccount(account, address string, amount,
+	satPerByte int64) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if amount <= 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"amount must be positive"))
+	}
+	if satPerByte <= 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must be positive"))
+	}
+
+	txid, err := lnd.SendFromAccount(
+		account, address, amount, btcutil.Amount(satPerByte*1000),
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return txid, nil
+}
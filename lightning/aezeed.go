@@ -0,0 +1,156 @@
+// +build aezeed
+
+package lightning
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/aezeed"
+	"github.com/roasbeef/btcd/chaincfg"
+	b39 "github.com/tyler-smith/go-bip39"
+)
+
+// aezeedInfo is the JSON result of ValidateAezeed.
+type aezeedInfo struct {
+	Version  uint8 `json:"version"`
+	Birthday int64 `json:"birthday"`
+}
+
+// IsAezeedWord returns true if the word is part of the aezeed word list, so
+// backup phrases can be validated word by word as they're entered.
+func IsAezeedWord(word string) bool {
+	// aezeed shares its word list with BIP39.
+	_, ok := b39.ReverseWordMap[strings.ToLower(strings.TrimSpace(word))]
+	return ok
+}
+
+// parseAezeed splits the space separated mnemonic into its words, checking
+// each of them against the word list.
+func parseAezeed(mnemonic string) (*aezeed.Mnemonic, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) != aezeed.NummnemonicWords {
+		return nil, newError(ErrCodeInvalidMnemonic, fmt.Errorf(
+			"mnemonic must be %d words, got %d",
+			aezeed.NummnemonicWords, len(words)))
+	}
+
+	var m aezeed.Mnemonic
+	for i, word := range words {
+		if !IsAezeedWord(word) {
+			return nil, newError(ErrCodeInvalidMnemonic, fmt.Errorf(
+				"word %d (%q) isn't part of the word list",
+				i+1, word))
+		}
+		m[i] = word
+	}
+
+	return &m, nil
+}
+
+// decipherAezeed decodes the mnemonic using the passphrase.
+func decipherAezeed(mnemonic, passphrase string) (*aezeed.CipherSeed, error) {
+	m, err := parseAezeed(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := m.ToCipherSeed([]byte(passphrase))
+	switch err {
+	case nil:
+		return seed, nil
+
+	case aezeed.ErrInvalidPass:
+		return nil, newError(ErrCodeInvalidPassphrase, err)
+
+	default:
+		return nil, newError(ErrCodeInvalidMnemonic, err)
+	}
+}
+
+// GenerateAezeed creates a new aezeed cipher seed, enciphered with the
+// optional passphrase, and returns its 24 word mnemonic separated by spaces.
+// The daemon doesn't need to be running.
+func GenerateAezeed(passphrase string) (string, error) {
+	seed, err := aezeed.New(aezeed.CipherSeedVersion, nil, time.Now())
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	m, err := seed.ToMnemonic([]byte(passphrase))
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return strings.Join(m[:], " "), nil
+}
+
+// ValidateAezeed checks that the mnemonic deciphers with the passphrase,
+// failing with ErrCodeInvalidMnemonic or ErrCodeInvalidPassphrase otherwise.
+// On success it returns the JSON encoded version of the seed, along with its
+// birthday as a unix timestamp. The daemon doesn't need to be running.
+func ValidateAezeed(mnemonic, passphrase string) (string, error) {
+	seed, err := decipherAezeed(mnemonic, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	// The birthday is stored as the number of days since the genesis
+	// block.
+	genesis := chaincfg.MainNetParams.GenesisBlock.Header.Timestamp
+	birthday := genesis.Add(time.Duration(seed.Birthday) * 24 * time.Hour)
+
+	info, err := json.Marshal(&aezeedInfo{
+		Version:  seed.InternalVersion,
+		Birthday: birthday.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(info), nil
+}
+
+// ChangeAezeedPassphrase re-enciphers the mnemonic under a new passphrase,
+// returning the new mnemonic. The daemon doesn't need to be running.
+func ChangeAezeedPassphrase(mnemonic, oldPassphrase,
+	newPassphrase string) (string, error) {
+
+	if _, err := decipherAezeed(mnemonic, oldPassphrase); err != nil {
+		return "", err
+	}
+
+	m, err := parseAezeed(mnemonic)
+	if err != nil {
+		return "", err
+	}
+
+	newMnemonic, err := m.ChangePass(
+		[]byte(oldPassphrase), []byte(newPassphrase),
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return strings.Join(newMnemonic[:], " "), nil
+}
+
+// StartWithAezeed starts the node like StartWithConfig, creating the wallet
+// from the aezeed mnemonic rather than a BIP39 one. The config may be nil to
+// use lnd.conf within dir.
+func StartWithAezeed(dir, mnemonic, passphrase string, config *Config) error {
+	if config != nil {
+		if err := config.Validate(); err != nil {
+			return newError(ErrCodeInvalidArgument, err)
+		}
+	}
+
+	seed, err := decipherAezeed(mnemonic, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return startWithSeed(dir, seed.Entropy[:], config)
+}
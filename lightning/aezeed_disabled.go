@@ -0,0 +1,38 @@
+// +build !aezeed
+
+package lightning
+
+import "errors"
+
+// errAezeedUnavailable is returned by the aezeed bindings of builds without
+// the aezeed build tag.
+var errAezeedUnavailable = newError(ErrCodeInvalidArgument, errors.New(
+	"this build doesn't support aezeed cipher seeds"))
+
+// IsAezeedWord always returns false, as this build can't decode aezeed
+// mnemonics.
+func IsAezeedWord(word string) bool {
+	return false
+}
+
+// GenerateAezeed always fails, as this build doesn't include aezeed.
+func GenerateAezeed(passphrase string) (string, error) {
+	return "", errAezeedUnavailable
+}
+
+// ValidateAezeed always fails, as this build doesn't include aezeed.
+func ValidateAezeed(mnemonic, passphrase string) (string, error) {
+	return "", errAezeedUnavailable
+}
+
+// ChangeAezeedPassphrase always fails, as this build doesn't include aezeed.
+func ChangeAezeedPassphrase(mnemonic, oldPassphrase,
+	newPassphrase string) (string, error) {
+
+	return "", errAezeedUnavailable
+}
+
+// StartWithAezeed always fails, as this build doesn't include aezeed.
+func StartWithAezeed(dir, mnemonic, passphrase string, config *Config) error {
+	return errAezeedUnavailable
+}
@@ -0,0 +1,154 @@
+// +build aezeed
+
+package lightning
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roasbeef/btcd/chaincfg"
+)
+
+// aezeedVectors are lnd's version 0 aezeed test vectors, enciphering the same
+// entropy with the salt "salt1". lnd's own copies are computed with its test
+// scrypt parameters, so these are recomputed with the version 0 ones.
+var aezeedVectors = []struct {
+	mnemonic   string
+	passphrase string
+	birthday   uint16
+}{
+	{
+		mnemonic: "above judge emerge veteran reform crunch system " +
+			"all snap please shoulder vault hurt city quarter " +
+			"cover enlist swear success suggest drink wagon " +
+			"enrich body",
+		passphrase: "",
+		birthday:   0,
+	},
+	{
+		mnemonic: "absorb century submit father path glove gloom " +
+			"super divert garden ice mirror wisdom grass dice " +
+			"kit ugly castle success suggest drink monster " +
+			"congress flight",
+		passphrase: "!very_safe_55345_password*",
+		birthday:   3365,
+	},
+}
+
+var aezeedVectorEntropy = [16]byte{
+	0x81, 0xb6, 0x37, 0xd8,
+	0x63, 0x59, 0xe6, 0x96,
+	0x0d, 0xe7, 0x95, 0xe4,
+	0x1e, 0x0b, 0x4c, 0xfd,
+}
+
+// assertErrorCode fails the test unless err carries the given code.
+func assertErrorCode(t *testing.T, err error, code int) {
+	t.Helper()
+
+	e, ok := err.(*Error)
+	if !ok || e.Code != code {
+		t.Fatalf("expected error code %d, got %v", code, err)
+	}
+}
+
+// TestAezeedVectors checks that the vectors decipher to their entropy and
+// birthday.
+func TestAezeedVectors(t *testing.T) {
+	for i, v := range aezeedVectors {
+		seed, err := decipherAezeed(v.mnemonic, v.passphrase)
+		if err != nil {
+			t.Fatalf("vector %d: unable to decipher: %v", i, err)
+		}
+
+		if seed.InternalVersion != 0 {
+			t.Fatalf("vector %d: expected version 0, got %d", i,
+				seed.InternalVersion)
+		}
+		if seed.Birthday != v.birthday {
+			t.Fatalf("vector %d: expected birthday %d, got %d", i,
+				v.birthday, seed.Birthday)
+		}
+		if seed.Entropy != aezeedVectorEntropy {
+			t.Fatalf("vector %d: expected entropy %x, got %x", i,
+				aezeedVectorEntropy, seed.Entropy)
+		}
+	}
+}
+
+// TestValidateAezeed checks the JSON ValidateAezeed returns and the errors
+// it fails with.
+func TestValidateAezeed(t *testing.T) {
+	v := aezeedVectors[1]
+
+	info, err := ValidateAezeed(v.mnemonic, v.passphrase)
+	if err != nil {
+		t.Fatalf("unable to validate: %v", err)
+	}
+
+	var decoded aezeedInfo
+	if err := json.Unmarshal([]byte(info), &decoded); err != nil {
+		t.Fatalf("unable to decode %v: %v", info, err)
+	}
+
+	genesis := chaincfg.MainNetParams.GenesisBlock.Header.Timestamp
+	birthday := genesis.Add(time.Duration(v.birthday) * 24 * time.Hour)
+	if decoded.Version != 0 || decoded.Birthday != birthday.Unix() {
+		t.Fatalf("unexpected info %v", info)
+	}
+
+	_, err = ValidateAezeed(v.mnemonic, "wrong")
+	assertErrorCode(t, err, ErrCodeInvalidPassphrase)
+
+	words := strings.Fields(v.mnemonic)
+	_, err = ValidateAezeed(strings.Join(words[1:], " "), v.passphrase)
+	assertErrorCode(t, err, ErrCodeInvalidMnemonic)
+
+	words[0] = "notaword"
+	_, err = ValidateAezeed(strings.Join(words, " "), v.passphrase)
+	assertErrorCode(t, err, ErrCodeInvalidMnemonic)
+}
+
+// TestChangeAezeedPassphrase checks that a mnemonic re-enciphered under a new
+// passphrase keeps its entropy and birthday.
+func TestChangeAezeedPassphrase(t *testing.T) {
+	v := aezeedVectors[0]
+
+	mnemonic, err := ChangeAezeedPassphrase(v.mnemonic, v.passphrase,
+		"new passphrase")
+	if err != nil {
+		t.Fatalf("unable to change passphrase: %v", err)
+	}
+
+	seed, err := decipherAezeed(mnemonic, "new passphrase")
+	if err != nil {
+		t.Fatalf("unable to decipher: %v", err)
+	}
+	if seed.Birthday != v.birthday || seed.Entropy != aezeedVectorEntropy {
+		t.Fatalf("seed changed along with the passphrase")
+	}
+
+	_, err = decipherAezeed(mnemonic, v.passphrase)
+	assertErrorCode(t, err, ErrCodeInvalidPassphrase)
+}
+
+// TestGenerateAezeed checks that generated mnemonics decipher with their
+// passphrase and carry today's birthday.
+func TestGenerateAezeed(t *testing.T) {
+	mnemonic, err := GenerateAezeed("passphrase")
+	if err != nil {
+		t.Fatalf("unable to generate: %v", err)
+	}
+
+	for _, word := range strings.Fields(mnemonic) {
+		if !IsAezeedWord(word) {
+			t.Fatalf("%q isn't part of the word list", word)
+		}
+	}
+
+	if _, err := ValidateAezeed(mnemonic, "passphrase"); err != nil {
+		t.Fatalf("unable to validate generated mnemonic: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/roasbeef/btcwallet/waddrmgr"
+	b39 "github.com/tyler-smith/go-bip39"
+)
+
+// bip39ImportReport is the JSON result of CheckBIP39Import, describing what
+// the wallet will recover from a BIP39 mnemonic created by another wallet.
+type bip39ImportReport struct {
+	// DerivationPath is the account path the funds were derived under by
+	// the other wallet, if one was given.
+	DerivationPath string `json:"derivation_path,omitempty"`
+
+	// Compatible is true if the funds of the derivation path can be
+	// recovered.
+	Compatible bool `json:"compatible"`
+
+	// ScannedPaths are the account paths the wallet derives addresses
+	// under.
+	ScannedPaths []string `json:"scanned_paths"`
+
+	// Unsupported lists why the derivation path can't be recovered.
+	Unsupported []string `json:"unsupported,omitempty"`
+
+	// Warnings lists what may not be recovered, even though the
+	// derivation path is supported.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// accountPath formats the path of the default account of the key scope.
+func accountPath(scope waddrmgr.KeyScope) string {
+	return fmt.Sprintf("m/%d'/%d'/0'", scope.Purpose, scope.Coin)
+}
+
+// parseDerivationPath parses a BIP32 path such as m/84'/0'/0', returning its
+// indexes along with whether each of them is hardened.
+func parseDerivationPath(path string) ([]uint32, []bool, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) < 2 || parts[0] != "m" {
+		return nil, nil, fmt.Errorf("derivation path %q must be of the "+
+			"form m/purpose'/coin'/account'", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	hardened := make([]bool, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		h := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if h {
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 31)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid index %q in "+
+				"derivation path %q", part, path)
+		}
+
+		indexes = append(indexes, uint32(index))
+		hardened = append(hardened, h)
+	}
+
+	return indexes, hardened, nil
+}
+
+// checkBIP39Import validates the mnemonic, and reports whether the funds the
+// other wallet derived under derivationPath can be recovered. The path may be
+// empty if it isn't known.
+func checkBIP39Import(mnemonic,
+	derivationPath string) (*bip39ImportReport, error) {
+
+	if !b39.IsMnemonicValid(mnemonic) {
+		return nil, newError(ErrCodeInvalidMnemonic, errors.New(
+			"invalid BIP39 mnemonic"))
+	}
+	if _, err := b39.MnemonicToByteArray(mnemonic); err != nil {
+		return nil, newError(ErrCodeInvalidMnemonic, err)
+	}
+
+	report := &bip39ImportReport{
+		DerivationPath: derivationPath,
+		Compatible:     true,
+		Warnings: []string{
+			"addresses are only watched once the wallet derives " +
+				"them, so past transactions may not show up " +
+				"until the wallet is recovered",
+		},
+	}
+	for _, scope := range waddrmgr.DefaultKeyScopes {
+		report.ScannedPaths = append(
+			report.ScannedPaths, accountPath(scope),
+		)
+	}
+
+	if derivationPath == "" {
+		return report, nil
+	}
+
+	indexes, hardened, err := parseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, err)
+	}
+
+	unsupported := func(format string, a ...interface{}) {
+		report.Compatible = false
+		report.Unsupported = append(
+			report.Unsupported, fmt.Sprintf(format, a...),
+		)
+	}
+
+	if len(indexes) != 3 {
+		unsupported("only account level paths of the form " +
+			"m/purpose'/coin'/account' are supported")
+		return report, nil
+	}
+	for i, h := range hardened {
+		if !h {
+			unsupported("index %d of the path must be hardened",
+				i+1)
+		}
+	}
+
+	purpose, coin, account := indexes[0], indexes[1], indexes[2]
+
+	var scope *waddrmgr.KeyScope
+	for i := range waddrmgr.DefaultKeyScopes {
+		if waddrmgr.DefaultKeyScopes[i].Purpose == purpose {
+			scope = &waddrmgr.DefaultKeyScopes[i]
+			break
+		}
+	}
+
+	switch {
+	case scope == nil:
+		unsupported("purpose %d' isn't derived by the wallet", purpose)
+
+	case coin != scope.Coin:
+		unsupported("coin type %d' isn't derived by the wallet, "+
+			"which uses coin type %d' on every network", coin,
+			scope.Coin)
+	}
+
+	if account != 0 {
+		unsupported("only the default account 0' is derived by the " +
+			"wallet")
+	}
+
+	if purpose == waddrmgr.KeyScopeBIP0049Plus.Purpose {
+		report.Warnings = append(report.Warnings, "the wallet uses "+
+			"native segwit rather than nested segwit change "+
+			"addresses under purpose 49', so change sent to "+
+			"nested segwit addresses won't be recovered")
+	}
+
+	return report, nil
+}
+
+// CheckBIP39Import reports whether a BIP39 mnemonic created by another wallet
+// can be imported, without requiring the daemon to be running. The derivation
+// path, such as m/84'/0'/0', is the account the other wallet derived its
+// addresses under, and may be empty if it isn't known. The JSON encoded report
+// lists the paths scanned by the wallet, along with anything that can't be
+// recovered.
+func CheckBIP39Import(mnemonic, derivationPath string) (string, error) {
+	report, err := checkBIP39Import(mnemonic, derivationPath)
+	if err != nil {
+		return "", err
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+
+	return string(reportJSON), nil
+}
+
+// StartWithBIP39 starts the node like StartWithConfig, creating the wallet
+// from a BIP39 mnemonic protected by the optional passphrase, as produced by
+// other wallets. The node isn't started if the funds of the derivation path
+// can't be recovered, see CheckBIP39Import. The config may be nil to use
+// lnd.conf within dir. An existing wallet is opened as is, ignoring the
+// mnemonic.
+func StartWithBIP39(dir, mnemonic, passphrase, derivationPath string,
+	config *Config) error {
+
+	if config != nil {
+		if err := config.Validate(); err != nil {
+			return newError(ErrCodeInvalidArgument, err)
+		}
+	}
+
+	report, err := checkBIP39Import(mnemonic, derivationPath)
+	if err != nil {
+		return err
+	}
+	if !report.Compatible {
+		return newError(ErrCodeInvalidArgument, fmt.Errorf("unable to "+
+			"import %v: %v", derivationPath,
+			strings.Join(report.Unsupported, ", ")))
+	}
+
+	seed := b39.NewSeed(mnemonic, passphrase)
+
+	return startWithSeed(dir, seed, config)
+}
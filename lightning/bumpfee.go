@@ -0,0 +1,74 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// EstimateBumpFee returns, JSON encoded, the fee BumpFee would pay to speed up
+// the unconfirmed transaction holding the output at outpoint, given as
+// txid:index, along with the fee rate the transaction and its child would pay
+// together. Nothing is published.
+func EstimateBumpFee(outpoint string, satPerByte int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if satPerByte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	op, err := parseOutPoint(outpoint)
+	if err != nil {
+		return "", err
+	}
+
+	estimate, err := lnd.EstimateBumpFee(*op, satPerByte)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	estimateJSON, err := json.Marshal(estimate)
+	if err != nil {
+		return "", err
+	}
+
+	return string(estimateJSON), nil
+}
+
+// BumpFee speeds up the unconfirmed transaction holding the output at
+// outpoint, given as txid:index, by spending the output back to the wallet in
+// a child transaction paying for both (CPFP). The fee is chosen so the two pay
+// satPerByte satoshis per vbyte together, or a rate estimated to confirm within
+// six blocks if it is zero. The output may be an incoming payment or change.
+// The published child is returned JSON encoded, like EstimateBumpFee.
+func BumpFee(outpoint string, satPerByte int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if satPerByte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	op, err := parseOutPoint(outpoint)
+	if err != nil {
+		return "", err
+	}
+
+	bump, err := lnd.BumpFee(*op, satPerByte)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	bumpJSON, err := json.Marshal(bump)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bumpJSON), nil
+}
@@ -0,0 +1,54 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// ChannelAcceptor is implemented by the app to decide on inbound channels,
+// for example by size, peer, whether the channel is private, or a list of
+// trusted LSPs.
+type ChannelAcceptor interface {
+	// AcceptChannel returns whether the channel described by the JSON
+	// encoded request is accepted. The request holds the peer's
+	// node_pubkey, the funding_amt and push_amt_msat, whether the
+	// channel is private, and the constraints the peer asks for.
+	AcceptChannel(request string) bool
+}
+
+// SetChannelAcceptor has the acceptor decide on every inbound channel. A
+// channel the acceptor doesn't decide on within timeoutSeconds, or 15 seconds
+// if it is zero, is rejected. Other channel openings wait while the acceptor
+// decides, so it should answer quickly. Passing nil accepts all inbound
+// channels again.
+func SetChannelAcceptor(acceptor ChannelAcceptor, timeoutSeconds int32) error {
+	if timeoutSeconds < 0 {
+		return newError(ErrCodeInvalidArgument, errors.New(
+			"timeout must not be negative"))
+	}
+	timeout := time.Duration(timeoutSeconds) * time.Second
+
+	if acceptor == nil {
+		lnd.SetChannelAcceptor(nil, timeout)
+		return nil
+	}
+
+	lnd.SetChannelAcceptor(func(req *lnd.ChannelRequest) bool {
+		reqJSON, err := json.Marshal(req)
+		if err != nil {
+			return false
+		}
+
+		var accept bool
+		dispatch(func() {
+			accept = acceptor.AcceptChannel(string(reqJSON))
+		})
+
+		return accept
+	}, timeout)
+
+	return nil
+}
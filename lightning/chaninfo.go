@@ -0,0 +1,132 @@
+package lightning
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// Channels are identified by their short channel ID in forwarding events and
+// HTLC failures, a 64 bit integer encoding the block height, transaction index
+// and output index of the funding output, and by their channel point,
+// txid:index, elsewhere. The bindings below convert between the two, and look
+// up single channels in the graph. The lnd here predates SCID aliases, so the
+// only short channel ID of a channel is the one of its funding output.
+
+// GetChanInfo returns the JSON encoded announcement of the channel with the
+// short channel ID, along with the policies of both its nodes.
+func GetChanInfo(chanID int64) (string, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	req := &lnrpc.ChanInfoRequest{ChanId: uint64(chanID)}
+	resp, err := server.GetChanInfo(nil, req)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// GetChanInfoByChanPoint returns the JSON encoded announcement of the channel
+// at the channel point, in the format txid:index, like GetChanInfo.
+func GetChanInfoByChanPoint(chanPoint string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	outPoint, err := parseOutPoint(chanPoint)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := lnd.ChanInfoByChanPoint(*outPoint)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// ChanPointFromChanID returns the channel point, in the format txid:index, of
+// the channel with the short channel ID. Private channels and channels of the
+// node that have since closed are found too.
+func ChanPointFromChanID(chanID int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	outPoint, err := lnd.ChanPointFromChanID(uint64(chanID))
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return outPoint.String(), nil
+}
+
+// ChanIDFromChanPoint returns the short channel ID of the channel at the
+// channel point, in the format txid:index. Pending channels have none yet.
+func ChanIDFromChanPoint(chanPoint string) (int64, error) {
+	if err := checkRunning(); err != nil {
+		return 0, err
+	}
+
+	outPoint, err := parseOutPoint(chanPoint)
+	if err != nil {
+		return 0, err
+	}
+
+	chanID, err := lnd.ChanIDFromChanPoint(*outPoint)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return int64(chanID), nil
+}
+
+// FormatChanID returns the short channel ID in its human readable form,
+// height:tx_index:output_index, which doesn't need the node to be running.
+func FormatChanID(chanID int64) string {
+	return lnwire.NewShortChanIDFromInt(uint64(chanID)).String()
+}
+
+// ParseChanID parses a short channel ID in the form returned by FormatChanID,
+// also accepting x as the separator, as block explorers use.
+func ParseChanID(scid string) (int64, error) {
+	parts := strings.FieldsFunc(scid, func(r rune) bool {
+		return r == ':' || r == 'x'
+	})
+	if len(parts) != 3 {
+		return 0, newError(ErrCodeInvalidArgument, errors.New(
+			"short channel id expected in format: "+
+				"height:tx_index:output_index"))
+	}
+
+	// The height takes three bytes of the short channel ID, and the
+	// transaction and output indexes three and two.
+	bitSizes := []int{24, 24, 16}
+	var fields [3]uint64
+	for i, part := range parts {
+		field, err := strconv.ParseUint(part, 10, bitSizes[i])
+		if err != nil {
+			return 0, newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"invalid short channel id %v: %v", scid, err))
+		}
+		fields[i] = field
+	}
+
+	shortChanID := lnwire.ShortChannelID{
+		BlockHeight: uint32(fields[0]),
+		TxIndex:     uint32(fields[1]),
+		TxPosition:  uint16(fields[2]),
+	}
+
+	return int64(shortChanID.ToUint64()), nil
+}
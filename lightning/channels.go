@@ -0,0 +1,242 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// Channel is an open channel, handed to the app as a bound struct rather than
+// JSON so it can be read without decoding it again on the other side of the
+// bridge. gomobile doesn't support unsigned integers, so those fields are
+// converted to their signed counterparts.
+type Channel struct {
+	Active                bool
+	Private               bool
+	RemotePubkey          string
+	ChannelPoint          string
+	ChanID                int64
+	Capacity              int64
+	LocalBalance          int64
+	RemoteBalance         int64
+	CommitFee             int64
+	CommitWeight          int64
+	FeePerKw              int64
+	UnsettledBalance      int64
+	TotalSatoshisSent     int64
+	TotalSatoshisReceived int64
+	NumUpdates            int64
+	NumPendingHtlcs       int
+	CsvDelay              int32
+}
+
+// newChannel converts the rpc channel into its bound form.
+func newChannel(c *lnrpc.Channel) *Channel {
+	return &Channel{
+		Active:                c.Active,
+		Private:               c.Private,
+		RemotePubkey:          c.RemotePubkey,
+		ChannelPoint:          c.ChannelPoint,
+		ChanID:                int64(c.ChanId),
+		Capacity:              c.Capacity,
+		LocalBalance:          c.LocalBalance,
+		RemoteBalance:         c.RemoteBalance,
+		CommitFee:             c.CommitFee,
+		CommitWeight:          c.CommitWeight,
+		FeePerKw:              c.FeePerKw,
+		UnsettledBalance:      c.UnsettledBalance,
+		TotalSatoshisSent:     c.TotalSatoshisSent,
+		TotalSatoshisReceived: c.TotalSatoshisReceived,
+		NumUpdates:            int64(c.NumUpdates),
+		NumPendingHtlcs:       len(c.PendingHtlcs),
+		CsvDelay:              int32(c.CsvDelay),
+	}
+}
+
+// ChannelList is a list of channels. As gomobile can't bind slices of
+// structs, channels are accessed by index.
+type ChannelList struct {
+	// NextCursor is the cursor of the next page of a filtered listing,
+	// or empty on the last page.
+	NextCursor string
+
+	channels []*Channel
+}
+
+// Len returns the number of channels in the list.
+func (l *ChannelList) Len() int {
+	return len(l.channels)
+}
+
+// Get returns the channel at index i, or nil if i is out of range.
+func (l *ChannelList) Get(i int) *Channel {
+	if i < 0 || i >= len(l.channels) {
+		return nil
+	}
+
+	return l.channels[i]
+}
+
+// ListChannelsDecoded returns the open channels like ListChannels, but as
+// bound structs instead of JSON, sparing the app from decoding the response on
+// hot paths.
+func ListChannelsDecoded() (*ChannelList, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &lnrpc.ListChannelsRequest{}
+	resp, err := server.ListChannels(nil, req)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	list := &ChannelList{
+		channels: make([]*Channel, 0, len(resp.Channels)),
+	}
+	for _, c := range resp.Channels {
+		list.channels = append(list.channels, newChannel(c))
+	}
+
+	return list, nil
+}
+
+// ChannelFilter selects the channels listed by ListChannelsFiltered and
+// ListChannelsDecodedFiltered, and pages through them. Calls taking a nil
+// ChannelFilter list all open channels.
+type ChannelFilter struct {
+	// ActiveOnly and InactiveOnly limit the channels to those that can,
+	// or can't, currently forward payments. At most one may be set.
+	ActiveOnly   bool
+	InactiveOnly bool
+
+	// PublicOnly and PrivateOnly limit the channels to announced, or
+	// unannounced, ones. At most one may be set.
+	PublicOnly  bool
+	PrivateOnly bool
+
+	// PeerPubkey limits the channels to those with the hex encoded node,
+	// if it isn't empty.
+	PeerPubkey string
+
+	// Cursor is the next cursor returned with the previous page, or empty
+	// for the first page.
+	Cursor string
+
+	// MaxChannels is the size of a page, or zero for no paging.
+	MaxChannels int
+}
+
+// NewChannelFilter returns a ChannelFilter listing all open channels at once.
+func NewChannelFilter() *ChannelFilter {
+	return &ChannelFilter{}
+}
+
+// listChannelsPage returns the page of channels selected by the filter, and
+// the cursor of the next page.
+func listChannelsPage(filter *ChannelFilter) (*lnrpc.ListChannelsResponse,
+	string, error) {
+
+	if filter == nil {
+		filter = NewChannelFilter()
+	}
+
+	switch {
+	case filter.ActiveOnly && filter.InactiveOnly:
+		return nil, "", newError(ErrCodeInvalidArgument, errors.New(
+			"either active only or inactive only can be set"))
+	case filter.PublicOnly && filter.PrivateOnly:
+		return nil, "", newError(ErrCodeInvalidArgument, errors.New(
+			"either public only or private only can be set"))
+	case filter.MaxChannels < 0:
+		return nil, "", newError(ErrCodeInvalidArgument, errors.New(
+			"max channels must not be negative"))
+	}
+
+	query := &lnd.ChannelQuery{
+		ActiveOnly:   filter.ActiveOnly,
+		InactiveOnly: filter.InactiveOnly,
+		PublicOnly:   filter.PublicOnly,
+		PrivateOnly:  filter.PrivateOnly,
+		Cursor:       filter.Cursor,
+		MaxChannels:  filter.MaxChannels,
+	}
+	if filter.PeerPubkey != "" {
+		var err error
+		query.Peer, err = parsePubKey(filter.PeerPubkey)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	resp, nextCursor, err := lnd.ListChannelsPage(query)
+	if err != nil {
+		return nil, "", wrapError(err)
+	}
+
+	return resp, nextCursor, nil
+}
+
+// ListChannelsFiltered returns the open channels selected by the filter as
+// JSON, like ListChannels, with the cursor of the next page under
+// "next_cursor", which is empty on the last page. Pages are in the order of
+// the channel points, so the app only decodes the channels it shows.
+func ListChannelsFiltered(filter *ChannelFilter) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	resp, nextCursor, err := listChannelsPage(filter)
+	if err != nil {
+		return "", err
+	}
+
+	respJSON, err := convertToJSON(resp)
+	if err != nil {
+		return "", err
+	}
+
+	// The channels keep the encoding of ListChannels, with the cursor
+	// added next to them.
+	var page struct {
+		Channels   json.RawMessage `json:"channels"`
+		NextCursor string          `json:"next_cursor"`
+	}
+	if err := json.Unmarshal([]byte(respJSON), &page); err != nil {
+		return "", err
+	}
+	page.NextCursor = nextCursor
+
+	pageJSON, err := json.Marshal(&page)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pageJSON), nil
+}
+
+// ListChannelsDecodedFiltered returns the open channels selected by the
+// filter as bound structs, with the cursor of the next page in NextCursor.
+func ListChannelsDecodedFiltered(filter *ChannelFilter) (*ChannelList, error) {
+	if err := checkRunning(); err != nil {
+		return nil, err
+	}
+
+	resp, nextCursor, err := listChannelsPage(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ChannelList{
+		NextCursor: nextCursor,
+		channels:   make([]*Channel, 0, len(resp.Channels)),
+	}
+	for _, c := range resp.Channels {
+		list.channels = append(list.channels, newChannel(c))
+	}
+
+	return list, nil
+}
@@ -0,0 +1,53 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/wire"
+)
+
+// SetCloseAddress sets the address the local balance of the channel at
+// channelPoint, in the format txid:index, is paid to when the channel is
+// closed cooperatively, such as an address of a cold wallet. With an empty
+// channelPoint, it sets the address of all channels without one of their
+// own. An empty address clears it. The addresses are kept in the wallet
+// database, and apply to closes initiated by either side. Force closes still
+// pay to the wallet.
+func SetCloseAddress(channelPoint, address string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	var chanPoint *wire.OutPoint
+	if channelPoint != "" {
+		var err error
+		chanPoint, err = parseOutPoint(channelPoint)
+		if err != nil {
+			return err
+		}
+	}
+
+	return wrapError(lnd.SetCloseAddress(chanPoint, address))
+}
+
+// ListCloseAddresses returns the JSON encoded close addresses set through
+// SetCloseAddress, keyed by channel point, with the address of all other
+// channels under "default".
+func ListCloseAddresses() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	addrs, err := lnd.CloseAddresses()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	addrsJSON, err := json.Marshal(addrs)
+	if err != nil {
+		return "", err
+	}
+
+	return string(addrsJSON), nil
+}
@@ -0,0 +1,307 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcd/wire"
+	"golang.org/x/net/context"
+)
+
+// The coin selection strategies, deciding which outputs of the wallet fund
+// channels and on-chain payments.
+const (
+	// CoinSelectLargest spends the largest outputs first, keeping fees
+	// low. It is the default.
+	CoinSelectLargest = lnd.CoinSelectLargest
+
+	// CoinSelectRandom spends outputs in random order.
+	CoinSelectRandom = lnd.CoinSelectRandom
+
+	// CoinSelectOldest spends the outputs with the most confirmations
+	// first.
+	CoinSelectOldest = lnd.CoinSelectOldest
+
+	// CoinSelectPrivacy spends all outputs received on the same address
+	// together, so a reused address doesn't link two transactions.
+	CoinSelectPrivacy = lnd.CoinSelectPrivacy
+)
+
+// SetCoinSelection changes the coin selection strategy used by calls that
+// don't pass one of their own.
+func SetCoinSelection(strategy string) error {
+	return wrapError(lnd.SetCoinSelection(strategy))
+}
+
+// utxoList is the JSON result of ListUnspent.
+type utxoList struct {
+	Utxos []*lnd.Utxo `json:"utxos"`
+}
+
+// ListUnspent returns the JSON encoded unspent outputs of all accounts with
+// between minConfs and maxConfs confirmations, so the app can offer manual coin
+// control.
+func ListUnspent(minConfs, maxConfs int32) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if minConfs < 0 || maxConfs < minConfs {
+		return "", newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"invalid confirmation range %d-%d", minConfs, maxConfs))
+	}
+
+	utxos, err := lnd.ListUnspent(minConfs, maxConfs)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	listJSON, err := json.Marshal(&utxoList{Utxos: utxos})
+	if err != nil {
+		return "", err
+	}
+
+	return string(listJSON), nil
+}
+
+// parseOutPoint parses an outpoint in the format txid:index.
+func parseOutPoint(outpoint string) (*wire.OutPoint, error) {
+	splitPoint := strings.Split(outpoint, ":")
+	if len(splitPoint) != 2 {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"outpoint expected in format: txid:output_index"))
+	}
+
+	hash, err := chainhash.NewHashFromStr(splitPoint[0])
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode txid: %v", err))
+	}
+
+	index, err := strconv.ParseUint(splitPoint[1], 10, 32)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode output index: %v", err))
+	}
+
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// CoinControl picks the coins spent by a send or channel funding, and where
+// its change goes. Calls taking a nil CoinControl use the defaults.
+type CoinControl struct {
+	// Strategy is one of the CoinSelect constants, or empty for the
+	// strategy set through SetCoinSelection.
+	Strategy string
+
+	// Outpoints is a comma separated list of txid:index. If it isn't
+	// empty, only those outputs are spent.
+	Outpoints string
+
+	// ChangeAccount is the name of the account change is paid to, such as
+	// one controlled from cold storage, or empty for the default account.
+	ChangeAccount string
+
+	// ChangeAddressType is the type of the change address, 0 for native
+	// segwit or 1 for nested segwit, as with NewAddress. Accounts other
+	// than the default one only hold native segwit addresses.
+	ChangeAddressType int32
+}
+
+// NewCoinControl returns a CoinControl with the defaults.
+func NewCoinControl() *CoinControl {
+	return &CoinControl{}
+}
+
+// parseCoinControl converts the coin control, which may be nil for the
+// defaults, to its lnd counterpart.
+func parseCoinControl(control *CoinControl) (*lnd.CoinControl, error) {
+	if control == nil {
+		return nil, nil
+	}
+
+	c := &lnd.CoinControl{
+		Strategy:      control.Strategy,
+		ChangeAccount: control.ChangeAccount,
+		ChangeType:    lnwallet.AddressType(control.ChangeAddressType),
+	}
+	for _, item := range splitList(control.Outpoints) {
+		op, err := parseOutPoint(item)
+		if err != nil {
+			return nil, err
+		}
+		c.OutPoints = append(c.OutPoints, *op)
+	}
+
+	return c, nil
+}
+
+// coinControlContext returns a context passing the coin control, which may be
+// nil for the defaults, to the rpc server along with a call.
+func coinControlContext(parent context.Context,
+	control *CoinControl) (context.Context, error) {
+
+	c, err := parseCoinControl(control)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := lnd.WithCoinControl(parent, c)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return ctx, nil
+}
+
+// SendCoins pays amount satoshis to the address, at the given fee rate in
+// satoshis per byte, or at a rate estimated to confirm within six blocks if it
+// is zero. The coins spent and the change are as chosen by the coin control,
+// which may be nil for the defaults. The response holds the id of the
+// transaction.
+func SendCoins(address string, amount, satPerByte int64,
+	control *CoinControl) (string, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	if amount <= 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"amount must be positive"))
+	}
+	if satPerByte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	ctx, err := coinControlContext(context.Background(), control)
+	if err != nil {
+		return "", err
+	}
+
+	req := &lnrpc.SendCoinsRequest{
+		Addr:       address,
+		Amount:     amount,
+		SatPerByte: satPerByte,
+	}
+	resp, err := server.SendCoins(ctx, req)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// parsePayments parses a comma separated list of address=amount pairs, with
+// the amounts in satoshis.
+func parsePayments(payments string) (map[string]int64, error) {
+	addrToAmount := make(map[string]int64)
+	for _, item := range splitList(payments) {
+		pair := strings.Split(item, "=")
+		if len(pair) != 2 {
+			return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"payment expected in format: address=amount"))
+		}
+
+		addr := strings.TrimSpace(pair[0])
+		amount, err := strconv.ParseInt(strings.TrimSpace(pair[1]), 10, 64)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"unable to decode amount: %v", err))
+		}
+		if amount <= 0 {
+			return nil, newError(ErrCodeInvalidArgument, errors.New(
+				"amount must be positive"))
+		}
+		if _, ok := addrToAmount[addr]; ok {
+			return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"address %v is paid more than once", addr))
+		}
+
+		addrToAmount[addr] = amount
+	}
+
+	if len(addrToAmount) == 0 {
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"no payments given"))
+	}
+
+	return addrToAmount, nil
+}
+
+// SendMany pays several addresses in a single transaction, saving the fees of
+// sending to each separately. The payments are a comma separated list of
+// address=amount pairs, with the amounts in satoshis. The fee rate, coins and
+// change are chosen as by SendCoins. The response holds the id of the
+// transaction.
+func SendMany(payments string, satPerByte int64,
+	control *CoinControl) (string, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	addrToAmount, err := parsePayments(payments)
+	if err != nil {
+		return "", err
+	}
+	if satPerByte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	ctx, err := coinControlContext(context.Background(), control)
+	if err != nil {
+		return "", err
+	}
+
+	req := &lnrpc.SendManyRequest{
+		AddrToAmount: addrToAmount,
+		SatPerByte:   satPerByte,
+	}
+	resp, err := server.SendMany(ctx, req)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// SendAll pays every confirmed output of the wallet's default account to the
+// address, taking the fee from the amount sent, so the wallet is emptied
+// without having to work out the spendable maximum. The fee rate is feeRate
+// satoshis per vbyte, or a rate estimated to confirm within six blocks if it
+// is zero. The transaction is returned JSON encoded, along with the amount
+// sent and the fee paid.
+func SendAll(address string, feeRate int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if feeRate < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	sweep, err := lnd.SendAll(address, feeRate)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	sweepJSON, err := json.Marshal(sweep)
+	if err != nil {
+		return "", err
+	}
+
+	return string(sweepJSON), nil
+}
@@ -0,0 +1,458 @@
+package lightning
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// colorRegexp matches a node color in the hex format #RRGGBB.
+var colorRegexp = regexp.MustCompile("^#[A-Fa-f0-9]{6}$")
+
+// maxNonWumboChanSize is the largest channel, in satoshis, without wumbo
+// channels, lnd's soft-limit.
+const maxNonWumboChanSize = 1 << 24
+
+// validLogLevels are the log levels accepted by DebugLevel.
+var validLogLevels = map[string]struct{}{
+	"trace":    {},
+	"debug":    {},
+	"info":     {},
+	"warn":     {},
+	"error":    {},
+	"critical": {},
+}
+
+// Config holds the options the node is started with. Options left at their
+// zero value fall back to lnd.conf within the data directory, and then to
+// lnd's defaults. As gomobile can only bind basic field types, list options
+// are passed as comma separated strings.
+type Config struct {
+	// Network is the bitcoin network to run on, one of testnet, simnet or
+	// regtest.
+	Network string
+
+	// AddPeers is a comma separated list of host:port neutrino peers to
+	// connect to in addition to those found through DNS seeds.
+	AddPeers string
+
+	// ConnectPeers is a comma separated list of host:port neutrino peers
+	// to connect to exclusively.
+	ConnectPeers string
+
+	// DebugLevel is the log level for all subsystems, or a comma separated
+	// list of <subsystem>=<level> pairs.
+	DebugLevel string
+
+	// Alias is the node alias announced to the network.
+	Alias string
+
+	// Color is the node color announced to the network, as #RRGGBB.
+	Color string
+
+	// NoNetBootstrap disables bootstrapping peers from the network.
+	NoNetBootstrap bool
+
+	// MaxPendingChannels is the maximum number of incoming pending
+	// channels permitted per peer.
+	MaxPendingChannels int
+
+	// AutopilotActive enables the autopilot agent.
+	AutopilotActive bool
+
+	// NoMacaroons skips creating and unlocking the macaroon service. The
+	// bindings talk to the daemon in-process without authentication, so
+	// macaroons are only needed by external gRPC clients. The macaroon
+	// files left on disk are kept for a later run with macaroons enabled,
+	// so remote apps paired with them keep on working then.
+	NoMacaroons bool
+
+	// RemoveMacaroons deletes the macaroon files and database left on disk
+	// by earlier runs, for apps that never want credentials stored on the
+	// device. It requires NoMacaroons. Remote apps paired with the deleted
+	// macaroons have to be paired anew once macaroons are enabled again.
+	RemoveMacaroons bool
+
+	// RPCListen is a comma separated list of host:port interfaces to serve
+	// gRPC over TLS on, for remote apps paired through an lndconnect URI.
+	// There are none by default, and the listeners are only brought up
+	// while macaroons are enabled.
+	RPCListen string
+
+	// ConsolidateOutputs enables consolidating the smallest outputs of the
+	// wallet into a single one while fees are low, so they don't turn
+	// into dust that costs more to spend than it's worth once fees rise.
+	ConsolidateOutputs bool
+
+	// ConsolidationMaxFeeRate is the highest estimated fee rate, in
+	// satoshis per vbyte, at which outputs are consolidated.
+	ConsolidationMaxFeeRate int64
+
+	// ConsolidationTargetCount is the number of outputs consolidation
+	// leaves the wallet with.
+	ConsolidationTargetCount int
+
+	// FeeEstimatorURL is an HTTPS URL fee estimates are fetched from, as
+	// neutrino can't estimate fees itself, such as
+	// https://mempool.space/api/v1/fees/recommended. Both the
+	// mempool.space recommended fees format and the Esplora fee estimates
+	// format are understood. If empty, a static fee rate is used. Only
+	// mempool.space and blockstream.info are allowed, unless
+	// FeeEstimatorPubKey is set.
+	FeeEstimatorURL string
+
+	// FeeEstimatorPubKey is the hex encoded public key the fee estimates
+	// must be signed by, through a DER signature over the SHA-256 of the
+	// response in its X-Signature header. Setting it allows fetching them
+	// from any host.
+	FeeEstimatorPubKey string
+
+	// FallbackFeeRate is the fee rate, in satoshis per vbyte, used while
+	// no fee estimates could be fetched, or zero for the default of 50.
+	FallbackFeeRate int64
+
+	// WalletDustLimit is the smallest output, in satoshis, the wallet
+	// creates, or zero for lnd's default of 573. Smaller payments are
+	// rejected with an InvalidArgument error, and smaller change is left
+	// to the miners. Lowering it tolerates smaller outputs, down to the
+	// dust threshold of the network's relay policy.
+	WalletDustLimit int64
+
+	// ChanReserveRatio is the fraction of a channel's capacity the remote
+	// peer is required to keep as reserve, at most 0.2, or zero for the
+	// default of 0.01.
+	ChanReserveRatio float64
+
+	// MinChanReserve is the smallest reserve, in satoshis, the remote peer
+	// is required to keep, no matter how small the channel, or zero for
+	// the default of 573.
+	MinChanReserve int64
+
+	// WumboChannels signals support for channels above the protocol's
+	// limit of 16777216 satoshis, which are then opened and accepted with
+	// peers that signal it too.
+	WumboChannels bool
+
+	// MaxChanSize is the largest channel, in satoshis, opened or
+	// accepted, or zero for the default of 16777216, or 10 BTC with wumbo
+	// channels. It can only exceed 16777216 with WumboChannels set.
+	MaxChanSize int64
+
+	// UnsafeAbandonChannel enables AbandonChannel, which deletes pending
+	// channels whose funding flow failed. It is meant for developers
+	// recovering wedged channels, as abandoning a channel whose funding
+	// transaction confirms loses its funds.
+	UnsafeAbandonChannel bool
+
+	// InvoiceRetentionDays is the number of days after which invoices
+	// that expired unpaid are deleted, so years of them don't bloat the
+	// channel database, or zero to keep them. They're pruned once the
+	// node starts and daily while it runs.
+	InvoiceRetentionDays int
+
+	// PruneSettledInvoices deletes settled invoices too, once
+	// InvoiceRetentionDays passed since they were settled.
+	PruneSettledInvoices bool
+
+	// CompactGraph keeps the routing graph small for mobile devices. The
+	// channel database is compacted on startup once a quarter of it is
+	// free space, as bolt keeps the space of the channel updates it
+	// replaced, and channels disabled for a day are pruned hourly along
+	// with the nodes left without channels.
+	CompactGraph bool
+
+	// GraphSnapshotURL is an https URL to fetch a snapshot of the graph
+	// from on first start, in the rapid gossip sync format, so payments
+	// can be routed without waiting hours for gossip. It must be signed
+	// by one of GraphSnapshotPubKeys, through a DER signature over the
+	// SHA-256 of the snapshot in the X-Signature header of the response,
+	// and be at most a week old. Gossip with peers takes over once it's
+	// applied.
+	GraphSnapshotURL string
+
+	// GraphSnapshotPubKeys are the comma separated hex encoded public
+	// keys pinned for GraphSnapshotURL.
+	GraphSnapshotPubKeys string
+
+	// NodeBreakerThreshold is the number of HTLCs in a row a node fails
+	// after which it's excluded from the routes of payments sent through
+	// SendPaymentV2, so payments stop retrying through dead ends, or zero
+	// to never exclude nodes.
+	NodeBreakerThreshold int
+
+	// NodeBreakerCooldownMinutes is how long a failing node is excluded
+	// for, after which its failures are forgotten, or zero for the
+	// default of 10 minutes.
+	NodeBreakerCooldownMinutes int
+
+	// Outbox enables QueuePayment, so payments made while the device has
+	// no connectivity are sent once a peer connects.
+	Outbox bool
+
+	// WalletPassword encrypts the wallet, along with the macaroon
+	// database. If empty, lnd's default password is used, which wallets
+	// created before the password could be set are encrypted with. It
+	// can be changed later on through ChangePassword.
+	WalletPassword string
+}
+
+// NewConfig returns a Config running neutrino on testnet, without macaroons.
+func NewConfig() *Config {
+	return &Config{
+		Network:     "testnet",
+		NoMacaroons: true,
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Validate checks the config for errors, so they can be reported before the
+// node is started. A nil config is an error, as apps passing one mean to
+// configure the node.
+func (c *Config) Validate() error {
+	if c == nil {
+		return fmt.Errorf("no config given")
+	}
+
+	switch c.Network {
+	case "testnet", "simnet", "regtest":
+	case "mainnet":
+		return fmt.Errorf("neutrino isn't yet supported for bitcoin's " +
+			"mainnet")
+	default:
+		return fmt.Errorf("unknown network %q, expected one of: "+
+			"testnet, simnet, regtest", c.Network)
+	}
+
+	if c.RemoveMacaroons && !c.NoMacaroons {
+		return fmt.Errorf("macaroons can only be removed while " +
+			"disabled")
+	}
+
+	for _, addr := range splitList(c.RPCListen) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid rpc listener %q, expected "+
+				"host:port: %v", addr, err)
+		}
+	}
+
+	for _, peer := range append(splitList(c.AddPeers),
+		splitList(c.ConnectPeers)...) {
+
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			return fmt.Errorf("invalid peer %q, expected "+
+				"host:port: %v", peer, err)
+		}
+	}
+
+	for _, spec := range splitList(c.DebugLevel) {
+		level := spec
+		if fields := strings.Split(spec, "="); len(fields) == 2 {
+			level = fields[1]
+		}
+
+		if _, ok := validLogLevels[level]; !ok {
+			return fmt.Errorf("invalid debug level %q", spec)
+		}
+	}
+
+	// The alias is announced in a 32 byte field.
+	if len(c.Alias) > 32 {
+		return fmt.Errorf("alias must be at most 32 bytes")
+	}
+
+	if c.Color != "" && !colorRegexp.MatchString(c.Color) {
+		return fmt.Errorf("color must be specified as #RRGGBB")
+	}
+
+	if c.MaxPendingChannels < 0 {
+		return fmt.Errorf("max pending channels must be non-negative")
+	}
+
+	if c.ConsolidationMaxFeeRate < 0 {
+		return fmt.Errorf("consolidation fee rate must be non-negative")
+	}
+	if c.ConsolidationTargetCount < 0 {
+		return fmt.Errorf("consolidation target count must be " +
+			"non-negative")
+	}
+
+	if c.FeeEstimatorURL != "" &&
+		!strings.HasPrefix(c.FeeEstimatorURL, "https://") {
+
+		return fmt.Errorf("fee estimator URL must be an https URL")
+	}
+	if c.FallbackFeeRate < 0 {
+		return fmt.Errorf("fallback fee rate must be non-negative")
+	}
+
+	if c.WalletDustLimit < 0 {
+		return fmt.Errorf("wallet dust limit must be non-negative")
+	}
+	if c.ChanReserveRatio < 0 || c.ChanReserveRatio > 0.2 {
+		return fmt.Errorf("channel reserve ratio must be between 0 " +
+			"and 0.2")
+	}
+	if c.MinChanReserve < 0 {
+		return fmt.Errorf("minimum channel reserve must be non-negative")
+	}
+	if c.MaxChanSize < 0 {
+		return fmt.Errorf("max channel size must be non-negative")
+	}
+	if !c.WumboChannels && c.MaxChanSize > maxNonWumboChanSize {
+		return fmt.Errorf("max channel size can only exceed %d with "+
+			"wumbo channels", maxNonWumboChanSize)
+	}
+
+	if c.InvoiceRetentionDays < 0 {
+		return fmt.Errorf("invoice retention must be non-negative")
+	}
+	if c.NodeBreakerThreshold < 0 {
+		return fmt.Errorf("node breaker threshold must be non-negative")
+	}
+	if c.NodeBreakerCooldownMinutes < 0 {
+		return fmt.Errorf("node breaker cooldown must be non-negative")
+	}
+	if c.GraphSnapshotURL != "" {
+		if !strings.HasPrefix(c.GraphSnapshotURL, "https://") {
+			return fmt.Errorf("graph snapshot URL must be an " +
+				"https URL")
+		}
+		if len(splitList(c.GraphSnapshotPubKeys)) == 0 {
+			return fmt.Errorf("graph snapshot URL requires a " +
+				"pinned public key")
+		}
+	}
+
+	return nil
+}
+
+// args converts the config into lnd command line options.
+func (c *Config) args() []string {
+	args := []string{
+		"--bitcoin.active",
+		"--bitcoin.node=neutrino",
+		"--bitcoin." + c.Network,
+	}
+
+	for _, peer := range splitList(c.AddPeers) {
+		args = append(args, "--neutrino.addpeer="+peer)
+	}
+	for _, peer := range splitList(c.ConnectPeers) {
+		args = append(args, "--neutrino.connect="+peer)
+	}
+
+	if c.DebugLevel != "" {
+		args = append(args, "--debuglevel="+c.DebugLevel)
+	}
+	if c.Alias != "" {
+		args = append(args, "--alias="+c.Alias)
+	}
+	if c.Color != "" {
+		args = append(args, "--color="+c.Color)
+	}
+	if c.NoNetBootstrap {
+		args = append(args, "--nobootstrap")
+	}
+	if c.MaxPendingChannels != 0 {
+		args = append(args, "--maxpendingchannels="+
+			strconv.Itoa(c.MaxPendingChannels))
+	}
+	if c.AutopilotActive {
+		args = append(args, "--autopilot.active")
+	}
+	if c.NoMacaroons {
+		args = append(args, "--no-macaroons")
+	}
+	if c.RemoveMacaroons {
+		args = append(args, "--removemacaroons")
+	}
+	for _, addr := range splitList(c.RPCListen) {
+		args = append(args, "--rpclisten="+addr)
+	}
+	if c.ConsolidateOutputs {
+		args = append(args, "--consolidation.active")
+	}
+	if c.ConsolidationMaxFeeRate != 0 {
+		args = append(args, "--consolidation.maxfeerate="+
+			strconv.FormatInt(c.ConsolidationMaxFeeRate, 10))
+	}
+	if c.ConsolidationTargetCount != 0 {
+		args = append(args, "--consolidation.targetcount="+
+			strconv.Itoa(c.ConsolidationTargetCount))
+	}
+	if c.FeeEstimatorURL != "" {
+		args = append(args, "--webfee.url="+c.FeeEstimatorURL)
+	}
+	if c.FeeEstimatorPubKey != "" {
+		args = append(args, "--webfee.pubkey="+c.FeeEstimatorPubKey)
+	}
+	if c.FallbackFeeRate != 0 {
+		args = append(args, "--webfee.fallbackfeerate="+
+			strconv.FormatInt(c.FallbackFeeRate, 10))
+	}
+	if c.WalletDustLimit != 0 {
+		args = append(args, "--walletdustlimit="+
+			strconv.FormatInt(c.WalletDustLimit, 10))
+	}
+	if c.ChanReserveRatio != 0 {
+		args = append(args, "--chanreserveratio="+
+			strconv.FormatFloat(c.ChanReserveRatio, 'f', -1, 64))
+	}
+	if c.MinChanReserve != 0 {
+		args = append(args, "--minchanreserve="+
+			strconv.FormatInt(c.MinChanReserve, 10))
+	}
+	if c.WumboChannels {
+		args = append(args, "--protocol.wumbo-channels")
+	}
+	if c.MaxChanSize != 0 {
+		args = append(args, "--maxchansize="+
+			strconv.FormatInt(c.MaxChanSize, 10))
+	}
+	if c.UnsafeAbandonChannel {
+		args = append(args, "--unsafe-abandonchannel")
+	}
+	if c.InvoiceRetentionDays != 0 {
+		args = append(args, "--invoices.retentiondays="+
+			strconv.Itoa(c.InvoiceRetentionDays))
+	}
+	if c.PruneSettledInvoices {
+		args = append(args, "--invoices.prunesettled")
+	}
+	if c.CompactGraph {
+		args = append(args, "--graph.compact")
+	}
+	if c.GraphSnapshotURL != "" {
+		args = append(args, "--graph.snapshoturl="+c.GraphSnapshotURL)
+	}
+	for _, key := range splitList(c.GraphSnapshotPubKeys) {
+		args = append(args, "--graph.snapshotpubkey="+key)
+	}
+	if c.NodeBreakerThreshold != 0 {
+		args = append(args, "--nodebreaker.threshold="+
+			strconv.Itoa(c.NodeBreakerThreshold))
+	}
+	if c.NodeBreakerCooldownMinutes != 0 {
+		args = append(args, "--nodebreaker.cooldown="+
+			strconv.Itoa(c.NodeBreakerCooldownMinutes)+"m")
+	}
+	if c.Outbox {
+		args = append(args, "--outbox")
+	}
+
+	return args
+}
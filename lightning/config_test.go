@@ -0,0 +1,33 @@
+package lightning
+
+import (
+	"testing"
+)
+
+// TestValidateNilConfig checks that a nil config is reported rather than
+// crashing the app, both when validated and when starting a node with it.
+func TestValidateNilConfig(t *testing.T) {
+	var config *Config
+	if err := config.Validate(); err == nil {
+		t.Fatalf("expected error validating a nil config")
+	}
+
+	_, err := NewNode("", "", nil)
+	if err == nil || ParseErrorCode(err.Error()) != ErrCodeInvalidArgument {
+		t.Fatalf("expected invalid argument error, got %v", err)
+	}
+}
+
+// TestValidateRemoveMacaroons checks that macaroons can only be removed while
+// they're disabled.
+func TestValidateRemoveMacaroons(t *testing.T) {
+	config := &Config{Network: "regtest", RemoveMacaroons: true}
+	if err := config.Validate(); err == nil {
+		t.Fatalf("expected error removing enabled macaroons")
+	}
+
+	config.NoMacaroons = true
+	if err := config.Validate(); err != nil {
+		t.Fatalf("unable to validate config: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package lightning
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// CrashCallback is implemented by the host app to be notified of panics
+// recovered on the Go side, so they can be passed on to a crash reporter.
+type CrashCallback interface {
+	OnCrash(subsystem, reason, stack string)
+}
+
+var (
+	// crashCallbackMtx guards crashCallback.
+	crashCallbackMtx sync.RWMutex
+
+	crashCallback CrashCallback
+)
+
+// SetCrashCallback registers the callback to report recovered panics to.
+// Passing nil removes the callback.
+func SetCrashCallback(callback CrashCallback) {
+	crashCallbackMtx.Lock()
+	crashCallback = callback
+	crashCallbackMtx.Unlock()
+
+	if callback == nil {
+		lnd.SetPanicHandler(nil)
+		return
+	}
+
+	lnd.SetPanicHandler(func(subsystem string, reason interface{},
+		stack []byte) {
+
+		reportCrash(subsystem, reason, stack)
+	})
+}
+
+// reportCrash hands a recovered panic to the crash callback, if any.
+func reportCrash(subsystem string, reason interface{}, stack []byte) {
+	crashCallbackMtx.RLock()
+	callback := crashCallback
+	crashCallbackMtx.RUnlock()
+
+	if callback == nil {
+		return
+	}
+
+	dispatch(func() {
+		callback.OnCrash(subsystem, fmt.Sprint(reason), string(stack))
+	})
+}
@@ -0,0 +1,259 @@
+package lightning
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// The dispatch modes deciding which thread the app's callbacks are invoked
+// on.
+const (
+	// DispatchDirect invokes callbacks from whichever goroutine produced
+	// them, so callbacks of different streams may run concurrently.
+	DispatchDirect = iota
+
+	// DispatchSerial invokes all callbacks one at a time from a single
+	// goroutine.
+	DispatchSerial
+
+	// DispatchPool invokes callbacks from a bounded pool of goroutines.
+	DispatchPool
+
+	// DispatchExecutor hands every callback to the app's Executor, e.g.
+	// to run it on the main thread.
+	DispatchExecutor
+)
+
+// Task is a callback invocation handed to the app's Executor.
+type Task struct {
+	fn   func()
+	done chan interface{}
+	once sync.Once
+}
+
+// Run invokes the callback on the calling thread. It must be called exactly
+// once for every task passed to the Executor, as the stream the callback
+// belongs to doesn't make progress until it has been.
+func (t *Task) Run() {
+	t.once.Do(func() {
+		id := goroutineID()
+		enterCallback(id)
+		defer func() {
+			leaveCallback(id)
+			t.done <- recover()
+		}()
+
+		t.fn()
+	})
+}
+
+// Executor is implemented by the host app to choose the thread callbacks are
+// invoked on under DispatchExecutor. It must eventually call Run on every task
+// it is passed, but must not run tasks on a thread that is blocked calling
+// into the bindings from outside a callback.
+type Executor interface {
+	Execute(task *Task)
+}
+
+// dispatcher invokes callbacks. dispatch returns once the callback has run,
+// so the messages of a stream are delivered in order whatever the mode.
+type dispatcher interface {
+	dispatch(task *Task)
+	stop()
+}
+
+// directDispatcher invokes callbacks on the calling goroutine.
+type directDispatcher struct{}
+
+func (directDispatcher) dispatch(task *Task) { task.Run() }
+func (directDispatcher) stop()               {}
+
+// poolDispatcher invokes callbacks from a fixed number of goroutines.
+type poolDispatcher struct {
+	tasks chan *Task
+	quit  chan struct{}
+}
+
+// newPoolDispatcher starts a pool of the given number of workers.
+func newPoolDispatcher(workers int) *poolDispatcher {
+	p := &poolDispatcher{
+		tasks: make(chan *Task),
+		quit:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+
+	return p
+}
+
+// worker runs tasks until the pool is stopped. It must be run as a goroutine.
+func (p *poolDispatcher) worker() {
+	for {
+		select {
+		case task := <-p.tasks:
+			task.Run()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *poolDispatcher) dispatch(task *Task) {
+	select {
+	case p.tasks <- task:
+
+	// The dispatcher was replaced while we were waiting for a worker,
+	// so run the task ourselves.
+	case <-p.quit:
+		task.Run()
+	}
+}
+
+func (p *poolDispatcher) stop() { close(p.quit) }
+
+// executorDispatcher hands callbacks to the app's Executor.
+type executorDispatcher struct {
+	executor Executor
+}
+
+func (e executorDispatcher) dispatch(task *Task) { e.executor.Execute(task) }
+func (e executorDispatcher) stop()               {}
+
+var (
+	// activeDispatcherMtx guards activeDispatcher.
+	activeDispatcherMtx sync.RWMutex
+
+	activeDispatcher dispatcher = directDispatcher{}
+
+	// callbacksMtx guards callbacks.
+	callbacksMtx sync.Mutex
+
+	// callbacks counts the callbacks running on every goroutine, by the
+	// goroutine's id. A callback calling into the bindings does so on the
+	// goroutine it runs on, even when called from the host app's thread.
+	callbacks = make(map[uint64]int)
+)
+
+// goroutineID returns the id of the calling goroutine, which the runtime only
+// exposes through its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+
+	// The trace starts with "goroutine <id> [<state>]:".
+	fields := bytes.Fields(buf[:n])
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[1]), 10, 64)
+
+	return id
+}
+
+// enterCallback records that a callback started running on the goroutine.
+func enterCallback(id uint64) {
+	callbacksMtx.Lock()
+	callbacks[id]++
+	callbacksMtx.Unlock()
+}
+
+// leaveCallback records that a callback running on the goroutine returned.
+func leaveCallback(id uint64) {
+	callbacksMtx.Lock()
+	callbacks[id]--
+	if callbacks[id] == 0 {
+		delete(callbacks, id)
+	}
+	callbacksMtx.Unlock()
+}
+
+// inCallback returns whether the calling goroutine is running a callback.
+func inCallback() bool {
+	callbacksMtx.Lock()
+	defer callbacksMtx.Unlock()
+
+	// Spare looking up the goroutine's id when no callback is running.
+	if len(callbacks) == 0 {
+		return false
+	}
+
+	return callbacks[goroutineID()] > 0
+}
+
+// SetDispatcher chooses the thread the callbacks of streams, crash reports
+// and progress updates are invoked on. workers is the size of the pool under
+// DispatchPool, and executor the app's Executor under DispatchExecutor; both
+// are ignored otherwise. Whatever the mode, the callbacks of a single stream
+// are invoked in order, one at a time.
+//
+// A callback may call into the bindings. The callbacks that call triggers,
+// e.g. the progress of ChangePassword, are then invoked right away on the
+// thread of the running callback rather than through the dispatcher, which
+// under DispatchSerial and DispatchExecutor is busy running that very
+// callback and would never get to them.
+func SetDispatcher(mode int, workers int, executor Executor) error {
+	var d dispatcher
+	switch mode {
+	case DispatchDirect:
+		d = directDispatcher{}
+
+	case DispatchSerial:
+		d = newPoolDispatcher(1)
+
+	case DispatchPool:
+		if workers < 1 {
+			return newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"worker pool needs at least one worker"))
+		}
+		d = newPoolDispatcher(workers)
+
+	case DispatchExecutor:
+		if executor == nil {
+			return newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"executor dispatch needs an executor"))
+		}
+		d = executorDispatcher{executor}
+
+	default:
+		return newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unknown dispatch mode %d", mode))
+	}
+
+	activeDispatcherMtx.Lock()
+	prev := activeDispatcher
+	activeDispatcher = d
+	activeDispatcherMtx.Unlock()
+
+	prev.stop()
+
+	return nil
+}
+
+// dispatch invokes the callback through the active dispatcher, blocking until
+// it has run. A panic within the callback is resumed on the calling goroutine.
+// Called from within a callback, the callback is invoked on the calling
+// goroutine, as the dispatcher may be waiting for the running callback.
+func dispatch(fn func()) {
+	task := &Task{
+		fn:   fn,
+		done: make(chan interface{}, 1),
+	}
+
+	if inCallback() {
+		task.Run()
+	} else {
+		activeDispatcherMtx.RLock()
+		d := activeDispatcher
+		activeDispatcherMtx.RUnlock()
+
+		d.dispatch(task)
+	}
+
+	if reason := <-task.done; reason != nil {
+		panic(reason)
+	}
+}
@@ -0,0 +1,63 @@
+package lightning
+
+import (
+	"testing"
+	"time"
+)
+
+// mainThread is an Executor running tasks one at a time on a single goroutine,
+// as an app running callbacks on its main thread does.
+type mainThread struct {
+	tasks chan *Task
+}
+
+func (m *mainThread) Execute(task *Task) { m.tasks <- task }
+
+func (m *mainThread) run(quit chan struct{}) {
+	for {
+		select {
+		case task := <-m.tasks:
+			task.Run()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// TestDispatchReentrant checks that a callback calling into the bindings gets
+// the callbacks that call triggers, rather than waiting for the dispatcher it
+// is running on.
+func TestDispatchReentrant(t *testing.T) {
+	defer SetDispatcher(DispatchDirect, 0, nil)
+
+	executor := &mainThread{tasks: make(chan *Task)}
+	quit := make(chan struct{})
+	defer close(quit)
+	go executor.run(quit)
+
+	modes := []int{DispatchSerial, DispatchPool, DispatchExecutor}
+	for _, mode := range modes {
+		if err := SetDispatcher(mode, 1, executor); err != nil {
+			t.Fatalf("unable to set dispatcher: %v", err)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			dispatch(func() {
+				dispatch(func() {})
+			})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("re-entrant callback deadlocked in mode %d",
+				mode)
+		}
+
+		if inCallback() {
+			t.Fatalf("callback still recorded as running")
+		}
+	}
+}
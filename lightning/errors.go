@@ -0,0 +1,250 @@
+package lightning
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcwallet/waddrmgr"
+	"github.com/roasbeef/btcwallet/wallet/txauthor"
+	"golang.org/x/net/context"
+)
+
+// The error codes attached to errors returned by the bindings. The values are
+// part of the API, so new codes must only ever be appended.
+const (
+	// ErrCodeUnknown is used for errors that don't fall into any of the
+	// categories below.
+	ErrCodeUnknown = iota
+
+	// ErrCodeNotRunning is used when a call requires the node to be
+	// running, but it isn't.
+	ErrCodeNotRunning
+
+	// ErrCodeAlreadyRunning is used when a call requires the node to be
+	// stopped, but it is running.
+	ErrCodeAlreadyRunning
+
+	// ErrCodeWalletLocked is used when the wallet must be unlocked to
+	// complete the call.
+	ErrCodeWalletLocked
+
+	// ErrCodePeerUnreachable is used when a connection to a peer couldn't
+	// be established.
+	ErrCodePeerUnreachable
+
+	// ErrCodeInsufficientBalance is used when the wallet doesn't hold
+	// enough funds to complete the call.
+	ErrCodeInsufficientBalance
+
+	// ErrCodeInvoiceExpired is used when paying an invoice that has
+	// expired.
+	ErrCodeInvoiceExpired
+
+	// ErrCodeNoRoute is used when no route to the destination of a
+	// payment could be found.
+	ErrCodeNoRoute
+
+	// ErrCodeInvalidArgument is used when an argument passed to the call
+	// is malformed.
+	ErrCodeInvalidArgument
+
+	// ErrCodeCancelled is used to terminate a stream that was cancelled
+	// through its StreamHandle.
+	ErrCodeCancelled
+
+	// ErrCodeMacaroonsDisabled is used when a call requires macaroons,
+	// but the node runs without them.
+	ErrCodeMacaroonsDisabled
+
+	// ErrCodeInvalidMnemonic is used when a mnemonic contains unknown
+	// words, or its checksum doesn't match.
+	ErrCodeInvalidMnemonic
+
+	// ErrCodeInvalidPassphrase is used when a seed can't be deciphered
+	// with the passphrase given.
+	ErrCodeInvalidPassphrase
+)
+
+// Error is an error with a stable code attached. gomobile only passes the
+// message of an error on to the host app, so the code is embedded within the
+// message, from which it can be recovered with ParseErrorCode.
+type Error struct {
+	Code    int
+	Message string
+}
+
+// Error returns the message of the error, prefixed with its code.
+func (e *Error) Error() string {
+	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
+}
+
+// errorCodeRegexp matches the code prefixed to the message of an Error.
+var errorCodeRegexp = regexp.MustCompile(`^\[(\d+)\] `)
+
+// ParseErrorCode returns the code embedded within the message of an error
+// returned by the bindings, or ErrCodeUnknown if the message has none.
+func ParseErrorCode(message string) int {
+	match := errorCodeRegexp.FindStringSubmatch(message)
+	if match == nil {
+		return ErrCodeUnknown
+	}
+
+	code, err := strconv.Atoi(match[1])
+	if err != nil {
+		return ErrCodeUnknown
+	}
+
+	return code
+}
+
+// newError creates an Error with the given code from the passed error.
+func newError(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{
+		Code:    code,
+		Message: err.Error(),
+	}
+}
+
+// wrapError attaches the code matching the passed error to it, so it can be
+// handed to the host app.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch e := err.(type) {
+	case *Error:
+		return e
+
+	case *lnwallet.ErrInsufficientFunds, txauthor.InputSourceError:
+		return newError(ErrCodeInsufficientBalance, err)
+
+	case *lnd.ErrInvoiceExpired:
+		return newError(ErrCodeInvoiceExpired, err)
+
+	case *lnd.ErrPasswordChanged:
+		return newError(ErrCodeNotRunning, err)
+
+	case *lnd.ErrCoinUnavailable:
+		return newError(ErrCodeInvalidArgument, err)
+
+	case *lnd.ErrDustOutput:
+		return newError(ErrCodeInvalidArgument, err)
+
+	case *lnd.ErrInvalidPsbt:
+		return newError(ErrCodeInvalidArgument, err)
+
+	case *lnd.ErrInvalidRoute:
+		return newError(ErrCodeInvalidArgument, err)
+
+	case *lnd.ErrInvalidRouteHint:
+		return newError(ErrCodeInvalidArgument, err)
+
+	case net.Error:
+		return newError(ErrCodePeerUnreachable, err)
+	}
+
+	switch {
+	case err == context.Canceled:
+		return newError(ErrCodeCancelled, err)
+
+	case err == lnd.ErrDaemonNotRunning:
+		return newError(ErrCodeNotRunning, err)
+
+	case err == lnd.ErrMacaroonsDisabled:
+		return newError(ErrCodeMacaroonsDisabled, err)
+
+	case err == lnd.ErrDaemonRunning, err == ErrOtherNodeRunning,
+		err == ErrNodeRunning, err == lnd.ErrMigrateWhileRunning,
+		err == lnd.ErrRecoveryInProgress:
+
+		return newError(ErrCodeAlreadyRunning, err)
+
+	case waddrmgr.IsError(err, waddrmgr.ErrLocked):
+		return newError(ErrCodeWalletLocked, err)
+
+	case waddrmgr.IsError(err, waddrmgr.ErrWrongPassphrase):
+		return newError(ErrCodeInvalidPassphrase, err)
+
+	case err == lnd.ErrEmptyPassword, err == lnd.ErrInvalidRecoveryWindow,
+		err == lnd.ErrDefaultAccount, err == lnd.ErrSeedUnavailable,
+		err == lnd.ErrSeedMismatch, err == lnd.ErrUnknownCoinSelection,
+		err == lnd.ErrNotBumpable, err == lnd.ErrFeeRateTooLow,
+		err == lnd.ErrNotReplaceable, err == lnd.ErrNoChange,
+		err == lnd.ErrLabelTooLong, err == lnd.ErrChangeType,
+		err == lnd.ErrReservedKeyFamily, err == lnd.ErrInvalidKeyLocator,
+		err == lnd.ErrInvalidSchnorrKey,
+		err == lnd.ErrInvalidSchnorrMessage,
+		err == lnd.ErrInvalidSignDescriptor,
+		err == lnd.ErrInvalidFallbackAddr, err == lnd.ErrInvalidExpiry,
+		err == lnd.ErrInvoiceTagsTooLarge,
+		err == lnd.ErrInvalidGraphCursor:
+
+		return newError(ErrCodeInvalidArgument, err)
+
+	case err == lnd.ErrUnknownMuSig2Session, err == lnd.ErrMuSig2Signers,
+		err == lnd.ErrMuSig2Nonces, err == lnd.ErrMuSig2NoncesMissing,
+		err == lnd.ErrMuSig2Signed, err == lnd.ErrMuSig2NotSigned,
+		err == lnd.ErrMuSig2InvalidSig, err == lnd.ErrMuSig2Message:
+
+		return newError(ErrCodeInvalidArgument, err)
+
+	case err == lnd.ErrAbandonDisabled, err == lnd.ErrAbandonToken,
+		err == lnd.ErrNotPendingChannel, err == lnd.ErrUnknownChannel,
+		err == lnd.ErrPaymentInFlight, err == lnd.ErrPaymentSucceeded,
+		err == lnd.ErrPaymentNotFound,
+		err == lnd.ErrPaymentQueued, err == lnd.ErrPaymentNotQueued,
+		err == lnd.ErrPaymentSending, err == lnd.ErrOutboxDisabled,
+		err == channeldb.ErrInvoiceNotFound,
+		err == channeldb.ErrGraphNodeNotFound:
+
+		return newError(ErrCodeInvalidArgument, err)
+
+	case waddrmgr.IsError(err, waddrmgr.ErrAccountNotFound),
+		waddrmgr.IsError(err, waddrmgr.ErrDuplicateAccount),
+		waddrmgr.IsError(err, waddrmgr.ErrInvalidAccount):
+
+		return newError(ErrCodeInvalidArgument, err)
+
+	case routing.IsError(err, routing.ErrNoPathFound,
+		routing.ErrNoRouteFound, routing.ErrTargetNotInNetwork):
+
+		return newError(ErrCodeNoRoute, err)
+	}
+
+	return newError(ErrCodeUnknown, err)
+}
+
+// checkRunning returns an ErrCodeNotRunning error if the node hasn't been
+// started, as the rpc server only exists while it is running.
+func checkRunning() error {
+	if !lnd.Running() {
+		return wrapError(lnd.ErrDaemonNotRunning)
+	}
+
+	return nil
+}
+
+// rpcServer returns the rpc server of the running node, or an
+// ErrCodeNotRunning error if it hasn't been started. Calls use the server for
+// their whole duration, so a node stopped or restarted meanwhile never leaves
+// them with another's.
+func rpcServer() (lnrpc.LightningServer, error) {
+	server, err := lnd.RPCServer()
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return server, nil
+}
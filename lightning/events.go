@@ -0,0 +1,93 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// taggedEvent is the JSON form of an event delivered by SubscribeEvents.
+type taggedEvent struct {
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+// marshalEvent encodes the event as JSON, tagged with its type.
+func marshalEvent(event *lnd.Event) (string, error) {
+	var (
+		payload []byte
+		err     error
+	)
+	switch p := event.Payload.(type) {
+	case proto.Message:
+		var jsonString string
+		jsonString, err = convertToJSON(p)
+		payload = []byte(jsonString)
+	default:
+		payload, err = json.Marshal(p)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	tagged, err := json.Marshal(&taggedEvent{
+		Type:  event.Type,
+		Event: payload,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(tagged), nil
+}
+
+// SubscribeEvents multiplexes invoice settles, channel opens and closes, peer
+// connects and disconnects, chain sync progress, sweeps, wallet recovery
+// progress and changes to queued payments into a single stream. Each message
+// is a JSON object holding the event type under "type", and the event itself
+// under "event". The subscription may be made before the node is started, and
+// stays active across restarts until the returned handle is cancelled. The
+// options may be nil to use the defaults, under which only the latest pending
+// chain sync and recovery events are delivered.
+func SubscribeEvents(callback RecvStream,
+	opts *StreamOptions) (*StreamHandle, error) {
+
+	if opts == nil {
+		opts = NewStreamOptions()
+	}
+
+	client := lnd.SubscribeEvents()
+	stream := newServerStream(callback, opts)
+
+	runStream(stream, func() error {
+		defer client.Cancel()
+
+		for {
+			select {
+			case event := <-client.Events:
+				jsonString, err := marshalEvent(event)
+				if err != nil {
+					return err
+				}
+
+				// Events of coalesced types replace the pending
+				// event of the same type.
+				var key string
+				if opts.coalesced(event.Type) {
+					key = event.Type
+				}
+
+				err = stream.queue.push(key, jsonString)
+				if err != nil {
+					return err
+				}
+
+			case <-stream.ctx.Done():
+				return stream.ctx.Err()
+			}
+		}
+	})
+
+	return stream.handle(), nil
+}
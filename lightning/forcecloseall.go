@@ -0,0 +1,40 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// ForceCloseAll force closes every open channel, for users who believe the
+// device is compromised or are about to lose it. It returns a JSON array with
+// the chan_point of each channel and either the closing_txid or the error
+// that kept it from closing.
+//
+// Commitment transactions pay the fee agreed with the peer when they were
+// signed. satPerByte is instead the lowest fee rate the time locked outputs
+// are swept back to the wallet at, until the node stops, or zero to use the
+// estimated rate.
+func ForceCloseAll(satPerByte int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if satPerByte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	results, err := lnd.ForceCloseAll(satPerByte)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultsJSON), nil
+}
@@ -0,0 +1,218 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// RapidGossipSync fills the channel graph from the rapid gossip sync server at
+// serverURL, an https URL such as
+// https://rapidsync.lightningdevkit.org/testnet/snapshot, so payments can be
+// routed seconds after the first start instead of after hours of gossip. Later
+// calls only fetch what changed since the previous one, and gossip from peers
+// keeps updating the graph in between. It returns JSON with the timestamp of
+// the snapshot applied, and the number of channels added and of policy
+// updates applied.
+func RapidGossipSync(serverURL string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(serverURL, "https://") {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"rapid gossip sync server must be an https URL"))
+	}
+
+	result, err := lnd.RapidGossipSync(serverURL)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultJSON), nil
+}
+
+// GraphFilter pages through the nodes or channels of the graph listed by
+// DescribeGraphNodes and DescribeGraphEdges, whose whole would be too large to
+// pass at once. Calls taking a nil GraphFilter list the whole graph.
+type GraphFilter struct {
+	// Cursor is the next cursor returned with the previous page, or empty
+	// for the first page.
+	Cursor string
+
+	// MaxItems is the size of a page, or zero for no paging.
+	MaxItems int
+
+	// ChangedSince limits the listing to nodes announced, or channels
+	// with a policy updated, at or after the unix time, if it isn't zero.
+	// Apps keeping a copy of the graph pass the time of their previous
+	// listing.
+	ChangedSince int64
+}
+
+// NewGraphFilter returns a GraphFilter listing the whole graph at once.
+func NewGraphFilter() *GraphFilter {
+	return &GraphFilter{}
+}
+
+// query returns the lnd query of the filter.
+func (f *GraphFilter) query() (*lnd.GraphQuery, error) {
+	if f == nil {
+		f = NewGraphFilter()
+	}
+
+	switch {
+	case f.MaxItems < 0:
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"max items must not be negative"))
+	case f.ChangedSince < 0:
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"changed since must not be negative"))
+	}
+
+	query := &lnd.GraphQuery{
+		Cursor:   f.Cursor,
+		MaxItems: f.MaxItems,
+	}
+	if f.ChangedSince > 0 {
+		query.ChangedSince = time.Unix(f.ChangedSince, 0)
+	}
+
+	return query, nil
+}
+
+// graphPage returns the nodes or edges of the graph as JSON under key, in the
+// encoding of lnd's DescribeGraph call, with the cursor of the next page added
+// next to them under "next_cursor".
+func graphPage(resp *lnrpc.ChannelGraph, key, nextCursor string) (string,
+	error) {
+
+	respJSON, err := convertToJSON(resp)
+	if err != nil {
+		return "", err
+	}
+
+	var graph map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(respJSON), &graph); err != nil {
+		return "", err
+	}
+
+	page := map[string]interface{}{
+		key:           graph[key],
+		"next_cursor": nextCursor,
+	}
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pageJSON), nil
+}
+
+// DescribeGraphNodes returns the nodes of the graph selected by the filter as
+// JSON under "nodes", as lnd's DescribeGraph call encodes them, with the
+// cursor of the next page under "next_cursor", which is empty on the last
+// page. Pages are in the order of the public keys of the nodes.
+func DescribeGraphNodes(filter *GraphFilter) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	query, err := filter.query()
+	if err != nil {
+		return "", err
+	}
+
+	resp, nextCursor, err := lnd.DescribeGraphNodes(query)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return graphPage(resp, "nodes", nextCursor)
+}
+
+// DescribeGraphEdges returns the channels of the graph selected by the filter
+// as JSON under "edges", as lnd's DescribeGraph call encodes them, with the
+// cursor of the next page under "next_cursor", which is empty on the last
+// page. Pages are in the order of the short channel IDs of the channels.
+func DescribeGraphEdges(filter *GraphFilter) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	query, err := filter.query()
+	if err != nil {
+		return "", err
+	}
+
+	resp, nextCursor, err := lnd.DescribeGraphEdges(query)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return graphPage(resp, "edges", nextCursor)
+}
+
+// GetNodeInfo returns the JSON encoded announcement of the hex encoded node
+// from the graph, with its alias, color and addresses, along with the number
+// and total capacity of its channels. Nodes missing from the graph give an
+// invalid argument error.
+func GetNodeInfo(pubKey string) (string, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := parsePubKey(pubKey); err != nil {
+		return "", err
+	}
+
+	req := &lnrpc.NodeInfoRequest{PubKey: pubKey}
+	resp, err := server.GetNodeInfo(nil, req)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// NodeAliases returns a JSON object mapping the comma separated hex encoded
+// nodes to the aliases they announced, for screens listing peers or payment
+// destinations by name. Nodes missing from the graph, or without an alias,
+// are left out.
+func NodeAliases(pubKeys string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	var keys []*btcec.PublicKey
+	for _, item := range splitList(pubKeys) {
+		pubKey, err := parsePubKey(item)
+		if err != nil {
+			return "", err
+		}
+		keys = append(keys, pubKey)
+	}
+
+	aliases, err := lnd.NodeAliases(keys)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	aliasesJSON, err := json.Marshal(aliases)
+	if err != nil {
+		return "", err
+	}
+
+	return string(aliasesJSON), nil
+}
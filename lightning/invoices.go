@@ -0,0 +1,264 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// InvoiceOptions sets the fields of an invoice created by AddInvoice. Calls
+// taking nil InvoiceOptions use the defaults.
+//
+// An invoice holds a single route hint, as the invoice encoding of this node
+// writes no more, so at most one channel is embedded in it.
+type InvoiceOptions struct {
+	// Private embeds a route hint for the active private channel whose
+	// peer can send the most, so the node can be paid without public
+	// channels.
+	Private bool
+
+	// RouteHintChanIDs is a comma separated list of the decimal short
+	// channel ids the route hint may be for instead, such as that of the
+	// channel with an LSP, whether they're private and active or not. The
+	// one whose peer can send the most is embedded, and creating the
+	// invoice fails if none of them is open with a known peer policy.
+	RouteHintChanIDs string
+
+	// HopHints is a route hint embedded as is instead of any channel, a
+	// JSON array of hops with the hex encoded node_id forwarding over the
+	// chan_id to the next hop, or to this node for the last one, and the
+	// fee_base_msat, fee_proportional_millionths and cltv_expiry_delta it
+	// charges for it.
+	HopHints string
+
+	// ExpirySeconds is how long the invoice can be paid for, at most a
+	// year, or zero for the default of an hour.
+	ExpirySeconds int64
+
+	// FallbackAddress is an on-chain address payers may pay to instead,
+	// if not empty.
+	FallbackAddress string
+
+	// DescriptionHash is the hex encoded SHA-256 hash of a description
+	// too long for the invoice, which it commits to instead of the memo,
+	// if not empty.
+	DescriptionHash string
+
+	// Label names the invoice for the app, and Metadata is any string the
+	// app stores with it, such as the id of an order. Payers see neither.
+	// They're kept JSON encoded as the receipt of the invoice in the
+	// channel database, within 1024 bytes, so they're restored along with
+	// it. SubscribeInvoices, SubscribeSingleInvoice and LookupInvoice
+	// return them as its base64 encoded receipt.
+	Label    string
+	Metadata string
+}
+
+// NewInvoiceOptions returns InvoiceOptions with the defaults, embedding no
+// route hint.
+func NewInvoiceOptions() *InvoiceOptions {
+	return &InvoiceOptions{}
+}
+
+// apply sets the fields of the invoice the options set.
+func (o *InvoiceOptions) apply(invoice *lnrpc.Invoice) error {
+	invoice.Expiry = o.ExpirySeconds
+	invoice.FallbackAddr = o.FallbackAddress
+
+	if o.Label != "" || o.Metadata != "" {
+		receipt, err := json.Marshal(&lnd.InvoiceTags{
+			Label:    o.Label,
+			Metadata: o.Metadata,
+		})
+		if err != nil {
+			return err
+		}
+		invoice.Receipt = receipt
+	}
+
+	if o.DescriptionHash != "" {
+		descHash, err := hex.DecodeString(o.DescriptionHash)
+		if err != nil || len(descHash) != 32 {
+			return newError(ErrCodeInvalidArgument, errors.New(
+				"description hash must be 32 hex encoded "+
+					"bytes"))
+		}
+		invoice.DescriptionHash = descHash
+	}
+
+	return nil
+}
+
+// routeHints returns the route hints the options pick.
+func (o *InvoiceOptions) routeHints() (*lnd.RouteHints, error) {
+	hints := &lnd.RouteHints{Private: o.Private}
+	for _, item := range splitList(o.RouteHintChanIDs) {
+		chanID, err := strconv.ParseUint(item, 10, 64)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidArgument, err)
+		}
+		hints.ChanIDs = append(hints.ChanIDs, chanID)
+	}
+
+	if o.HopHints != "" {
+		err := json.Unmarshal([]byte(o.HopHints), &hints.HopHints)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidArgument, err)
+		}
+	}
+
+	return hints, nil
+}
+
+// AddInvoice creates an invoice for amount satoshis, or for any amount if it's
+// zero, with the memo as its description unless the options set a description
+// hash, and the other fields set by the options, which may be nil for the
+// defaults. It returns the JSON encoded r_hash and payment_request of the
+// invoice. A fallback address of another network or an expiry over a year
+// fail with ErrCodeInvalidArgument.
+func AddInvoice(amount int64, memo string,
+	options *InvoiceOptions) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if amount < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"amount must not be negative"))
+	}
+
+	invoice := &lnrpc.Invoice{
+		Memo:  memo,
+		Value: amount,
+	}
+
+	var hints *lnd.RouteHints
+	if options != nil {
+		if err := options.apply(invoice); err != nil {
+			return "", err
+		}
+
+		var err error
+		hints, err = options.routeHints()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := lnd.AddInvoice(invoice, hints)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// LookupInvoice returns the invoice with the hex encoded payment hash, JSON
+// encoded as SubscribeInvoices sends it.
+func LookupInvoice(paymentHash string) (string, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"payment hash must be 32 hex encoded bytes"))
+	}
+
+	invoice, err := server.LookupInvoice(
+		nil, &lnrpc.PaymentHash{RHash: hash},
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(invoice)
+}
+
+// SubscribeSingleInvoice streams the invoice with the hex encoded payment
+// hash, JSON encoded as SubscribeInvoices sends it, once as it is now and
+// again when it's settled, after which the stream ends. Unlike
+// SubscribeInvoices, it only follows the one invoice, as a point of sale
+// screen needs. Invoices are only ever open or settled, as the node has no
+// hold invoices to accept nor a way to cancel an invoice.
+func SubscribeSingleInvoice(paymentHash string,
+	callback RecvStream) (*StreamHandle, error) {
+
+	if err := checkRunning(); err != nil {
+		return nil, err
+	}
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"payment hash must be 32 hex encoded bytes"))
+	}
+	var rHash [32]byte
+	copy(rHash[:], hash)
+
+	stream := invoiceStream{newServerStream(callback, nil)}
+	runStream(stream.serverStream, func() error {
+		err := lnd.SubscribeSingleInvoice(
+			rHash, stream.ctx.Done(), stream.Send,
+		)
+		if err != nil {
+			return err
+		}
+
+		return stream.ctx.Err()
+	})
+
+	return stream.handle(), nil
+}
+
+// DecodePaymentRequest decodes the BOLT11 payment request, or lightning: URI,
+// of any network without the node running, so it can be shown as soon as it's
+// pasted. It returns JSON with its network, destination, payment_hash,
+// amount_msat, zero if the payer picks it, timestamp, expiry in seconds,
+// description or description_hash, fallback_addr, cltv_expiry, and the hops
+// of its route_hint in the form of InvoiceOptions.HopHints. Feature bits
+// aren't decoded, as the invoice encoding of this node skips them.
+func DecodePaymentRequest(paymentRequest string) (string, error) {
+	payReq, err := lnd.DecodePaymentRequest(paymentRequest)
+	if err != nil {
+		return "", newError(ErrCodeInvalidArgument, err)
+	}
+
+	payReqJSON, err := json.Marshal(payReq)
+	if err != nil {
+		return "", err
+	}
+
+	return string(payReqJSON), nil
+}
+
+// PurgeInvoices deletes the invoices that expired unpaid more than
+// olderThanDays days ago, along with those settled more than olderThanDays
+// days ago if includeSettled is set, and returns the number deleted. Invoices
+// are otherwise pruned as set by Config.InvoiceRetentionDays.
+func PurgeInvoices(olderThanDays int32, includeSettled bool) (int64, error) {
+	if err := checkRunning(); err != nil {
+		return 0, err
+	}
+
+	if olderThanDays < 0 {
+		return 0, newError(ErrCodeInvalidArgument, errors.New(
+			"days must not be negative"))
+	}
+
+	olderThan := time.Duration(olderThanDays) * 24 * time.Hour
+	count, err := lnd.PruneInvoices(olderThan, includeSettled)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return int64(count), nil
+}
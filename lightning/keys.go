@@ -0,0 +1,95 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// MinAppKeyFamily is the lowest key family DerivePrivKey accepts. Lower ones
+// hold lnd's own keys.
+const MinAppKeyFamily = lnd.MinAppKeyFamily
+
+// marshalKey encodes a derived key as JSON.
+func marshalKey(key interface{}) (string, error) {
+	keyJSON, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(keyJSON), nil
+}
+
+// DeriveKey returns the JSON encoded public key derived from the node's seed
+// at the key family and index, which is the same on every call, so companion
+// protocols don't need to store it.
+func DeriveKey(family, index int32) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	key, err := lnd.DeriveKey(family, index)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalKey(key)
+}
+
+// DerivePrivKey returns the JSON encoded key pair derived from the node's seed
+// at the key family and index, for protocols such as LNURL-auth or static
+// backup encryption. The family must be at least MinAppKeyFamily.
+func DerivePrivKey(family, index int32) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	key, err := lnd.DerivePrivKey(family, index)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalKey(key)
+}
+
+// parsePubKey parses the hex encoded public key.
+func parsePubKey(pubKeyHex string) (*btcec.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode public key: %v", err))
+	}
+	pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to parse public key: %v", err))
+	}
+
+	return pubKey, nil
+}
+
+// DeriveSharedKey returns the JSON encoded ECDH shared secret of the key at
+// the family and index with the hex encoded public key, without the private
+// key leaving the wallet. Family 6, index 0 is the node's identity key.
+func DeriveSharedKey(pubKeyHex string, family, index int32) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	pubKey, err := parsePubKey(pubKeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	sharedKey, err := lnd.DeriveSharedKey(pubKey, family, index)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalKey(map[string]string{
+		"shared_key": hex.EncodeToString(sharedKey),
+	})
+}
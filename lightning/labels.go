@@ -0,0 +1,118 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+)
+
+// SetTransactionLabel labels the transaction with the given id, replacing any
+// previous label. Labels are kept in the wallet database, and returned with
+// GetTransactions.
+func SetTransactionLabel(txid, label string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return newError(ErrCodeInvalidArgument, err)
+	}
+
+	return wrapError(lnd.SetTransactionLabel(*hash, label))
+}
+
+// DeleteTransactionLabel deletes the label of the transaction with the given
+// id, if it has one.
+func DeleteTransactionLabel(txid string) error {
+	return SetTransactionLabel(txid, "")
+}
+
+// SetAddressLabel labels the address, replacing any previous label. Labels
+// are kept in the wallet database, and returned with GetTransactions for the
+// transactions paying to the address.
+func SetAddressLabel(address, label string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	return wrapError(lnd.SetAddressLabel(address, label))
+}
+
+// DeleteAddressLabel deletes the label of the address, if it has one.
+func DeleteAddressLabel(address string) error {
+	return SetAddressLabel(address, "")
+}
+
+// ListLabels returns the labels of all transactions and addresses, JSON
+// encoded as maps from transaction id and address to label.
+func ListLabels() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	labels, err := lnd.ListLabels()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+
+	return string(labelsJSON), nil
+}
+
+// labelTransactions adds the labels to the JSON encoded transactions returned
+// by the rpc server. Every transaction gets its own label, along with the
+// labels of the addresses it pays to.
+func labelTransactions(txsJSON string, labels *lnd.Labels) (string, error) {
+	var txs struct {
+		Transactions []map[string]json.RawMessage `json:"transactions"`
+	}
+	if err := json.Unmarshal([]byte(txsJSON), &txs); err != nil {
+		return "", err
+	}
+
+	for _, tx := range txs.Transactions {
+		var (
+			txHash    string
+			destAddrs []string
+		)
+		if err := json.Unmarshal(tx["tx_hash"], &txHash); err != nil {
+			return "", err
+		}
+		if raw, ok := tx["dest_addresses"]; ok {
+			if err := json.Unmarshal(raw, &destAddrs); err != nil {
+				return "", err
+			}
+		}
+
+		addrLabels := make(map[string]string)
+		for _, addr := range destAddrs {
+			if label, ok := labels.Addresses[addr]; ok {
+				addrLabels[addr] = label
+			}
+		}
+
+		label, err := json.Marshal(labels.Transactions[txHash])
+		if err != nil {
+			return "", err
+		}
+		tx["label"] = label
+
+		tx["address_labels"], err = json.Marshal(addrLabels)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	labeledJSON, err := json.MarshalIndent(&txs, "", "    ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(labeledJSON), nil
+}
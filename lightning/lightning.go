@@ -11,43 +11,83 @@ import (
 	"github.com/golang/protobuf/jsonpb"
 	"fmt"
 	"github.com/lightningnetwork/lnd/lnrpc"
+	"golang.org/x/net/context"
 )
 
- 
-var started = false
-
+// Start starts the node using the options found in lnd.conf within dir.
 func Start(dir, mnemonic string) error {
+	return start(dir, mnemonic, nil)
+}
 
-
-	btcutil.SetDir(dir);
-
-
-	if started {
-		log.Print("LND already started")
-		return nil
+// StartWithConfig starts the node using the passed config, which takes
+// precedence over lnd.conf within dir. The config is validated before the
+// node is started.
+func StartWithConfig(dir, mnemonic string, config *Config) error {
+	if err := config.Validate(); err != nil {
+		return newError(ErrCodeInvalidArgument, err)
 	}
 
-	started = true 
+	return start(dir, mnemonic, config)
+}
 
+// start starts the node, creating the wallet from the BIP39 mnemonic if it
+// doesn't exist yet. The config may be nil to use lnd.conf within dir.
+func start(dir, mnemonic string, config *Config) error {
 	var seed []byte = nil
 	var err error
 
 	if mnemonic != "" {
 		seed, err = b39.NewSeedWithErrorChecking(mnemonic, "")
 		if err != nil {
-			return err
+			return newError(ErrCodeInvalidArgument, err)
 		}
 	}
 
-	err = lnd.Start(seed,dir)
+	return startWithSeed(dir, seed, config)
+}
+
+// startWithSeed starts lnd within dir, creating the wallet from the seed if it
+// doesn't exist yet. The config may be nil to use lnd.conf within dir.
+func startWithSeed(dir string, seed []byte, config *Config) error {
+	var (
+		args     []string
+		walletPw []byte
+	)
+	if config != nil {
+		args = config.args()
+		walletPw = []byte(config.WalletPassword)
+	}
+
+
+	btcutil.SetDir(dir);
+
+
+	if lnd.Running() {
+		log.Print("LND already started")
+		return nil
+	}
+
+	err := lnd.Start(seed, walletPw, dir, args)
 	if err != nil {
 		log.Printf("lnd.Start failed: %v\n", err)
-		return err
+		return wrapError(err)
 	}
  	 
 
 	return nil
 }
+
+// StopDaemon gracefully shuts down the chain backend, peers and databases.
+// The node can be started again afterwards within the same process.
+func StopDaemon() error {
+	return wrapError(lnd.Stop())
+}
+
+// RestartDaemon stops the node and starts it again with the data directory
+// and seed it was last started with.
+func RestartDaemon() error {
+	return wrapError(lnd.Restart())
+}
  
 func CreateBip39Seed() (string, error) {
 	// Using 32 bytes of entropy gives us a 24 word seed phrase. Here we use
@@ -83,12 +123,17 @@ func convertToJSON(resp proto.Message) (string,error) {
 
 func GetInfo() (string, error){
 	req := &lnrpc.GetInfoRequest{}
-	resp, err := lnd.LndRpcServer.GetInfo(nil, req)
-	
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.GetInfo(nil, req)
+	
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -104,12 +149,17 @@ func NewAddress(addressType int32) (string, error){
 
 	req := &lnrpc.NewAddressRequest{}
 	req.Type = lnrpc.NewAddressRequest_AddressType(addressType)
-	resp, err := lnd.LndRpcServer.NewAddress(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.NewAddress(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -123,12 +173,17 @@ func WalletBalance() (string, error){
 
 
 	req := &lnrpc.WalletBalanceRequest{}
-	resp, err := lnd.LndRpcServer.WalletBalance(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.WalletBalance(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -141,12 +196,17 @@ func WalletBalance() (string, error){
 func ChannelBalance() (string, error){
 
 	req := &lnrpc.ChannelBalanceRequest{}
-	resp, err := lnd.LndRpcServer.ChannelBalance(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.ChannelBalance(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -159,12 +219,17 @@ func ChannelBalance() (string, error){
 func PendingChannels() (string, error){
 
 	req := &lnrpc.PendingChannelsRequest{}
-	resp, err := lnd.LndRpcServer.PendingChannels(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.PendingChannels(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -177,12 +242,17 @@ func PendingChannels() (string, error){
 func ListChannels() (string, error){
 
 	req := &lnrpc.ListChannelsRequest{}
-	resp, err := lnd.LndRpcServer.ListChannels(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.ListChannels(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -195,12 +265,17 @@ func ListChannels() (string, error){
 func ListPayments() (string, error){
 
 	req := &lnrpc.ListPaymentsRequest{}
-	resp, err := lnd.LndRpcServer.ListPayments(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.ListPayments(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -213,12 +288,17 @@ func ListPayments() (string, error){
 func ListPeers() (string, error){
 
 	req := &lnrpc.ListPeersRequest{}
-	resp, err := lnd.LndRpcServer.ListPeers(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.ListPeers(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -228,30 +308,42 @@ func ListPeers() (string, error){
 	return jsonString, nil
 }
 
+// GetTransactions returns the JSON encoded transactions of the wallet, each
+// along with its label and those of the addresses it pays to.
 func GetTransactions() (string, error){
 
 	req := &lnrpc.GetTransactionsRequest{}
-	resp, err := lnd.LndRpcServer.GetTransactions(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.GetTransactions(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
 		return "", err
 	}
 
-	return jsonString, nil
+	labels, err := lnd.ListLabels()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return labelTransactions(jsonString, labels)
 }
 
 func ConnectPeer(targetAddress string) (string, error){
 
 	splitAddr := strings.Split(targetAddress, "@")
 	if len(splitAddr) != 2 {
-		return "", fmt.Errorf("target address expected in format: " +
-			"pubkey@host:port")
+		return "", newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"target address expected in format: pubkey@host:port"))
 	}
 
 	addr := &lnrpc.LightningAddress{
@@ -263,12 +355,17 @@ func ConnectPeer(targetAddress string) (string, error){
 		Perm:false,
 	}
 
-	resp, err := lnd.LndRpcServer.ConnectPeer(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.ConnectPeer(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -278,7 +375,12 @@ func ConnectPeer(targetAddress string) (string, error){
 	return jsonString, nil
 }
 
-func OpenChannelSync(nodePubKeyHex string,localAmount int64) (string, error){
+// OpenChannelSync opens a channel to the given node, returning once the
+// funding transaction has been published.
+// The coins funding the channel, and its change, are as chosen by the coin
+// control, which may be nil for the defaults.
+func OpenChannelSync(nodePubKeyHex string, localAmount int64,
+	control *CoinControl) (string, error) {
 
 	req := &lnrpc.OpenChannelRequest{}	 
 		 
@@ -288,9 +390,19 @@ func OpenChannelSync(nodePubKeyHex string,localAmount int64) (string, error){
 		 
 	req.Private = false; 
 	  
-	resp, err := lnd.LndRpcServer.OpenChannelSync(nil, req)
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, err := coinControlContext(context.Background(), control)
 	if err != nil {
 		return "", err
+	}
+
+	resp, err := server.OpenChannelSync(ctx, req)
+	if err != nil {
+		return "", wrapError(err)
 	} 
 	jsonString,err := convertToJSON(resp);
  	
@@ -307,12 +419,17 @@ func SendPaymentSync(paymentRequest string) (string, error){
 			PaymentRequest: paymentRequest, 
 		} 
 	
-	resp, err := lnd.LndRpcServer.SendPaymentSync(nil, req)
-
+	server, err := rpcServer()
 	if err != nil {
 		return "", err
 	}
 
+	resp, err := server.SendPaymentSync(nil, req)
+
+	if err != nil {
+		return "", wrapError(err)
+	}
+
 	jsonString,err := convertToJSON(resp);
  	
  	if err != nil {
@@ -323,3 +440,14 @@ func SendPaymentSync(paymentRequest string) (string, error){
 }
 
  
+// SetLogLevel changes the log level of a subsystem, such as CHDB, PEER or
+// CRTR, without restarting the node. An empty subsystem changes the level of
+// all subsystems.
+func SetLogLevel(subsystem, level string) error {
+	return newError(ErrCodeInvalidArgument, lnd.SetLogLevel(subsystem, level))
+}
+
+// LogSubsystems returns the space separated list of logging subsystems.
+func LogSubsystems() string {
+	return strings.Join(lnd.LogSubsystems(), " ")
+}
@@ -0,0 +1,56 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// GetLimits returns the JSON encoded limits in effect: max_pending_channels,
+// max_peers and max_inbound_peers, with zero peer limits meaning no limit.
+func GetLimits() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	limits, err := lnd.GetLimits()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	limitsJSON, err := json.Marshal(limits)
+	if err != nil {
+		return "", err
+	}
+
+	return string(limitsJSON), nil
+}
+
+// SetLimits changes the limits on pending channels and peers without a
+// restart, such as when switching between Wi-Fi and cellular, until the node
+// stops. maxPendingChannels is the maximum number of incoming pending
+// channels per peer. Inbound connections are refused once maxPeers peers, or
+// maxInboundPeers inbound peers, are connected, unless the node has channels
+// with the peer. Zero peer limits mean no limit. Connected peers are kept when
+// a limit is lowered.
+func SetLimits(maxPendingChannels, maxPeers, maxInboundPeers int32) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	switch {
+	case maxPendingChannels < 1:
+		return newError(ErrCodeInvalidArgument, errors.New(
+			"max pending channels must be at least 1"))
+	case maxPeers < 0 || maxInboundPeers < 0:
+		return newError(ErrCodeInvalidArgument, errors.New(
+			"peer limits must not be negative"))
+	}
+
+	return wrapError(lnd.SetLimits(&lnd.Limits{
+		MaxPendingChannels: int(maxPendingChannels),
+		MaxPeers:           int(maxPeers),
+		MaxInboundPeers:    int(maxInboundPeers),
+	}))
+}
@@ -0,0 +1,21 @@
+package lightning
+
+import (
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// GenerateLndConnectURI returns an lndconnect:// URI pairing a remote app such
+// as Zeus or Zap with the node, to be shown as a QR code. The URI holds the
+// node's TLS certificate and a freshly baked macaroon scoped to the comma
+// separated entity:action permissions, e.g. "info:read,invoices:write". An
+// empty list grants admin access. If host doesn't include a port, the port of
+// the first RPC listener not on a loopback address is used. The node must be
+// running with macaroons enabled and Config.RPCListen set.
+func GenerateLndConnectURI(host, macaroonPermissions string) (string, error) {
+	uri, err := lnd.LndConnectURI(host, splitList(macaroonPermissions))
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return uri, nil
+}
@@ -0,0 +1,58 @@
+package lightning
+
+import (
+	"errors"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// SignMessage signs the message with the node's identity key, so the app can
+// prove ownership of the node, for example to log in to a service. The
+// response holds the zbase32 encoded, pubkey recoverable signature, in the
+// format of lncli signmessage.
+func SignMessage(msg string) (string, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	if msg == "" {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"need a message to sign"))
+	}
+
+	req := &lnrpc.SignMessageRequest{Msg: []byte(msg)}
+	resp, err := server.SignMessage(nil, req)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
+
+// VerifyMessage checks the zbase32 encoded signature over the message, as made
+// by SignMessage or lncli signmessage. The response holds the public key of
+// the signer, and whether it is valid, which requires the signer to be a node
+// with channels in the graph.
+func VerifyMessage(msg, signature string) (string, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	if msg == "" || signature == "" {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"need a message and signature to verify"))
+	}
+
+	req := &lnrpc.VerifyMessageRequest{
+		Msg:       []byte(msg),
+		Signature: signature,
+	}
+	resp, err := server.VerifyMessage(nil, req)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return convertToJSON(resp)
+}
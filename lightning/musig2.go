@@ -0,0 +1,144 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// decodeHexList decodes a comma-separated list of hex strings.
+func decodeHexList(list, name string) ([][]byte, error) {
+	var items [][]byte
+	for _, item := range splitList(list) {
+		b, err := hex.DecodeString(item)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"unable to decode %s %q: %v", name, item, err))
+		}
+		items = append(items, b)
+	}
+
+	return items, nil
+}
+
+// marshalMuSig2Result encodes the result of a MuSig2 call as JSON.
+func marshalMuSig2Result(result interface{}) (string, error) {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultJSON), nil
+}
+
+// MuSig2CreateSession opens a MuSig2 signing session for the key at the family
+// and index, with the other signers' hex encoded compressed public keys
+// comma-separated in signers. With taprootTweak set, the combined key is that
+// of a BIP 86 taproot output. The other signers' public nonces may be passed
+// now, or later through MuSig2RegisterNonces. The session is returned JSON
+// encoded, with the combined key and the local public nonces to share. Only
+// families from MinAppKeyFamily onwards may be used, and sessions expire
+// after an hour.
+func MuSig2CreateSession(family, index int32, signers string,
+	taprootTweak bool, otherNonces string) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	pubKeys, err := decodeHexList(signers, "public key")
+	if err != nil {
+		return "", err
+	}
+	nonces, err := decodeHexList(otherNonces, "nonce")
+	if err != nil {
+		return "", err
+	}
+
+	session, err := lnd.MuSig2CreateSession(
+		family, index, pubKeys, taprootTweak, nonces,
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalMuSig2Result(session)
+}
+
+// MuSig2RegisterNonces adds the comma-separated hex encoded public nonces of
+// other signers to the session, returning whether all signers' are now known.
+func MuSig2RegisterNonces(sessionID, nonces string) (bool, error) {
+	if err := checkRunning(); err != nil {
+		return false, err
+	}
+
+	nonceList, err := decodeHexList(nonces, "nonce")
+	if err != nil {
+		return false, err
+	}
+
+	haveAll, err := lnd.MuSig2RegisterNonces(sessionID, nonceList)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	return haveAll, nil
+}
+
+// MuSig2Sign returns the hex encoded partial signature of the session over
+// the hex encoded 32 byte message digest. A session signs only once.
+func MuSig2Sign(sessionID, msgHex string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	msg, err := hex.DecodeString(msgHex)
+	if err != nil {
+		return "", newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode message: %v", err))
+	}
+
+	sig, err := lnd.MuSig2Sign(sessionID, msg)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return hex.EncodeToString(sig), nil
+}
+
+// MuSig2CombineSig verifies the comma-separated hex encoded partial signatures
+// of other signers and adds them to the session's own. Once all signers' are
+// known, the JSON encoded result holds the final signature and the session is
+// closed.
+func MuSig2CombineSig(sessionID, partialSigs string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	sigs, err := decodeHexList(partialSigs, "partial signature")
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := lnd.MuSig2CombineSig(sessionID, sigs)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalMuSig2Result(map[string]interface{}{
+		"have_all_signatures": sig != nil,
+		"final_signature":     hex.EncodeToString(sig),
+	})
+}
+
+// MuSig2Cleanup closes the session, wiping its secret nonce, for sessions
+// abandoned before their final signature.
+func MuSig2Cleanup(sessionID string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	return wrapError(lnd.MuSig2Cleanup(sessionID))
+}
@@ -0,0 +1,105 @@
+package lightning
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// ErrOtherNodeRunning is returned when starting a Node while another Node is
+// running within the process. lnd keeps its configuration, chain parameters
+// and loggers in package level state, so only a single node can be active at
+// a time. Stop the running node first to switch between nodes.
+var ErrOtherNodeRunning = errors.New("another node is already running " +
+	"in this process")
+
+// ErrNodeRunning is returned when starting a Node that is already running.
+var ErrNodeRunning = errors.New("this node is already running")
+
+var (
+	// nodeMtx guards activeNode.
+	nodeMtx sync.Mutex
+
+	// activeNode is the Node currently running, if any.
+	activeNode *Node
+)
+
+// Node is a handle to an lnd instance with its own data directory and config,
+// allowing an app to keep e.g. a testnet and a regtest node and switch
+// between them. The handles don't run nodes side by side: lnd is a single
+// instance per process, so a handle only holds what to start it with, and at
+// most one handle is running at a time. Starting and stopping lnd through the
+// package level functions affects the running handle too.
+type Node struct {
+	dir      string
+	mnemonic string
+	config   *Config
+}
+
+// NewNode returns a handle to a node storing its data within dir. The config
+// is validated up front, the node isn't started until Start is called. The
+// handle keeps a copy of the config, so later changes to the app's config
+// don't affect the node, nor does the node change the app's config.
+func NewNode(dir, mnemonic string, config *Config) (*Node, error) {
+	var nodeConfig *Config
+	if config != nil {
+		c := *config
+		nodeConfig = &c
+	}
+
+	if err := nodeConfig.Validate(); err != nil {
+		return nil, newError(ErrCodeInvalidArgument, err)
+	}
+
+	return &Node{
+		dir:      dir,
+		mnemonic: mnemonic,
+		config:   nodeConfig,
+	}, nil
+}
+
+// Start starts the node. ErrNodeRunning is returned if the node is already
+// running, and ErrOtherNodeRunning if a different node, or lnd started through
+// the package level functions, is.
+func (n *Node) Start() error {
+	nodeMtx.Lock()
+	defer nodeMtx.Unlock()
+
+	switch {
+	case activeNode == n && lnd.Running():
+		return wrapError(ErrNodeRunning)
+
+	case lnd.Running():
+		return wrapError(ErrOtherNodeRunning)
+	}
+
+	if err := start(n.dir, n.mnemonic, n.config); err != nil {
+		return err
+	}
+
+	activeNode = n
+	return nil
+}
+
+// Stop gracefully shuts the node down.
+func (n *Node) Stop() error {
+	nodeMtx.Lock()
+	defer nodeMtx.Unlock()
+
+	// The node may have been stopped through StopDaemon already.
+	if activeNode != n || !lnd.Running() {
+		return wrapError(lnd.ErrDaemonNotRunning)
+	}
+
+	activeNode = nil
+	return wrapError(lnd.Stop())
+}
+
+// Running returns true if this node is the one currently running.
+func (n *Node) Running() bool {
+	nodeMtx.Lock()
+	defer nodeMtx.Unlock()
+
+	return activeNode == n && lnd.Running()
+}
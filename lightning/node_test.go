@@ -0,0 +1,42 @@
+package lightning
+
+import (
+	"testing"
+)
+
+// TestNodeNotRunning checks that a handle that was never started reports so,
+// rather than acting on whatever lnd runs in the process.
+func TestNodeNotRunning(t *testing.T) {
+	n, err := NewNode("", "", &Config{Network: "regtest"})
+	if err != nil {
+		t.Fatalf("unable to create node: %v", err)
+	}
+
+	if n.Running() {
+		t.Fatalf("node running before it was started")
+	}
+	err = n.Stop()
+	if err == nil || ParseErrorCode(err.Error()) != ErrCodeNotRunning {
+		t.Fatalf("expected not running error, got %v", err)
+	}
+}
+
+// TestNewNodeCopiesConfig checks that the handle keeps its own copy of the
+// config, which changes on neither side affect.
+func TestNewNodeCopiesConfig(t *testing.T) {
+	config := &Config{Network: "regtest", WalletPassword: "old"}
+	n, err := NewNode("", "", config)
+	if err != nil {
+		t.Fatalf("unable to create node: %v", err)
+	}
+
+	n.config.WalletPassword = "new"
+	if config.WalletPassword != "old" {
+		t.Fatalf("node changed the app's config")
+	}
+
+	config.Network = "invalid"
+	if n.config.Network != "regtest" {
+		t.Fatalf("app's change reached the node's config")
+	}
+}
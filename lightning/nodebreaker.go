@@ -0,0 +1,41 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// NodeFailures returns the nodes HTLCs failed at in a row, as counted by the
+// node breaker set up through Config.NodeBreakerThreshold, the most failing
+// first. It's a JSON array of objects with the hex encoded pub_key of the
+// node, its consecutive_failures, the unix time of its last_failure, and the
+// unix time it's excluded from the routes of payments until, excluded_until,
+// or zero if it isn't. The array is empty if the breaker is disabled.
+func NodeFailures() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	nodes, err := lnd.NodeBreakerFailures()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	nodesJSON, err := json.Marshal(nodes)
+	if err != nil {
+		return "", err
+	}
+
+	return string(nodesJSON), nil
+}
+
+// ResetNodeFailures forgets the failures of all nodes, ending the exclusion of
+// those the node breaker excluded, such as once the device is back online.
+func ResetNodeFailures() error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	return wrapError(lnd.ResetNodeBreaker())
+}
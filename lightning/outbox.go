@@ -0,0 +1,95 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcutil"
+)
+
+// queuedPaymentList is the JSON result of ListQueuedPayments.
+type queuedPaymentList struct {
+	Payments []*lnd.QueuedPayment `json:"payments"`
+}
+
+// QueuePayment adds the payment request to the outbox, for payments made
+// while the device has no connectivity. It's paid as soon as the node has a
+// peer connected, and retried whenever a peer reconnects for as long as no
+// route to the destination is found, until the request expires. amount is
+// the amount in satoshis paid to requests without one.
+//
+// It returns the JSON encoded queued payment, with its payment_hash,
+// payment_request, amount, state, attempts, last_error and queued_time. The
+// payment is published with the "outbox" event of SubscribeEvents whenever
+// its state changes, to SENDING, back to QUEUED, or to SUCCEEDED, with its
+// payment_preimage, FAILED, EXPIRED or CANCELLED, after which it leaves the
+// outbox. Queued payments are kept across restarts. The node must run with
+// Config.Outbox set.
+func QueuePayment(paymentRequest string, amount int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if paymentRequest == "" || amount < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"payment request must be set and amount not be "+
+				"negative"))
+	}
+
+	payment, err := lnd.QueuePayment(
+		paymentRequest, btcutil.Amount(amount),
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	paymentJSON, err := json.Marshal(payment)
+	if err != nil {
+		return "", err
+	}
+
+	return string(paymentJSON), nil
+}
+
+// ListQueuedPayments returns the payments in the outbox, JSON encoded under
+// "payments" in the form QueuePayment returns.
+func ListQueuedPayments() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	payments, err := lnd.ListQueuedPayments()
+	if err != nil {
+		return "", wrapError(err)
+	}
+	if payments == nil {
+		payments = []*lnd.QueuedPayment{}
+	}
+
+	listJSON, err := json.Marshal(&queuedPaymentList{Payments: payments})
+	if err != nil {
+		return "", err
+	}
+
+	return string(listJSON), nil
+}
+
+// CancelQueuedPayment removes the payment with the hex encoded hash from the
+// outbox. A payment being sent can't be cancelled.
+func CancelQueuedPayment(paymentHash string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return newError(ErrCodeInvalidArgument, errors.New(
+			"payment hash must be 32 hex encoded bytes"))
+	}
+	var rHash [32]byte
+	copy(rHash[:], hash)
+
+	return wrapError(lnd.CancelQueuedPayment(rHash))
+}
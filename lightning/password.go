@@ -0,0 +1,52 @@
+package lightning
+
+import "github.com/mandelmonkey/lndmobile/lnd"
+
+// PasswordProgress is implemented by the host app to follow the progress of
+// ChangePassword. The stage is one of wallet, macaroons or restart, and
+// completed counts the items done out of the total within that stage. The node
+// is locked while the password changes, so OnProgress must not call into it.
+type PasswordProgress interface {
+	OnProgress(stage string, completed, total int64)
+}
+
+// ChangePassword changes the password the wallet and macaroon database are
+// encrypted with, restarting the node in the process. An empty oldPassword
+// stands for the default password of wallets created without one. The node
+// must be running. From then on it has to be started with the new
+// WalletPassword, which is updated within the copy of the config a running
+// Node holds. Macaroons remain valid, so paired remote apps keep on working.
+// Should the node fail to restart, the error is an ErrCodeNotRunning one
+// saying that the password has changed nonetheless.
+func ChangePassword(oldPassword, newPassword string,
+	callback PasswordProgress) error {
+
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	nodeMtx.Lock()
+	defer nodeMtx.Unlock()
+
+	err := lnd.ChangePassword([]byte(oldPassword), []byte(newPassword),
+		func(stage string, completed, total int64) {
+			if callback != nil {
+				dispatch(func() {
+					callback.OnProgress(
+						stage, completed, total,
+					)
+				})
+			}
+		})
+	if _, ok := err.(*lnd.ErrPasswordChanged); err != nil && !ok {
+		return wrapError(err)
+	}
+
+	// Keep the config of the active node in sync, so it is started with
+	// the new password from now on, even if it failed to restart.
+	if activeNode != nil && activeNode.config != nil {
+		activeNode.config.WalletPassword = newPassword
+	}
+
+	return wrapError(err)
+}
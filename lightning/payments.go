@@ -0,0 +1,165 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// SendPaymentV2 pays the payment request, streaming the state of the payment
+// to the callback as it progresses. amount is the amount in satoshis paid to
+// requests without one, and timeoutSeconds how long routes are tried for, or
+// zero for the default of 60 seconds.
+//
+// outgoingChanIDs, a comma separated list of channel IDs, restricts the
+// channels the payment may leave through, and lastHopPubkey, the hex encoded
+// public key of a node, the peer of the destination it must arrive from,
+// such as to keep payments to the channels with an LSP. Either may be empty
+// to leave it unrestricted. Should the peer of an outgoing channel have
+// several channels with this node, the payment may leave through any of them.
+//
+// Each message is the JSON encoded payment, with its payment_hash, its state,
+// one of IN_FLIGHT, SUCCEEDED or FAILED, and its htlcs. Every HTLC attempt
+// has its state, the path of node public keys it takes, and once failed, the
+// failure code and the failure_source node that returned it. A message is
+// sent when the payment starts, when each attempt starts and resolves, and
+// once the payment succeeded, with its payment_preimage, route and fee_msat,
+// or failed, with its failure_reason, after which the stream ends. Cancelling
+// the handle stops the messages, but not the payment.
+//
+// A failed payment also has its failure_detail, whose reason is one of
+// NO_ROUTE, INSUFFICIENT_BALANCE, INCORRECT_PAYMENT_DETAILS, TIMEOUT or ERROR.
+// Along with its message, it holds the failure_code of the last HTLC attempt,
+// the failure_source node returning it and its failure_source_index along
+// the route, 0 being this node, and the chan_id that failed, if known.
+func SendPaymentV2(paymentRequest string, amount int64, timeoutSeconds int32,
+	outgoingChanIDs, lastHopPubkey string,
+	callback RecvStream) (*StreamHandle, error) {
+
+	if err := checkRunning(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case paymentRequest == "":
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"payment request must be set"))
+	case amount < 0 || timeoutSeconds < 0:
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"amount and timeout must not be negative"))
+	}
+
+	restrictions := &lnd.PaymentRestrictions{}
+	for _, chanID := range splitList(outgoingChanIDs) {
+		id, err := strconv.ParseUint(chanID, 10, 64)
+		if err != nil {
+			return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+				"invalid channel id %v: %v", chanID, err))
+		}
+		restrictions.OutgoingChanIDs = append(
+			restrictions.OutgoingChanIDs, id,
+		)
+	}
+	if lastHopPubkey != "" {
+		lastHop, err := parsePubKey(lastHopPubkey)
+		if err != nil {
+			return nil, err
+		}
+		restrictions.LastHop = lastHop
+	}
+
+	req := &lnrpc.SendRequest{
+		PaymentRequest: paymentRequest,
+		Amt:            amount,
+	}
+	timeout := time.Duration(timeoutSeconds) * time.Second
+
+	stream := newServerStream(callback, nil)
+	runStream(stream, func() error {
+		_, err := lnd.SendPaymentV2(
+			req, timeout, restrictions, pushPaymentStatus(stream),
+		)
+		return err
+	})
+
+	return stream.handle(), nil
+}
+
+// pushPaymentStatus returns the callback pushing the JSON encoded status of a
+// payment onto the stream.
+func pushPaymentStatus(stream *serverStream) func(*lnd.PaymentStatus) {
+	return func(status *lnd.PaymentStatus) {
+		statusJSON, err := json.Marshal(status)
+		if err != nil {
+			return
+		}
+		stream.queue.push("", string(statusJSON))
+	}
+}
+
+// TrackPayment streams the state of the payment with the hex encoded hash,
+// sent through SendPaymentV2, in the form SendPaymentV2 does, until it
+// succeeded or failed. It lets the app pick up a payment again after it was
+// restarted. A payment left in flight when the node last stopped succeeds
+// once its preimage is learned, with no route or fee, and fails once none of
+// its HTLCs can remain, which may wait for force closed channels to resolve.
+func TrackPayment(paymentHash string, callback RecvStream) (*StreamHandle,
+	error) {
+
+	if err := checkRunning(); err != nil {
+		return nil, err
+	}
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return nil, newError(ErrCodeInvalidArgument, errors.New(
+			"payment hash must be 32 hex encoded bytes"))
+	}
+	var rHash [32]byte
+	copy(rHash[:], hash)
+
+	stream := newServerStream(callback, nil)
+	runStream(stream, func() error {
+		err := lnd.TrackPayment(
+			rHash, stream.ctx.Done(), pushPaymentStatus(stream),
+		)
+		if err != nil {
+			return err
+		}
+
+		// Tracking ends early when the stream is cancelled.
+		return stream.ctx.Err()
+	})
+
+	return stream.handle(), nil
+}
+
+// TrackPayments streams the state of every payment sent through SendPaymentV2
+// that is in flight, as TrackPayment does, until all of them succeeded or
+// failed. Messages of different payments are told apart by their
+// payment_hash.
+func TrackPayments(callback RecvStream) (*StreamHandle, error) {
+	if err := checkRunning(); err != nil {
+		return nil, err
+	}
+
+	stream := newServerStream(callback, nil)
+	runStream(stream, func() error {
+		err := lnd.TrackPayments(
+			stream.ctx.Done(), pushPaymentStatus(stream),
+		)
+		if err != nil {
+			return err
+		}
+
+		return stream.ctx.Err()
+	})
+
+	return stream.handle(), nil
+}
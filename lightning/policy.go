@@ -0,0 +1,80 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// channelPolicyUpdate is the response of UpdateChannelPolicy.
+type channelPolicyUpdate struct {
+	ChanPoint     string `json:"chan_point,omitempty"`
+	BaseFeeMsat   int64  `json:"base_fee_msat"`
+	FeeRatePpm    int64  `json:"fee_rate_ppm"`
+	TimeLockDelta int32  `json:"time_lock_delta"`
+
+	// SupportsInboundFees and SupportsHtlcLimits tell the app whether
+	// the policy can hold inbound fees and minimum and maximum HTLC
+	// amounts. The lnd here only updates fees and the time lock delta,
+	// so both are false.
+	SupportsInboundFees bool `json:"supports_inbound_fees"`
+	SupportsHtlcLimits  bool `json:"supports_htlc_limits"`
+}
+
+// UpdateChannelPolicy sets the forwarding policy of the channel at chanPoint,
+// in the format txid:index, or of all channels if it is empty, and announces
+// it to the network. feeRatePpm is the proportional fee in millionths of the
+// amount forwarded, and must be at least 1. The response holds the policy
+// applied, and whether inbound fees and HTLC limits are supported.
+func UpdateChannelPolicy(chanPoint string, baseFeeMsat, feeRatePpm int64,
+	timeLockDelta int32) (string, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return "", err
+	}
+
+	if baseFeeMsat < 0 || feeRatePpm < 0 || timeLockDelta < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"policy values must not be negative"))
+	}
+
+	req := &lnrpc.PolicyUpdateRequest{
+		BaseFeeMsat:   baseFeeMsat,
+		FeeRate:       float64(feeRatePpm) / 1e6,
+		TimeLockDelta: uint32(timeLockDelta),
+	}
+	if chanPoint == "" {
+		req.Scope = &lnrpc.PolicyUpdateRequest_Global{Global: true}
+	} else {
+		outPoint, err := parseOutPoint(chanPoint)
+		if err != nil {
+			return "", err
+		}
+		req.Scope = &lnrpc.PolicyUpdateRequest_ChanPoint{
+			ChanPoint: &lnrpc.ChannelPoint{
+				FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{
+					FundingTxidStr: outPoint.Hash.String(),
+				},
+				OutputIndex: outPoint.Index,
+			},
+		}
+	}
+
+	if _, err := server.UpdateChannelPolicy(nil, req); err != nil {
+		return "", wrapError(err)
+	}
+
+	update, err := json.Marshal(&channelPolicyUpdate{
+		ChanPoint:     chanPoint,
+		BaseFeeMsat:   baseFeeMsat,
+		FeeRatePpm:    feeRatePpm,
+		TimeLockDelta: timeLockDelta,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(update), nil
+}
@@ -0,0 +1,46 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcutil"
+)
+
+// Probe tests whether amount satoshis can be paid to the hex encoded node
+// public key, without paying anything, so the fee of a payment can be shown
+// before the user confirms it. Up to three of the cheapest routes are tried
+// with a payment hash nobody can settle.
+//
+// It returns JSON with whether the destination is reachable, the fee_msat of
+// the cheapest route that reached it, the success_probability, which is the
+// share of the routes tried that did, and the routes, each with whether it
+// was reachable, or its failure and failure_source.
+func Probe(dest string, amount int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if amount <= 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"amount must be positive"))
+	}
+
+	destPub, err := parsePubKey(dest)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := lnd.Probe(destPub, btcutil.Amount(amount))
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultJSON), nil
+}
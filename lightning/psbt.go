@@ -0,0 +1,150 @@
+package lightning
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// decodePsbt decodes a base64 encoded PSBT.
+func decodePsbt(psbt string) ([]byte, error) {
+	packet, err := base64.StdEncoding.DecodeString(psbt)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode psbt: %v", err))
+	}
+
+	return packet, nil
+}
+
+// marshalPsbtResult encodes the result of a PSBT call as JSON, in which the
+// PSBT is base64 encoded.
+func marshalPsbtResult(result interface{}) (string, error) {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultJSON), nil
+}
+
+// FundPsbt funds a PSBT from the wallet's default account, adding inputs and
+// change, and locks the spent outputs until the PSBT is published or
+// released. Either psbt, a base64 encoded PSBT whose outputs are funded, or
+// payments, a comma separated list of address=amount pairs with the amounts in
+// satoshis, must be given. If the PSBT already has inputs, those are spent
+// instead of coins picked by the coin control, which may be nil for the
+// defaults. The fee rate is satPerByte satoshis per vbyte, or a rate estimated
+// to confirm within six blocks if it is zero.
+//
+// The response holds the funded base64 encoded PSBT, the index of the change
+// output or -1 if there is none, the fee and the locked outpoints.
+func FundPsbt(psbt, payments string, satPerByte int64,
+	control *CoinControl) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if (psbt == "") == (payments == "") {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"either a psbt or payments must be given"))
+	}
+	if satPerByte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	var packet []byte
+	if psbt != "" {
+		var err error
+		packet, err = decodePsbt(psbt)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		addrToAmount, err := parsePayments(payments)
+		if err != nil {
+			return "", err
+		}
+		packet, err = lnd.PsbtTemplate(addrToAmount)
+		if err != nil {
+			return "", newError(ErrCodeInvalidArgument, err)
+		}
+	}
+
+	c, err := parseCoinControl(control)
+	if err != nil {
+		return "", err
+	}
+
+	funded, err := lnd.FundPsbt(packet, satPerByte, c)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalPsbtResult(funded)
+}
+
+// SignPsbt signs the inputs of the base64 encoded PSBT that spend the wallet's
+// outputs, leaving the others, such as those of a hardware wallet, unsigned.
+// The response holds the updated PSBT along with the indexes of the inputs
+// signed.
+func SignPsbt(psbt string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	packet, err := decodePsbt(psbt)
+	if err != nil {
+		return "", err
+	}
+
+	signed, err := lnd.SignPsbt(packet)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalPsbtResult(signed)
+}
+
+// FinalizePsbt signs the inputs of the base64 encoded PSBT that spend the
+// wallet's outputs, finalizes all inputs and extracts the signed transaction,
+// publishing it if requested. Inputs the wallet can't sign must already be
+// signed, for example by a hardware wallet. The response holds the finalized
+// PSBT, along with the hex encoded transaction and its id.
+func FinalizePsbt(psbt string, publish bool) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	packet, err := decodePsbt(psbt)
+	if err != nil {
+		return "", err
+	}
+
+	finalized, err := lnd.FinalizePsbt(packet, publish)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return marshalPsbtResult(finalized)
+}
+
+// ReleasePsbt unlocks the outputs spent by a base64 encoded PSBT funded
+// through FundPsbt, once it is abandoned.
+func ReleasePsbt(psbt string) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	packet, err := decodePsbt(psbt)
+	if err != nil {
+		return err
+	}
+
+	return wrapError(lnd.ReleasePsbt(packet))
+}
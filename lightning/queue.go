@@ -0,0 +1,236 @@
+package lightning
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// The overflow policies of a stream, deciding what happens once its delivery
+// queue is full.
+const (
+	// OverflowBlock makes the daemon wait for the app to catch up, so no
+	// message is ever lost.
+	OverflowBlock = iota
+
+	// OverflowDropOldest discards the oldest queued message to make room
+	// for the new one.
+	OverflowDropOldest
+
+	// OverflowDropNewest discards the new message.
+	OverflowDropNewest
+)
+
+// defaultQueueSize is the number of messages a stream queues by default while
+// the app is busy handling earlier ones.
+const defaultQueueSize = 1000
+
+// StreamOptions control how the messages of a subscription are delivered to
+// the app. Messages are queued between the daemon and the RecvStream, so a
+// slow callback doesn't stall the daemon, while the queue bound keeps a flood
+// of messages, e.g. while the graph syncs, from exhausting the app's memory.
+type StreamOptions struct {
+	// QueueSize is the maximum number of messages waiting to be delivered.
+	QueueSize int
+
+	// Overflow is the policy applied once the queue is full, one of
+	// OverflowBlock, OverflowDropOldest or OverflowDropNewest.
+	Overflow int
+
+	// Coalesce is a comma separated list of event types of which only the
+	// latest is kept while waiting to be delivered, such as chain_sync.
+	// It only applies to SubscribeEvents.
+	Coalesce string
+}
+
+// NewStreamOptions returns the default options, which never drop a message
+// and coalesce chain sync and wallet recovery progress.
+func NewStreamOptions() *StreamOptions {
+	return &StreamOptions{
+		QueueSize: defaultQueueSize,
+		Overflow:  OverflowBlock,
+		Coalesce:  "chain_sync,recovery",
+	}
+}
+
+// coalesced returns true if only the latest pending event of the given type
+// should be kept.
+func (o *StreamOptions) coalesced(eventType string) bool {
+	for _, t := range splitList(o.Coalesce) {
+		if t == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queuedMsg is a message waiting to be delivered. Messages with a non-empty
+// key replace a pending message with the same key.
+type queuedMsg struct {
+	key string
+	msg string
+}
+
+// deliveryQueue hands the messages of a stream to the app from its own
+// goroutine, in the order they were pushed. Once the stream terminates, the
+// callback's OnError is called after all pending messages were delivered.
+type deliveryQueue struct {
+	opts     *StreamOptions
+	callback RecvStream
+
+	// ctx is the context of the stream. Once it is cancelled, pending
+	// messages are discarded rather than delivered.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mtx     sync.Mutex
+	pending []*queuedMsg
+	done    bool
+	termErr error
+
+	// signal is poked whenever a message is pushed or the stream
+	// terminates, and space whenever a message is popped.
+	signal chan struct{}
+	space  chan struct{}
+}
+
+// newDeliveryQueue creates the queue and starts delivering to the callback.
+func newDeliveryQueue(ctx context.Context, cancel context.CancelFunc,
+	callback RecvStream, opts *StreamOptions) *deliveryQueue {
+
+	// Copy the options, as the app may keep on modifying them.
+	if opts == nil {
+		opts = NewStreamOptions()
+	}
+	optsCopy := *opts
+	if optsCopy.QueueSize <= 0 {
+		optsCopy.QueueSize = defaultQueueSize
+	}
+
+	q := &deliveryQueue{
+		opts:     &optsCopy,
+		callback: callback,
+		ctx:      ctx,
+		cancel:   cancel,
+		signal:   make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+	}
+	go q.deliver()
+
+	return q
+}
+
+// poke wakes up whoever waits on the channel, without blocking.
+func poke(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// push queues the message for delivery, applying the overflow policy if the
+// queue is full. An error is returned if the stream was cancelled.
+func (q *deliveryQueue) push(key, msg string) error {
+	for {
+		q.mtx.Lock()
+
+		if key != "" {
+			for _, pending := range q.pending {
+				if pending.key == key {
+					pending.msg = msg
+					q.mtx.Unlock()
+					return nil
+				}
+			}
+		}
+
+		if len(q.pending) >= q.opts.QueueSize {
+			switch q.opts.Overflow {
+			case OverflowDropOldest:
+				q.pending[0] = nil
+				q.pending = q.pending[1:]
+
+			case OverflowDropNewest:
+				q.mtx.Unlock()
+				return nil
+
+			default:
+				q.mtx.Unlock()
+
+				select {
+				case <-q.space:
+					continue
+				case <-q.ctx.Done():
+					return q.ctx.Err()
+				}
+			}
+		}
+
+		q.pending = append(q.pending, &queuedMsg{key: key, msg: msg})
+		q.mtx.Unlock()
+
+		poke(q.signal)
+		return nil
+	}
+}
+
+// finish terminates the stream with the passed error, which is delivered to
+// the callback once all pending messages have been.
+func (q *deliveryQueue) finish(err error) {
+	q.mtx.Lock()
+	q.done = true
+	q.termErr = err
+	q.mtx.Unlock()
+
+	poke(q.signal)
+}
+
+// deliver hands queued messages to the callback until the stream terminates.
+// It must be run as a goroutine.
+func (q *deliveryQueue) deliver() {
+	// Release the stream's context once the app has been notified of
+	// its termination.
+	defer q.cancel()
+	defer func() {
+		if reason := recover(); reason != nil {
+			reportCrash("RPCS", reason, debug.Stack())
+		}
+	}()
+
+	for {
+		q.mtx.Lock()
+
+		// The app is no longer interested in messages of a cancelled
+		// stream.
+		if q.ctx.Err() != nil {
+			q.pending = nil
+		}
+
+		if len(q.pending) == 0 {
+			done, termErr := q.done, q.termErr
+			q.mtx.Unlock()
+
+			if done {
+				dispatch(func() {
+					q.callback.OnError(termErr)
+				})
+				return
+			}
+
+			<-q.signal
+			continue
+		}
+
+		next := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		q.mtx.Unlock()
+
+		poke(q.space)
+		dispatch(func() {
+			q.callback.OnResponse(next.msg)
+		})
+	}
+}
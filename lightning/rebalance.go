@@ -0,0 +1,55 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcutil"
+)
+
+// Rebalance moves amount satoshis of local balance from outgoingChan to
+// incomingChan, both channel points in the format txid:index, by paying this
+// node over a circular route through the network, for at most feeLimit
+// satoshis in fees. Up to five routes are tried. The lnd here can't split
+// payments, so large amounts may need several smaller calls.
+//
+// It returns JSON with whether it succeeded, the payment_hash, the
+// payment_preimage and fee_msat paid if it did, the error if it didn't, and
+// the attempts made, each with its route and any error and failure_source.
+func Rebalance(outgoingChan, incomingChan string, amount,
+	feeLimit int64) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if amount <= 0 || feeLimit < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"amount must be positive and fee limit not negative"))
+	}
+
+	outgoing, err := parseOutPoint(outgoingChan)
+	if err != nil {
+		return "", err
+	}
+	incoming, err := parseOutPoint(incomingChan)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := lnd.Rebalance(
+		*outgoing, *incoming, btcutil.Amount(amount),
+		btcutil.Amount(feeLimit),
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultJSON), nil
+}
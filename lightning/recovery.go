@@ -0,0 +1,73 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// RecoverWallet scans the chain from startHeight for funds of the seed the
+// node was started with, so a wallet restored from its mnemonic finds the
+// funds it held before. The first recoveryWindow addresses of every address
+// type are looked for, and the window is extended for as long as funds keep
+// turning up in it. Only the addresses up to the last one that received
+// funds are marked as used, so recovering doesn't use up the wallet's unused
+// addresses. The call blocks until the wallet has recorded everything found,
+// while progress is delivered through SubscribeRecoveryProgress.
+func RecoverWallet(recoveryWindow, startHeight int32) error {
+	if err := checkRunning(); err != nil {
+		return err
+	}
+
+	return wrapError(lnd.RecoverWallet(int(recoveryWindow), startHeight))
+}
+
+// SubscribeRecoveryProgress streams the progress of RecoverWallet, as JSON
+// objects holding the scanned and best heights, the recovery round, the
+// number of addresses found, the recovered balance and whether the recovery
+// has finished. Like SubscribeEvents, the subscription may be made before the
+// recovery starts. The options may be nil to use the defaults, under which
+// only the latest pending progress is delivered.
+func SubscribeRecoveryProgress(callback RecvStream,
+	opts *StreamOptions) (*StreamHandle, error) {
+
+	if opts == nil {
+		opts = NewStreamOptions()
+	}
+
+	client := lnd.SubscribeEvents()
+	stream := newServerStream(callback, opts)
+
+	runStream(stream, func() error {
+		defer client.Cancel()
+
+		for {
+			select {
+			case event := <-client.Events:
+				if event.Type != lnd.EventRecovery {
+					continue
+				}
+
+				progress, err := json.Marshal(event.Payload)
+				if err != nil {
+					return err
+				}
+
+				var key string
+				if opts.coalesced(event.Type) {
+					key = event.Type
+				}
+
+				err = stream.queue.push(key, string(progress))
+				if err != nil {
+					return err
+				}
+
+			case <-stream.ctx.Done():
+				return stream.ctx.Err()
+			}
+		}
+	})
+
+	return stream.handle(), nil
+}
@@ -0,0 +1,43 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+)
+
+// ReplaceTransaction replaces the unconfirmed transaction with the given id by
+// one paying newSatPerVbyte satoshis per vbyte, or a rate estimated to confirm
+// within six blocks if it is zero. The outputs are kept, and the higher fee is
+// paid out of the change. Transactions sent through SendCoins, as well as the
+// children published by BumpFee, can be replaced. The replacement is returned
+// JSON encoded.
+func ReplaceTransaction(txid string, newSatPerVbyte int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if newSatPerVbyte < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return "", newError(ErrCodeInvalidArgument, err)
+	}
+
+	replacement, err := lnd.ReplaceTransaction(*hash, newSatPerVbyte)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	replacementJSON, err := json.Marshal(replacement)
+	if err != nil {
+		return "", err
+	}
+
+	return string(replacementJSON), nil
+}
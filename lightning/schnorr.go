@@ -0,0 +1,65 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// SignSchnorr signs the hex encoded 32 byte message with the key derived at
+// the family and index, returning the JSON encoded BIP 340 signature and the
+// x-only public key it verifies against. The family must be at least
+// MinAppKeyFamily.
+func SignSchnorr(msgHex string, family, index int32) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	msg, err := hex.DecodeString(msgHex)
+	if err != nil {
+		return "", newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode message: %v", err))
+	}
+
+	sig, err := lnd.SignSchnorr(msg, family, index)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	sigJSON, err := json.Marshal(sig)
+	if err != nil {
+		return "", err
+	}
+
+	return string(sigJSON), nil
+}
+
+// VerifySchnorr returns whether the hex encoded BIP 340 signature over the hex
+// encoded 32 byte message is valid for the hex encoded x-only public key. It
+// works whether or not the node is running.
+func VerifySchnorr(msgHex, sigHex, pubKeyHex string) (bool, error) {
+	msg, err := hex.DecodeString(msgHex)
+	if err != nil {
+		return false, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode message: %v", err))
+	}
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode signature: %v", err))
+	}
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return false, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode public key: %v", err))
+	}
+
+	valid, err := lnd.VerifySchnorr(msg, sig, pubKey)
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	return valid, nil
+}
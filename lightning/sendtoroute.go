@@ -0,0 +1,100 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcutil"
+)
+
+// BuildRoute returns the JSON encoded route paying amount satoshis to the last
+// of hopPubkeys, a comma separated list of hex encoded node public keys,
+// through the others in order, with finalCLTVDelta blocks for it to settle
+// in. Between each two nodes the channel forwarding for the lowest fee is
+// taken, except that outgoingChanID, if not zero, picks the channel to the
+// first node.
+//
+// The route holds its total_time_lock, total_amt_msat, total_fees_msat and
+// hops, each with its chan_id, the pub_key of the node it leads to, its
+// amt_to_forward_msat, fee_msat and expiry. It may be edited before being
+// passed to SendToRoute.
+func BuildRoute(amount int64, hopPubkeys string, outgoingChanID int64,
+	finalCLTVDelta int32) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if amount <= 0 || finalCLTVDelta < 0 || finalCLTVDelta > 0xffff {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"amount must be positive and final cltv delta "+
+				"within 0 and 65535"))
+	}
+
+	var nodes []*btcec.PublicKey
+	for _, hopPubkey := range splitList(hopPubkeys) {
+		node, err := parsePubKey(hopPubkey)
+		if err != nil {
+			return "", err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"at least one hop must be given"))
+	}
+
+	route, err := lnd.BuildRoute(
+		btcutil.Amount(amount), nodes, uint64(outgoingChanID),
+		uint16(finalCLTVDelta),
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	routeJSON, err := json.Marshal(route)
+	if err != nil {
+		return "", err
+	}
+
+	return string(routeJSON), nil
+}
+
+// SendToRoute pays the hex encoded payment hash over the JSON encoded route,
+// as returned by BuildRoute, without any retries. It returns JSON with
+// whether the payment succeeded and its payment_preimage if it did, or its
+// failure, failure_source and failure_detail, as with SendPaymentV2, if it
+// didn't.
+func SendToRoute(paymentHash, route string) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != 32 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"payment hash must be 32 hex encoded bytes"))
+	}
+	var rHash [32]byte
+	copy(rHash[:], hash)
+
+	paymentRoute := &lnd.PaymentRoute{}
+	if err := json.Unmarshal([]byte(route), paymentRoute); err != nil {
+		return "", newError(ErrCodeInvalidArgument, err)
+	}
+
+	result, err := lnd.SendToRoute(rHash, paymentRoute)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultJSON), nil
+}
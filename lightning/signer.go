@@ -0,0 +1,27 @@
+package lightning
+
+import (
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// ExternalSigner is implemented by the app to sign with on-chain keys held
+// outside of the wallet, such as on a Ledger or Trezor reached over NFC or USB,
+// or in the device's secure element. Keys are named by their BIP 32 derivation
+// path, such as m/84'/0'/0'/0/5.
+type ExternalSigner interface {
+	// PubKey returns the 33 byte compressed public key at the derivation
+	// path, or an error if the signer doesn't hold it.
+	PubKey(path string) ([]byte, error)
+
+	// SignDigest returns the DER encoded ECDSA signature of the key at the
+	// derivation path over the 32 byte digest.
+	SignDigest(path string, digest []byte) ([]byte, error)
+}
+
+// SetExternalSigner routes the signing of PSBT inputs the wallet holds no keys
+// for to the signer, through SignPsbt and FinalizePsbt. Such inputs must carry
+// the BIP 32 derivation of their key, and the output they spend. Passing nil
+// stops the routing.
+func SetExternalSigner(signer ExternalSigner) {
+	lnd.SetExternalSigner(signer)
+}
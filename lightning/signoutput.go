@@ -0,0 +1,116 @@
+package lightning
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/mandelmonkey/lndmobile/lnd"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+)
+
+// SignDescriptor describes the transaction input signed by SignOutputRaw.
+// Scripts and the tweak are hex encoded.
+type SignDescriptor struct {
+	// KeyFamily and KeyIndex locate the signing key, as with
+	// DerivePrivKey. The family must be at least MinAppKeyFamily.
+	KeyFamily int32
+	KeyIndex  int32
+
+	// SingleTweak is an optional 32 byte tweak added to the private key,
+	// for keys derived as pubkey + tweak*G.
+	SingleTweak string
+
+	// WitnessScript is the script the signature commits to. For a pay to
+	// witness key hash output, it is the matching pay to key hash script.
+	WitnessScript string
+
+	// Value and PkScript are those of the output spent by the input.
+	Value    int64
+	PkScript string
+
+	// SigHash is the sighash type, or 0 for SIGHASH_ALL.
+	SigHash int32
+
+	// InputIndex is the index of the input within the transaction.
+	InputIndex int32
+}
+
+// NewSignDescriptor returns an empty SignDescriptor.
+func NewSignDescriptor() *SignDescriptor {
+	return &SignDescriptor{}
+}
+
+// decodeHexField decodes the hex encoded field of a call.
+func decodeHexField(field, name string) ([]byte, error) {
+	b, err := hex.DecodeString(field)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode %s: %v", name, err))
+	}
+
+	return b, nil
+}
+
+// SignOutputRaw signs an input of the hex encoded raw transaction with a key
+// derived from the node's seed, for custom script protocols such as
+// submarine swaps. It returns the hex encoded DER signature, without its
+// sighash flag, for the app to assemble into the input's witness.
+func SignOutputRaw(rawTx string, desc *SignDescriptor) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if desc == nil {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"a sign descriptor must be given"))
+	}
+
+	txBytes, err := decodeHexField(rawTx, "transaction")
+	if err != nil {
+		return "", err
+	}
+	tx := wire.NewMsgTx(2)
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return "", newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode transaction: %v", err))
+	}
+
+	witnessScript, err := decodeHexField(desc.WitnessScript,
+		"witness script")
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := decodeHexField(desc.PkScript, "pk script")
+	if err != nil {
+		return "", err
+	}
+	var singleTweak []byte
+	if desc.SingleTweak != "" {
+		singleTweak, err = decodeHexField(desc.SingleTweak, "tweak")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	hashType := txscript.SigHashType(desc.SigHash)
+	if hashType == 0 {
+		hashType = txscript.SigHashAll
+	}
+
+	sig, err := lnd.SignOutputRaw(tx, &lnwallet.SignDescriptor{
+		SingleTweak:   singleTweak,
+		WitnessScript: witnessScript,
+		Output:        wire.NewTxOut(desc.Value, pkScript),
+		HashType:      hashType,
+		InputIndex:    int(desc.InputIndex),
+	}, desc.KeyFamily, desc.KeyIndex)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	return hex.EncodeToString(sig), nil
+}
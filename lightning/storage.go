@@ -0,0 +1,45 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// StorageUsage reports the disk space used by the node within dir, broken
+// down into channel.db, wallet.db, the neutrino database, block and filter
+// headers, macaroons and logs. The node doesn't need to be running.
+func StorageUsage(dir string) (string, error) {
+	usage, err := lnd.DiskUsage(dir)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	jsonBytes, err := json.Marshal(usage)
+	if err != nil {
+		return "", err
+	}
+
+	return string(jsonBytes), nil
+}
+
+// MigrationProgress is implemented by the host app to follow the progress of
+// MigrateDataDir.
+type MigrationProgress interface {
+	OnProgress(copiedBytes, totalBytes int64)
+}
+
+// MigrateDataDir moves the node's data from oldDir to newDir, e.g. to or from
+// external storage. Every file is verified after being copied, and on failure
+// the partial copy is removed leaving oldDir untouched. The node must be
+// stopped, and afterwards started with newDir.
+func MigrateDataDir(oldDir, newDir string, callback MigrationProgress) error {
+	err := lnd.MigrateDataDir(oldDir, newDir, func(copied, total int64) {
+		if callback != nil {
+			dispatch(func() {
+				callback.OnProgress(copied, total)
+			})
+		}
+	})
+	return wrapError(err)
+}
@@ -0,0 +1,370 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// RecvStream is implemented by the host app in order to receive the messages
+// of a streaming call. Every message is delivered to OnResponse as a JSON
+// string. Once the stream terminates OnError is called exactly once, with
+// io.EOF if the stream was closed cleanly by the daemon.
+type RecvStream interface {
+	OnResponse(string)
+	OnError(error)
+}
+
+// StreamHandle is returned to the host app for every streaming call, so the
+// stream can be torn down once it is no longer needed, e.g. when a screen is
+// dismissed.
+type StreamHandle struct {
+	cancel context.CancelFunc
+}
+
+// Cancel tears down the stream, releasing its resources. The RecvStream is
+// then terminated with an ErrCodeCancelled error, unless the stream had
+// already terminated.
+func (h *StreamHandle) Cancel() {
+	h.cancel()
+}
+
+// SendStream is returned to the host app for calls that also stream requests
+// from the client to the daemon, such as SendPayment.
+type SendStream struct {
+	reqs chan *lnrpc.SendRequest
+
+	quit     chan struct{}
+	stopOnce sync.Once
+
+	cancel context.CancelFunc
+}
+
+// Send decodes the passed JSON encoded request and pushes it onto the stream.
+func (s *SendStream) Send(reqJSON string) error {
+	req := &lnrpc.SendRequest{}
+	if err := jsonpb.UnmarshalString(reqJSON, req); err != nil {
+		return newError(ErrCodeInvalidArgument, err)
+	}
+
+	select {
+	case s.reqs <- req:
+		return nil
+	case <-s.quit:
+		return fmt.Errorf("stream has been stopped")
+	}
+}
+
+// Stop closes the client side of the stream. Any responses still in flight
+// will continue to be delivered to the RecvStream.
+func (s *SendStream) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.quit)
+	})
+	return nil
+}
+
+// Cancel tears down the stream without waiting for the responses still in
+// flight.
+func (s *SendStream) Cancel() {
+	s.cancel()
+}
+
+// serverStream is an in-process stand in for the server side of a gRPC
+// stream. The rpcServer never touches the stream metadata, so the embedded
+// grpc.ServerStream is left nil.
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  *deliveryQueue
+}
+
+// Context returns the context of the stream.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// send marshals the message to JSON and hands it to the host app.
+func (s *serverStream) send(resp proto.Message) error {
+	jsonString, err := convertToJSON(resp)
+	if err != nil {
+		return err
+	}
+
+	return s.queue.push("", jsonString)
+}
+
+type invoiceStream struct{ *serverStream }
+
+func (s invoiceStream) Send(m *lnrpc.Invoice) error { return s.send(m) }
+
+type transactionStream struct{ *serverStream }
+
+func (s transactionStream) Send(m *lnrpc.Transaction) error { return s.send(m) }
+
+type graphStream struct{ *serverStream }
+
+func (s graphStream) Send(m *lnrpc.GraphTopologyUpdate) error { return s.send(m) }
+
+type openChannelStream struct{ *serverStream }
+
+func (s openChannelStream) Send(m *lnrpc.OpenStatusUpdate) error { return s.send(m) }
+
+type closeChannelStream struct{ *serverStream }
+
+func (s closeChannelStream) Send(m *lnrpc.CloseStatusUpdate) error { return s.send(m) }
+
+type paymentStream struct {
+	*serverStream
+	client *SendStream
+}
+
+func (s paymentStream) Send(m *lnrpc.SendResponse) error { return s.send(m) }
+
+// Recv blocks until the host app sends the next payment, returning io.EOF once
+// the client side of the stream has been stopped.
+func (s paymentStream) Recv() (*lnrpc.SendRequest, error) {
+	select {
+	case req := <-s.client.reqs:
+		return req, nil
+	case <-s.client.quit:
+		return nil, io.EOF
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+// newServerStream creates the in-process stream that feeds the callback. The
+// options may be nil to use the defaults.
+func newServerStream(callback RecvStream, opts *StreamOptions) *serverStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &serverStream{
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  newDeliveryQueue(ctx, cancel, callback, opts),
+	}
+}
+
+// handle returns the handle through which the host app may cancel the stream.
+func (s *serverStream) handle() *StreamHandle {
+	return &StreamHandle{cancel: s.cancel}
+}
+
+// runStream executes the passed streaming handler in its own goroutine,
+// reporting its termination to the callback once all its messages have been
+// delivered. Errors are passed on with their error code attached.
+func runStream(stream *serverStream, handler func() error) {
+	go func() {
+		// If the handler panics, the panic is reported to the crash
+		// callback and the stream is terminated with an error.
+		defer func() {
+			if reason := recover(); reason != nil {
+				reportCrash("RPCS", reason, debug.Stack())
+				stream.queue.finish(wrapError(fmt.Errorf(
+					"stream terminated by panic: %v",
+					reason)))
+			}
+		}()
+
+		err := handler()
+		if err == nil {
+			stream.queue.finish(io.EOF)
+			return
+		}
+		stream.queue.finish(wrapError(err))
+	}()
+}
+
+// SubscribeInvoices streams every invoice settled by the daemon, until the
+// returned handle is cancelled. The options may be nil to use the defaults.
+func SubscribeInvoices(callback RecvStream,
+	opts *StreamOptions) (*StreamHandle, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &lnrpc.InvoiceSubscription{}
+	stream := invoiceStream{newServerStream(callback, opts)}
+
+	runStream(stream.serverStream, func() error {
+		return server.SubscribeInvoices(req, stream)
+	})
+
+	return stream.handle(), nil
+}
+
+// SubscribeTransactions streams every wallet transaction, both when it is
+// first seen unconfirmed and once it confirms, so balances can be updated
+// without polling GetTransactions. Confirmed transactions carry the hash and
+// height of their block along with the number of confirmations, unconfirmed
+// ones have none of these set.
+func SubscribeTransactions(callback RecvStream,
+	opts *StreamOptions) (*StreamHandle, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &lnrpc.GetTransactionsRequest{}
+	stream := transactionStream{newServerStream(callback, opts)}
+
+	runStream(stream.serverStream, func() error {
+		return server.SubscribeTransactions(req, stream)
+	})
+
+	return stream.handle(), nil
+}
+
+// SubscribeChannelGraph streams node, channel and channel close updates from
+// the channel graph.
+func SubscribeChannelGraph(callback RecvStream,
+	opts *StreamOptions) (*StreamHandle, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &lnrpc.GraphTopologySubscription{}
+	stream := graphStream{newServerStream(callback, opts)}
+
+	runStream(stream.serverStream, func() error {
+		return server.SubscribeChannelGraph(req, stream)
+	})
+
+	return stream.handle(), nil
+}
+
+// OpenChannel opens a channel to the given node, streaming the pending and
+// open status updates of the channel.
+// The coins funding the channel, and its change, are as chosen by the coin
+// control, which may be nil for the defaults.
+func OpenChannel(nodePubKeyHex string, localAmount int64,
+	control *CoinControl, callback RecvStream) (*StreamHandle, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	nodePubKey, err := hex.DecodeString(nodePubKeyHex)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, err)
+	}
+
+	req := &lnrpc.OpenChannelRequest{
+		NodePubkey:         nodePubKey,
+		NodePubkeyString:   nodePubKeyHex,
+		LocalFundingAmount: localAmount,
+		Private:            false,
+	}
+
+	stream := openChannelStream{newServerStream(callback, nil)}
+	stream.ctx, err = coinControlContext(stream.ctx, control)
+	if err != nil {
+		stream.cancel()
+		return nil, err
+	}
+
+	runStream(stream.serverStream, func() error {
+		return server.OpenChannel(req, stream)
+	})
+
+	return stream.handle(), nil
+}
+
+// parseChannelPoint parses a channel point in the format txid:index.
+func parseChannelPoint(channelPoint string) (*lnrpc.ChannelPoint, error) {
+	splitPoint := strings.Split(channelPoint, ":")
+	if len(splitPoint) != 2 {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"channel point expected in format: "+
+				"funding_txid:output_index"))
+	}
+
+	index, err := strconv.ParseUint(splitPoint[1], 10, 32)
+	if err != nil {
+		return nil, newError(ErrCodeInvalidArgument, fmt.Errorf(
+			"unable to decode output index: %v", err))
+	}
+
+	return &lnrpc.ChannelPoint{
+		FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{
+			FundingTxidStr: splitPoint[0],
+		},
+		OutputIndex: uint32(index),
+	}, nil
+}
+
+// CloseChannel closes the channel identified by the channel point, given in
+// the format funding_txid:output_index, streaming the close status updates.
+func CloseChannel(channelPoint string, force bool,
+	callback RecvStream) (*StreamHandle, error) {
+
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	chanPoint, err := parseChannelPoint(channelPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &lnrpc.CloseChannelRequest{
+		ChannelPoint: chanPoint,
+		Force:        force,
+	}
+	stream := closeChannelStream{newServerStream(callback, nil)}
+
+	runStream(stream.serverStream, func() error {
+		return server.CloseChannel(req, stream)
+	})
+
+	return stream.handle(), nil
+}
+
+// SendPayment opens a payment stream. Payments are pushed as JSON encoded
+// SendRequests through the returned SendStream, and their results are
+// delivered to the callback.
+//
+// Deprecated: SendPaymentV2 reports the progress of a payment, HTLC attempt by
+// HTLC attempt, rather than just its result.
+func SendPayment(callback RecvStream) (*SendStream, error) {
+	server, err := rpcServer()
+	if err != nil {
+		return nil, err
+	}
+
+	serverStream := newServerStream(callback, nil)
+	client := &SendStream{
+		reqs:   make(chan *lnrpc.SendRequest),
+		quit:   make(chan struct{}),
+		cancel: serverStream.cancel,
+	}
+	stream := paymentStream{
+		serverStream: serverStream,
+		client:       client,
+	}
+
+	runStream(serverStream, func() error {
+		return server.SendPayment(stream)
+	})
+
+	return client, nil
+}
@@ -0,0 +1,183 @@
+package lightning
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// gossipCatchUpTime is how long SyncChunk keeps the node running once the
+// chain backend is synced, giving peers a chance to send us the graph updates
+// we missed.
+const gossipCatchUpTime = 30 * time.Second
+
+// syncPollInterval is how often SyncChunk checks the progress of the chain
+// backend.
+const syncPollInterval = time.Second
+
+// syncChunkStatus is the JSON result of SyncChunk.
+type syncChunkStatus struct {
+	BlockHeight      int32  `json:"block_height"`
+	BlockHash        string `json:"block_hash"`
+	Synced           bool   `json:"synced"`
+	DeadlineExceeded bool   `json:"deadline_exceeded"`
+}
+
+// SyncChunk brings the node up to date within the given number of seconds,
+// for use from background tasks such as iOS' BGProcessingTask or Android's
+// WorkManager. If the node isn't running it is started, synced for as long as
+// the deadline allows, and then stopped again, checkpointing its progress so
+// the next chunk picks up where this one left off. Once the chain is synced
+// the node stays up a little longer to catch up on gossip. A node that was
+// already running is left running. The config may be nil to use lnd.conf
+// within dir. The returned JSON reports the best block reached, and whether
+// the chain is fully synced.
+func SyncChunk(dir, mnemonic string, config *Config,
+	deadlineSeconds int64) (string, error) {
+
+	deadline := time.Now().Add(time.Duration(deadlineSeconds) * time.Second)
+
+	if config != nil {
+		if err := config.Validate(); err != nil {
+			return "", newError(ErrCodeInvalidArgument, err)
+		}
+	}
+
+	nodeMtx.Lock()
+	defer nodeMtx.Unlock()
+
+	wasRunning := lnd.Running()
+	if !wasRunning {
+		if err := start(dir, mnemonic, config); err != nil {
+			return "", err
+		}
+	}
+
+	status, err := waitForSync(deadline)
+
+	// Stopping the node flushes and closes its databases, persisting the
+	// progress made.
+	if !wasRunning {
+		if stopErr := lnd.Stop(); err == nil {
+			err = stopErr
+		}
+	}
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resp, err := json.Marshal(status)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resp), nil
+}
+
+// waitForSync polls the chain backend until it is synced or the deadline
+// passes. Once synced, it waits out the gossip catch up time, bounded by the
+// deadline.
+func waitForSync(deadline time.Time) (*syncChunkStatus, error) {
+	for {
+		state, err := lnd.ChainSyncState()
+		if err != nil {
+			return nil, err
+		}
+
+		status := &syncChunkStatus{
+			BlockHeight: state.BlockHeight,
+			BlockHash:   state.BlockHash,
+			Synced:      state.Synced,
+		}
+
+		remaining := time.Until(deadline)
+		switch {
+		case state.Synced:
+			if remaining > gossipCatchUpTime {
+				remaining = gossipCatchUpTime
+			}
+			time.Sleep(remaining)
+			return status, nil
+
+		case remaining <= 0:
+			status.DeadlineExceeded = true
+			return status, nil
+		}
+
+		if remaining > syncPollInterval {
+			remaining = syncPollInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+// syncProgressKey coalesces the pending messages of SubscribeSyncProgress, so
+// a slow app only receives the latest progress.
+const syncProgressKey = "sync_progress"
+
+// GetSyncProgress returns the JSON encoded sync progress of the chain
+// backend, as streamed by SubscribeSyncProgress.
+func GetSyncProgress() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	progress, err := lnd.SyncProgressState()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resp, err := json.Marshal(progress)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resp), nil
+}
+
+// SubscribeSyncProgress streams the sync progress of the chain backend now
+// and whenever it changes, checked every second, until the returned handle is
+// cancelled or the node stops. Each message is a JSON object with the
+// header_height of the block headers downloaded, the filter_header_height of
+// the compact filter headers, the filter_height the wallet matched filters up
+// to, the best peer_height announced by the num_peers connected peers, the
+// blocks_remaining between the two, and whether the wallet is synced. Only
+// the latest progress waits to be delivered, for progress bars to render.
+func SubscribeSyncProgress(callback RecvStream) (*StreamHandle, error) {
+	if err := checkRunning(); err != nil {
+		return nil, err
+	}
+
+	stream := newServerStream(callback, nil)
+	runStream(stream, func() error {
+		var pushErr error
+		err := lnd.SubscribeSyncProgress(stream.ctx.Done(),
+			func(progress *lnd.SyncProgress) {
+				if pushErr != nil {
+					return
+				}
+
+				resp, err := json.Marshal(progress)
+				if err != nil {
+					pushErr = err
+					return
+				}
+				pushErr = stream.queue.push(
+					syncProgressKey, string(resp),
+				)
+			},
+		)
+		switch {
+		case err != nil:
+			return err
+		case pushErr != nil:
+			return pushErr
+		}
+
+		// The subscription ends early when the stream is cancelled.
+		return stream.ctx.Err()
+	})
+
+	return stream.handle(), nil
+}
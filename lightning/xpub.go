@@ -0,0 +1,29 @@
+package lightning
+
+import (
+	"encoding/json"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// ExportAccountXpubs returns the JSON encoded extended public keys of the
+// wallet's accounts, with their derivation paths, address types and the
+// master key fingerprint, so the node's on-chain funds can be watched from
+// desktop software. The node must have been started with its mnemonic.
+func ExportAccountXpubs() (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	xpubs, err := lnd.ExportAccountXpubs()
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	xpubsJSON, err := json.Marshal(xpubs)
+	if err != nil {
+		return "", err
+	}
+
+	return string(xpubsJSON), nil
+}
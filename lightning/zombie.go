@@ -0,0 +1,80 @@
+package lightning
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mandelmonkey/lndmobile/lnd"
+)
+
+// ListZombieChannels returns a JSON array of the open channels whose peers are
+// offline, and were last seen over thresholdSeconds ago, with those offline
+// longest first. Each holds the chan_point, remote_pubkey, last_seen as a unix
+// time, capacity, local_balance, the csv_delay in blocks the local balance is
+// time locked for once force closed, and the estimated sweep_fee and
+// recoverable_amount.
+func ListZombieChannels(thresholdSeconds int64) (string, error) {
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	if thresholdSeconds < 0 {
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"threshold must not be negative"))
+	}
+
+	zombies, err := lnd.ZombieChannels(
+		time.Duration(thresholdSeconds) * time.Second,
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	zombiesJSON, err := json.Marshal(zombies)
+	if err != nil {
+		return "", err
+	}
+
+	return string(zombiesJSON), nil
+}
+
+// CloseZombieChannels force closes up to maxChannels of the channels
+// ListZombieChannels returns, those offline longest first, so stranded funds
+// can be recovered a batch at a time. satPerByte is the lowest fee rate the
+// time locked outputs are swept at, as with ForceCloseAll. It returns the same
+// JSON array as ForceCloseAll.
+func CloseZombieChannels(thresholdSeconds int64, maxChannels int32,
+	satPerByte int64) (string, error) {
+
+	if err := checkRunning(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case thresholdSeconds < 0:
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"threshold must not be negative"))
+	case maxChannels <= 0:
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"max channels must be positive"))
+	case satPerByte < 0:
+		return "", newError(ErrCodeInvalidArgument, errors.New(
+			"fee rate must not be negative"))
+	}
+
+	results, err := lnd.CloseZombieChannels(
+		time.Duration(thresholdSeconds)*time.Second,
+		int(maxChannels), satPerByte,
+	)
+	if err != nil {
+		return "", wrapError(err)
+	}
+
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultsJSON), nil
+}
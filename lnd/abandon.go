@@ -0,0 +1,150 @@
+package lnd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/roasbeef/btcd/wire"
+)
+
+// abandonTokenTTL is how long a token returned by AbandonToken can be used.
+const abandonTokenTTL = time.Minute
+
+var (
+	// ErrAbandonDisabled is returned when abandoning a channel without
+	// the node running with unsafe-abandonchannel.
+	ErrAbandonDisabled = errors.New("abandoning channels requires " +
+		"unsafe-abandonchannel")
+
+	// ErrAbandonToken is returned for an abandon token that wasn't issued
+	// for the channel, or has expired.
+	ErrAbandonToken = errors.New("invalid or expired abandon token")
+
+	// ErrNotPendingChannel is returned when abandoning a channel that
+	// isn't pending open.
+	ErrNotPendingChannel = errors.New("only pending channels can be " +
+		"abandoned")
+)
+
+// abandonToken is a token issued by AbandonToken.
+type abandonToken struct {
+	chanPoint wire.OutPoint
+	token     string
+	expiry    time.Time
+}
+
+var (
+	// abandonTokenMtx guards pendingAbandonToken.
+	abandonTokenMtx sync.Mutex
+
+	// pendingAbandonToken is the last token issued, or nil.
+	pendingAbandonToken *abandonToken
+)
+
+// runningChanDB returns the channel database of the running daemon.
+func runningChanDB() (*channeldb.DB, error) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	if activeDaemon == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return activeDaemon.chanDB, nil
+}
+
+// fetchPendingChannel returns the pending open channel at the channel point.
+func fetchPendingChannel(
+	chanPoint wire.OutPoint) (*channeldb.OpenChannel, error) {
+
+	chanDB, err := runningChanDB()
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := chanDB.FetchPendingChannels()
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if channel.FundingOutpoint == chanPoint {
+			return channel, nil
+		}
+	}
+
+	return nil, ErrNotPendingChannel
+}
+
+// AbandonToken returns a token that lets AbandonChannel abandon the pending
+// channel at the channel point within the next minute, so a channel is never
+// abandoned by a single mistaken call. Only the last token issued is valid.
+func AbandonToken(chanPoint wire.OutPoint) (string, error) {
+	if !cfg.UnsafeAbandon {
+		return "", ErrAbandonDisabled
+	}
+	if _, err := fetchPendingChannel(chanPoint); err != nil {
+		return "", err
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b[:])
+
+	abandonTokenMtx.Lock()
+	pendingAbandonToken = &abandonToken{
+		chanPoint: chanPoint,
+		token:     token,
+		expiry:    time.Now().Add(abandonTokenTTL),
+	}
+	abandonTokenMtx.Unlock()
+
+	return token, nil
+}
+
+// AbandonChannel deletes the pending channel at the channel point from the
+// channel database, as lnd does with channels whose funding never confirms,
+// given a token from AbandonToken. It is meant for channels wedged by a
+// failed funding flow, whose funding transaction can never confirm. Should
+// it confirm after all, the node no longer knows of the channel, and its
+// funds are lost unless the peer closes it.
+func AbandonChannel(chanPoint wire.OutPoint, token string) error {
+	if !cfg.UnsafeAbandon {
+		return ErrAbandonDisabled
+	}
+
+	// The token can only be used once.
+	abandonTokenMtx.Lock()
+	issued := pendingAbandonToken
+	pendingAbandonToken = nil
+	abandonTokenMtx.Unlock()
+
+	if issued == nil || issued.chanPoint != chanPoint ||
+		time.Now().After(issued.expiry) ||
+		subtle.ConstantTimeCompare(
+			[]byte(issued.token), []byte(token)) != 1 {
+
+		return ErrAbandonToken
+	}
+
+	channel, err := fetchPendingChannel(chanPoint)
+	if err != nil {
+		return err
+	}
+
+	ltndLog.Warnf("Abandoning pending channel %v", chanPoint)
+
+	return channel.CloseChannel(&channeldb.ChannelCloseSummary{
+		ChainHash: channel.ChainHash,
+		ChanPoint: channel.FundingOutpoint,
+		RemotePub: channel.IdentityPub,
+		Capacity:  channel.Capacity,
+		CloseType: channeldb.FundingCanceled,
+	})
+}
@@ -0,0 +1,330 @@
+package lnd
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/btcwallet"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/waddrmgr"
+	base "github.com/roasbeef/btcwallet/wallet"
+)
+
+// accountKeyScope is the key scope accounts created through CreateAccount are
+// derived under, so they hold native segwit outputs.
+var accountKeyScope = waddrmgr.KeyScopeBIP0084
+
+// ErrDefaultAccount is returned by SendFromAccount for the default account,
+// whose outputs are left to lnd.
+var ErrDefaultAccount = errors.New("outputs of the default account can't " +
+	"be sent from an account")
+
+// fundingWallet restricts the outputs the wallet offers lnd to those of the
+// default account, so the outputs of other accounts, such as savings, are
+// never used to fund channels or pay on-chain through the rpc server. The
+// outputs are offered in the order of the default coin selection strategy,
+// unless a call passes a CoinControl of its own.
+type fundingWallet struct {
+	*btcwallet.BtcWallet
+
+	// sendMtx serializes SendOutputs.
+	sendMtx sync.Mutex
+
+	// reserveMtx serializes reserve, so the coin control of a channel
+	// reservation is never seen by another.
+	reserveMtx sync.Mutex
+
+	// controlMtx guards reservation.
+	controlMtx sync.Mutex
+
+	// reservation is the coin control of the channel reservation being
+	// made through reserve, or nil.
+	reservation *CoinControl
+}
+
+// reserve makes a channel reservation through init, which the wallet selects
+// the funding coins and change of through ListUnspentWitness and NewAddress.
+// Those follow the coin control, which may be nil for the defaults, until
+// init returns.
+func (f *fundingWallet) reserve(c *CoinControl, init func() error) error {
+	f.reserveMtx.Lock()
+	defer f.reserveMtx.Unlock()
+
+	f.controlMtx.Lock()
+	f.reservation = c
+	f.controlMtx.Unlock()
+
+	defer func() {
+		f.controlMtx.Lock()
+		f.reservation = nil
+		f.controlMtx.Unlock()
+	}()
+
+	return init()
+}
+
+// reservationControl returns the coin control of the channel reservation
+// being made, or nil.
+func (f *fundingWallet) reservationControl() *CoinControl {
+	f.controlMtx.Lock()
+	defer f.controlMtx.Unlock()
+
+	return f.reservation
+}
+
+// ListUnspentWitness returns the unspent witness outputs of the default
+// account, as chosen by the coin control of the channel reservation being
+// made, if any.
+//
+// This is a part of the WalletController interface.
+func (f *fundingWallet) ListUnspentWitness(
+	minConfs int32) ([]*lnwallet.Utxo, error) {
+
+	return f.coins(minConfs, f.reservationControl())
+}
+
+// coins returns the unspent witness outputs of the default account with at
+// least minConfs confirmations, ordered by the strategy of the coin control,
+// which may be nil for the defaults. If it pins outpoints, only those are
+// returned.
+func (f *fundingWallet) coins(minConfs int32,
+	c *CoinControl) ([]*lnwallet.Utxo, error) {
+
+	utxos, err := f.defaultUtxos(minConfs)
+	if err != nil {
+		return nil, err
+	}
+
+	if c != nil && len(c.OutPoints) > 0 {
+		utxos, err = pinCoins(utxos, c.OutPoints)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.orderCoins(utxos, c.strategy()); err != nil {
+		return nil, err
+	}
+
+	return utxos, nil
+}
+
+// defaultUtxos returns the unspent witness outputs of the default account with
+// at least minConfs confirmations.
+func (f *fundingWallet) defaultUtxos(
+	minConfs int32) ([]*lnwallet.Utxo, error) {
+
+	utxos, err := f.BtcWallet.ListUnspentWitness(minConfs)
+	if err != nil {
+		return nil, err
+	}
+
+	w := f.InternalWallet()
+	defaultUtxos := make([]*lnwallet.Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			utxo.PkScript, activeNetParams.Params,
+		)
+		if err != nil || len(addrs) != 1 {
+			continue
+		}
+
+		account, err := w.AccountOfAddress(addrs[0])
+		if err != nil {
+			return nil, err
+		}
+		if account == waddrmgr.DefaultAccountNum {
+			defaultUtxos = append(defaultUtxos, utxo)
+		}
+	}
+
+	return defaultUtxos, nil
+}
+
+// ConfirmedBalance returns the balance of the default account's witness
+// outputs with at least confs confirmations.
+//
+// This is a part of the WalletController interface.
+func (f *fundingWallet) ConfirmedBalance(confs int32) (btcutil.Amount, error) {
+	utxos, err := f.defaultUtxos(confs)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance btcutil.Amount
+	for _, utxo := range utxos {
+		balance += utxo.Value
+	}
+
+	return balance, nil
+}
+
+// wallet returns the controller of the daemon's wallet.
+func (d *daemon) wallet() *fundingWallet {
+	return d.cc.wallet.WalletController.(*fundingWallet)
+}
+
+// internalWallet returns the btcwallet instance backing the daemon's wallet.
+func (d *daemon) internalWallet() *base.Wallet {
+	return d.wallet().InternalWallet()
+}
+
+// runningWallet returns the btcwallet instance of the running daemon.
+func runningWallet() (*base.Wallet, error) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	if activeDaemon == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return activeDaemon.internalWallet(), nil
+}
+
+// runningFundingWallet returns the controller of the running daemon's wallet.
+func runningFundingWallet() (*fundingWallet, error) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	if activeDaemon == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return activeDaemon.wallet(), nil
+}
+
+// Account describes an on-chain account of the wallet, along with its
+// balance in satoshis.
+type Account struct {
+	Name               string `json:"name"`
+	Number             uint32 `json:"number"`
+	ConfirmedBalance   int64  `json:"confirmed_balance"`
+	UnconfirmedBalance int64  `json:"unconfirmed_balance"`
+}
+
+// accountBalance fills in the balances of the account.
+func accountBalance(w *base.Wallet, account *Account) error {
+	confirmed, err := w.CalculateAccountBalances(account.Number, 1)
+	if err != nil {
+		return err
+	}
+	total, err := w.CalculateAccountBalances(account.Number, 0)
+	if err != nil {
+		return err
+	}
+
+	account.ConfirmedBalance = int64(confirmed.Spendable)
+	account.UnconfirmedBalance = int64(total.Spendable - confirmed.Spendable)
+
+	return nil
+}
+
+// CreateAccount creates a new on-chain account with the given unique name.
+// Its outputs are kept apart from those of the default account, which alone
+// fund channels.
+func CreateAccount(name string) (*Account, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := w.NextAccount(accountKeyScope, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Account{
+		Name:   name,
+		Number: number,
+	}, nil
+}
+
+// ListAccounts returns the default account, followed by all accounts created
+// through CreateAccount, along with their balances.
+func ListAccounts() ([]*Account, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := w.Accounts(accountKeyScope)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []*Account
+	for _, props := range result.Accounts {
+		if props.AccountNumber == waddrmgr.ImportedAddrAccount {
+			continue
+		}
+
+		account := &Account{
+			Name:   props.AccountName,
+			Number: props.AccountNumber,
+		}
+		if err := accountBalance(w, account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// AccountAddress returns a new native segwit address of the named account.
+func AccountAddress(name string) (btcutil.Address, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	number, err := w.AccountNumber(accountKeyScope, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.NewAddress(number, accountKeyScope)
+}
+
+// SendFromAccount pays amount satoshis to the address, spending only the
+// outputs of the named account, and returns the hash of the transaction.
+// Outputs of the default account are left to lnd, which funds channels and
+// pays on-chain through the rpc server with them.
+func SendFromAccount(name, address string, amount int64,
+	satPerKb btcutil.Amount) (string, error) {
+
+	w, err := runningWallet()
+	if err != nil {
+		return "", err
+	}
+
+	number, err := w.AccountNumber(accountKeyScope, name)
+	if err != nil {
+		return "", err
+	}
+	if number == waddrmgr.DefaultAccountNum {
+		return "", ErrDefaultAccount
+	}
+
+	addr, err := btcutil.DecodeAddress(address, activeNetParams.Params)
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+
+	txid, err := w.SendOutputs(
+		[]*wire.TxOut{wire.NewTxOut(amount, pkScript)}, number, 1,
+		satPerKb,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return txid.String(), nil
+}
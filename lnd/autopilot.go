@@ -0,0 +1,20 @@
+// +build !noautopilot
+
+package lnd
+
+// startAutopilot initializes a fresh instance of the autopilot agent and
+// starts it.
+func (d *daemon) startAutopilot(server *server) error {
+	pilot, err := initAutoPilot(server, cfg.Autopilot)
+	if err != nil {
+		ltndLog.Errorf("unable to create autopilot agent: %v", err)
+		return err
+	}
+	if err := pilot.Start(); err != nil {
+		ltndLog.Errorf("unable to start autopilot agent: %v", err)
+		return err
+	}
+	d.pilot = pilot
+
+	return nil
+}
@@ -0,0 +1,11 @@
+// +build noautopilot
+
+package lnd
+
+// startAutopilot is a no-op, as the autopilot agent was stripped from this
+// build through the noautopilot build tag.
+func (d *daemon) startAutopilot(server *server) error {
+	ltndLog.Warnf("Autopilot is active, but this build doesn't include " +
+		"the autopilot agent")
+	return nil
+}
@@ -0,0 +1,84 @@
+package lnd
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufConnSize is the size of the buffer of every in-memory connection.
+const bufConnSize = 1 << 20
+
+// bufConnTarget is the dummy target in-memory connections are dialed with.
+const bufConnTarget = "bufconn"
+
+// ErrNoInMemoryListener is returned when dialing the in-memory listener while
+// lnd isn't running.
+var ErrNoInMemoryListener = errors.New("in-memory listener is only " +
+	"available while lnd is running")
+
+// startInMemoryListener serves the rpcServer over an in-memory listener, so
+// libraries embedded within the same app can use gRPC without TCP sockets or
+// TLS. Macaroons are enforced unless they were disabled.
+func (d *daemon) startInMemoryListener() {
+	var serverOpts []grpc.ServerOption
+	if d.macaroonService != nil {
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(d.macaroonService.
+				UnaryServerInterceptor(permissions)),
+			grpc.StreamInterceptor(d.macaroonService.
+				StreamServerInterceptor(permissions)),
+		)
+	}
+
+	d.grpcServer = grpc.NewServer(serverOpts...)
+	lnrpc.RegisterLightningServer(d.grpcServer, d.rpcServer)
+
+	d.bufListener = bufconn.Listen(bufConnSize)
+	go func() {
+		defer RecoverPanic("RPCS")
+
+		err := d.grpcServer.Serve(d.bufListener)
+		if err != nil {
+			rpcsLog.Errorf("in-memory listener stopped: %v", err)
+		}
+	}()
+}
+
+// InMemoryDialer returns a dialer connecting to the in-memory listener of the
+// running daemon, to be passed to grpc.WithDialer. The connections don't use
+// TLS, so grpc.WithInsecure must be passed as well.
+func InMemoryDialer() (func(string, time.Duration) (net.Conn, error), error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrNoInMemoryListener
+	}
+
+	return func(string, time.Duration) (net.Conn, error) {
+		return d.bufListener.Dial()
+	}, nil
+}
+
+// DialInMemory creates a gRPC client connection to the in-memory listener of
+// the running daemon. Unless macaroons are disabled, the credentials of a
+// macaroon must be passed along with the options.
+func DialInMemory(opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	dialer, err := InMemoryDialer()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithDialer(dialer),
+	}, opts...)
+
+	return grpc.Dial(bufConnTarget, opts...)
+}
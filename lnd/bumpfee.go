@@ -0,0 +1,247 @@
+package lnd
+
+import (
+	"errors"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/blockchain"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/waddrmgr"
+	base "github.com/roasbeef/btcwallet/wallet"
+)
+
+// ErrNotBumpable is returned by BumpFee for an outpoint that isn't an
+// unconfirmed, unspent output of the wallet.
+var ErrNotBumpable = errors.New("outpoint isn't an unconfirmed, unspent " +
+	"output of the wallet")
+
+// ErrFeeRateTooLow is returned by BumpFee if the unconfirmed transaction
+// already pays at least the requested fee rate on its own.
+var ErrFeeRateTooLow = errors.New("transaction already pays at least the " +
+	"requested fee rate")
+
+// BumpFeeEstimate describes a child transaction paying for its unconfirmed
+// parent, and the fee rate the two pay as a package. Fees are in satoshis, and
+// the fee rate in satoshis per vbyte.
+//
+// The fee of a parent paying to the wallet from outside of it isn't known, as
+// the values of its inputs aren't. Such a parent is assumed to pay no fee at
+// all, so the package pays at least the requested fee rate.
+type BumpFeeEstimate struct {
+	ParentTxid     string  `json:"parent_txid"`
+	ParentVSize    int64   `json:"parent_vsize"`
+	ParentFee      int64   `json:"parent_fee"`
+	ParentFeeKnown bool    `json:"parent_fee_known"`
+	ChildTxid      string  `json:"child_txid,omitempty"`
+	ChildVSize     int64   `json:"child_vsize"`
+	ChildFee       int64   `json:"child_fee"`
+	PackageFeeRate float64 `json:"package_fee_rate"`
+}
+
+// planBumpFee works out the fee of a child spending the unconfirmed output at
+// the outpoint, such that the package pays the fee rate. The output is
+// returned along with the estimate.
+func planBumpFee(f *fundingWallet, op wire.OutPoint,
+	feeRate lnwallet.SatPerVByte) (*BumpFeeEstimate, *lnwallet.Utxo, error) {
+
+	w := f.InternalWallet()
+
+	// Outputs locked for a pending channel funding aren't listed, so
+	// they're never bumped from underneath the funding manager.
+	unspent, err := w.ListUnspent(0, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := false
+	for _, output := range unspent {
+		if output.TxID == op.Hash.String() && output.Vout == op.Index {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil, ErrNotBumpable
+	}
+
+	parent, err := base.UnstableAPI(w).TxDetails(&op.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil || int(op.Index) >= len(parent.MsgTx.TxOut) {
+		return nil, nil, ErrNotBumpable
+	}
+
+	output := parent.MsgTx.TxOut[op.Index]
+	coin := &lnwallet.Utxo{
+		Value:    btcutil.Amount(output.Value),
+		PkScript: output.PkScript,
+		OutPoint: op,
+	}
+
+	var weight lnwallet.TxWeightEstimator
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(output.PkScript):
+		coin.AddressType = lnwallet.WitnessPubKey
+		weight.AddP2WKHInput()
+	case txscript.IsPayToScriptHash(output.PkScript):
+		coin.AddressType = lnwallet.NestedWitnessPubKey
+		weight.AddNestedP2WKHInput()
+	default:
+		return nil, nil, ErrNotBumpable
+	}
+	weight.AddP2WKHOutput()
+
+	parentWeight := blockchain.GetTransactionWeight(
+		btcutil.NewTx(&parent.MsgTx),
+	)
+	estimate := &BumpFeeEstimate{
+		ParentTxid:  op.Hash.String(),
+		ParentVSize: (parentWeight + 3) / 4,
+		ChildVSize:  int64(weight.VSize()),
+	}
+
+	// The fee of the parent is only known if the wallet funded all of
+	// its inputs.
+	if len(parent.Debits) == len(parent.MsgTx.TxIn) {
+		var fee btcutil.Amount
+		for _, debit := range parent.Debits {
+			fee += debit.Amount
+		}
+		for _, txOut := range parent.MsgTx.TxOut {
+			fee -= btcutil.Amount(txOut.Value)
+		}
+
+		estimate.ParentFee = int64(fee)
+		estimate.ParentFeeKnown = true
+	}
+
+	if estimate.ParentFeeKnown &&
+		btcutil.Amount(estimate.ParentFee) >=
+			feeRate.FeeForVSize(estimate.ParentVSize) {
+
+		return nil, nil, ErrFeeRateTooLow
+	}
+
+	packageVSize := estimate.ParentVSize + estimate.ChildVSize
+	childFee := feeRate.FeeForVSize(packageVSize) -
+		btcutil.Amount(estimate.ParentFee)
+	dustLimit := walletDustLimit(lnwallet.P2WPKHSize)
+	if coin.Value-childFee < dustLimit {
+		return nil, nil, insufficientCoinsError{
+			needed:    childFee + dustLimit,
+			available: coin.Value,
+		}
+	}
+
+	estimate.ChildFee = int64(childFee)
+	estimate.PackageFeeRate = float64(estimate.ParentFee+
+		estimate.ChildFee) / float64(packageVSize)
+
+	return estimate, coin, nil
+}
+
+// bumpFeeParams returns the wallet of the running daemon, along with the fee
+// rate to bump to. A zero satPerByte picks a rate estimated to confirm within
+// six blocks.
+func bumpFeeParams(satPerByte int64) (*fundingWallet, lnwallet.SatPerVByte,
+	error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, 0, ErrDaemonNotRunning
+	}
+
+	feeRate, err := determineFeePerVSize(d.cc.feeEstimator, 0, satPerByte)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return d.wallet(), feeRate, nil
+}
+
+// EstimateBumpFee estimates the fee of a child transaction spending the
+// unconfirmed output at the outpoint, such that it and its parent together pay
+// satPerByte satoshis per vbyte. Nothing is published.
+func EstimateBumpFee(op wire.OutPoint,
+	satPerByte int64) (*BumpFeeEstimate, error) {
+
+	f, feeRate, err := bumpFeeParams(satPerByte)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate, _, err := planBumpFee(f, op, feeRate)
+	return estimate, err
+}
+
+// BumpFee speeds up the confirmation of the unconfirmed transaction holding the
+// output at the outpoint, by publishing a child spending the output back to
+// the wallet at a fee high enough for the two to pay satPerByte satoshis per
+// vbyte as a package (CPFP).
+func BumpFee(op wire.OutPoint, satPerByte int64) (*BumpFeeEstimate, error) {
+	f, feeRate, err := bumpFeeParams(satPerByte)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold off sends, so the output can't be spent twice.
+	f.sendMtx.Lock()
+	defer f.sendMtx.Unlock()
+
+	estimate, coin, err := planBumpFee(f, op, feeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pay the output back to the account it belongs to.
+	w := f.InternalWallet()
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		coin.PkScript, activeNetParams.Params,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) != 1 {
+		return nil, ErrNotBumpable
+	}
+	account, err := w.AccountOfAddress(addrs[0])
+	if err != nil {
+		return nil, err
+	}
+	changeAddr, err := w.NewChangeAddress(
+		account, waddrmgr.KeyScopeBIP0084,
+	)
+	if err != nil {
+		return nil, err
+	}
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: coin.OutPoint,
+		Sequence:         rbfSequence,
+	})
+	tx.AddTxOut(wire.NewTxOut(
+		int64(coin.Value)-estimate.ChildFee, changeScript,
+	))
+
+	inputs := map[wire.OutPoint]*lnwallet.Utxo{coin.OutPoint: coin}
+	if err := f.signTx(tx, inputs); err != nil {
+		return nil, err
+	}
+
+	if err := f.PublishTransaction(tx); err != nil {
+		return nil, err
+	}
+
+	estimate.ChildTxid = tx.TxHash().String()
+	return estimate, nil
+}
@@ -105,6 +105,10 @@ type chainControl struct {
 	wallet *lnwallet.LightningWallet
 
 	routingPolicy htlcswitch.ForwardingPolicy
+
+	// lightClient is the neutrino light client backing the chain, or nil
+	// with other backends.
+	lightClient *neutrino.ChainService
 }
 
 // newChainControlFromConfig attempts to create a chainControl instance
@@ -228,6 +232,7 @@ func newChainControlFromConfig(cfg *config, chanDB *channeldb.DB,
 			return nil, nil, fmt.Errorf("unable to create neutrino: %v", err)
 		}
 		svc.Start()
+		cc.lightClient = svc
 
 		// Next we'll create the instances of the ChainNotifier and
 		// FilteredChainView interface which is backed by the neutrino
@@ -245,6 +250,24 @@ func newChainControlFromConfig(cfg *config, chanDB *channeldb.DB,
 		// create our clean up function which simply closes the
 		// database.
 		walletConfig.ChainSource = chain.NewNeutrinoClient(svc)
+
+		// Neutrino can't estimate fees, so they're fetched from the
+		// web if a source is configured.
+		if cfg.WebFee.URL != "" {
+			ltndLog.Infof("Initializing web fee estimator for %v",
+				cfg.WebFee.URL)
+
+			webFee, err := newWebFeeEstimator(cfg.WebFee, cfg.net)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := webFee.Start(); err != nil {
+				return nil, nil, err
+			}
+			cc.feeEstimator = webFee
+			walletConfig.FeeEstimator = webFee
+		}
+
 		cleanUp = func() {
 			svc.Stop()
 			nodeDatabase.Close()
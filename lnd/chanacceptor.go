@@ -0,0 +1,118 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// defaultChanAcceptorTimeout is how long the channel acceptor is waited for
+// before an inbound channel is rejected.
+const defaultChanAcceptorTimeout = 15 * time.Second
+
+// ChannelRequest describes an inbound channel proposed by a peer, as passed to
+// the channel acceptor. Amounts are in satoshis, except for those in
+// millisatoshis as named.
+type ChannelRequest struct {
+	NodePubKey       string `json:"node_pubkey"`
+	ChainHash        string `json:"chain_hash"`
+	PendingChanID    string `json:"pending_chan_id"`
+	FundingAmt       int64  `json:"funding_amt"`
+	PushAmtMsat      int64  `json:"push_amt_msat"`
+	DustLimit        int64  `json:"dust_limit"`
+	MaxValueInFlight int64  `json:"max_value_in_flight_msat"`
+	ChannelReserve   int64  `json:"channel_reserve"`
+	MinHtlcMsat      int64  `json:"min_htlc_msat"`
+	FeePerKw         int64  `json:"fee_per_kw"`
+	CsvDelay         int32  `json:"csv_delay"`
+	MaxAcceptedHtlcs int32  `json:"max_accepted_htlcs"`
+	Private          bool   `json:"private"`
+}
+
+// ChannelAcceptor decides whether an inbound channel is accepted.
+type ChannelAcceptor func(req *ChannelRequest) bool
+
+// channelRejectedError is the funding error of a channel turned down by the
+// channel acceptor, whose message is passed on to the peer.
+type channelRejectedError struct{}
+
+func (channelRejectedError) Error() string {
+	return "channel rejected"
+}
+
+var (
+	// chanAcceptorMtx guards chanAcceptor and chanAcceptorTimeout.
+	chanAcceptorMtx sync.Mutex
+
+	// chanAcceptor decides on inbound channels, or is nil to accept all
+	// of them.
+	chanAcceptor ChannelAcceptor
+
+	chanAcceptorTimeout = defaultChanAcceptorTimeout
+)
+
+// SetChannelAcceptor has the acceptor decide on every inbound channel, which
+// is rejected if it doesn't decide within the timeout, or the default of 15
+// seconds if the timeout is zero. While it decides, other funding flows wait,
+// so it should be quick. A nil acceptor accepts all inbound channels again.
+func SetChannelAcceptor(acceptor ChannelAcceptor, timeout time.Duration) {
+	if timeout == 0 {
+		timeout = defaultChanAcceptorTimeout
+	}
+
+	chanAcceptorMtx.Lock()
+	chanAcceptor = acceptor
+	chanAcceptorTimeout = timeout
+	chanAcceptorMtx.Unlock()
+}
+
+// acceptChannel returns whether the inbound channel the peer proposed is
+// accepted by the channel acceptor, if one is set.
+func acceptChannel(peer *btcec.PublicKey, msg *lnwire.OpenChannel) bool {
+	chanAcceptorMtx.Lock()
+	acceptor := chanAcceptor
+	timeout := chanAcceptorTimeout
+	chanAcceptorMtx.Unlock()
+
+	if acceptor == nil {
+		return true
+	}
+
+	req := &ChannelRequest{
+		NodePubKey:       hex.EncodeToString(peer.SerializeCompressed()),
+		ChainHash:        msg.ChainHash.String(),
+		PendingChanID:    hex.EncodeToString(msg.PendingChannelID[:]),
+		FundingAmt:       int64(msg.FundingAmount),
+		PushAmtMsat:      int64(msg.PushAmount),
+		DustLimit:        int64(msg.DustLimit),
+		MaxValueInFlight: int64(msg.MaxValueInFlight),
+		ChannelReserve:   int64(msg.ChannelReserve),
+		MinHtlcMsat:      int64(msg.HtlcMinimum),
+		FeePerKw:         int64(msg.FeePerKiloWeight),
+		CsvDelay:         int32(msg.CsvDelay),
+		MaxAcceptedHtlcs: int32(msg.MaxAcceptedHTLCs),
+		Private:          msg.ChannelFlags&lnwire.FFAnnounceChannel == 0,
+	}
+
+	// The acceptor runs on its own goroutine, so a hung or panicking one
+	// rejects the channel once the timeout expires.
+	accepted := make(chan bool, 1)
+	go func() {
+		defer RecoverPanic("chanacceptor")
+
+		accepted <- acceptor(req)
+	}()
+
+	select {
+	case accept := <-accepted:
+		return accept
+
+	case <-time.After(timeout):
+		fndgLog.Warnf("Channel acceptor timed out on pendingChan(%x), "+
+			"rejecting it", msg.PendingChannelID)
+		return false
+	}
+}
@@ -0,0 +1,135 @@
+package lnd
+
+import (
+	"errors"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/roasbeef/btcd/wire"
+)
+
+// ErrUnknownChannel is returned when a channel is neither in the graph nor
+// among the open and closed channels of the node.
+var ErrUnknownChannel = errors.New("channel not found")
+
+// edgeNotFound returns true if the error means the graph doesn't hold the
+// channel.
+func edgeNotFound(err error) bool {
+	return err == channeldb.ErrEdgeNotFound ||
+		err == channeldb.ErrGraphNoEdgesFound ||
+		err == channeldb.ErrGraphNotFound
+}
+
+// ChanPointFromChanID returns the channel point of the channel with the short
+// channel ID. The graph is looked at first, then the open and closed channels
+// of the node, so private channels, and those that closed since forwarding an
+// HTLC, are found too.
+func ChanPointFromChanID(chanID uint64) (*wire.OutPoint, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	graph := d.chanDB.ChannelGraph()
+	info, _, _, err := graph.FetchChannelEdgesByID(chanID)
+	switch {
+	case err == nil:
+		return &info.ChannelPoint, nil
+	case !edgeNotFound(err):
+		return nil, err
+	}
+
+	openChannels, err := d.chanDB.FetchAllChannels()
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range openChannels {
+		if channel.ShortChanID.ToUint64() == chanID {
+			return &channel.FundingOutpoint, nil
+		}
+	}
+
+	closedChannels, err := d.chanDB.FetchClosedChannels(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range closedChannels {
+		if summary.ShortChanID.ToUint64() == chanID {
+			return &summary.ChanPoint, nil
+		}
+	}
+
+	return nil, ErrUnknownChannel
+}
+
+// ChanIDFromChanPoint returns the short channel ID of the channel at the
+// channel point, looking in the same places as ChanPointFromChanID. Channels
+// still pending have no short channel ID yet.
+func ChanIDFromChanPoint(chanPoint wire.OutPoint) (uint64, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return 0, ErrDaemonNotRunning
+	}
+
+	chanID, err := d.chanDB.ChannelGraph().ChannelID(&chanPoint)
+	switch {
+	case err == nil:
+		return chanID, nil
+	case !edgeNotFound(err):
+		return 0, err
+	}
+
+	openChannels, err := d.chanDB.FetchAllChannels()
+	if err != nil {
+		return 0, err
+	}
+	for _, channel := range openChannels {
+		if channel.FundingOutpoint == chanPoint && !channel.IsPending {
+			return channel.ShortChanID.ToUint64(), nil
+		}
+	}
+
+	summary, err := d.chanDB.FetchClosedChannel(&chanPoint)
+	switch {
+	case err == nil:
+		return summary.ShortChanID.ToUint64(), nil
+	case err != channeldb.ErrClosedChannelNotFound:
+		return 0, err
+	}
+
+	return 0, ErrUnknownChannel
+}
+
+// ChanInfoByChanPoint returns the announcement of the channel at the channel
+// point, with the policies of both its nodes, as GetChanInfo does by channel
+// ID.
+func ChanInfoByChanPoint(chanPoint wire.OutPoint) (*lnrpc.ChannelEdge,
+	error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	graph := d.chanDB.ChannelGraph()
+	edgeInfo, edge1, edge2, err := graph.FetchChannelEdgesByOutpoint(
+		&chanPoint,
+	)
+	switch {
+	case edgeNotFound(err):
+		return nil, ErrUnknownChannel
+	case err != nil:
+		return nil, err
+	}
+
+	return marshalDbEdge(edgeInfo, edge1, edge2), nil
+}
@@ -5,6 +5,7 @@
 package lnd
 
 import (
+	"errors"
 	"log"
 	"net"
 	"time"
@@ -17,7 +18,6 @@ import (
 	"github.com/lightninglabs/neutrino"
 	"github.com/roasbeef/btcwallet/chain"
 	"github.com/roasbeef/btcwallet/walletdb"
-	 "github.com/lightningnetwork/lnd/autopilot"
 	"runtime/pprof"
 	"github.com/lightningnetwork/lnd/keychain"
 	"google.golang.org/grpc"
@@ -27,12 +27,16 @@ import (
 	"github.com/lightningnetwork/lnd/lnwallet" 
 	"github.com/roasbeef/btcd/btcec"
 	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/roasbeef/btcd/wire"
 	"github.com/roasbeef/btcutil"
 	"crypto/rand" 
 	"sync" 
+	"sync/atomic"
 	"github.com/lightningnetwork/lnd/chainntnfs/neutrinonotify"
 	"github.com/lightningnetwork/lnd/routing/chainview"
+	"github.com/lightningnetwork/lnd/macaroons"
+	"google.golang.org/grpc/test/bufconn"
 
 )
 
@@ -57,10 +61,251 @@ var rpcServerMutex = &sync.Mutex{}
 
 var lndGrpcServer *grpc.Server 
 
-var LndRpcServer * rpcServer;
- 
-// Start, analogous to lndMain
-func Start(seed []byte, dataDir string) error {
+
+var (
+	// ErrDaemonRunning is returned by Start if lnd is already running
+	// within this process.
+	ErrDaemonRunning = errors.New("lnd is already running")
+
+	// ErrDaemonNotRunning is returned by Stop if lnd hasn't been started.
+	ErrDaemonNotRunning = errors.New("lnd is not running")
+)
+
+// defaultWalletPw is the password the wallet is encrypted with if none is
+// given. Wallets created before the password could be set use it.
+var defaultWalletPw = []byte("hello")
+
+// daemon bundles the subsystems brought up by Start, so that Stop can tear
+// them down again and allow lnd to be restarted within the same process.
+type daemon struct {
+	seed     []byte
+	walletPw []byte
+	dataDir  string
+	args     []string
+
+	chanDB          *channeldb.DB
+	macaroonService *macaroons.Service
+	cc              *chainControl
+	chainCleanUp    func()
+	server          *server
+	fundingMgr      *fundingManager
+	rpcServer       *rpcServer
+	grpcServer      *grpc.Server
+	bufListener     *bufconn.Listener
+	tlsGrpcServer   *grpc.Server
+	pilot           subsystem
+
+	// rpcListeners are the addresses tlsGrpcServer serves remote apps on.
+	rpcListeners []net.Addr
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// subsystem is a part of the daemon that can be stopped, such as the autopilot
+// agent, allowing it to be stripped from the build through build tags.
+type subsystem interface {
+	Stop() error
+}
+
+// Controls access to activeDaemon.
+var daemonMtx sync.Mutex
+
+// activeDaemon is the currently running daemon, or nil if lnd is stopped.
+var activeDaemon *daemon
+
+// Running returns true if lnd has been started and not yet stopped.
+func Running() bool {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	return activeDaemon != nil
+}
+
+// ChainSyncState reports the current progress of the chain backend of the
+// running daemon.
+func ChainSyncState() (*ChainSyncEvent, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	synced, _, err := d.cc.wallet.IsSynced()
+	if err != nil {
+		return nil, err
+	}
+	bestHash, bestHeight, err := d.cc.chainIO.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChainSyncEvent{
+		BlockHeight: bestHeight,
+		BlockHash:   bestHash.String(),
+		Synced:      synced,
+	}, nil
+}
+
+// Start, analogous to lndMain. The passed args are parsed as lnd command line
+// options, overriding those found in lnd.conf within the data directory. The
+// wallet is encrypted with walletPw, or the default password if it is empty.
+func Start(seed, walletPw []byte, dataDir string, args []string) error {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	return startDaemon(seed, walletPw, dataDir, args)
+}
+
+// startDaemon starts the daemon as Start does. The caller must hold daemonMtx.
+func startDaemon(seed, walletPw []byte, dataDir string, args []string) error {
+	if activeDaemon != nil {
+		return ErrDaemonRunning
+	}
+
+	if len(walletPw) == 0 {
+		walletPw = defaultWalletPw
+	}
+
+	d := &daemon{
+		seed:     seed,
+		walletPw: walletPw,
+		dataDir:  dataDir,
+		args:     args,
+		quit:     make(chan struct{}),
+	}
+	if err := d.start(); err != nil {
+		// Release whatever was opened before the failure, so a
+		// later call to Start doesn't find the databases locked.
+		d.stop()
+		return err
+	}
+
+	activeDaemon = d
+
+	return nil
+}
+
+// Stop gracefully shuts down all subsystems started by Start, closing the
+// chain backend and all databases. Once Stop returns, lnd may be started again
+// within the same process.
+func Stop() error {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	return stopDaemon()
+}
+
+// stopDaemon stops the daemon as Stop does. The caller must hold daemonMtx.
+func stopDaemon() error {
+	if activeDaemon == nil {
+		return ErrDaemonNotRunning
+	}
+
+	ltndLog.Infof("Gracefully shutting down the server...")
+
+	activeDaemon.stop()
+	activeDaemon = nil
+
+	return nil
+}
+
+// Restart stops the running daemon and starts it again with the seed, wallet
+// password, data directory and options it was originally started with. No
+// other daemon can be started or stopped in between.
+func Restart() error {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	d := activeDaemon
+	if err := stopDaemon(); err != nil {
+		return err
+	}
+
+	return startDaemon(d.seed, d.walletPw, d.dataDir, d.args)
+}
+
+// RPCServer returns the rpc server of the running daemon. Callers use it for
+// the whole of a call, rather than look it up again, so that a call never
+// spans two runs of the daemon.
+func RPCServer() (lnrpc.LightningServer, error) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	if activeDaemon == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return activeDaemon.rpcServer, nil
+}
+
+// stop tears down the subsystems of the daemon in the reverse order they were
+// started in. Subsystems that were never brought up are skipped.
+func (d *daemon) stop() {
+	// Signal the startup goroutine to exit, and wait for it so that it
+	// doesn't race us while starting the server.
+	close(d.quit)
+	d.wg.Wait()
+
+	if d.tlsGrpcServer != nil {
+		d.tlsGrpcServer.Stop()
+	}
+	if d.grpcServer != nil {
+		d.grpcServer.Stop()
+	}
+	if d.rpcServer != nil {
+		d.rpcServer.Stop()
+	}
+	if d.fundingMgr != nil {
+		d.fundingMgr.Stop()
+	}
+	if d.pilot != nil {
+		d.pilot.Stop()
+	}
+
+	// The server shuts down the wallet itself, but if we never made it
+	// past syncing we'll need to do so ourselves.
+	switch {
+	case d.server != nil && d.server.Started():
+		d.server.Stop()
+		d.server.WaitForShutdown()
+
+	case d.cc != nil:
+		d.cc.wallet.Shutdown()
+	}
+
+	if d.chainCleanUp != nil {
+		d.chainCleanUp()
+	}
+	if d.macaroonService != nil {
+		d.macaroonService.Close()
+	}
+	if d.chanDB != nil {
+		d.chanDB.Close()
+	}
+
+	// The sweep fee rate set by ForceCloseAll only lasts until the node
+	// stops.
+	atomic.StoreInt64(&sweepFeeFloor, 0)
+
+	// As do the limits set through SetLimits.
+	resetLimits()
+
+	ltndLog.Info("Shutdown complete")
+
+	if logRotator != nil {
+		logRotator.Close()
+		logRotatorPipe.Close()
+		logRotator = nil
+	}
+}
+
+// start brings up all subsystems of the daemon. The server itself is started
+// in the background once the chain backend has finished syncing.
+func (d *daemon) start() error {
+	seed, dataDir := d.seed, d.dataDir
 
 	// Use all processor cores.
 	// TODO(roasbeef): remove this if required version # is > 1.6?
@@ -68,16 +313,11 @@ func Start(seed []byte, dataDir string) error {
 
 // Load the configuration, and parse any command line options. This
 	// function will also set up logging properly.
-	loadedConfig, err := loadConfig(dataDir)
+	loadedConfig, err := loadConfig(dataDir, d.args)
 	if err != nil {
 		return err
 	}
 	cfg = loadedConfig
-	defer func() {
-		if logRotator != nil {
-			logRotator.Close()
-		}
-	}()
 
 	// Show version at startup.
 	ltndLog.Infof("Version %s", version())
@@ -130,6 +370,15 @@ func Start(seed []byte, dataDir string) error {
 		defaultGraphSubDirname,
 		normalizeNetwork(activeNetParams.Name))
 
+	// In compact graph mode, drop the free pages the churn of channel
+	// updates left in the channeldb before opening it.
+	dbPath := filepath.Join(graphDir, channelDBName)
+	if _, err := os.Stat(dbPath); cfg.Graph.Compact && err == nil {
+		if err := compactDB(dbPath); err != nil {
+			ltndLog.Warnf("Unable to compact channeldb: %v", err)
+		}
+	}
+
 	// Open the channeldb, which is dedicated to storing channel, and
 	// network related metadata.
 	chanDB, err := channeldb.Open(graphDir)
@@ -137,26 +386,30 @@ func Start(seed []byte, dataDir string) error {
 		ltndLog.Errorf("unable to open channeldb: %v", err)
 		return err
 	}
-	//defer chanDB.Close() //this was closed for ios specific
+	d.chanDB = chanDB
 
 	
 	 
-	// We wait until the user provides a password over RPC. In case lnd is
-	// started with the --noencryptwallet flag, we use the default password
-	// "hello" for wallet encryption.
-	privateWalletPw := []byte("hello")
+	// The wallet is encrypted with the password the daemon was started
+	// with.
+	privateWalletPw := d.walletPw
 	publicWalletPw := []byte("public")
-	 
+
+	if err := d.startMacaroonService(privateWalletPw); err != nil {
+		return err
+	}
 
 	// With the information parsed from the configuration, create valid
 	// instances of the pertinent interfaces required to operate the
 	// Lightning Network Daemon.
-	activeChainControl, _, err := newChainControlFromConfigCustom(cfg,
+	activeChainControl, chainCleanUp, err := newChainControlFromConfigCustom(cfg,
 		chanDB, privateWalletPw, publicWalletPw,seed)
 	if err != nil {
 		fmt.Printf("unable to create chain control: %v\n", err)
 		return err
 	}
+	d.cc = activeChainControl
+	d.chainCleanUp = chainCleanUp
 	 
 	 
 	// Finally before we start the server, we'll register the "holy
@@ -209,6 +462,7 @@ func Start(seed []byte, dataDir string) error {
 		srvrLog.Errorf("unable to create server: %v\n", err)
 		return err
 	}
+	d.server = server
 
 	// Next, we'll initialize the funding manager itself so it can answer
 	// queries while the wallet+chain are still syncing.
@@ -349,20 +603,22 @@ func Start(seed []byte, dataDir string) error {
 		ReportShortChanID: func(chanPoint wire.OutPoint,
 			sid lnwire.ShortChannelID) error {
 
+			publishEvent(EventChannelOpened, &ChannelEvent{
+				ChannelPoint: chanPoint.String(),
+				ChanID:       sid.ToUint64(),
+			})
+
 			cid := lnwire.NewChanIDFromOutPoint(&chanPoint)
 			return server.htlcSwitch.UpdateShortChanID(cid, sid)
 		},
-		RequiredRemoteChanReserve: func(chanAmt btcutil.Amount) btcutil.Amount {
-			// By default, we'll require the remote peer to maintain
-			// at least 1% of the total channel capacity at all
-			// times.
-			return chanAmt / 100
-		},
+		RequiredRemoteChanReserve: requiredChanReserve,
 		RequiredRemoteMaxValue: func(chanAmt btcutil.Amount) lnwire.MilliSatoshi {
 			// By default, we'll allow the remote peer to fully
 			// utilize the full bandwidth of the channel, minus our
 			// required reserve.
-			reserve := lnwire.NewMSatFromSatoshis(chanAmt / 100)
+			reserve := lnwire.NewMSatFromSatoshis(
+				requiredChanReserve(chanAmt),
+			)
 			return lnwire.NewMSatFromSatoshis(chanAmt) - reserve
 		},
 		RequiredRemoteMaxHTLCs: func(chanAmt btcutil.Amount) uint16 {
@@ -379,6 +635,7 @@ func Start(seed []byte, dataDir string) error {
 	if err := fundingMgr.Start(); err != nil {
 		return err
 	}
+	d.fundingMgr = fundingMgr
 	server.fundingMgr = fundingMgr
 	 
 	// Initialize, and register our implementation of the gRPC interface
@@ -388,11 +645,19 @@ func Start(seed []byte, dataDir string) error {
 	if err := rpcServer.Start(); err != nil {
 		return err
 	}
-	LndRpcServer = rpcServer;
+	d.rpcServer = rpcServer
+
+	d.startInMemoryListener()
+	if err := d.startRPCListeners(); err != nil {
+		return err
+	}
 	 
 
  
+	d.wg.Add(1)
 	go func() error {
+		defer d.wg.Done()
+		defer RecoverPanic("LTND")
  
 		_, bestHeight, err := activeChainControl.chainIO.GetBestBlock()
 		if err != nil {
@@ -409,11 +674,25 @@ func Start(seed []byte, dataDir string) error {
 				return err
 			}
 
+			bestHash, bestHeight, err := activeChainControl.chainIO.GetBestBlock()
+			if err != nil {
+				return err
+			}
+			publishEvent(EventChainSync, &ChainSyncEvent{
+				BlockHeight: bestHeight,
+				BlockHash:   bestHash.String(),
+				Synced:      synced,
+			})
+
 			if synced {
 				break
 			}
 
-			time.Sleep(time.Second * 1)
+			select {
+			case <-time.After(time.Second * 1):
+			case <-d.quit:
+				return nil
+			}
 		}
 
 		_, bestHeight, err = activeChainControl.chainIO.GetBestBlock()
@@ -425,6 +704,13 @@ func Start(seed []byte, dataDir string) error {
 			bestHeight)
 	 
 
+	// Bail out if we were asked to shutdown while syncing.
+	select {
+	case <-d.quit:
+		return nil
+	default:
+	}
+
 	// With all the relevant chains initialized, we can finally start the
 	// server itself.
 	if err := server.Start(); err != nil {
@@ -435,43 +721,57 @@ func Start(seed []byte, dataDir string) error {
 
 	// Now that the server has started, if the autopilot mode is currently
 	// active, then we'll initialize a fresh instance of it and start it.
- var pilot *autopilot.Agent
 	if cfg.Autopilot.Active {
-		pilot, err := initAutoPilot(server, cfg.Autopilot)
-		if err != nil {
-			ltndLog.Errorf("unable to create autopilot agent: %v",
-				err)
+		if err := d.startAutopilot(server); err != nil {
 			return err
 		}
-		if err := pilot.Start(); err != nil {
-			ltndLog.Errorf("unable to start autopilot agent: %v",
-				err)
-			return err
-		}
-	} 
+	}
 
+	// Consolidate small outputs in the background if asked to.
+	if cfg.Consolidation.Active {
+		d.wg.Add(1)
+		go d.consolidateOutputs(
+			lnwallet.SatPerVByte(cfg.Consolidation.MaxFeeRate),
+			cfg.Consolidation.TargetCount,
+		)
+	}
 
-	addInterruptHandler(func() {
-		ltndLog.Infof("Gracefully shutting down the server...")
-		rpcServer.Stop()
-		fundingMgr.Stop()
-		server.Stop()
+	// Delete the invoices past their retention, if any is set.
+	if cfg.Invoices.RetentionDays > 0 {
+		retention := time.Duration(cfg.Invoices.RetentionDays) *
+			24 * time.Hour
 
-		if pilot != nil {
-			pilot.Stop()
-		}
+		d.wg.Add(1)
+		go d.pruneInvoicesPeriodically(
+			retention, cfg.Invoices.PruneSettled,
+		)
+	}
 
-		server.WaitForShutdown()
-	})
+	// Keep pruning the graph down to the channels payments can use.
+	if cfg.Graph.Compact {
+		d.wg.Add(1)
+		go d.pruneGraphPeriodically()
+	}
 
+	// Fill the graph from the snapshot on first start, if one is set.
+	if cfg.Graph.SnapshotURL != "" {
+		pubKeys, err := validateGraphConfig(cfg.Graph)
+		if err != nil {
+			return err
+		}
 
-	// Wait for shutdown signal from either a graceful server stop or from
-	// the interrupt handler.
-	<-shutdownChannel
-	ltndLog.Info("Shutdown complete")
+		d.wg.Add(1)
+		go d.bootstrapGraph(cfg.Graph.SnapshotURL, pubKeys)
+	}
 
-	 
-return nil
+	// Send any payments queued while the node was offline.
+	if cfg.Outbox {
+		d.wg.Add(1)
+		go d.runOutbox()
+	}
+
+	// Publish chain events until Stop tears down the daemon.
+	return d.notifyChainEvents()
 
 	}()
  
@@ -596,6 +896,7 @@ func fileExists(name string) bool {
 			return nil, nil, fmt.Errorf("unable to create neutrino: %v", err)
 		}
 		svc.Start()
+		cc.lightClient = svc
 
 		// Next we'll create the instances of the ChainNotifier and
 		// FilteredChainView interface which is backed by the neutrino
@@ -622,13 +923,26 @@ func fileExists(name string) bool {
 	wc, err := btcwallet.New(*walletConfig)
 	if err != nil {
 		fmt.Printf("unable to create wallet controller: %v\n", err)
+		cleanUp()
 		return nil, nil, err
 	}
 
+	// The wallet controller leaves its database open on shutdown, so
+	// we'll close it along with the neutrino database.
+	chainCleanUp := cleanUp
+	cleanUp = func() {
+		chainCleanUp()
+		wc.InternalWallet().Database().Close()
+	}
+
 	cc.msgSigner = wc
 	cc.signer = wc
 	cc.chainIO = wc
 
+	// Only the outputs of the default account are offered to lnd, keeping
+	// those of other accounts out of channel funding.
+	walletController := &fundingWallet{BtcWallet: wc}
+
 	// Select the default channel constraints for the primary chain.
 	channelConstraints := defaultBtcChannelConstraints
 	if registeredChains.PrimaryChain() == litecoinChain {
@@ -644,7 +958,7 @@ func fileExists(name string) bool {
 	walletCfg := lnwallet.Config{
 		Database:           chanDB,
 		Notifier:           cc.chainNotifier,
-		WalletController:   wc,
+		WalletController:   walletController,
 		Signer:             cc.signer,
 		FeeEstimator:       cc.feeEstimator,
 		SecretKeyRing:      keyRing,
@@ -655,10 +969,13 @@ func fileExists(name string) bool {
 	wallet, err := lnwallet.NewLightningWallet(walletCfg)
 	if err != nil {
 		fmt.Printf("unable to create wallet: %v\n", err)
+		cleanUp()
 		return nil, nil, err
 	}
 	if err := wallet.Startup(); err != nil {
 		fmt.Printf("unable to start wallet: %v\n", err)
+		wallet.Shutdown()
+		cleanUp()
 		return nil, nil, err
 	}
 
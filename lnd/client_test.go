@@ -0,0 +1,21 @@
+package lnd
+
+import (
+	"testing"
+)
+
+// TestDaemonNotRunning checks that the daemon's lifecycle and its rpc server
+// report that no daemon runs, rather than act on a stale one.
+func TestDaemonNotRunning(t *testing.T) {
+	if _, err := RPCServer(); err != ErrDaemonNotRunning {
+		t.Fatalf("expected ErrDaemonNotRunning from RPCServer, got %v",
+			err)
+	}
+	if err := Stop(); err != ErrDaemonNotRunning {
+		t.Fatalf("expected ErrDaemonNotRunning from Stop, got %v", err)
+	}
+	if err := Restart(); err != ErrDaemonNotRunning {
+		t.Fatalf("expected ErrDaemonNotRunning from Restart, got %v",
+			err)
+	}
+}
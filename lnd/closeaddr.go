@@ -0,0 +1,133 @@
+package lnd
+
+import (
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+var (
+	// closeAddrsBucketKey is the top level bucket of the wallet database
+	// mapping channel points to the addresses their cooperative closes
+	// pay to.
+	closeAddrsBucketKey = []byte("lndmobile-close-addrs")
+
+	// defaultCloseAddrKey is the key of the close address of channels
+	// without one of their own.
+	defaultCloseAddrKey = []byte("default")
+)
+
+// closeAddrKey returns the key the close address of the channel is stored
+// under, or that of the default address if chanPoint is nil.
+func closeAddrKey(chanPoint *wire.OutPoint) []byte {
+	if chanPoint == nil {
+		return defaultCloseAddrKey
+	}
+
+	return []byte(chanPoint.String())
+}
+
+// SetCloseAddress sets the address the local balance of the channel at the
+// channel point is paid to when it is closed cooperatively, such as one of a
+// cold wallet, instead of a fresh address of the wallet. With a nil channel
+// point, it sets the address of all channels without one of their own. An
+// empty address clears it. Force closes always pay to the wallet, which must
+// sweep the time locked output.
+func SetCloseAddress(chanPoint *wire.OutPoint, address string) error {
+	if address != "" {
+		var err error
+		address, err = normalizeAddress(address)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := runningWallet()
+	if err != nil {
+		return err
+	}
+
+	key := closeAddrKey(chanPoint)
+	return walletdb.Update(w.Database(), func(tx walletdb.ReadWriteTx) error {
+		bucket := tx.ReadWriteBucket(closeAddrsBucketKey)
+		if bucket == nil {
+			if address == "" {
+				return nil
+			}
+
+			var err error
+			bucket, err = tx.CreateTopLevelBucket(closeAddrsBucketKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		if address == "" {
+			return bucket.Delete(key)
+		}
+		return bucket.Put(key, []byte(address))
+	})
+}
+
+// CloseAddresses returns the close addresses set, keyed by channel point, with
+// the default one under "default".
+func CloseAddresses() (map[string]string, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make(map[string]string)
+	err = walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket(closeAddrsBucketKey)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			addrs[string(k)] = string(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return addrs, nil
+}
+
+// closeDeliveryScript returns the script paying to the close address of the
+// channel, or to the default close address, or nil if neither is set.
+func closeDeliveryScript(db walletdb.DB,
+	chanPoint *wire.OutPoint) ([]byte, error) {
+
+	var address string
+	err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket(closeAddrsBucketKey)
+		if bucket == nil {
+			return nil
+		}
+
+		for _, key := range [][]byte{
+			closeAddrKey(chanPoint), defaultCloseAddrKey,
+		} {
+			if v := bucket.Get(key); v != nil {
+				address = string(v)
+				return nil
+			}
+		}
+
+		return nil
+	})
+	if err != nil || address == "" {
+		return nil, err
+	}
+
+	addr, err := btcutil.DecodeAddress(address, activeNetParams.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return txscript.PayToAddrScript(addr)
+}
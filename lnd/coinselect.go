@@ -0,0 +1,546 @@
+package lnd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcutil/txsort"
+	"golang.org/x/net/context"
+)
+
+// The coin selection strategies, deciding which outputs of the default account
+// fund channels and on-chain payments.
+const (
+	// CoinSelectLargest spends the largest outputs first, keeping the
+	// number of inputs, and so the fee, low.
+	CoinSelectLargest = "largest"
+
+	// CoinSelectRandom spends outputs in random order.
+	CoinSelectRandom = "random"
+
+	// CoinSelectOldest spends the outputs with the most confirmations
+	// first.
+	CoinSelectOldest = "oldest"
+
+	// CoinSelectPrivacy spends all outputs paying to the same address
+	// together, so a reused address never links two transactions.
+	CoinSelectPrivacy = "privacy"
+)
+
+// ErrUnknownCoinSelection is returned for a coin selection strategy that isn't
+// one of the CoinSelect constants.
+var ErrUnknownCoinSelection = errors.New("unknown coin selection strategy")
+
+// ErrCoinUnavailable is returned if an outpoint pinned through CoinControl
+// isn't a confirmed, unlocked output of the default account.
+type ErrCoinUnavailable struct {
+	OutPoint wire.OutPoint
+}
+
+func (e *ErrCoinUnavailable) Error() string {
+	return fmt.Sprintf("outpoint %v isn't a spendable output of the "+
+		"default account", e.OutPoint)
+}
+
+// CoinControl overrides how the coins of a single send or channel funding are
+// selected.
+type CoinControl struct {
+	// Strategy is the coin selection strategy, or the empty string for
+	// the default.
+	Strategy string
+
+	// OutPoints, if not empty, are the only outputs that may be spent.
+	// As many of them as needed are spent, in the order of the strategy.
+	OutPoints []wire.OutPoint
+
+	// ChangeAccount is the name of the account change is paid to, or the
+	// empty string for the default account.
+	ChangeAccount string
+
+	// ChangeType is the type of the change address. Accounts other than
+	// the default one only hold native segwit addresses.
+	ChangeType lnwallet.AddressType
+}
+
+// ErrChangeType is returned for a change address type other than native or
+// nested segwit, or for nested segwit change paid to an account other than the
+// default one.
+var ErrChangeType = errors.New("unsupported change address type")
+
+var (
+	// coinSelectionMtx guards defaultCoinSelection.
+	coinSelectionMtx sync.Mutex
+
+	// defaultCoinSelection is the strategy used unless a call overrides
+	// it.
+	defaultCoinSelection = CoinSelectLargest
+)
+
+// validCoinSelection returns ErrUnknownCoinSelection if the strategy isn't one
+// of the CoinSelect constants.
+func validCoinSelection(strategy string) error {
+	switch strategy {
+	case CoinSelectLargest, CoinSelectRandom, CoinSelectOldest,
+		CoinSelectPrivacy:
+
+		return nil
+	}
+
+	return ErrUnknownCoinSelection
+}
+
+// SetCoinSelection changes the strategy used to select the coins funding
+// channels and on-chain payments. It remains in effect across restarts of the
+// daemon.
+func SetCoinSelection(strategy string) error {
+	if err := validCoinSelection(strategy); err != nil {
+		return err
+	}
+
+	coinSelectionMtx.Lock()
+	defaultCoinSelection = strategy
+	coinSelectionMtx.Unlock()
+
+	return nil
+}
+
+// validate checks the strategy and change address type of the coin control,
+// which may be nil.
+func (c *CoinControl) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Strategy != "" {
+		if err := validCoinSelection(c.Strategy); err != nil {
+			return err
+		}
+	}
+
+	nested := c.ChangeType == lnwallet.NestedWitnessPubKey
+	if (c.ChangeType != lnwallet.WitnessPubKey && !nested) ||
+		(nested && c.ChangeAccount != "") {
+
+		return ErrChangeType
+	}
+
+	return nil
+}
+
+// strategy returns the coin selection strategy of the coin control, which may
+// be nil, falling back on the default one.
+func (c *CoinControl) strategy() string {
+	if c != nil && c.Strategy != "" {
+		return c.Strategy
+	}
+
+	coinSelectionMtx.Lock()
+	defer coinSelectionMtx.Unlock()
+
+	return defaultCoinSelection
+}
+
+// coinControlKey is the context key the coin control of a call is passed to
+// the rpc server under.
+type coinControlKey struct{}
+
+// WithCoinControl returns a copy of ctx passing the coin control to the rpc
+// server's SendCoins, SendMany, OpenChannel and OpenChannelSync calls. Only
+// in-process callers can set it, so the calls of remote clients and autopilot
+// always use the defaults.
+func WithCoinControl(ctx context.Context,
+	c *CoinControl) (context.Context, error) {
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	return context.WithValue(ctx, coinControlKey{}, c), nil
+}
+
+// coinControlFromContext returns the coin control passed with ctx, or nil.
+func coinControlFromContext(ctx context.Context) *CoinControl {
+	if ctx == nil {
+		return nil
+	}
+
+	c, _ := ctx.Value(coinControlKey{}).(*CoinControl)
+	return c
+}
+
+// changeScriptSize returns the size of the output script paying to the change
+// address asked for by the coin control, which may be nil.
+func (c *CoinControl) changeScriptSize() int {
+	if c != nil && c.ChangeType == lnwallet.NestedWitnessPubKey {
+		return p2shScriptSize
+	}
+
+	return lnwallet.P2WPKHSize
+}
+
+// changeAddress returns a new change address of the account and type asked
+// for by the coin control, which may be nil for the default account.
+func (f *fundingWallet) changeAddress(c *CoinControl) (btcutil.Address, error) {
+	if c == nil {
+		return f.BtcWallet.NewAddress(lnwallet.WitnessPubKey, true)
+	}
+	if c.ChangeAccount == "" {
+		return f.BtcWallet.NewAddress(c.ChangeType, true)
+	}
+
+	w := f.InternalWallet()
+	number, err := w.AccountNumber(accountKeyScope, c.ChangeAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.NewChangeAddress(number, accountKeyScope)
+}
+
+// NewAddress returns a new address of the default account. While a channel
+// reservation is made through reserve, its change address is the one asked
+// for by its coin control instead.
+//
+// This is a part of the WalletController interface.
+func (f *fundingWallet) NewAddress(t lnwallet.AddressType,
+	change bool) (btcutil.Address, error) {
+
+	if c := f.reservationControl(); change && c != nil {
+		return f.changeAddress(c)
+	}
+
+	return f.BtcWallet.NewAddress(t, change)
+}
+
+// pinCoins returns the coins at the pinned outpoints, which must all be among
+// the passed coins.
+func pinCoins(coins []*lnwallet.Utxo,
+	pinned []wire.OutPoint) ([]*lnwallet.Utxo, error) {
+
+	byOutPoint := make(map[wire.OutPoint]*lnwallet.Utxo, len(coins))
+	for _, coin := range coins {
+		byOutPoint[coin.OutPoint] = coin
+	}
+
+	pinnedCoins := make([]*lnwallet.Utxo, 0, len(pinned))
+	for _, op := range pinned {
+		coin, ok := byOutPoint[op]
+		if !ok {
+			return nil, &ErrCoinUnavailable{OutPoint: op}
+		}
+		pinnedCoins = append(pinnedCoins, coin)
+	}
+
+	return pinnedCoins, nil
+}
+
+// Utxo describes an unspent output of the wallet.
+type Utxo struct {
+	OutPoint      string `json:"outpoint"`
+	Address       string `json:"address"`
+	Account       string `json:"account"`
+	AmountSat     int64  `json:"amount_sat"`
+	Confirmations int64  `json:"confirmations"`
+	PkScript      string `json:"pk_script"`
+}
+
+// ListUnspent returns the unspent outputs of all accounts with between
+// minConfs and maxConfs confirmations. Outputs locked for a pending channel
+// funding are left out.
+func ListUnspent(minConfs, maxConfs int32) ([]*Utxo, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	unspent, err := w.ListUnspent(minConfs, maxConfs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]*Utxo, 0, len(unspent))
+	for _, output := range unspent {
+		amt, err := btcutil.NewAmount(output.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		utxos = append(utxos, &Utxo{
+			OutPoint:      fmt.Sprintf("%v:%v", output.TxID, output.Vout),
+			Address:       output.Address,
+			Account:       output.Account,
+			AmountSat:     int64(amt),
+			Confirmations: output.Confirmations,
+			PkScript:      output.ScriptPubKey,
+		})
+	}
+
+	return utxos, nil
+}
+
+// orderCoins sorts the coins in the order the strategy spends them.
+func (f *fundingWallet) orderCoins(coins []*lnwallet.Utxo,
+	strategy string) error {
+
+	switch strategy {
+	case CoinSelectRandom:
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		for i := len(coins) - 1; i > 0; i-- {
+			j := r.Intn(i + 1)
+			coins[i], coins[j] = coins[j], coins[i]
+		}
+
+	case CoinSelectOldest:
+		unspent, err := f.InternalWallet().ListUnspent(
+			0, math.MaxInt32, nil,
+		)
+		if err != nil {
+			return err
+		}
+
+		confs := make(map[string]int64, len(unspent))
+		for _, output := range unspent {
+			op := fmt.Sprintf("%v:%v", output.TxID, output.Vout)
+			confs[op] = output.Confirmations
+		}
+
+		sort.SliceStable(coins, func(i, j int) bool {
+			return confs[coins[i].OutPoint.String()] >
+				confs[coins[j].OutPoint.String()]
+		})
+
+	case CoinSelectPrivacy:
+		// Spend the addresses holding the most first, with all
+		// outputs of an address next to each other.
+		totals := make(map[string]btcutil.Amount)
+		for _, coin := range coins {
+			totals[string(coin.PkScript)] += coin.Value
+		}
+
+		sort.SliceStable(coins, func(i, j int) bool {
+			ti := totals[string(coins[i].PkScript)]
+			tj := totals[string(coins[j].PkScript)]
+			if ti != tj {
+				return ti > tj
+			}
+			return string(coins[i].PkScript) <
+				string(coins[j].PkScript)
+		})
+
+	default:
+		sort.SliceStable(coins, func(i, j int) bool {
+			return coins[i].Value > coins[j].Value
+		})
+	}
+
+	return nil
+}
+
+// insufficientCoinsError is returned by SendOutputs if the outputs of the
+// default account can't pay for the outputs along with the fee. It is an
+// InputSourceError, like the errors of btcwallet's own coin selection.
+type insufficientCoinsError struct {
+	needed    btcutil.Amount
+	available btcutil.Amount
+}
+
+func (insufficientCoinsError) InputSourceError() {}
+
+func (e insufficientCoinsError) Error() string {
+	return fmt.Sprintf("insufficient funds: need %v, only have %v "+
+		"available", e.needed, e.available)
+}
+
+// addOutputWeight accounts for the output paying to pkScript in the estimate.
+func addOutputWeight(weight *lnwallet.TxWeightEstimator, pkScript []byte) {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyHashTy:
+		weight.AddP2PKHOutput()
+	case txscript.WitnessV0PubKeyHashTy:
+		weight.AddP2WKHOutput()
+	case txscript.ScriptHashTy:
+		weight.AddP2SHOutput()
+	default:
+		weight.AddP2WSHOutput()
+	}
+}
+
+// selectCoins picks coins, in the passed order, until they pay for the outputs
+// and the fee of the transaction including a change output. With wholeAddrs
+// set, the remaining outputs of an address are picked along with its first.
+// The selected coins are returned along with the change.
+func selectCoins(coins []*lnwallet.Utxo, outputs []*wire.TxOut,
+	feeRate lnwallet.SatPerVByte,
+	wholeAddrs bool) ([]*lnwallet.Utxo, btcutil.Amount, error) {
+
+	var (
+		weight  lnwallet.TxWeightEstimator
+		target  btcutil.Amount
+		total   btcutil.Amount
+		balance btcutil.Amount
+	)
+	for _, output := range outputs {
+		addOutputWeight(&weight, output.PkScript)
+		target += btcutil.Amount(output.Value)
+	}
+	weight.AddP2WKHOutput()
+
+	for _, coin := range coins {
+		balance += coin.Value
+	}
+
+	var selected []*lnwallet.Utxo
+	for i, coin := range coins {
+		switch coin.AddressType {
+		case lnwallet.WitnessPubKey:
+			weight.AddP2WKHInput()
+		case lnwallet.NestedWitnessPubKey:
+			weight.AddNestedP2WKHInput()
+		default:
+			continue
+		}
+		selected = append(selected, coin)
+		total += coin.Value
+
+		// Keep going while the next output pays to the same address.
+		sameAddr := i+1 < len(coins) &&
+			string(coins[i+1].PkScript) == string(coin.PkScript)
+		if wholeAddrs && sameAddr {
+			continue
+		}
+
+		fee := feeRate.FeeForVSize(int64(weight.VSize()))
+		if total >= target+fee {
+			return selected, total - target - fee, nil
+		}
+	}
+
+	fee := feeRate.FeeForVSize(int64(weight.VSize()))
+	return nil, 0, insufficientCoinsError{
+		needed:    target + fee,
+		available: balance,
+	}
+}
+
+// SendOutputs funds, signs and broadcasts a transaction paying to the outputs,
+// with the outputs of the default account picked by the default coin
+// selection strategy.
+//
+// This is a part of the WalletController interface.
+func (f *fundingWallet) SendOutputs(outputs []*wire.TxOut,
+	feeRate lnwallet.SatPerVByte) (*chainhash.Hash, error) {
+
+	return f.sendOutputs(outputs, feeRate, nil)
+}
+
+// sendOutputs funds, signs and broadcasts a transaction paying to the outputs,
+// with the coins spent and the change chosen by the coin control, which may be
+// nil for the defaults. The transaction signals that it may be replaced by a
+// higher fee version through ReplaceTransaction.
+func (f *fundingWallet) sendOutputs(outputs []*wire.TxOut,
+	feeRate lnwallet.SatPerVByte, c *CoinControl) (*chainhash.Hash, error) {
+
+	if err := checkDust(outputs); err != nil {
+		return nil, err
+	}
+
+	// Hold off concurrent sends, which would otherwise pick the same
+	// coins.
+	f.sendMtx.Lock()
+	defer f.sendMtx.Unlock()
+
+	coins, err := f.coins(1, c)
+	if err != nil {
+		return nil, err
+	}
+
+	wholeAddrs := c.strategy() == CoinSelectPrivacy
+	selected, change, err := selectCoins(
+		coins, outputs, feeRate, wholeAddrs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(2)
+	inputs := make(map[wire.OutPoint]*lnwallet.Utxo, len(selected))
+	for _, coin := range selected {
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: coin.OutPoint,
+			Sequence:         rbfSequence,
+		})
+		inputs[coin.OutPoint] = coin
+	}
+	for _, output := range outputs {
+		tx.AddTxOut(output)
+	}
+
+	// Change too small to be relayed is left to the miners.
+	if change >= walletDustLimit(c.changeScriptSize()) {
+		changeAddr, err := f.changeAddress(c)
+		if err != nil {
+			return nil, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(change), changeScript))
+	}
+
+	txsort.InPlaceSort(tx)
+
+	if err := f.signTx(tx, inputs); err != nil {
+		return nil, err
+	}
+
+	if err := f.PublishTransaction(tx); err != nil {
+		return nil, err
+	}
+
+	txid := tx.TxHash()
+	return &txid, nil
+}
+
+// signTx signs every input of the transaction, all of which spend the passed
+// outputs of the wallet.
+func (f *fundingWallet) signTx(tx *wire.MsgTx,
+	inputs map[wire.OutPoint]*lnwallet.Utxo) error {
+
+	sigHashes := txscript.NewTxSigHashes(tx)
+	for i, txIn := range tx.TxIn {
+		coin := inputs[txIn.PreviousOutPoint]
+		signDesc := &lnwallet.SignDescriptor{
+			Output: &wire.TxOut{
+				Value:    int64(coin.Value),
+				PkScript: coin.PkScript,
+			},
+			HashType:   txscript.SigHashAll,
+			SigHashes:  sigHashes,
+			InputIndex: i,
+		}
+
+		inputScript, err := f.ComputeInputScript(tx, signDesc)
+		if err != nil {
+			return err
+		}
+		if inputScript == nil {
+			return fmt.Errorf("unable to sign input %v",
+				txIn.PreviousOutPoint)
+		}
+
+		txIn.SignatureScript = inputScript.ScriptSig
+		txIn.Witness = inputScript.Witness
+	}
+
+	return nil
+}
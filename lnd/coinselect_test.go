@@ -0,0 +1,106 @@
+package lnd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"golang.org/x/net/context"
+)
+
+// TestCoinControlContext checks that the coin control reaches the rpc server
+// only through contexts it was set on, once validated.
+func TestCoinControlContext(t *testing.T) {
+	if c := coinControlFromContext(nil); c != nil {
+		t.Fatalf("expected no coin control without a context")
+	}
+	if c := coinControlFromContext(context.Background()); c != nil {
+		t.Fatalf("expected no coin control on a remote context")
+	}
+
+	c := &CoinControl{Strategy: CoinSelectOldest}
+	ctx, err := WithCoinControl(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unable to set coin control: %v", err)
+	}
+	if coinControlFromContext(ctx) != c {
+		t.Fatalf("coin control not passed with the context")
+	}
+	if c.strategy() != CoinSelectOldest {
+		t.Fatalf("expected strategy %v, got %v", CoinSelectOldest,
+			c.strategy())
+	}
+
+	var none *CoinControl
+	if none.strategy() != defaultCoinSelection {
+		t.Fatalf("expected default strategy, got %v", none.strategy())
+	}
+
+	invalid := []*CoinControl{
+		{Strategy: "smallest"},
+		{ChangeType: lnwallet.UnknownAddressType},
+		{
+			ChangeAccount: "savings",
+			ChangeType:    lnwallet.NestedWitnessPubKey,
+		},
+	}
+	for i, c := range invalid {
+		_, err := WithCoinControl(context.Background(), c)
+		if err == nil {
+			t.Fatalf("coin control %d: expected error", i)
+		}
+	}
+}
+
+// TestReserveCoinControl checks that the coin control of a reservation is seen
+// only while it is made, and never by a concurrent one.
+func TestReserveCoinControl(t *testing.T) {
+	f := &fundingWallet{}
+	c := &CoinControl{Strategy: CoinSelectRandom}
+
+	if f.reservationControl() != nil {
+		t.Fatalf("expected no coin control outside a reservation")
+	}
+
+	selecting := make(chan struct{})
+	resume := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- f.reserve(c, func() error {
+			close(selecting)
+			<-resume
+			if f.reservationControl() != c {
+				t.Errorf("coin control not in effect")
+			}
+			return nil
+		})
+	}()
+	<-selecting
+
+	// A second reservation, as made for autopilot, waits for the first
+	// and then uses the defaults.
+	second := make(chan *CoinControl, 1)
+	go func() {
+		f.reserve(nil, func() error {
+			second <- f.reservationControl()
+			return nil
+		})
+	}()
+
+	select {
+	case <-second:
+		t.Fatalf("reservations weren't serialized")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(resume)
+	if err := <-done; err != nil {
+		t.Fatalf("unable to reserve: %v", err)
+	}
+	if other := <-second; other != nil {
+		t.Fatalf("coin control leaked into another reservation")
+	}
+	if f.reservationControl() != nil {
+		t.Fatalf("coin control outlived its reservation")
+	}
+}
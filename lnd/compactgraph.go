@@ -0,0 +1,286 @@
+package lnd
+
+import (
+	"os"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcd/wire"
+)
+
+const (
+	// channelDBName is the file name of the channel database within its
+	// directory.
+	channelDBName = "channel.db"
+
+	// compactFreeRatio is the share of the channel database in free pages
+	// at which compact graph mode rewrites it.
+	compactFreeRatio = 4
+
+	// compactTxSize is how many bytes are copied per transaction when
+	// rewriting a database, bounding the memory it takes.
+	compactTxSize = 4 << 20
+
+	// disabledChannelExpiry is how long both directions of a channel have
+	// to be disabled for compact graph mode to prune it.
+	disabledChannelExpiry = 24 * time.Hour
+
+	// graphPruneInterval is how often compact graph mode prunes the graph.
+	graphPruneInterval = time.Hour
+)
+
+// compactDB rewrites the bolt database at path, once at least a quarter of it
+// is free pages, into a fresh file holding its live data alone, with pages
+// filled completely. bolt never gives freed pages back to the file system, so
+// the channel updates gossiped over months otherwise keep their space. The
+// database must not be open.
+func compactDB(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	src, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// FreeAlloc is only updated by read-write transactions, while the
+	// free page count is loaded along with the freelist on open.
+	free := int64(src.Stats().FreePageN) * int64(src.Info().PageSize)
+	if free*compactFreeRatio < info.Size() {
+		return nil
+	}
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := copyDB(src, dst); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	compacted, err := os.Stat(tmpPath)
+	if err != nil {
+		return err
+	}
+	ltndLog.Infof("Compacted %v from %d to %d bytes", path, info.Size(),
+		compacted.Size())
+
+	// The rename is atomic, so the database is never lost midway.
+	src.Close()
+	return os.Rename(tmpPath, path)
+}
+
+// copyDB copies every bucket of src into the empty dst, committing every
+// compactTxSize bytes.
+func copyDB(src, dst *bolt.DB) error {
+	tx, err := dst.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	var size int
+	return src.View(func(srcTx *bolt.Tx) error {
+		// path holds the names of the buckets leading to the one being
+		// copied, which are looked up again in each new transaction.
+		var path [][]byte
+
+		var copyBucket func(b *bolt.Bucket) error
+		copyBucket = func(b *bolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				if size > compactTxSize {
+					if err := tx.Commit(); err != nil {
+						return err
+					}
+					tx, err = dst.Begin(true)
+					if err != nil {
+						return err
+					}
+					size = 0
+				}
+
+				parent := tx.Bucket(path[0])
+				for _, name := range path[1:] {
+					parent = parent.Bucket(name)
+				}
+				parent.FillPercent = 1.0
+
+				if v != nil {
+					size += len(k) + len(v)
+					return parent.Put(k, v)
+				}
+
+				child := b.Bucket(k)
+				nested, err := parent.CreateBucket(k)
+				if err != nil {
+					return err
+				}
+				err = nested.SetSequence(child.Sequence())
+				if err != nil {
+					return err
+				}
+
+				path = append(path, k)
+				err = copyBucket(child)
+				path = path[:len(path)-1]
+				return err
+			})
+		}
+
+		err := srcTx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bucket, err := tx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			if err := bucket.SetSequence(b.Sequence()); err != nil {
+				return err
+			}
+
+			path = [][]byte{name}
+			return copyBucket(b)
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// pruneGraph deletes the channels of others whose both directions have been
+// disabled for disabledChannelExpiry, which pathfinding skips, and then the
+// nodes left without channels.
+func (d *daemon) pruneGraph() error {
+	graph := d.chanDB.ChannelGraph()
+	sourceNode, err := graph.SourceNode()
+	if err != nil {
+		return err
+	}
+	self := sourceNode.PubKeyBytes
+
+	disabled := func(policy *channeldb.ChannelEdgePolicy) bool {
+		if policy == nil {
+			return true
+		}
+		age := time.Since(policy.LastUpdate)
+		return policy.Flags&lnwire.ChanUpdateDisabled != 0 &&
+			age >= disabledChannelExpiry
+	}
+
+	var chanPoints []wire.OutPoint
+	err = graph.ForEachChannel(func(info *channeldb.ChannelEdgeInfo,
+		policy1, policy2 *channeldb.ChannelEdgePolicy) error {
+
+		if info.NodeKey1Bytes == self || info.NodeKey2Bytes == self {
+			return nil
+		}
+
+		// Channels with no policy at all are left to the router, which
+		// prunes them with the zombies.
+		if policy1 == nil && policy2 == nil {
+			return nil
+		}
+		if disabled(policy1) && disabled(policy2) {
+			chanPoints = append(chanPoints, info.ChannelPoint)
+		}
+
+		return nil
+	})
+	if err != nil && err != channeldb.ErrGraphNoEdgesFound {
+		return err
+	}
+
+	for i := range chanPoints {
+		if err := graph.DeleteChannelEdge(&chanPoints[i]); err != nil {
+			return err
+		}
+	}
+
+	var lonely []*btcec.PublicKey
+	err = graph.ForEachNode(nil, func(tx *bolt.Tx,
+		node *channeldb.LightningNode) error {
+
+		if node.PubKeyBytes == self {
+			return nil
+		}
+
+		var hasChannels bool
+		err := node.ForEachChannel(tx, func(_ *bolt.Tx,
+			_ *channeldb.ChannelEdgeInfo,
+			_, _ *channeldb.ChannelEdgePolicy) error {
+
+			hasChannels = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if hasChannels {
+			return nil
+		}
+
+		pubKey, err := node.PubKey()
+		if err != nil {
+			return err
+		}
+		lonely = append(lonely, pubKey)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, pubKey := range lonely {
+		if err := graph.DeleteLightningNode(pubKey); err != nil {
+			return err
+		}
+	}
+
+	if len(chanPoints) > 0 || len(lonely) > 0 {
+		ltndLog.Infof("Pruned %d disabled channels and %d nodes "+
+			"without channels from the graph", len(chanPoints),
+			len(lonely))
+	}
+
+	return nil
+}
+
+// pruneGraphPeriodically prunes the graph every graphPruneInterval until the
+// node stops.
+func (d *daemon) pruneGraphPeriodically() {
+	defer d.wg.Done()
+	defer RecoverPanic("LTND")
+
+	ticker := time.NewTicker(graphPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.pruneGraph(); err != nil {
+			ltndLog.Errorf("Unable to prune graph: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/bbolt"
+)
+
+var (
+	testBucket = []byte("bucket")
+	testNested = []byte("nested")
+)
+
+// fillTestDB creates a bolt database at path holding n keys, half of them
+// within a nested bucket.
+func fillTestDB(t *testing.T, path string, n int) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("unable to open db: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(testBucket)
+		if err != nil {
+			return err
+		}
+		if err := bucket.SetSequence(42); err != nil {
+			return err
+		}
+		nested, err := bucket.CreateBucket(testNested)
+		if err != nil {
+			return err
+		}
+
+		value := bytes.Repeat([]byte{1}, 1024)
+		for i := 0; i < n; i++ {
+			var k [8]byte
+			binary.BigEndian.PutUint64(k[:], uint64(i))
+
+			b := bucket
+			if i%2 == 0 {
+				b = nested
+			}
+			if err := b.Put(k[:], value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to fill db: %v", err)
+	}
+}
+
+// deleteTestKeys deletes all but the first keep keys of both buckets.
+func deleteTestKeys(t *testing.T, path string, keep int) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("unable to open db: %v", err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range testBuckets(tx) {
+			var keys [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				if v != nil {
+					keys = append(keys, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(keys) > keep {
+				keys = keys[keep:]
+			} else {
+				keys = nil
+			}
+
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to delete keys: %v", err)
+	}
+}
+
+// testBuckets returns the bucket filled by fillTestDB and the one nested
+// within it.
+func testBuckets(tx *bolt.Tx) []*bolt.Bucket {
+	bucket := tx.Bucket(testBucket)
+	return []*bolt.Bucket{bucket, bucket.Bucket(testNested)}
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat %v: %v", path, err)
+	}
+	return info.Size()
+}
+
+// TestCompactDB checks that a database that's mostly free pages shrinks once
+// compacted, keeping its remaining data.
+func TestCompactDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compactdb")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, channelDBName)
+	fillTestDB(t, path, 4000)
+	deleteTestKeys(t, path, 100)
+
+	before := fileSize(t, path)
+	if err := compactDB(path); err != nil {
+		t.Fatalf("unable to compact db: %v", err)
+	}
+	after := fileSize(t, path)
+	if after >= before {
+		t.Fatalf("db didn't shrink: %d bytes before, %d after", before,
+			after)
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("unable to open compacted db: %v", err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(testBucket)
+		if bucket == nil {
+			t.Fatalf("bucket missing after compaction")
+		}
+		if bucket.Sequence() != 42 {
+			t.Fatalf("expected sequence 42, got %d",
+				bucket.Sequence())
+		}
+
+		for _, b := range testBuckets(tx) {
+			var n int
+			err := b.ForEach(func(k, v []byte) error {
+				if v != nil {
+					n++
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if n != 100 {
+				t.Fatalf("expected 100 keys, got %d", n)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to read compacted db: %v", err)
+	}
+}
+
+// TestCompactDBSkipsFull checks that a database with little free space is
+// left as it is.
+func TestCompactDBSkipsFull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compactdb")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, channelDBName)
+	fillTestDB(t, path, 4000)
+
+	before := fileSize(t, path)
+	if err := compactDB(path); err != nil {
+		t.Fatalf("unable to compact db: %v", err)
+	}
+	if after := fileSize(t, path); after != before {
+		t.Fatalf("db was rewritten: %d bytes before, %d after", before,
+			after)
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	flags "github.com/jessevdk/go-flags"
 	"github.com/lightningnetwork/lnd/brontide"
+	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/torsvc"
 	"github.com/roasbeef/btcd/btcec"
@@ -49,6 +50,25 @@ const (
 
 	defaultBroadcastDelta = 10
 
+	// defaultMaxWumboChanSize is the largest channel opened or accepted
+	// with wumbo channels enabled, unless set otherwise.
+	defaultMaxWumboChanSize = btcutil.Amount(10 * btcutil.SatoshiPerBitcoin)
+
+	// defaultChanReserveRatio is the fraction of a channel's capacity the
+	// remote peer must keep as reserve by default.
+	defaultChanReserveRatio = 0.01
+
+	// defaultWebFeeCacheTTL is how long fee estimates fetched from a web
+	// source are used before being refreshed.
+	defaultWebFeeCacheTTL = 10 * time.Minute
+
+	// defaultNodeBreakerCooldown is how long the node breaker excludes a
+	// node for by default.
+	defaultNodeBreakerCooldown = 10 * time.Minute
+
+	defaultConsolidationFeeRate     = 2
+	defaultConsolidationTargetCount = 3
+
 	// minTimeLockDelta is the minimum timelock we require for incoming
 	// HTLCs on our channels.
 	minTimeLockDelta = 4
@@ -58,6 +78,10 @@ const (
 )
 
 var (
+	// defaultWebFeeAllowHosts are the hosts fee estimates may be fetched
+	// from without being signed.
+	defaultWebFeeAllowHosts = []string{"mempool.space", "blockstream.info"}
+
 	defaultLndDir      = btcutil.AppDataDir("lnd", false)
 	defaultConfigFile = filepath.Join(defaultLndDir, defaultConfigFilename)
 	defaultDataDir    = filepath.Join(defaultLndDir, defaultDataDirname)
@@ -132,6 +156,36 @@ type autoPilotConfig struct {
 	MaxChannelSize int64   `long:"maxchansize" description:"The largest channel that the autopilot agent should create"`
 }
 
+type consolidationConfig struct {
+	Active      bool  `long:"active" description:"If small wallet outputs should be consolidated into a single one while fees are low"`
+	MaxFeeRate  int64 `long:"maxfeerate" description:"The highest estimated fee rate, in sat/vbyte, at which outputs are consolidated"`
+	TargetCount int   `long:"targetcount" description:"The number of outputs consolidation leaves the wallet with"`
+}
+
+type invoicesConfig struct {
+	RetentionDays int  `long:"retentiondays" description:"The number of days after which invoices that expired unpaid are deleted, or 0 to keep them"`
+	PruneSettled  bool `long:"prunesettled" description:"If settled invoices should be deleted too, the number of retention days after they were settled"`
+}
+
+type graphConfig struct {
+	Compact         bool     `long:"compact" description:"If the graph should be kept small for mobile devices, by compacting the channel database on startup once a quarter of it is free space and pruning channels disabled for a day along with nodes left without channels"`
+	SnapshotURL     string   `long:"snapshoturl" description:"An HTTPS URL to fetch a graph snapshot in the rapid gossip sync format from on first start, instead of waiting for gossip to fill the graph"`
+	SnapshotPubKeys []string `long:"snapshotpubkey" description:"A hex encoded public key the graph snapshot may be signed by, through a DER signature over the SHA-256 of the snapshot in its X-Signature header"`
+}
+
+type nodeBreakerConfig struct {
+	Threshold int           `long:"threshold" description:"The number of consecutive HTLC failures after which a node is excluded from the routes of payments, or 0 to never exclude nodes"`
+	Cooldown  time.Duration `long:"cooldown" description:"How long a failing node is excluded for, and after which its failures are forgotten"`
+}
+
+type webFeeConfig struct {
+	URL             string        `long:"url" description:"An HTTPS URL to fetch fee estimates from, in the mempool.space recommended fees or Esplora fee estimates format, for backends that can't estimate fees themselves"`
+	AllowHosts      []string      `long:"allowhost" description:"A host the fee estimates may be fetched from without being signed"`
+	PubKey          string        `long:"pubkey" description:"The hex encoded public key the fee estimates must be signed by, through a DER signature over the SHA-256 of the response in its X-Signature header"`
+	CacheTTL        time.Duration `long:"cachettl" description:"How long fetched fee estimates are used before being refreshed"`
+	FallbackFeeRate int64         `long:"fallbackfeerate" description:"The fee rate, in sat/vbyte, used while no fee estimates could be fetched"`
+}
+
 type torConfig struct {
 	Socks           string `long:"socks" description:"The port that Tor's exposed SOCKS5 proxy is listening on. Using Tor allows outbound-only connections (listening will be disabled) -- NOTE port must be between 1024 and 65535"`
 	DNS             string `long:"dns" description:"The DNS server as IP:PORT that Tor will use for SRV queries - NOTE must have TCP resolution enabled"`
@@ -158,6 +212,8 @@ type config struct {
 	InvoiceMacPath string `long:"invoicemacaroonpath" description:"Path to the invoice-only macaroon for lnd's RPC and REST services if it doesn't exist"`
 	LogDir         string `long:"logdir" description:"Directory to log output."`
 
+	RemoveMacaroons bool `long:"removemacaroons" description:"With --no-macaroons, delete the macaroon files and database left by earlier runs. Remote apps paired with them have to be paired anew"`
+
 	RPCListeners  []string `long:"rpclisten" description:"Add an interface/port to listen for RPC connections"`
 	RESTListeners []string `long:"restlisten" description:"Add an interface/port to listen for REST connections"`
 	Listeners     []string `long:"listen" description:"Add an interface/port to listen for peer connections"`
@@ -174,8 +230,18 @@ type config struct {
 	HodlHTLC           bool `long:"hodlhtlc" description:"Activate the hodl HTLC mode.  With hodl HTLC mode, all incoming HTLCs will be accepted by the receiving node, but no attempt will be made to settle the payment with the sender."`
 	UnsafeDisconnect   bool `long:"unsafe-disconnect" description:"Allows the rpcserver to intentionally disconnect from peers with open channels. USED FOR TESTING ONLY."`
 	UnsafeReplay       bool `long:"unsafe-replay" description:"Causes a link to replay the adds on its commitment txn after starting up, this enables testing of the sphinx replay logic."`
+	UnsafeAbandon      bool `long:"unsafe-abandonchannel" description:"Allows pending channels whose funding flow failed to be abandoned, deleting them from the channel database. Abandoning a channel whose funding transaction confirms loses its funds."`
 	MaxPendingChannels int  `long:"maxpendingchannels" description:"The maximum number of incoming pending channels permitted per peer."`
 
+	WalletDustLimit  int64   `long:"walletdustlimit" description:"The smallest output, in satoshis, the wallet creates. Smaller change is left to the miners, and smaller payments are rejected. The network's dust threshold applies however low it is set."`
+	ChanReserveRatio float64 `long:"chanreserveratio" description:"The fraction of a channel's capacity the remote peer is required to keep as reserve"`
+	MinChanReserve   int64   `long:"minchanreserve" description:"The smallest reserve, in satoshis, the remote peer is required to keep"`
+
+	WumboChannels bool  `long:"protocol.wumbo-channels" description:"Signal support for channels above the 16777216 satoshi limit, and open or accept them with peers that signal it too"`
+	MaxChanSize   int64 `long:"maxchansize" description:"The largest channel, in satoshis, opened or accepted. It can only exceed 16777216 satoshis with wumbo channels enabled"`
+
+	Outbox bool `long:"outbox" description:"If payments may be queued while offline, to be sent once a peer connects and retried while no route is found"`
+
 	Bitcoin      *chainConfig    `group:"Bitcoin" namespace:"bitcoin"`
 	BtcdMode     *btcdConfig     `group:"btcd" namespace:"btcd"`
 	BitcoindMode *bitcoindConfig `group:"bitcoind" namespace:"bitcoind"`
@@ -187,6 +253,16 @@ type config struct {
 
 	Autopilot *autoPilotConfig `group:"autopilot" namespace:"autopilot"`
 
+	Consolidation *consolidationConfig `group:"consolidation" namespace:"consolidation"`
+
+	Invoices *invoicesConfig `group:"invoices" namespace:"invoices"`
+
+	Graph *graphConfig `group:"graph" namespace:"graph"`
+
+	NodeBreaker *nodeBreakerConfig `group:"nodebreaker" namespace:"nodebreaker"`
+
+	WebFee *webFeeConfig `group:"webfee" namespace:"webfee"`
+
 	Tor *torConfig `group:"Tor" namespace:"tor"`
 
 	NoNetBootstrap bool `long:"nobootstrap" description:"If true, then automatic network bootstrapping will not be attempted."`
@@ -209,9 +285,14 @@ type config struct {
 // 	2) Pre-parse the command line to check for an alternative config file
 // 	3) Load configuration file overwriting defaults with any specified options
 // 	4) Parse CLI options and overwrite/add any specified options
-func loadConfig(dataDirec string) (*config, error) {
+//
+// If args is nil, the command line options are taken from os.Args.
+func loadConfig(dataDirec string, args []string) (*config, error) {
+	if args == nil {
+		args = os.Args[1:]
+	}
 
-	defaultLndDir       = filepath.Join(dataDirec, "Lnd")//btcutil.AppDataDir("lnd", false)
+	defaultLndDir       = filepath.Join(dataDirec, lndDirname)//btcutil.AppDataDir("lnd", false)
 	defaultConfigFile   = filepath.Join(defaultLndDir, defaultConfigFilename)
 	defaultDataDir      = filepath.Join(defaultLndDir, defaultDataDirname)
 	defaultTLSCertPath  = filepath.Join(defaultLndDir, defaultTLSCertFilename)
@@ -272,6 +353,9 @@ func loadConfig(dataDirec string) (*config, error) {
 			RPCHost: defaultRPCHost,
 		},
 		MaxPendingChannels: defaultMaxPendingChannels,
+		WalletDustLimit:    int64(lnwallet.DefaultDustLimit()),
+		ChanReserveRatio:   defaultChanReserveRatio,
+		MinChanReserve:     int64(lnwallet.DefaultDustLimit()),
 		NoEncryptWallet:    defaultNoEncryptWallet,
 		Autopilot: &autoPilotConfig{
 			MaxChannels:    5,
@@ -279,6 +363,20 @@ func loadConfig(dataDirec string) (*config, error) {
 			MinChannelSize: int64(minChanFundingSize),
 			MaxChannelSize: int64(maxFundingAmount),
 		},
+		Consolidation: &consolidationConfig{
+			MaxFeeRate:  defaultConsolidationFeeRate,
+			TargetCount: defaultConsolidationTargetCount,
+		},
+		Invoices: &invoicesConfig{},
+		Graph:    &graphConfig{},
+		NodeBreaker: &nodeBreakerConfig{
+			Cooldown: defaultNodeBreakerCooldown,
+		},
+		WebFee: &webFeeConfig{
+			AllowHosts:      defaultWebFeeAllowHosts,
+			CacheTTL:        defaultWebFeeCacheTTL,
+			FallbackFeeRate: int64(defaultBitcoinStaticFeeRate),
+		},
 		TrickleDelay: defaultTrickleDelay,
 		Alias:        defaultAlias,
 		Color:        defaultColor,
@@ -287,7 +385,7 @@ func loadConfig(dataDirec string) (*config, error) {
 	// Pre-parse the command line options to pick up an alternative config
 	// file.
 	preCfg := defaultCfg
-	if _, err := flags.Parse(&preCfg); err != nil {
+	if _, err := flags.ParseArgs(&preCfg, args); err != nil {
 		return nil, err
 	}
 
@@ -343,7 +441,7 @@ func loadConfig(dataDirec string) (*config, error) {
 
 	// Finally, parse the remaining command line options again to ensure
 	// they take precedence.
-	if _, err := flags.Parse(&cfg); err != nil {
+	if _, err := flags.ParseArgs(&cfg, args); err != nil {
 		return nil, err
 	}
 
@@ -394,10 +492,104 @@ func loadConfig(dataDirec string) (*config, error) {
 	if cfg.Autopilot.MinChannelSize < int64(minChanFundingSize) {
 		cfg.Autopilot.MinChannelSize = int64(minChanFundingSize)
 	}
+	// Channels may only exceed the protocol's soft-limit with wumbo
+	// channels enabled, in which case the default limit is raised.
+	if cfg.MaxChanSize == 0 {
+		cfg.MaxChanSize = int64(maxFundingAmount)
+		if cfg.WumboChannels {
+			cfg.MaxChanSize = int64(defaultMaxWumboChanSize)
+		}
+	}
+	if cfg.MaxChanSize < int64(minChanFundingSize) {
+		str := "%s: maxchansize must be at least %d"
+		err := fmt.Errorf(str, funcName, int64(minChanFundingSize))
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	if !cfg.WumboChannels && cfg.MaxChanSize > int64(maxFundingAmount) {
+		str := "%s: maxchansize can only exceed %d with " +
+			"protocol.wumbo-channels"
+		err := fmt.Errorf(str, funcName, int64(maxFundingAmount))
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+
 	if cfg.Autopilot.MaxChannelSize > int64(maxFundingAmount) {
 		cfg.Autopilot.MaxChannelSize = int64(maxFundingAmount)
 	}
 
+	// The reserve must stay within what peers accept, and can't be
+	// below the dust limit, or the reserve's output couldn't be spent.
+	if cfg.WalletDustLimit < 0 {
+		str := "%s: walletdustlimit must be non-negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	if cfg.ChanReserveRatio < 0 || cfg.ChanReserveRatio > 0.2 {
+		str := "%s: chanreserveratio must be between 0 and 0.2"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	if cfg.MinChanReserve < int64(lnwallet.DefaultDustLimit()) {
+		str := "%s: minchanreserve must be at least the dust " +
+			"limit of %d"
+		err := fmt.Errorf(str, funcName, lnwallet.DefaultDustLimit())
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+
+	// Consolidation needs at least one output to consolidate into.
+	if cfg.Consolidation.MaxFeeRate < 0 {
+		str := "%s: consolidation.maxfeerate must be non-negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	if cfg.Consolidation.TargetCount < 1 {
+		str := "%s: consolidation.targetcount must be positive"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+
+	if cfg.Invoices.RetentionDays < 0 {
+		str := "%s: invoices.retentiondays must be non-negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+
+	if cfg.NodeBreaker.Threshold < 0 {
+		str := "%s: nodebreaker.threshold must be non-negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+	if cfg.NodeBreaker.Threshold > 0 &&
+		cfg.NodeBreaker.Cooldown < time.Minute {
+
+		str := "%s: nodebreaker.cooldown must be at least a minute"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+
+	if _, err := validateGraphConfig(cfg.Graph); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, err
+	}
+
+	if cfg.WebFee.URL != "" {
+		if _, err := validateWebFeeConfig(cfg.WebFee); err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, err
+		}
+	}
+
 	// Setup dial and DNS resolution functions depending on the specified
 	// options. The default is to use the standard golang "net" package
 	// functions. When Tor's proxy is specified, the dial function is set to
@@ -688,11 +880,9 @@ func loadConfig(dataDirec string) (*config, error) {
 		return nil, err
 	}
 
-	// At least one RPCListener is required.
-	if len(cfg.RPCListeners) == 0 {
-		addr := fmt.Sprintf("localhost:%d", defaultRPCPort)
-		cfg.RPCListeners = append(cfg.RPCListeners, addr)
-	}
+	// The bindings reach the rpc server in-process, and the TLS gRPC
+	// listeners hand control of the node to remote apps, so unlike lnd's
+	// there are none unless asked for.
 
 	// Listen on the default interface/port if no REST listeners were
 	// specified.
@@ -0,0 +1,163 @@
+package lnd
+
+import (
+	"sort"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+)
+
+// consolidationConfTarget is the confirmation target the fee rate is estimated
+// for before consolidating. Consolidation is never urgent, so it waits for the
+// rate of a slow confirmation to drop.
+const consolidationConfTarget = 144
+
+// consolidate spends the smallest confirmed outputs of the default account
+// into a single one, leaving the account with targetCount outputs, provided
+// the estimated fee rate is at most maxFeeRate. Outputs worth less than the
+// fee of spending them are left alone.
+func (d *daemon) consolidate(maxFeeRate lnwallet.SatPerVByte,
+	targetCount int) error {
+
+	feeRate, err := d.cc.feeEstimator.EstimateFeePerVSize(
+		consolidationConfTarget,
+	)
+	if err != nil {
+		return err
+	}
+	if feeRate > maxFeeRate {
+		ltndLog.Debugf("Not consolidating outputs at fee rate %v "+
+			"sat/vbyte", int64(feeRate))
+		return nil
+	}
+
+	f := d.wallet()
+
+	// Hold off sends, which would otherwise race for the same outputs.
+	f.sendMtx.Lock()
+	defer f.sendMtx.Unlock()
+
+	coins, err := f.defaultUtxos(1)
+	if err != nil {
+		return err
+	}
+	if len(coins) <= targetCount {
+		return nil
+	}
+
+	sort.Slice(coins, func(i, j int) bool {
+		return coins[i].Value < coins[j].Value
+	})
+
+	var (
+		weight lnwallet.TxWeightEstimator
+		total  btcutil.Amount
+	)
+	tx := wire.NewMsgTx(2)
+	inputs := make(map[wire.OutPoint]*lnwallet.Utxo)
+	for _, coin := range coins[:len(coins)-targetCount+1] {
+		var input lnwallet.TxWeightEstimator
+		switch coin.AddressType {
+		case lnwallet.WitnessPubKey:
+			input.AddP2WKHInput()
+		case lnwallet.NestedWitnessPubKey:
+			input.AddNestedP2WKHInput()
+		default:
+			continue
+		}
+
+		// Skip outputs that would cost more than they're worth, leaving
+		// out the size of the transaction itself.
+		var empty lnwallet.TxWeightEstimator
+		inputVSize := int64(input.VSize() - empty.VSize())
+		if coin.Value <= feeRate.FeeForVSize(inputVSize) {
+			continue
+		}
+
+		if coin.AddressType == lnwallet.WitnessPubKey {
+			weight.AddP2WKHInput()
+		} else {
+			weight.AddNestedP2WKHInput()
+		}
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: coin.OutPoint,
+			Sequence:         rbfSequence,
+		})
+		inputs[coin.OutPoint] = coin
+		total += coin.Value
+	}
+	if len(tx.TxIn) < 2 {
+		return nil
+	}
+	weight.AddP2WKHOutput()
+
+	fee := feeRate.FeeForVSize(int64(weight.VSize()))
+	if total-fee < walletDustLimit(lnwallet.P2WPKHSize) {
+		return nil
+	}
+
+	addr, err := f.BtcWallet.NewAddress(lnwallet.WitnessPubKey, true)
+	if err != nil {
+		return err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return err
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(total-fee), pkScript))
+
+	if err := f.signTx(tx, inputs); err != nil {
+		return err
+	}
+	if err := f.PublishTransaction(tx); err != nil {
+		return err
+	}
+
+	ltndLog.Infof("Consolidated %v outputs in %v at fee rate %v sat/vbyte",
+		len(tx.TxIn), tx.TxHash(), int64(feeRate))
+
+	publishEvent(EventConsolidation, &ConsolidationEvent{
+		TxHash:    tx.TxHash().String(),
+		NumInputs: len(tx.TxIn),
+		Amount:    int64(total - fee),
+		Fee:       int64(fee),
+	})
+
+	return nil
+}
+
+// consolidateOutputs attempts to consolidate the wallet's outputs on every new
+// block, until the daemon is stopped. It must be run as a goroutine.
+func (d *daemon) consolidateOutputs(maxFeeRate lnwallet.SatPerVByte,
+	targetCount int) {
+
+	defer d.wg.Done()
+	defer RecoverPanic("LTND")
+
+	blockEpochs, err := d.cc.chainNotifier.RegisterBlockEpochNtfn()
+	if err != nil {
+		ltndLog.Errorf("Unable to register for blocks, outputs won't "+
+			"be consolidated: %v", err)
+		return
+	}
+	defer blockEpochs.Cancel()
+
+	for {
+		select {
+		case _, ok := <-blockEpochs.Epochs:
+			if !ok {
+				return
+			}
+
+			if err := d.consolidate(maxFeeRate, targetCount); err != nil {
+				ltndLog.Errorf("Unable to consolidate outputs: %v",
+					err)
+			}
+
+		case <-d.quit:
+			return
+		}
+	}
+}
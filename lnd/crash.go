@@ -0,0 +1,47 @@
+package lnd
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	// panicHandlerMtx guards panicHandler.
+	panicHandlerMtx sync.RWMutex
+
+	// panicHandler is called with every panic recovered by recoverPanic.
+	panicHandler func(subsystem string, reason interface{}, stack []byte)
+)
+
+// SetPanicHandler registers a function that is called with the subsystem,
+// reason and stack trace of any panic recovered within the goroutines spawned
+// for the host app. Passing nil removes the handler.
+func SetPanicHandler(handler func(subsystem string, reason interface{},
+	stack []byte)) {
+
+	panicHandlerMtx.Lock()
+	panicHandler = handler
+	panicHandlerMtx.Unlock()
+}
+
+// RecoverPanic recovers a panic within the calling goroutine and reports it
+// to the panic handler, instead of taking down the host app. It must be
+// deferred directly.
+func RecoverPanic(subsystem string) {
+	reason := recover()
+	if reason == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	ltndLog.Criticalf("Recovered panic in %v: %v\n%s", subsystem, reason,
+		stack)
+
+	panicHandlerMtx.RLock()
+	handler := panicHandler
+	panicHandlerMtx.RUnlock()
+
+	if handler != nil {
+		handler(subsystem, reason, stack)
+	}
+}
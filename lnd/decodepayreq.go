@@ -0,0 +1,116 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/roasbeef/btcd/chaincfg"
+)
+
+// ErrUnknownPayReqNetwork is returned for a payment request of none of the
+// networks the node can run on.
+var ErrUnknownPayReqNetwork = errors.New("payment request of unknown " +
+	"network")
+
+// payReqNetworks are the networks payment requests are decoded for. Regtest
+// requests share the prefix of testnet ones with this version of lnd.
+var payReqNetworks = []*chaincfg.Params{
+	bitcoinMainNetParams.Params,
+	bitcoinTestNetParams.Params,
+	bitcoinSimNetParams.Params,
+}
+
+// PaymentRequest is a decoded payment request.
+type PaymentRequest struct {
+	// Network is the name of the network of the request, such as mainnet
+	// or testnet3.
+	Network     string `json:"network"`
+	Destination string `json:"destination"`
+	PaymentHash string `json:"payment_hash"`
+
+	// AmountMsat is the amount requested, or zero if the payer picks it.
+	AmountMsat int64 `json:"amount_msat"`
+
+	// Timestamp is when the request was created, and Expiry the number of
+	// seconds it can be paid for since.
+	Timestamp int64 `json:"timestamp"`
+	Expiry    int64 `json:"expiry"`
+
+	Description     string `json:"description"`
+	DescriptionHash string `json:"description_hash,omitempty"`
+	FallbackAddr    string `json:"fallback_addr,omitempty"`
+	CltvExpiry      uint64 `json:"cltv_expiry"`
+
+	// RouteHint is the route to the destination over its private
+	// channels, if the request holds one.
+	RouteHint []*HopHint `json:"route_hint"`
+}
+
+// DecodePaymentRequest decodes the payment request, whose network is told by
+// its prefix, as is or as a lightning: URI. It needs no running node.
+func DecodePaymentRequest(payReq string) (*PaymentRequest, error) {
+	payReq = strings.TrimSpace(payReq)
+	if strings.HasPrefix(strings.ToLower(payReq), "lightning:") {
+		payReq = payReq[len("lightning:"):]
+	}
+
+	var net *chaincfg.Params
+	for _, params := range payReqNetworks {
+		prefix := "ln" + params.Bech32HRPSegwit
+		if strings.HasPrefix(strings.ToLower(payReq), prefix) {
+			net = params
+			break
+		}
+	}
+	if net == nil {
+		return nil, ErrUnknownPayReqNetwork
+	}
+
+	invoice, err := zpay32.Decode(payReq, net)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &PaymentRequest{
+		Network: net.Name,
+		Destination: hex.EncodeToString(
+			invoice.Destination.SerializeCompressed(),
+		),
+		PaymentHash: hex.EncodeToString(invoice.PaymentHash[:]),
+		Timestamp:   invoice.Timestamp.Unix(),
+		Expiry:      int64(invoice.Expiry().Seconds()),
+		CltvExpiry:  invoice.MinFinalCLTVExpiry(),
+		RouteHint:   []*HopHint{},
+	}
+	if invoice.MilliSat != nil {
+		decoded.AmountMsat = int64(*invoice.MilliSat)
+	}
+	if invoice.Description != nil {
+		decoded.Description = *invoice.Description
+	}
+	if invoice.DescriptionHash != nil {
+		decoded.DescriptionHash = hex.EncodeToString(
+			invoice.DescriptionHash[:],
+		)
+	}
+	if invoice.FallbackAddr != nil {
+		decoded.FallbackAddr = invoice.FallbackAddr.String()
+	}
+
+	for _, hop := range invoice.RoutingInfo {
+		hint := &HopHint{
+			NodeID: hex.EncodeToString(
+				hop.PubKey.SerializeCompressed(),
+			),
+			ChanID:          hop.ShortChanID,
+			FeeBaseMsat:     hop.FeeBaseMsat,
+			CltvExpiryDelta: hop.CltvExpDelta,
+		}
+		hint.FeeProportionalMillionths = hop.FeeProportionalMillionths
+		decoded.RouteHint = append(decoded.RouteHint, hint)
+	}
+
+	return decoded, nil
+}
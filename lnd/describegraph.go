@@ -0,0 +1,149 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// ErrInvalidGraphCursor is returned for a cursor of channels that isn't a
+// short channel ID.
+var ErrInvalidGraphCursor = errors.New("invalid graph cursor")
+
+// errPageFull stops iterating the graph once a page is full.
+var errPageFull = errors.New("page full")
+
+// GraphQuery selects a page of the nodes or channels of the graph listed by
+// DescribeGraphNodes and DescribeGraphEdges.
+type GraphQuery struct {
+	// Cursor is where the previous page ended, or empty for the first
+	// page.
+	Cursor string
+
+	// MaxItems is the size of the page, or zero for the whole graph.
+	MaxItems int
+
+	// ChangedSince limits the page to nodes announced, or channels with a
+	// policy updated, at or after it, if it isn't zero.
+	ChangedSince time.Time
+}
+
+// DescribeGraphNodes returns a page of the nodes of the graph matching the
+// query, in the order of their public keys, along with the cursor of the next
+// page, which is empty on the last one. The cursor is the hex encoded public
+// key of the last node of the page.
+func DescribeGraphNodes(query *GraphQuery) (*lnrpc.ChannelGraph, string,
+	error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, "", ErrDaemonNotRunning
+	}
+
+	// Nodes are iterated in the order of their keys, the serialized
+	// public keys, which the order of their hex encodings matches.
+	var nextCursor string
+	resp := &lnrpc.ChannelGraph{}
+	graph := d.chanDB.ChannelGraph()
+	err := graph.ForEachNode(nil, func(_ *bolt.Tx,
+		node *channeldb.LightningNode) error {
+
+		pubKey := hex.EncodeToString(node.PubKeyBytes[:])
+		if pubKey <= query.Cursor ||
+			node.LastUpdate.Before(query.ChangedSince) {
+
+			return nil
+		}
+
+		// A node past the end of a full page means there is a next
+		// one.
+		if query.MaxItems > 0 && len(resp.Nodes) == query.MaxItems {
+			nextCursor = resp.Nodes[len(resp.Nodes)-1].PubKey
+			return errPageFull
+		}
+
+		resp.Nodes = append(resp.Nodes, marshalDbNode(node))
+		return nil
+	})
+	if err != nil && err != errPageFull {
+		return nil, "", err
+	}
+
+	return resp, nextCursor, nil
+}
+
+// DescribeGraphEdges returns a page of the channels of the graph matching the
+// query, in the order of their short channel IDs, along with the cursor of the
+// next page, which is empty on the last one. The cursor is the short channel
+// ID of the last channel of the page.
+func DescribeGraphEdges(query *GraphQuery) (*lnrpc.ChannelGraph, string,
+	error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, "", ErrDaemonNotRunning
+	}
+
+	var cursor uint64
+	if query.Cursor != "" {
+		var err error
+		cursor, err = strconv.ParseUint(query.Cursor, 10, 64)
+		if err != nil {
+			return nil, "", ErrInvalidGraphCursor
+		}
+	}
+
+	changed := func(policy *channeldb.ChannelEdgePolicy) bool {
+		return policy != nil &&
+			!policy.LastUpdate.Before(query.ChangedSince)
+	}
+
+	// Channels are iterated in the order of their big endian short
+	// channel IDs.
+	var nextCursor string
+	resp := &lnrpc.ChannelGraph{}
+	graph := d.chanDB.ChannelGraph()
+	err := graph.ForEachChannel(func(info *channeldb.ChannelEdgeInfo,
+		policy1, policy2 *channeldb.ChannelEdgePolicy) error {
+
+		if query.Cursor != "" && info.ChannelID <= cursor {
+			return nil
+		}
+		if !query.ChangedSince.IsZero() &&
+			!changed(policy1) && !changed(policy2) {
+
+			return nil
+		}
+
+		// A channel past the end of a full page means there is a next
+		// one.
+		if query.MaxItems > 0 && len(resp.Edges) == query.MaxItems {
+			last := resp.Edges[len(resp.Edges)-1]
+			nextCursor = strconv.FormatUint(last.ChannelId, 10)
+			return errPageFull
+		}
+
+		resp.Edges = append(
+			resp.Edges, marshalDbEdge(info, policy1, policy2),
+		)
+		return nil
+	})
+	if err != nil && err != errPageFull &&
+		err != channeldb.ErrGraphNoEdgesFound {
+
+		return nil, "", err
+	}
+
+	return resp, nextCursor, nil
+}
@@ -0,0 +1,72 @@
+package lnd
+
+import (
+	"fmt"
+
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/wallet/txrules"
+)
+
+// ErrDustOutput is returned for a payment too small to be worth creating an
+// output for, either by the wallet's dust limit or by the network's relay
+// policy.
+type ErrDustOutput struct {
+	Amount btcutil.Amount
+	Limit  btcutil.Amount
+}
+
+func (e *ErrDustOutput) Error() string {
+	return fmt.Sprintf("output of %v is below the dust limit of %v",
+		e.Amount, e.Limit)
+}
+
+// p2shScriptSize is the size of a pay to script hash output script, as paid to
+// by nested segwit change.
+const p2shScriptSize = 1 + 1 + 20 + 1
+
+// walletDustLimit returns the smallest output paying to a script of the given
+// size the wallet creates. That's the wallet's dust limit, or the dust
+// threshold of the network's relay policy, which applies no matter how low the
+// wallet's limit is set. Change below it is left to the miners, and payments
+// below it are rejected.
+func walletDustLimit(scriptSize int) btcutil.Amount {
+	limit := txrules.GetDustThreshold(
+		scriptSize, txrules.DefaultRelayFeePerKb,
+	)
+	configured := btcutil.Amount(cfg.WalletDustLimit)
+	if configured > limit {
+		limit = configured
+	}
+
+	return limit
+}
+
+// checkDust returns an ErrDustOutput for the first output below the wallet's
+// dust limit.
+func checkDust(outputs []*wire.TxOut) error {
+	for _, output := range outputs {
+		limit := walletDustLimit(len(output.PkScript))
+		if btcutil.Amount(output.Value) < limit {
+			return &ErrDustOutput{
+				Amount: btcutil.Amount(output.Value),
+				Limit:  limit,
+			}
+		}
+	}
+
+	return nil
+}
+
+// requiredChanReserve returns the reserve the remote peer must keep in a
+// channel of the given capacity, a configured fraction of the capacity but no
+// less than the configured minimum.
+func requiredChanReserve(chanAmt btcutil.Amount) btcutil.Amount {
+	reserve := btcutil.Amount(float64(chanAmt) * cfg.ChanReserveRatio)
+	minReserve := btcutil.Amount(cfg.MinChanReserve)
+	if reserve < minReserve {
+		reserve = minReserve
+	}
+
+	return reserve
+}
@@ -0,0 +1,46 @@
+package lnd
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/wallet/txrules"
+)
+
+// TestWalletDustLimit checks that the network's dust threshold for the script
+// applies however low the wallet's dust limit is set.
+func TestWalletDustLimit(t *testing.T) {
+	defer func(c *config) { cfg = c }(cfg)
+
+	scriptSizes := []int{lnwallet.P2WPKHSize, p2shScriptSize, 25}
+	for _, size := range scriptSizes {
+		threshold := txrules.GetDustThreshold(
+			size, txrules.DefaultRelayFeePerKb,
+		)
+
+		cfg = &config{WalletDustLimit: 0}
+		if limit := walletDustLimit(size); limit != threshold {
+			t.Fatalf("script of %d bytes: expected threshold %v, "+
+				"got %v", size, threshold, limit)
+		}
+
+		cfg = &config{WalletDustLimit: int64(threshold) + 1000}
+		if limit := walletDustLimit(size); limit != threshold+1000 {
+			t.Fatalf("script of %d bytes: expected configured "+
+				"limit %v, got %v", size, threshold+1000, limit)
+		}
+	}
+
+	cfg = &config{WalletDustLimit: 0}
+	pkScript := make([]byte, lnwallet.P2WPKHSize)
+	threshold := walletDustLimit(len(pkScript))
+	outputs := []*wire.TxOut{wire.NewTxOut(int64(threshold)-1, pkScript)}
+	err := checkDust(outputs)
+	if dustErr, ok := err.(*ErrDustOutput); !ok ||
+		dustErr.Limit != btcutil.Amount(threshold) {
+
+		t.Fatalf("expected dust error at %v, got %v", threshold, err)
+	}
+}
@@ -0,0 +1,263 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+)
+
+// The event types published through SubscribeEvents.
+const (
+	EventInvoiceSettled   = "invoice_settled"
+	EventChannelOpened    = "channel_opened"
+	EventChannelClosed    = "channel_closed"
+	EventPeerConnected    = "peer_connected"
+	EventPeerDisconnected = "peer_disconnected"
+	EventChainSync        = "chain_sync"
+	EventSweep            = "sweep"
+	EventRecovery         = "recovery"
+	EventConsolidation    = "consolidation"
+	EventOutbox           = "outbox"
+)
+
+// Event is a single tagged notification published on the event bus. The
+// payload is either an lnrpc message or one of the event structs below.
+type Event struct {
+	Type    string
+	Payload interface{}
+}
+
+// ChannelEvent describes a channel that was opened or closed.
+type ChannelEvent struct {
+	ChannelPoint  string `json:"channel_point"`
+	ChanID        uint64 `json:"chan_id"`
+	RemotePubkey  string `json:"remote_pubkey,omitempty"`
+	Capacity      int64  `json:"capacity,omitempty"`
+	ClosingTxHash string `json:"closing_tx_hash,omitempty"`
+	CloseHeight   uint32 `json:"close_height,omitempty"`
+}
+
+// PeerEvent describes a peer that connected or disconnected.
+type PeerEvent struct {
+	PubKey  string `json:"pub_key"`
+	Address string `json:"address"`
+	Inbound bool   `json:"inbound"`
+}
+
+// ChainSyncEvent reports the progress of the chain backend.
+type ChainSyncEvent struct {
+	BlockHeight int32  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	Synced      bool   `json:"synced"`
+}
+
+// SweepEvent describes a transaction sweeping matured outputs back into the
+// wallet.
+type SweepEvent struct {
+	TxHash     string `json:"tx_hash"`
+	NumOutputs int    `json:"num_outputs"`
+	Height     uint32 `json:"height"`
+}
+
+// ConsolidationEvent describes a transaction consolidating small outputs of
+// the wallet into a single one.
+type ConsolidationEvent struct {
+	TxHash    string `json:"tx_hash"`
+	NumInputs int    `json:"num_inputs"`
+	Amount    int64  `json:"amount"`
+	Fee       int64  `json:"fee"`
+}
+
+// EventClient is an intent to receive all events published after it was
+// created. Events are queued without bound, so a slow client never blocks the
+// subsystem publishing the event.
+type EventClient struct {
+	// Events is sent upon for every published event.
+	Events <-chan *Event
+
+	incoming chan *Event
+	events   chan *Event
+
+	id   uint64
+	quit chan struct{}
+	once sync.Once
+}
+
+// Cancel unregisters the client, freeing any previously allocated resources.
+func (c *EventClient) Cancel() {
+	c.once.Do(func() {
+		eventClientMtx.Lock()
+		delete(eventClients, c.id)
+		eventClientMtx.Unlock()
+
+		close(c.quit)
+	})
+}
+
+// queueEvents moves events from the incoming channel onto the events channel,
+// buffering them in between so publishers never wait on the client.
+func (c *EventClient) queueEvents() {
+	defer RecoverPanic("LTND")
+
+	var pending []*Event
+	for {
+		var (
+			next *Event
+			out  chan *Event
+		)
+		if len(pending) > 0 {
+			next = pending[0]
+			out = c.events
+		}
+
+		select {
+		case event := <-c.incoming:
+			pending = append(pending, event)
+
+		case out <- next:
+			pending[0] = nil
+			pending = pending[1:]
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+var (
+	// eventClientMtx guards eventClients and nextEventClientID.
+	eventClientMtx sync.Mutex
+
+	eventClients      = make(map[uint64]*EventClient)
+	nextEventClientID uint64
+)
+
+// SubscribeEvents registers a new client of the event bus. The subscription
+// outlives daemon restarts, and must be cancelled once no longer needed.
+func SubscribeEvents() *EventClient {
+	events := make(chan *Event)
+	client := &EventClient{
+		Events:   events,
+		incoming: make(chan *Event),
+		events:   events,
+		quit:     make(chan struct{}),
+	}
+
+	eventClientMtx.Lock()
+	client.id = nextEventClientID
+	nextEventClientID++
+	eventClients[client.id] = client
+	eventClientMtx.Unlock()
+
+	go client.queueEvents()
+
+	return client
+}
+
+// publishEvent delivers the event to all clients of the event bus.
+func publishEvent(eventType string, payload interface{}) {
+	event := &Event{
+		Type:    eventType,
+		Payload: payload,
+	}
+
+	eventClientMtx.Lock()
+	defer eventClientMtx.Unlock()
+
+	for _, client := range eventClients {
+		select {
+		case client.incoming <- event:
+		case <-client.quit:
+		}
+	}
+}
+
+// newPeerEvent creates the event describing the passed peer.
+func newPeerEvent(p *peer) *PeerEvent {
+	return &PeerEvent{
+		PubKey:  hex.EncodeToString(p.addr.IdentityKey.SerializeCompressed()),
+		Address: p.addr.Address.String(),
+		Inbound: p.inbound,
+	}
+}
+
+// closedChannels returns the close summaries of all closed channels, keyed by
+// their channel point.
+func closedChannels(chanDB *channeldb.DB) (map[string]*channeldb.ChannelCloseSummary,
+	error) {
+
+	summaries, err := chanDB.FetchClosedChannels(false)
+	if err != nil && err != channeldb.ErrNoClosedChannels {
+		return nil, err
+	}
+
+	closed := make(map[string]*channeldb.ChannelCloseSummary)
+	for _, summary := range summaries {
+		closed[summary.ChanPoint.String()] = summary
+	}
+
+	return closed, nil
+}
+
+// notifyChainEvents publishes an event for every new block, along with an
+// event for every channel closed within it. It runs until the daemon is
+// stopped.
+func (d *daemon) notifyChainEvents() error {
+	blockEpochs, err := d.cc.chainNotifier.RegisterBlockEpochNtfn()
+	if err != nil {
+		return err
+	}
+	defer blockEpochs.Cancel()
+
+	knownClosed, err := closedChannels(d.chanDB)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case epoch, ok := <-blockEpochs.Epochs:
+			if !ok {
+				return nil
+			}
+
+			publishEvent(EventChainSync, &ChainSyncEvent{
+				BlockHeight: epoch.Height,
+				BlockHash:   epoch.Hash.String(),
+				Synced:      true,
+			})
+
+			closed, err := closedChannels(d.chanDB)
+			if err != nil {
+				ltndLog.Errorf("unable to fetch closed "+
+					"channels: %v", err)
+				continue
+			}
+
+			for chanPoint, summary := range closed {
+				if _, ok := knownClosed[chanPoint]; ok {
+					continue
+				}
+
+				event := &ChannelEvent{
+					ChannelPoint:  chanPoint,
+					ChanID:        summary.ShortChanID.ToUint64(),
+					Capacity:      int64(summary.Capacity),
+					ClosingTxHash: summary.ClosingTXID.String(),
+					CloseHeight:   summary.CloseHeight,
+				}
+				if summary.RemotePub != nil {
+					event.RemotePubkey = hex.EncodeToString(
+						summary.RemotePub.SerializeCompressed(),
+					)
+				}
+				publishEvent(EventChannelClosed, event)
+			}
+			knownClosed = closed
+
+		case <-d.quit:
+			return nil
+		}
+	}
+}
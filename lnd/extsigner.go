@@ -0,0 +1,170 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcutil/hdkeychain"
+)
+
+// ExternalSigner holds on-chain keys outside of the wallet, such as on a
+// hardware wallet or in a secure element. Keys are named by their BIP 32
+// derivation path, such as m/84'/0'/0'/0/5.
+type ExternalSigner interface {
+	// PubKey returns the compressed public key at the derivation path,
+	// or an error if the signer doesn't hold it.
+	PubKey(path string) ([]byte, error)
+
+	// SignDigest returns the DER encoded signature of the key at the
+	// derivation path over the 32 byte digest.
+	SignDigest(path string, digest []byte) ([]byte, error)
+}
+
+var (
+	// externalSignerMtx guards externalSigner.
+	externalSignerMtx sync.Mutex
+
+	// externalSigner signs the inputs of PSBTs the wallet holds no keys
+	// for, or is nil.
+	externalSigner ExternalSigner
+)
+
+// SetExternalSigner routes the signing of PSBT inputs the wallet holds no keys
+// for to the signer. A nil signer stops the routing.
+func SetExternalSigner(signer ExternalSigner) {
+	externalSignerMtx.Lock()
+	externalSigner = signer
+	externalSignerMtx.Unlock()
+}
+
+// activeExternalSigner returns the signer set through SetExternalSigner, or
+// nil.
+func activeExternalSigner() ExternalSigner {
+	externalSignerMtx.Lock()
+	defer externalSignerMtx.Unlock()
+
+	return externalSigner
+}
+
+// derivationPath formats the path of a PSBT BIP 32 derivation field, which
+// holds the master key fingerprint followed by the path's indexes.
+func derivationPath(value []byte) (string, error) {
+	if len(value) < 4 || len(value)%4 != 0 {
+		return "", fmt.Errorf("malformed derivation path")
+	}
+
+	parts := []string{"m"}
+	for i := 4; i < len(value); i += 4 {
+		index := binary.LittleEndian.Uint32(value[i : i+4])
+		if index >= hdkeychain.HardenedKeyStart {
+			parts = append(parts, fmt.Sprintf("%d'",
+				index-hdkeychain.HardenedKeyStart))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", index))
+		}
+	}
+
+	return strings.Join(parts, "/"), nil
+}
+
+// witnessKeyHash returns the hash of the key a native or nested segwit output
+// pays to, checking the redeem script of the input against a nested one.
+// False is returned for other outputs.
+func witnessKeyHash(output *wire.TxOut, redeemScript []byte) ([]byte, bool) {
+	pkScript := output.PkScript
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		return pkScript[2:22], true
+
+	case txscript.IsPayToScriptHash(pkScript):
+		if !txscript.IsPayToWitnessPubKeyHash(redeemScript) ||
+			!bytes.Equal(btcutil.Hash160(redeemScript),
+				pkScript[2:22]) {
+
+			return nil, false
+		}
+		return redeemScript[2:22], true
+	}
+
+	return nil, false
+}
+
+// signPsbtExternal signs the input at the index through the external signer,
+// if one is set and holds a key of the input's BIP 32 derivations. The
+// signature is checked before it is added, so a signer can't corrupt the
+// PSBT. False is returned if the input wasn't signed.
+func signPsbtExternal(p *psbtPacket, index int, output *wire.TxOut,
+	hashType txscript.SigHashType,
+	sigHashes *txscript.TxSigHashes) (bool, error) {
+
+	signer := activeExternalSigner()
+	if signer == nil {
+		return false, nil
+	}
+
+	m := &p.inputs[index]
+	keyHash, ok := witnessKeyHash(output, m.get(psbtInRedeemScript))
+	if !ok {
+		return false, nil
+	}
+
+	for _, derivation := range m.all(psbtInBip32Derivation) {
+		pubKeyBytes := derivation.key[1:]
+		if !bytes.Equal(btcutil.Hash160(pubKeyBytes), keyHash) {
+			continue
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
+			return false, invalidPsbt("malformed derivation key "+
+				"of input %d", index)
+		}
+		path, err := derivationPath(derivation.value)
+		if err != nil {
+			return false, invalidPsbt("malformed derivation of "+
+				"input %d", index)
+		}
+
+		// Only ask for signatures of keys the signer holds.
+		signerKey, err := signer.PubKey(path)
+		if err != nil || !bytes.Equal(signerKey, pubKeyBytes) {
+			continue
+		}
+
+		// For a segwit key hash, the sighash expands the witness
+		// program into its pay to key hash script (BIP 143).
+		witnessProgram := append(
+			[]byte{txscript.OP_0, txscript.OP_DATA_20}, keyHash...,
+		)
+		digest, err := txscript.CalcWitnessSigHash(
+			witnessProgram, sigHashes, hashType, p.tx, index,
+			output.Value,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		sigBytes, err := signer.SignDigest(path, digest)
+		if err != nil {
+			return false, err
+		}
+		sig, err := btcec.ParseDERSignature(sigBytes, btcec.S256())
+		if err != nil || !sig.Verify(digest, pubKey) {
+			return false, fmt.Errorf("external signer returned an "+
+				"invalid signature for input %d", index)
+		}
+
+		sigKey := append([]byte{psbtInPartialSig}, pubKeyBytes...)
+		m.set(sigKey, append(sig.Serialize(), byte(hashType)))
+
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,112 @@
+package lnd
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/wire"
+)
+
+// ForceCloseResult is the outcome of force closing one channel through
+// ForceCloseAll.
+type ForceCloseResult struct {
+	ChanPoint   string `json:"chan_point"`
+	ClosingTxid string `json:"closing_txid,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// sweepFeeFloor is the lowest fee rate, in satoshis per vbyte, the nursery
+// sweeps time locked outputs at, set by ForceCloseAll. It is read and written
+// atomically.
+var sweepFeeFloor int64
+
+// sweepFeeRate returns the fee rate to sweep time locked outputs at, which is
+// the estimate given, raised to the floor set by ForceCloseAll.
+func sweepFeeRate(estimate lnwallet.SatPerVByte) lnwallet.SatPerVByte {
+	floor := lnwallet.SatPerVByte(atomic.LoadInt64(&sweepFeeFloor))
+	if estimate < floor {
+		return floor
+	}
+
+	return estimate
+}
+
+// ForceCloseAll force closes every open channel, for when the device is
+// believed compromised or about to be lost, and returns the outcome for each.
+// A failure to close one channel doesn't stop the others from being closed.
+//
+// The fee of a commitment transaction was agreed with the peer when it was
+// signed, and can't be changed. Instead, satPerByte is the lowest fee rate the
+// time locked outputs of the channels, and of any other channel, are swept
+// back to the wallet at until the node stops. A zero satPerByte keeps the
+// estimated rate.
+func ForceCloseAll(satPerByte int64) ([]*ForceCloseResult, error) {
+	if satPerByte < 0 {
+		return nil, fmt.Errorf("negative fee rate: %v", satPerByte)
+	}
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	dbChannels, err := d.chanDB.FetchAllChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	chanPoints := make([]wire.OutPoint, 0, len(dbChannels))
+	for _, dbChannel := range dbChannels {
+		chanPoints = append(chanPoints, dbChannel.FundingOutpoint)
+	}
+
+	return forceCloseChannels(d, chanPoints, satPerByte), nil
+}
+
+// forceCloseChannels force closes the open channels at the channel points,
+// sweeping their time locked outputs at satPerByte or more, and returns the
+// outcome for each.
+func forceCloseChannels(d *daemon, chanPoints []wire.OutPoint,
+	satPerByte int64) []*ForceCloseResult {
+
+	if satPerByte > 0 {
+		atomic.StoreInt64(&sweepFeeFloor, satPerByte)
+	}
+
+	ltndLog.Warnf("Force closing %v channels", len(chanPoints))
+
+	results := make([]*ForceCloseResult, 0, len(chanPoints))
+	for _, chanPoint := range chanPoints {
+		result := &ForceCloseResult{ChanPoint: chanPoint.String()}
+		results = append(results, result)
+
+		closingTx, err := forceCloseActiveChannel(d.rpcServer, chanPoint)
+		if err != nil {
+			ltndLog.Errorf("Unable to force close channel %v: %v",
+				chanPoint, err)
+			result.Error = err.Error()
+			continue
+		}
+
+		result.ClosingTxid = closingTx.TxHash().String()
+	}
+
+	return results
+}
+
+// forceCloseActiveChannel force closes the open channel at the channel point.
+func forceCloseActiveChannel(r *rpcServer,
+	chanPoint wire.OutPoint) (*wire.MsgTx, error) {
+
+	channel, err := r.fetchActiveChannel(chanPoint)
+	if err != nil {
+		return nil, err
+	}
+	channel.Stop()
+
+	return r.forceCloseChannel(channel)
+}
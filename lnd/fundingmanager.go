@@ -780,6 +780,10 @@ func (f *fundingManager) failFundingFlow(peer *btcec.PublicKey,
 	case lnwire.ErrorCode:
 		msg = lnwire.ErrorData{byte(e)}
 
+	// Tell the remote its channel was turned down.
+	case channelRejectedError:
+		msg = lnwire.ErrorData(e.Error())
+
 	// We just send a generic error.
 	default:
 		msg = lnwire.ErrorData("funding failed due to internal error")
@@ -911,7 +915,8 @@ func (f *fundingManager) handleFundingOpen(fmsg *fundingOpenMsg) {
 	// TODO(roasbeef): modify to only accept a _single_ pending channel per
 	// block unless white listed
 	f.resMtx.RLock()
-	if len(f.activeReservations[peerIDKey]) >= cfg.MaxPendingChannels {
+	maxPending := currentLimits().MaxPendingChannels
+	if len(f.activeReservations[peerIDKey]) >= maxPending {
 		f.resMtx.RUnlock()
 		f.failFundingFlow(
 			fmsg.peerAddress.IdentityKey, fmsg.msg.PendingChannelID,
@@ -935,14 +940,25 @@ func (f *fundingManager) handleFundingOpen(fmsg *fundingOpenMsg) {
 	}
 
 	// We'll reject any request to create a channel that's above the
-	// current soft-limit for channel size.
-	if msg.FundingAmount > maxFundingAmount {
+	// maximum channel size, which is only above the protocol's soft-limit
+	// if the peer supports wumbo channels.
+	remotePeer, _ := f.cfg.FindPeer(fmsg.peerAddress.IdentityKey)
+	if msg.FundingAmount > maxChanSize(remotePeer) {
 		f.failFundingFlow(
 			fmsg.peerAddress.IdentityKey, fmsg.msg.PendingChannelID,
 			lnwire.ErrChanTooLarge)
 		return
 	}
 
+	// Lastly, we'll let the app's channel acceptor, if any, turn the
+	// channel down.
+	if !acceptChannel(fmsg.peerAddress.IdentityKey, msg) {
+		f.failFundingFlow(
+			fmsg.peerAddress.IdentityKey, fmsg.msg.PendingChannelID,
+			channelRejectedError{})
+		return
+	}
+
 	fndgLog.Infof("Recv'd fundingRequest(amt=%v, push=%v, delay=%v, "+
 		"pendingId=%x) from peer(%x)", amt, msg.PushAmount,
 		msg.CsvDelay, msg.PendingChannelID,
@@ -2503,12 +2519,25 @@ func (f *fundingManager) handleInitFundingMsg(msg *initFundingMsg) {
 
 	// Initialize a funding reservation with the local wallet. If the
 	// wallet doesn't have enough funds to commit to this channel, then the
-	// request will fail, and be aborted.
-	reservation, err := f.cfg.Wallet.InitChannelReservation(
-		capacity, localAmt, msg.pushAmt, commitFeePerKw,
-		msg.fundingFeePerVSize, peerKey, msg.peerAddress.Address,
-		&msg.chainHash, channelFlags,
-	)
+	// request will fail, and be aborted. The coins are selected as asked
+	// by the coin control of the request, which holds for this
+	// reservation alone.
+	var reservation *lnwallet.ChannelReservation
+	initReservation := func() error {
+		var err error
+		reservation, err = f.cfg.Wallet.InitChannelReservation(
+			capacity, localAmt, msg.pushAmt, commitFeePerKw,
+			msg.fundingFeePerVSize, peerKey,
+			msg.peerAddress.Address, &msg.chainHash, channelFlags,
+		)
+		return err
+	}
+	w, ok := f.cfg.Wallet.WalletController.(*fundingWallet)
+	if ok {
+		err = w.reserve(msg.coinControl, initReservation)
+	} else {
+		err = initReservation()
+	}
 	if err != nil {
 		msg.err <- err
 		return
@@ -0,0 +1,175 @@
+package lnd
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/roasbeef/btcd/btcec"
+)
+
+const (
+	// graphSnapshotSignatureHeader is the response header holding the hex
+	// encoded DER signature of a pinned public key over the SHA-256 of the
+	// graph snapshot.
+	graphSnapshotSignatureHeader = "X-Signature"
+
+	// maxGraphSnapshotAge is the age past which a graph snapshot is
+	// refused, as its channels would soon be pruned as zombies anyway. It
+	// also bounds how stale a snapshot replayed by a compromised server
+	// can be.
+	maxGraphSnapshotAge = 7 * 24 * time.Hour
+
+	// graphSnapshotRetry is how long to wait before fetching the graph
+	// snapshot again after failing to.
+	graphSnapshotRetry = 10 * time.Minute
+)
+
+var (
+	// errGraphSnapshotSignature is returned for a graph snapshot that
+	// isn't signed by any of the pinned public keys.
+	errGraphSnapshotSignature = errors.New("graph snapshot isn't signed " +
+		"by a pinned public key")
+
+	// errStaleGraphSnapshot is returned for a graph snapshot older than
+	// maxGraphSnapshotAge.
+	errStaleGraphSnapshot = errors.New("graph snapshot is too old")
+)
+
+// validateGraphConfig checks the graph snapshot is fetched from an HTTPS URL
+// and signed by at least one pinned public key, returning the parsed keys.
+func validateGraphConfig(c *graphConfig) ([]*btcec.PublicKey, error) {
+	if c.SnapshotURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(c.SnapshotURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("graph.snapshoturl must be an https URL")
+	}
+	if len(c.SnapshotPubKeys) == 0 {
+		return nil, fmt.Errorf("graph.snapshoturl requires a " +
+			"graph.snapshotpubkey")
+	}
+
+	var pubKeys []*btcec.PublicKey
+	for _, key := range c.SnapshotPubKeys {
+		keyBytes, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid graph.snapshotpubkey: "+
+				"%v", err)
+		}
+		pubKey, err := btcec.ParsePubKey(keyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid graph.snapshotpubkey: "+
+				"%v", err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	return pubKeys, nil
+}
+
+// bootstrapGraph fills the graph from the signed snapshot at snapshotURL, in
+// the rapid gossip sync format, unless a snapshot was applied before. It's
+// fetched again every graphSnapshotRetry until it's applied or the node stops,
+// while gossip with peers goes on as usual. Later RapidGossipSync calls only
+// fetch what changed since.
+func (d *daemon) bootstrapGraph(snapshotURL string,
+	pubKeys []*btcec.PublicKey) {
+
+	defer d.wg.Done()
+	defer RecoverPanic("LTND")
+
+	for {
+		err := d.applyGraphSnapshot(snapshotURL, pubKeys)
+		if err == nil {
+			return
+		}
+		ltndLog.Errorf("Unable to bootstrap graph from %v: %v",
+			snapshotURL, err)
+
+		select {
+		case <-time.After(graphSnapshotRetry):
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// applyGraphSnapshot fetches the snapshot at snapshotURL and adds it to the
+// graph, once its signature and age are checked.
+func (d *daemon) applyGraphSnapshot(snapshotURL string,
+	pubKeys []*btcec.PublicKey) error {
+
+	since, err := d.rapidSyncTimestamp()
+	if err != nil {
+		return err
+	}
+	if since != 0 {
+		return nil
+	}
+
+	snapshot, header, err := fetchSnapshot(snapshotURL)
+	if err != nil {
+		return err
+	}
+
+	sig := header.Get(graphSnapshotSignatureHeader)
+	if err := verifyGraphSnapshot(snapshot, sig, pubKeys); err != nil {
+		return err
+	}
+
+	// The timestamp follows the prefix and the chain hash.
+	if len(snapshot) < 40 {
+		return invalidSnapshot("%d bytes", len(snapshot))
+	}
+	timestamp := time.Unix(int64(binary.BigEndian.Uint32(snapshot[36:])), 0)
+	if time.Since(timestamp) > maxGraphSnapshotAge {
+		return errStaleGraphSnapshot
+	}
+
+	result, err := d.applySnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := d.setRapidSyncTimestamp(result.Timestamp); err != nil {
+		return err
+	}
+
+	ltndLog.Infof("Graph snapshot of %v added %d channels and %d policy "+
+		"updates", timestamp, result.Channels, result.Updates)
+
+	return nil
+}
+
+// verifyGraphSnapshot checks the hex encoded signature is a signature of one
+// of the pinned public keys over the SHA-256 of the snapshot.
+func verifyGraphSnapshot(snapshot []byte, signature string,
+	pubKeys []*btcec.PublicKey) error {
+
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return errGraphSnapshotSignature
+	}
+	sig, err := btcec.ParseDERSignature(sigBytes, btcec.S256())
+	if err != nil {
+		return errGraphSnapshotSignature
+	}
+
+	digest := sha256.Sum256(snapshot)
+	for _, pubKey := range pubKeys {
+		if sig.Verify(digest[:], pubKey) {
+			return nil
+		}
+	}
+
+	return errGraphSnapshotSignature
+}
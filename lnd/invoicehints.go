@@ -0,0 +1,231 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcutil"
+)
+
+// ErrInvalidRouteHint is returned for a route hint that can't be embedded in
+// an invoice.
+type ErrInvalidRouteHint struct {
+	Reason string
+}
+
+func (e *ErrInvalidRouteHint) Error() string {
+	return "invalid route hint: " + e.Reason
+}
+
+// invalidRouteHint returns an ErrInvalidRouteHint with the formatted reason.
+func invalidRouteHint(format string, a ...interface{}) error {
+	return &ErrInvalidRouteHint{Reason: fmt.Sprintf(format, a...)}
+}
+
+// ErrInvalidFallbackAddr is returned for an invoice whose fallback address
+// isn't an address of the network of the node.
+var ErrInvalidFallbackAddr = errors.New("invalid fallback address")
+
+// ErrInvalidExpiry is returned for an invoice with a negative expiry, or one
+// over a year.
+var ErrInvalidExpiry = errors.New("invoice expiry must be within a year")
+
+// ErrInvoiceTagsTooLarge is returned for an invoice whose label and metadata
+// don't fit in its receipt.
+var ErrInvoiceTagsTooLarge = errors.New("invoice label and metadata are " +
+	"too large")
+
+// maxInvoiceExpiry is the longest expiry an invoice may have.
+const maxInvoiceExpiry = 365 * 24 * time.Hour
+
+// InvoiceTags are the label and metadata of an invoice, kept JSON encoded as
+// its receipt in the channel database. Unlike the memo, payers don't see them.
+type InvoiceTags struct {
+	Label    string `json:"label,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+}
+
+// HopHint is a hop of the route hint of an invoice, over the channel of the
+// node to the next hop, or to this node if it's the last one.
+type HopHint struct {
+	NodeID                    string `json:"node_id"`
+	ChanID                    uint64 `json:"chan_id"`
+	FeeBaseMsat               uint32 `json:"fee_base_msat"`
+	FeeProportionalMillionths uint32 `json:"fee_proportional_millionths"`
+	CltvExpiryDelta           uint16 `json:"cltv_expiry_delta"`
+}
+
+// RouteHints picks the route hint embedded in an invoice. An invoice holds a
+// single route hint, as zpay32 encodes no more, so at most one channel of the
+// node is embedded.
+type RouteHints struct {
+	// Private embeds a hint for the active private channel whose peer can
+	// send the most.
+	Private bool
+
+	// ChanIDs are the channels the hint may be for instead, whether
+	// they're private or active or not, the one whose peer can send the
+	// most being picked. One of them must be usable.
+	ChanIDs []uint64
+
+	// HopHints is embedded as is instead of any channel, if not empty.
+	HopHints []*HopHint
+}
+
+// AddInvoice adds the invoice as the AddInvoice call of the rpc server does,
+// embedding the route hint picked by hints, which may be nil for none.
+func AddInvoice(invoice *lnrpc.Invoice,
+	hints *RouteHints) (*lnrpc.AddInvoiceResponse, error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	// The rpc server checks these too, though not with errors that tell
+	// an invalid invoice apart.
+	if len(invoice.Receipt) > channeldb.MaxReceiptSize {
+		return nil, ErrInvoiceTagsTooLarge
+	}
+	if invoice.FallbackAddr != "" {
+		addr, err := btcutil.DecodeAddress(
+			invoice.FallbackAddr, activeNetParams.Params,
+		)
+		if err != nil || !addr.IsForNet(activeNetParams.Params) {
+			return nil, ErrInvalidFallbackAddr
+		}
+	}
+	maxExpiry := int64(maxInvoiceExpiry / time.Second)
+	if invoice.Expiry < 0 || invoice.Expiry > maxExpiry {
+		return nil, ErrInvalidExpiry
+	}
+
+	var routeHint []zpay32.ExtraRoutingInfo
+	if hints != nil {
+		var err error
+		routeHint, err = d.routeHint(hints)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d.rpcServer.addInvoice(invoice, routeHint)
+}
+
+// routeHint returns the route hint the hints pick, or nil if there's none.
+func (d *daemon) routeHint(hints *RouteHints) ([]zpay32.ExtraRoutingInfo,
+	error) {
+
+	if len(hints.HopHints) > 0 {
+		var routeHint []zpay32.ExtraRoutingInfo
+		for _, hop := range hints.HopHints {
+			if hop == nil {
+				return nil, invalidRouteHint("missing hop")
+			}
+			nodeID, err := hex.DecodeString(hop.NodeID)
+			if err != nil {
+				return nil, invalidRouteHint("node id %v "+
+					"isn't hex encoded", hop.NodeID)
+			}
+			pubKey, err := btcec.ParsePubKey(nodeID, btcec.S256())
+			if err != nil {
+				return nil, invalidRouteHint("node id %v: %v",
+					hop.NodeID, err)
+			}
+
+			hint := zpay32.ExtraRoutingInfo{
+				PubKey:       pubKey,
+				ShortChanID:  hop.ChanID,
+				FeeBaseMsat:  hop.FeeBaseMsat,
+				CltvExpDelta: hop.CltvExpiryDelta,
+			}
+			hint.FeeProportionalMillionths =
+				hop.FeeProportionalMillionths
+			routeHint = append(routeHint, hint)
+		}
+
+		return routeHint, nil
+	}
+
+	if !hints.Private && len(hints.ChanIDs) == 0 {
+		return nil, nil
+	}
+
+	chosen := make(map[uint64]struct{}, len(hints.ChanIDs))
+	for _, chanID := range hints.ChanIDs {
+		chosen[chanID] = struct{}{}
+	}
+
+	dbChannels, err := d.chanDB.FetchAllChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := d.chanDB.ChannelGraph()
+	var (
+		best     *channeldb.OpenChannel
+		bestHint *zpay32.ExtraRoutingInfo
+	)
+	for _, dbChannel := range dbChannels {
+		if dbChannel.IsPending {
+			continue
+		}
+
+		chanID := dbChannel.ShortChanID.ToUint64()
+		if len(chosen) > 0 {
+			if _, ok := chosen[chanID]; !ok {
+				continue
+			}
+		} else {
+			isActive, isPublic := d.rpcServer.channelStatus(
+				dbChannel,
+			)
+			if !isActive || isPublic {
+				continue
+			}
+		}
+
+		// Payers are told the policy of the peer, as it forwards to
+		// this node. It's only known once the peer sent it.
+		hop, err := channelPolicy(graph, chanID, dbChannel.IdentityPub)
+		if err != nil {
+			continue
+		}
+
+		if best != nil && dbChannel.LocalCommitment.RemoteBalance <=
+			best.LocalCommitment.RemoteBalance {
+
+			continue
+		}
+		best = dbChannel
+		bestHint = &zpay32.ExtraRoutingInfo{
+			PubKey:       dbChannel.IdentityPub,
+			ShortChanID:  chanID,
+			FeeBaseMsat:  uint32(hop.FeeBaseMSat),
+			CltvExpDelta: hop.TimeLockDelta,
+			FeeProportionalMillionths: uint32(
+				hop.FeeProportionalMillionths,
+			),
+		}
+	}
+
+	switch {
+	case bestHint != nil:
+		return []zpay32.ExtraRoutingInfo{*bestHint}, nil
+
+	case len(chosen) > 0:
+		return nil, invalidRouteHint("none of the channels %v is "+
+			"open with a known peer policy", hints.ChanIDs)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,189 @@
+package lnd
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/zpay32"
+)
+
+// invoicePruneInterval is how often invoices past their retention are pruned
+// while the node runs.
+const invoicePruneInterval = 24 * time.Hour
+
+var (
+	// invoiceBucket and invoiceIndexBucket are the buckets channeldb
+	// keeps invoices in, by their number and by their payment hash.
+	invoiceBucket      = []byte("invoices")
+	invoiceIndexBucket = []byte("paymenthashes")
+)
+
+// PruneInvoices deletes the invoices that expired unpaid more than olderThan
+// ago, along with those settled more than olderThan ago if settled is set. It
+// returns the number of invoices deleted. The space they took is reused by the
+// database rather than given back to the file system.
+func PruneInvoices(olderThan time.Duration, settled bool) (int, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return 0, ErrDaemonNotRunning
+	}
+
+	return d.pruneInvoices(olderThan, settled)
+}
+
+// pruneInvoices deletes the invoices as PruneInvoices does.
+func (d *daemon) pruneInvoices(olderThan time.Duration,
+	settled bool) (int, error) {
+
+	invoices, err := d.chanDB.FetchAllInvoices(false)
+	switch {
+	case err == channeldb.ErrNoInvoicesCreated:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var expired, pruned [][32]byte
+	for _, invoice := range invoices {
+		hash := sha256.Sum256(invoice.Terms.PaymentPreimage[:])
+
+		if invoice.Terms.Settled {
+			if settled && invoice.SettleDate.Before(cutoff) {
+				pruned = append(pruned, hash)
+			}
+			continue
+		}
+
+		// Invoices whose payment request can't be decoded are kept,
+		// as when they expire isn't known.
+		payReq, err := zpay32.Decode(
+			string(invoice.PaymentRequest), activeNetParams.Params,
+		)
+		if err != nil {
+			continue
+		}
+		expiry := invoice.CreationDate.Add(payReq.Expiry())
+		if expiry.Before(cutoff) {
+			expired = append(expired, hash)
+		}
+	}
+
+	var count int
+	err = d.chanDB.Update(func(tx *bolt.Tx) error {
+		count = 0
+
+		invoiceB := tx.Bucket(invoiceBucket)
+		if invoiceB == nil {
+			return nil
+		}
+		indexB := invoiceB.Bucket(invoiceIndexBucket)
+		if indexB == nil {
+			return nil
+		}
+
+		// An expired invoice paid since it was fetched is kept.
+		remove := func(hash [32]byte, unsettled bool) error {
+			deleted, err := deleteInvoice(
+				invoiceB, indexB, hash, unsettled,
+			)
+			if deleted {
+				count++
+			}
+			return err
+		}
+
+		for _, hash := range expired {
+			if err := remove(hash, true); err != nil {
+				return err
+			}
+		}
+		for _, hash := range pruned {
+			if err := remove(hash, false); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if count > 0 {
+		ltndLog.Infof("Pruned %d invoices", count)
+	}
+
+	return count, nil
+}
+
+// deleteInvoice deletes the invoice with the payment hash from channeldb's
+// buckets, returning whether there was one. With unsettled set, an invoice
+// that was paid is kept.
+func deleteInvoice(invoiceB, indexB *bolt.Bucket, hash [32]byte,
+	unsettled bool) (bool, error) {
+
+	key := indexB.Get(hash[:])
+	if key == nil {
+		return false, nil
+	}
+	invoice := invoiceB.Get(key)
+
+	// The settled flag ends the serialized invoice.
+	if unsettled && len(invoice) > 0 && invoice[len(invoice)-1] != 0 {
+		return false, nil
+	}
+
+	if err := invoiceB.Delete(key); err != nil {
+		return false, err
+	}
+
+	return true, indexB.Delete(hash[:])
+}
+
+// deleteUnsettledInvoice deletes the invoice with the payment hash, unless it
+// was paid.
+func (d *daemon) deleteUnsettledInvoice(hash [32]byte) error {
+	return d.chanDB.Update(func(tx *bolt.Tx) error {
+		invoiceB := tx.Bucket(invoiceBucket)
+		if invoiceB == nil {
+			return nil
+		}
+		indexB := invoiceB.Bucket(invoiceIndexBucket)
+		if indexB == nil {
+			return nil
+		}
+
+		_, err := deleteInvoice(invoiceB, indexB, hash, true)
+		return err
+	})
+}
+
+// pruneInvoicesPeriodically prunes the invoices past the retention once the
+// node starts and then every invoicePruneInterval until it stops.
+func (d *daemon) pruneInvoicesPeriodically(retention time.Duration,
+	settled bool) {
+
+	defer d.wg.Done()
+	defer RecoverPanic("LTND")
+
+	ticker := time.NewTicker(invoicePruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.pruneInvoices(retention, settled); err != nil {
+			ltndLog.Errorf("Unable to prune invoices: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package lnd
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+)
+
+// TestDeleteUnsettledInvoice checks that the invoice of a failed payment is
+// deleted, while one that was paid is kept.
+func TestDeleteUnsettledInvoice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deleteinvoice")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	chanDB, err := channeldb.Open(dir)
+	if err != nil {
+		t.Fatalf("unable to open db: %v", err)
+	}
+	defer chanDB.Close()
+	d := &daemon{chanDB: chanDB}
+
+	var hashes [][32]byte
+	for i := byte(1); i <= 2; i++ {
+		preimage := [32]byte{i}
+		err := chanDB.AddInvoice(&channeldb.Invoice{
+			CreationDate: time.Now(),
+			Terms: channeldb.ContractTerm{
+				Value:           1000,
+				PaymentPreimage: preimage,
+			},
+		})
+		if err != nil {
+			t.Fatalf("unable to add invoice: %v", err)
+		}
+		hashes = append(hashes, sha256.Sum256(preimage[:]))
+	}
+	if err := chanDB.SettleInvoice(hashes[1]); err != nil {
+		t.Fatalf("unable to settle invoice: %v", err)
+	}
+
+	for _, hash := range hashes {
+		if err := d.deleteUnsettledInvoice(hash); err != nil {
+			t.Fatalf("unable to delete invoice: %v", err)
+		}
+	}
+
+	_, err = chanDB.LookupInvoice(hashes[0])
+	if err != channeldb.ErrInvoiceNotFound {
+		t.Fatalf("expected unsettled invoice deleted, got %v", err)
+	}
+	if _, err := chanDB.LookupInvoice(hashes[1]); err != nil {
+		t.Fatalf("settled invoice deleted: %v", err)
+	}
+
+	// Deleting an invoice that's gone already is a no-op.
+	if err := d.deleteUnsettledInvoice(hashes[0]); err != nil {
+		t.Fatalf("unable to delete missing invoice: %v", err)
+	}
+}
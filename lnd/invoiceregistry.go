@@ -139,8 +139,16 @@ func (i *invoiceRegistry) SettleInvoice(rHash chainhash.Hash) error {
 	i.RUnlock()
 
 	// If this isn't a debug invoice, then we'll attempt to settle an
-	// invoice matching this rHash on disk (if one exists).
-	if err := i.cdb.SettleInvoice(rHash); err != nil {
+	// invoice matching this rHash on disk (if one exists). The link only
+	// settles invoices it found, so one that's gone was pruned while
+	// being paid, which mustn't fail the link.
+	err := i.cdb.SettleInvoice(rHash)
+	if err == channeldb.ErrInvoiceNotFound {
+		ltndLog.Warnf("Invoice %x pruned before being settled",
+			rHash[:])
+		return nil
+	}
+	if err != nil {
 		return err
 	}
 
@@ -156,6 +164,13 @@ func (i *invoiceRegistry) SettleInvoice(rHash chainhash.Hash) error {
 		ltndLog.Infof("Payment received: %v", spew.Sdump(invoice))
 
 		i.notifyClients(invoice, true)
+
+		rpcInvoice, err := createRPCInvoice(invoice)
+		if err != nil {
+			ltndLog.Errorf("unable to marshal invoice: %v", err)
+			return
+		}
+		publishEvent(EventInvoiceSettled, rpcInvoice)
 	}()
 
 	return nil
@@ -175,9 +190,12 @@ func (i *invoiceRegistry) notifyClients(invoice *channeldb.Invoice, settle bool)
 			eventChan = client.NewInvoices
 		}
 
-		go func() {
-			eventChan <- invoice
-		}()
+		go func(cancel chan struct{}) {
+			select {
+			case eventChan <- invoice:
+			case <-cancel:
+			}
+		}(client.cancel)
 	}
 }
 
@@ -192,6 +210,11 @@ type invoiceSubscription struct {
 
 	inv *invoiceRegistry
 	id  uint32
+
+	// cancel is closed once the subscription is cancelled, releasing the
+	// notifications the client never received.
+	cancel     chan struct{}
+	cancelOnce sync.Once
 }
 
 // Cancel unregisters the invoiceSubscription, freeing any previously allocated
@@ -200,6 +223,8 @@ func (i *invoiceSubscription) Cancel() {
 	i.inv.clientMtx.Lock()
 	delete(i.inv.notificationClients, i.id)
 	i.inv.clientMtx.Unlock()
+
+	i.cancelOnce.Do(func() { close(i.cancel) })
 }
 
 // SubscribeNotifications returns an invoiceSubscription which allows the
@@ -210,6 +235,7 @@ func (i *invoiceRegistry) SubscribeNotifications() *invoiceSubscription {
 		NewInvoices:     make(chan *channeldb.Invoice),
 		SettledInvoices: make(chan *channeldb.Invoice),
 		inv:             i,
+		cancel:          make(chan struct{}),
 	}
 
 	i.clientMtx.Lock()
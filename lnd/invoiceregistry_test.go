@@ -0,0 +1,43 @@
+package lnd
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+)
+
+// TestInvoiceSubscriptionCancel checks that cancelling a subscription releases
+// the notifications its client never received.
+func TestInvoiceSubscriptionCancel(t *testing.T) {
+	registry := newInvoiceRegistry(nil)
+	before := runtime.NumGoroutine()
+
+	client := registry.SubscribeNotifications()
+	invoice := &channeldb.Invoice{}
+	registry.notifyClients(invoice, false)
+	registry.notifyClients(invoice, true)
+
+	select {
+	case <-client.SettledInvoices:
+	case <-time.After(time.Second):
+		t.Fatalf("settled invoice not received")
+	}
+
+	client.Cancel()
+	client.Cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("notification left pending after cancel")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	registry.notifyClients(invoice, true)
+	if runtime.NumGoroutine() > before {
+		t.Fatalf("cancelled subscription still notified")
+	}
+}
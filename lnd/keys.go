@@ -0,0 +1,138 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// MinAppKeyFamily is the lowest key family whose private keys DerivePrivKey
+// hands out. The families below it are reserved for lnd's own keys, such as
+// those of channels and the node identity, which must never leave the wallet.
+const MinAppKeyFamily = 128
+
+var (
+	// ErrReservedKeyFamily is returned by DerivePrivKey for a key family
+	// below MinAppKeyFamily.
+	ErrReservedKeyFamily = errors.New("key family is reserved for lnd's " +
+		"own keys")
+
+	// ErrInvalidKeyLocator is returned for a negative key family or
+	// index.
+	ErrInvalidKeyLocator = errors.New("key family and index must be " +
+		"non-negative")
+)
+
+// DerivedKey is a key derived from the wallet's seed, at the BIP 43 path
+// m/1017'/coin_type'/family'/0/index. Keys are hex encoded, and the private
+// key is only set by DerivePrivKey.
+type DerivedKey struct {
+	Family  int32  `json:"key_family"`
+	Index   int32  `json:"key_index"`
+	PubKey  string `json:"raw_key_bytes"`
+	PrivKey string `json:"priv_key,omitempty"`
+}
+
+// runningKeyRing returns the key ring of the running daemon.
+func runningKeyRing() (keychain.SecretKeyRing, error) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	if activeDaemon == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return activeDaemon.cc.wallet.Cfg.SecretKeyRing, nil
+}
+
+// keyLocator returns the locator of the key at the family and index.
+func keyLocator(family, index int32) (keychain.KeyLocator, error) {
+	if family < 0 || index < 0 {
+		return keychain.KeyLocator{}, ErrInvalidKeyLocator
+	}
+
+	return keychain.KeyLocator{
+		Family: keychain.KeyFamily(family),
+		Index:  uint32(index),
+	}, nil
+}
+
+// DeriveKey returns the public key at the family and index, which is the same
+// for every call, so companion protocols can rely on it without storing it.
+func DeriveKey(family, index int32) (*DerivedKey, error) {
+	keyRing, err := runningKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	keyLoc, err := keyLocator(family, index)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDesc, err := keyRing.DeriveKey(keyLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DerivedKey{
+		Family: family,
+		Index:  index,
+		PubKey: hex.EncodeToString(keyDesc.PubKey.SerializeCompressed()),
+	}, nil
+}
+
+// DerivePrivKey returns the key pair at the family and index, for companion
+// protocols such as LNURL-auth or backup encryption that need to sign or
+// decrypt outside of the wallet. Only families from MinAppKeyFamily onwards
+// may be used.
+func DerivePrivKey(family, index int32) (*DerivedKey, error) {
+	keyRing, err := runningKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	keyLoc, err := keyLocator(family, index)
+	if err != nil {
+		return nil, err
+	}
+	if family < MinAppKeyFamily {
+		return nil, ErrReservedKeyFamily
+	}
+
+	privKey, err := keyRing.DerivePrivKey(keychain.KeyDescriptor{
+		KeyLocator: keyLoc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey := privKey.PubKey().SerializeCompressed()
+	return &DerivedKey{
+		Family:  family,
+		Index:   index,
+		PubKey:  hex.EncodeToString(pubKey),
+		PrivKey: hex.EncodeToString(privKey.Serialize()),
+	}, nil
+}
+
+// DeriveSharedKey returns the shared secret of the key at the family and index
+// with the passed public key, the SHA-256 of their ECDH point, without the
+// private key leaving the wallet. It may be used with any family, such as the
+// node key's.
+func DeriveSharedKey(pubKey *btcec.PublicKey, family,
+	index int32) ([]byte, error) {
+
+	keyRing, err := runningKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	keyLoc, err := keyLocator(family, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyRing.ScalarMult(
+		keychain.KeyDescriptor{KeyLocator: keyLoc}, pubKey,
+	)
+}
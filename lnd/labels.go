@@ -0,0 +1,147 @@
+package lnd
+
+import (
+	"errors"
+
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+var (
+	// labelsBucketKey is the top level bucket of the wallet database
+	// holding the labels, next to btcwallet's own namespaces.
+	labelsBucketKey = []byte("lndmobile-labels")
+
+	// txLabelsKey is the bucket within the labels bucket that maps
+	// transaction ids to their labels.
+	txLabelsKey = []byte("tx")
+
+	// addrLabelsKey is the bucket within the labels bucket that maps
+	// addresses to their labels.
+	addrLabelsKey = []byte("addr")
+)
+
+// MaxLabelLength is the maximum length of a label in bytes.
+const MaxLabelLength = 500
+
+// ErrLabelTooLong is returned for a label longer than MaxLabelLength.
+var ErrLabelTooLong = errors.New("label is too long")
+
+// Labels holds the labels of transactions and addresses, keyed by transaction
+// id and address respectively.
+type Labels struct {
+	Transactions map[string]string `json:"transactions"`
+	Addresses    map[string]string `json:"addresses"`
+}
+
+// putLabel stores the label under the key within the named bucket, deleting
+// it if the label is empty.
+func putLabel(bucketKey, key []byte, label string) error {
+	if len(label) > MaxLabelLength {
+		return ErrLabelTooLong
+	}
+
+	w, err := runningWallet()
+	if err != nil {
+		return err
+	}
+
+	return walletdb.Update(w.Database(), func(tx walletdb.ReadWriteTx) error {
+		labels := tx.ReadWriteBucket(labelsBucketKey)
+		if labels == nil {
+			// Nothing to delete if no label was ever stored.
+			if label == "" {
+				return nil
+			}
+
+			var err error
+			labels, err = tx.CreateTopLevelBucket(labelsBucketKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		bucket, err := labels.CreateBucketIfNotExists(bucketKey)
+		if err != nil {
+			return err
+		}
+
+		if label == "" {
+			return bucket.Delete(key)
+		}
+		return bucket.Put(key, []byte(label))
+	})
+}
+
+// normalizeAddress returns the address in its canonical encoding, so every
+// spelling of an address shares its label.
+func normalizeAddress(address string) (string, error) {
+	addr, err := btcutil.DecodeAddress(address, activeNetParams.Params)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.EncodeAddress(), nil
+}
+
+// SetTransactionLabel labels the transaction with the given id, replacing any
+// previous label. An empty label deletes it.
+func SetTransactionLabel(txid chainhash.Hash, label string) error {
+	return putLabel(txLabelsKey, []byte(txid.String()), label)
+}
+
+// SetAddressLabel labels the address, replacing any previous label. An empty
+// label deletes it.
+func SetAddressLabel(address, label string) error {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	return putLabel(addrLabelsKey, []byte(address), label)
+}
+
+// ListLabels returns all labels stored in the wallet database.
+func ListLabels() (*Labels, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Labels{
+		Transactions: make(map[string]string),
+		Addresses:    make(map[string]string),
+	}
+	err = walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
+		labels := tx.ReadBucket(labelsBucketKey)
+		if labels == nil {
+			return nil
+		}
+
+		for key, m := range map[string]map[string]string{
+			string(txLabelsKey):   result.Transactions,
+			string(addrLabelsKey): result.Addresses,
+		} {
+			bucket := labels.NestedReadBucket([]byte(key))
+			if bucket == nil {
+				continue
+			}
+
+			err := bucket.ForEach(func(k, v []byte) error {
+				m[string(k)] = string(v)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package lnd
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// Limits are the limits on pending channels and peers that can be changed
+// while the node runs, such as when switching between Wi-Fi and cellular.
+type Limits struct {
+	// MaxPendingChannels is the maximum number of incoming pending
+	// channels permitted per peer.
+	MaxPendingChannels int `json:"max_pending_channels"`
+
+	// MaxPeers is the number of connected peers past which inbound
+	// connections are refused, or zero for no limit.
+	MaxPeers int `json:"max_peers"`
+
+	// MaxInboundPeers is the number of peers connected inbound past which
+	// further inbound connections are refused, or zero for no limit.
+	MaxInboundPeers int `json:"max_inbound_peers"`
+}
+
+var (
+	// limitsMtx guards runtimeLimits.
+	limitsMtx sync.Mutex
+
+	// runtimeLimits are the limits set through SetLimits, or nil to use
+	// those of the configuration.
+	runtimeLimits *Limits
+)
+
+// currentLimits returns the limits in effect.
+func currentLimits() Limits {
+	limitsMtx.Lock()
+	defer limitsMtx.Unlock()
+
+	if runtimeLimits != nil {
+		return *runtimeLimits
+	}
+
+	return Limits{
+		MaxPendingChannels: cfg.MaxPendingChannels,
+	}
+}
+
+// GetLimits returns the limits in effect.
+func GetLimits() (*Limits, error) {
+	if !Running() {
+		return nil, ErrDaemonNotRunning
+	}
+
+	limits := currentLimits()
+	return &limits, nil
+}
+
+// SetLimits replaces the limits in effect until the node stops. Lowered peer
+// limits only refuse new connections, without disconnecting any peer.
+func SetLimits(limits *Limits) error {
+	switch {
+	case limits.MaxPendingChannels < 1:
+		return errors.New("max pending channels must be at least 1")
+	case limits.MaxPeers < 0 || limits.MaxInboundPeers < 0:
+		return errors.New("peer limits must not be negative")
+	}
+
+	if !Running() {
+		return ErrDaemonNotRunning
+	}
+
+	ltndLog.Infof("Setting max pending channels to %v, max peers to %v, "+
+		"max inbound peers to %v", limits.MaxPendingChannels,
+		limits.MaxPeers, limits.MaxInboundPeers)
+
+	limitsMtx.Lock()
+	l := *limits
+	runtimeLimits = &l
+	limitsMtx.Unlock()
+
+	return nil
+}
+
+// resetLimits goes back to the limits of the configuration.
+func resetLimits() {
+	limitsMtx.Lock()
+	runtimeLimits = nil
+	limitsMtx.Unlock()
+}
+
+// admitInboundPeer returns whether an inbound connection from the peer is
+// within the limits. Peers the node has channels with are always admitted,
+// so the channels keep working. The caller must hold s.mu.
+func (s *server) admitInboundPeer(nodePub *btcec.PublicKey) bool {
+	limits := currentLimits()
+
+	withinLimits := (limits.MaxPeers == 0 ||
+		len(s.peersByPub) < limits.MaxPeers) &&
+		(limits.MaxInboundPeers == 0 ||
+			len(s.inboundPeers) < limits.MaxInboundPeers)
+	if withinLimits {
+		return true
+	}
+
+	channels, err := s.chanDB.FetchOpenChannels(nodePub)
+	if err != nil {
+		srvrLog.Errorf("unable to fetch channels of peer %x: %v",
+			nodePub.SerializeCompressed(), err)
+		return false
+	}
+
+	return len(channels) > 0
+}
@@ -0,0 +1,101 @@
+package lnd
+
+import (
+	"sort"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// ChannelQuery selects a page of the open channels listed by
+// ListChannelsPage.
+type ChannelQuery struct {
+	ActiveOnly   bool
+	InactiveOnly bool
+	PublicOnly   bool
+	PrivateOnly  bool
+
+	// Peer limits the channels to those with the peer, if not nil.
+	Peer *btcec.PublicKey
+
+	// Cursor is the channel point the previous page ended at, or empty
+	// for the first page.
+	Cursor string
+
+	// MaxChannels is the size of the page, or zero for all channels.
+	MaxChannels int
+}
+
+// ListChannelsPage returns a page of the open channels matching the query, in
+// the order of their channel points, along with the cursor of the next page,
+// which is empty on the last one. Only the channels of the page are converted
+// to their rpc form.
+func ListChannelsPage(query *ChannelQuery) (*lnrpc.ListChannelsResponse,
+	string, error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, "", ErrDaemonNotRunning
+	}
+	r := d.rpcServer
+
+	var (
+		dbChannels []*channeldb.OpenChannel
+		err        error
+	)
+	if query.Peer != nil {
+		dbChannels, err = d.chanDB.FetchOpenChannels(query.Peer)
+	} else {
+		dbChannels, err = d.chanDB.FetchAllChannels()
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Channel points are ordered as strings, so a page still starts in
+	// the right place if the channel the previous one ended at closed.
+	sort.Slice(dbChannels, func(i, j int) bool {
+		return dbChannels[i].FundingOutpoint.String() <
+			dbChannels[j].FundingOutpoint.String()
+	})
+
+	graph := d.chanDB.ChannelGraph()
+	resp := &lnrpc.ListChannelsResponse{}
+	for _, dbChannel := range dbChannels {
+		chanPoint := dbChannel.FundingOutpoint.String()
+		if dbChannel.IsPending || chanPoint <= query.Cursor {
+			continue
+		}
+
+		isActive, isPublic := r.channelStatus(dbChannel)
+		switch {
+		case query.ActiveOnly && !isActive:
+			continue
+		case query.InactiveOnly && isActive:
+			continue
+		case query.PublicOnly && !isPublic:
+			continue
+		case query.PrivateOnly && isPublic:
+			continue
+		}
+
+		// A channel past the end of a full page means there is a
+		// next one.
+		if query.MaxChannels > 0 &&
+			len(resp.Channels) == query.MaxChannels {
+
+			last := resp.Channels[len(resp.Channels)-1]
+			return resp, last.ChannelPoint, nil
+		}
+
+		resp.Channels = append(
+			resp.Channels, r.marshalChannel(graph, dbChannel),
+		)
+	}
+
+	return resp, "", nil
+}
@@ -0,0 +1,134 @@
+// +build !noremoterpc
+
+package lnd
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+)
+
+// ErrNoRPCListener is returned when pairing a remote app with a node that
+// serves no RPC listener the app could reach.
+var ErrNoRPCListener = errors.New("no RPC listener for remote apps, " +
+	"configure one with --rpclisten")
+
+// remoteRPCPort returns the port of the first of the RPC listeners that
+// doesn't only accept connections from the device itself.
+func remoteRPCPort(listeners []net.Addr) (string, error) {
+	for _, addr := range listeners {
+		host, port, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return "", err
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			continue
+		}
+
+		return port, nil
+	}
+
+	return "", ErrNoRPCListener
+}
+
+// parsePermissions parses permissions in the format entity:action, such as
+// invoices:write, into bakery operations.
+func parsePermissions(perms []string) ([]bakery.Op, error) {
+	ops := make([]bakery.Op, 0, len(perms))
+	for _, perm := range perms {
+		fields := strings.Split(perm, ":")
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("permission expected in "+
+				"format entity:action, got %q", perm)
+		}
+
+		ops = append(ops, bakery.Op{
+			Entity: fields[0],
+			Action: fields[1],
+		})
+	}
+
+	return ops, nil
+}
+
+// LndConnectURI bakes a macaroon granting the given permissions, and returns
+// an lndconnect URI pairing a remote app with the node at host, along with
+// the node's TLS certificate. The node must serve RPC listeners, which are
+// only brought up with --rpclisten. If host doesn't include a port, the port
+// of the first listener that isn't on a loopback address is used. Without any
+// permissions, the macaroon grants admin access.
+func LndConnectURI(host string, perms []string) (string, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	switch {
+	case d == nil:
+		return "", ErrDaemonNotRunning
+
+	case d.macaroonService == nil:
+		return "", ErrMacaroonsDisabled
+
+	case len(d.rpcListeners) == 0:
+		return "", ErrNoRPCListener
+	}
+
+	ops := append(readPermissions, writePermissions...)
+	if len(perms) > 0 {
+		var err error
+		ops, err = parsePermissions(perms)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	mac, err := d.macaroonService.Oven.NewMacaroon(
+		context.Background(), bakery.LatestVersion, nil, ops...,
+	)
+	if err != nil {
+		return "", err
+	}
+	macBytes, err := mac.M().MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+
+	// The certificate is passed in its DER form.
+	certPEM, err := ioutil.ReadFile(cfg.TLSCertPath)
+	if err != nil {
+		return "", err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", fmt.Errorf("unable to decode TLS certificate %v",
+			cfg.TLSCertPath)
+	}
+
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port, err := remoteRPCPort(d.rpcListeners)
+		if err != nil {
+			return "", err
+		}
+		host = net.JoinHostPort(host, port)
+	}
+
+	query := url.Values{}
+	query.Set("cert", base64.RawURLEncoding.EncodeToString(block.Bytes))
+	query.Set("macaroon", base64.RawURLEncoding.EncodeToString(macBytes))
+
+	uri := url.URL{
+		Scheme:   "lndconnect",
+		Host:     host,
+		RawQuery: query.Encode(),
+	}
+
+	return uri.String(), nil
+}
@@ -0,0 +1,37 @@
+// +build !noremoterpc
+
+package lnd
+
+import (
+	"net"
+	"testing"
+)
+
+// TestRemoteRPCPort checks that remote apps are paired with a listener they
+// can reach.
+func TestRemoteRPCPort(t *testing.T) {
+	tcpAddr := func(ip string, port int) net.Addr {
+		return &net.TCPAddr{IP: net.ParseIP(ip), Port: port}
+	}
+
+	if _, err := remoteRPCPort(nil); err != ErrNoRPCListener {
+		t.Fatalf("expected ErrNoRPCListener without listeners, got %v",
+			err)
+	}
+
+	local := []net.Addr{tcpAddr("127.0.0.1", 10009), tcpAddr("::1", 10010)}
+	if _, err := remoteRPCPort(local); err != ErrNoRPCListener {
+		t.Fatalf("expected ErrNoRPCListener with loopback listeners, "+
+			"got %v", err)
+	}
+
+	listeners := append(local, tcpAddr("0.0.0.0", 10011),
+		tcpAddr("192.168.1.2", 10012))
+	port, err := remoteRPCPort(listeners)
+	if err != nil {
+		t.Fatalf("unable to pick port: %v", err)
+	}
+	if port != "10011" {
+		t.Fatalf("expected port 10011, got %v", port)
+	}
+}
@@ -162,6 +162,21 @@ func setLogLevels(logLevel string) {
 	}
 }
 
+// SetLogLevel changes the logging level of a single subsystem while lnd is
+// running. If subsystemID is empty, the level is applied to all subsystems.
+func SetLogLevel(subsystemID string, logLevel string) error {
+	if subsystemID == "" {
+		return parseAndSetDebugLevels(logLevel)
+	}
+
+	return parseAndSetDebugLevels(subsystemID + "=" + logLevel)
+}
+
+// LogSubsystems returns the sorted identifiers of all logging subsystems.
+func LogSubsystems() []string {
+	return supportedSubsystems()
+}
+
 // logClosure is used to provide a closure over expensive logging operations so
 // don't have to be performed when the logging level doesn't warrant it.
 type logClosure func() string
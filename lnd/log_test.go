@@ -0,0 +1,19 @@
+package lnd
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/btcsuite/btclog"
+)
+
+func init() {
+	// The log rotator is only set up when the daemon starts, so the
+	// loggers used by the code under test are disabled, and the logs of
+	// the packages it calls into are discarded.
+	ltndLog = btclog.Disabled
+
+	r, w := io.Pipe()
+	go io.Copy(ioutil.Discard, r)
+	logRotatorPipe = w
+}
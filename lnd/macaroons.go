@@ -0,0 +1,233 @@
+package lnd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/bbolt"
+	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/roasbeef/btcwallet/snacl"
+	"golang.org/x/net/context"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+)
+
+// ErrMacaroonsDisabled is returned when baking a macaroon while lnd runs with
+// --no-macaroons.
+var ErrMacaroonsDisabled = errors.New("macaroons are disabled")
+
+// macaroonDBFilename is the name of the database the macaroon service stores
+// its root keys in.
+const macaroonDBFilename = "macaroons.db"
+
+var (
+	// macaroonRootKeyBucket is the bucket of the macaroon database
+	// holding the encrypted root keys, mirroring the macaroons package.
+	macaroonRootKeyBucket = []byte("macrootkeys")
+
+	// macaroonEncKeyID is the key within macaroonRootKeyBucket storing
+	// the encryption key of the root keys.
+	macaroonEncKeyID = []byte("enckey")
+)
+
+// startMacaroonService creates and unlocks the macaroon service, generating
+// the macaroon files if they don't exist yet. The bindings call the rpcServer
+// in-process, bypassing authentication entirely, so macaroons are only needed
+// by external gRPC clients. If they're disabled through --no-macaroons, the
+// service isn't started, and the macaroons left on disk from earlier runs are
+// kept for a later run with macaroons enabled, unless --removemacaroons is
+// set too.
+func (d *daemon) startMacaroonService(privateWalletPw []byte) error {
+	if cfg.NoMacaroons {
+		if cfg.RemoveMacaroons {
+			return removeMacaroons()
+		}
+		return nil
+	}
+
+	macaroonService, err := macaroons.NewService(macaroonDatabaseDir,
+		macaroons.IPLockChecker)
+	if err != nil {
+		srvrLog.Errorf("unable to create macaroon service: %v", err)
+		return err
+	}
+	d.macaroonService = macaroonService
+
+	err = macaroonService.CreateUnlock(&privateWalletPw)
+	if err != nil && err != macaroons.ErrAlreadyUnlocked {
+		srvrLog.Error(err)
+		return err
+	}
+
+	// Create macaroon files for external clients to use if they don't
+	// exist.
+	if !fileExists(cfg.AdminMacPath) && !fileExists(cfg.ReadMacPath) &&
+		!fileExists(cfg.InvoiceMacPath) {
+
+		err = genMacaroons(
+			context.Background(), macaroonService,
+			cfg.AdminMacPath, cfg.ReadMacPath, cfg.InvoiceMacPath,
+		)
+		if err != nil {
+			ltndLog.Errorf("unable to create macaroon files: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeMacaroons deletes the macaroon files along with the macaroon
+// database.
+func removeMacaroons() error {
+	paths := []string{
+		cfg.AdminMacPath,
+		cfg.ReadMacPath,
+		cfg.InvoiceMacPath,
+		filepath.Join(macaroonDatabaseDir, macaroonDBFilename),
+	}
+	for _, path := range paths {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rekeyMacaroons re-encrypts the root keys of the macaroon database under
+// newPw, reporting the number of root keys re-encrypted so far. The root keys
+// themselves are unchanged, so existing macaroons remain valid. The database
+// is updated within a single transaction, and must not be open, so the macaroon
+// service has to be stopped first.
+func rekeyMacaroons(oldPw, newPw []byte,
+	progress func(completed, total int64)) error {
+
+	dbPath := filepath.Join(macaroonDatabaseDir, macaroonDBFilename)
+	db, err := bolt.Open(dbPath, 0600, bolt.DefaultOptions)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(macaroonRootKeyBucket)
+		if bucket == nil {
+			return errors.New("macaroon database has no root keys")
+		}
+
+		oldKey := &snacl.SecretKey{}
+		if err := oldKey.Unmarshal(bucket.Get(macaroonEncKeyID)); err != nil {
+			return err
+		}
+		if err := oldKey.DeriveKey(&oldPw); err != nil {
+			return err
+		}
+		defer oldKey.Zero()
+
+		newKey, err := snacl.NewSecretKey(
+			&newPw, snacl.DefaultN, snacl.DefaultR, snacl.DefaultP,
+		)
+		if err != nil {
+			return err
+		}
+		defer newKey.Zero()
+
+		// Collect the IDs of the root keys first, as the bucket can't
+		// be modified while iterating over it.
+		var ids [][]byte
+		err = bucket.ForEach(func(k, _ []byte) error {
+			if !bytes.Equal(k, macaroonEncKeyID) {
+				ids = append(ids, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		total := int64(len(ids))
+		progress(0, total)
+		for i, id := range ids {
+			rootKey, err := oldKey.Decrypt(bucket.Get(id))
+			if err != nil {
+				return err
+			}
+
+			encRootKey, err := newKey.Encrypt(rootKey)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(id, encRootKey); err != nil {
+				return err
+			}
+
+			progress(int64(i+1), total)
+		}
+
+		return bucket.Put(macaroonEncKeyID, newKey.Marshal())
+	})
+}
+
+// genMacaroons generates a pair of macaroon files; one admin-level and one
+// read-only. These can also be used to generate more granular macaroons.
+func genMacaroons(ctx context.Context, svc *macaroons.Service,
+	admFile, roFile, invoiceFile string) error {
+
+	// First, we'll generate a macaroon that only allows the caller to
+	// access invoice related calls. This is useful for merchants and other
+	// services to allow an isolated instance that can only query and
+	// modify invoices.
+	invoiceMac, err := svc.Oven.NewMacaroon(
+		ctx, bakery.LatestVersion, nil, invoicePermissions...,
+	)
+	if err != nil {
+		return err
+	}
+	invoiceMacBytes, err := invoiceMac.M().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(invoiceFile, invoiceMacBytes, 0644)
+	if err != nil {
+		os.Remove(invoiceFile)
+		return err
+	}
+
+	// Generate the read-only macaroon and write it to a file.
+	roMacaroon, err := svc.Oven.NewMacaroon(
+		ctx, bakery.LatestVersion, nil, readPermissions...,
+	)
+	if err != nil {
+		return err
+	}
+	roBytes, err := roMacaroon.M().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(roFile, roBytes, 0644); err != nil {
+		os.Remove(roFile)
+		return err
+	}
+
+	// Generate the admin macaroon and write it to a file.
+	adminPermissions := append(readPermissions, writePermissions...)
+	admMacaroon, err := svc.Oven.NewMacaroon(
+		ctx, bakery.LatestVersion, nil, adminPermissions...,
+	)
+	if err != nil {
+		return err
+	}
+	admBytes, err := admMacaroon.M().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(admFile, admBytes, 0600); err != nil {
+		return err
+	}
+
+	return nil
+}
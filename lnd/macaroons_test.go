@@ -0,0 +1,69 @@
+package lnd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNoMacaroonsKeepsFiles checks that running with --no-macaroons leaves the
+// macaroon files and database of earlier runs in place, unless they're to be
+// removed.
+func TestNoMacaroonsKeepsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nomacaroons")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevCfg, prevDir := cfg, macaroonDatabaseDir
+	defer func() {
+		cfg, macaroonDatabaseDir = prevCfg, prevDir
+	}()
+
+	cfg = &config{
+		NoMacaroons:    true,
+		AdminMacPath:   filepath.Join(dir, "admin.macaroon"),
+		ReadMacPath:    filepath.Join(dir, "readonly.macaroon"),
+		InvoiceMacPath: filepath.Join(dir, "invoice.macaroon"),
+	}
+	macaroonDatabaseDir = dir
+
+	paths := []string{
+		cfg.AdminMacPath,
+		cfg.ReadMacPath,
+		cfg.InvoiceMacPath,
+		filepath.Join(dir, macaroonDBFilename),
+	}
+	for _, path := range paths {
+		if err := ioutil.WriteFile(path, []byte{1}, 0600); err != nil {
+			t.Fatalf("unable to write %v: %v", path, err)
+		}
+	}
+
+	d := &daemon{}
+	if err := d.startMacaroonService(nil); err != nil {
+		t.Fatalf("unable to skip macaroon service: %v", err)
+	}
+	if d.macaroonService != nil {
+		t.Fatalf("macaroon service started")
+	}
+
+	for _, path := range paths {
+		if !fileExists(path) {
+			t.Fatalf("%v removed", path)
+		}
+	}
+
+	// With --removemacaroons, they're deleted.
+	cfg.RemoveMacaroons = true
+	if err := d.startMacaroonService(nil); err != nil {
+		t.Fatalf("unable to remove macaroons: %v", err)
+	}
+	for _, path := range paths {
+		if fileExists(path) {
+			t.Fatalf("%v not removed", path)
+		}
+	}
+}
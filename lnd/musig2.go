@@ -0,0 +1,845 @@
+package lnd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// The MuSig2 multi-signature scheme of BIP 327, in which several signers
+// produce a single BIP 340 signature valid for their aggregate key, such as
+// the key of a taproot output they share.
+
+var (
+	// ErrUnknownMuSig2Session is returned for a session id that doesn't
+	// belong to an open session.
+	ErrUnknownMuSig2Session = errors.New("unknown musig2 session")
+
+	// ErrMuSig2Signers is returned for a session without other signers
+	// than the local key.
+	ErrMuSig2Signers = errors.New("a session needs other signers")
+
+	// ErrMuSig2Nonces is returned for public nonces that aren't 66 bytes,
+	// are registered twice, or outnumber the signers.
+	ErrMuSig2Nonces = errors.New("invalid public nonces")
+
+	// ErrMuSig2NoncesMissing is returned when signing before the nonces
+	// of all signers are known.
+	ErrMuSig2NoncesMissing = errors.New("the nonces of all signers are " +
+		"needed to sign")
+
+	// ErrMuSig2Signed is returned when signing twice in a session, which
+	// would reuse its nonce and leak the private key.
+	ErrMuSig2Signed = errors.New("the session has already signed")
+
+	// ErrMuSig2NotSigned is returned when combining signatures before the
+	// local signer signed.
+	ErrMuSig2NotSigned = errors.New("the session hasn't signed yet")
+
+	// ErrMuSig2InvalidSig is returned when the combined signature isn't
+	// valid, because a partial signature is wrong or missing.
+	ErrMuSig2InvalidSig = errors.New("combined signature is invalid")
+
+	// ErrMuSig2Message is returned for a message that isn't a 32 byte
+	// digest.
+	ErrMuSig2Message = errors.New("message must be a 32 byte digest")
+)
+
+// MuSig2Session describes an open signing session. Keys are x-only and, like
+// the nonces, hex encoded.
+type MuSig2Session struct {
+	SessionID          string `json:"session_id"`
+	CombinedKey        string `json:"combined_key"`
+	TaprootInternalKey string `json:"taproot_internal_key,omitempty"`
+	LocalPublicNonces  string `json:"local_public_nonces"`
+	HaveAllNonces      bool   `json:"have_all_nonces"`
+}
+
+// muSig2SessionExpiry is how long a session stays open. Sessions abandoned
+// without a call to MuSig2Cleanup are closed once they expire.
+const muSig2SessionExpiry = time.Hour
+
+// muSig2KeyAgg is the aggregate key of a list of signers' compressed keys.
+type muSig2KeyAgg struct {
+	pubKeys [][]byte
+
+	// qx and qy are the aggregate key, and gacc and tacc accumulate the
+	// tweaks applied to it (BIP 327).
+	qx, qy     *big.Int
+	gacc, tacc *big.Int
+}
+
+// muSig2Session is the state of a signing session.
+type muSig2Session struct {
+	muSig2KeyAgg
+
+	keyLoc   keychain.KeyLocator
+	localKey []byte
+	created  time.Time
+
+	// secNonce holds the two secret nonces, and is wiped once used.
+	// nonces holds the public nonces, the local ones first.
+	secNonce []byte
+	nonces   [][]byte
+
+	// The session values of the message signed, and the partial
+	// signatures known, the local one first.
+	aggNonce []byte
+	msg      []byte
+	sigs     [][]byte
+
+	// signedKeys and usedNonces mark the signers and the nonces the
+	// partial signatures known were verified against.
+	signedKeys map[int]bool
+	usedNonces map[int]bool
+}
+
+var (
+	// muSig2Mtx guards muSig2Sessions.
+	muSig2Mtx sync.Mutex
+
+	// muSig2Sessions holds the open sessions by id.
+	muSig2Sessions = make(map[string]*muSig2Session)
+)
+
+// compressedPoint encodes the point in 33 byte compressed form.
+func compressedPoint(x, y *big.Int) []byte {
+	prefix := byte(0x02)
+	if !hasEvenY(y) {
+		prefix = 0x03
+	}
+
+	return append([]byte{prefix}, scalarBytes(x)...)
+}
+
+// compressedPointExt encodes the point like compressedPoint, or as 33 zero
+// bytes for the point at infinity.
+func compressedPointExt(x, y *big.Int) []byte {
+	if isInfinity(x, y) {
+		return make([]byte, 33)
+	}
+
+	return compressedPoint(x, y)
+}
+
+// parsePointExt decodes a point encoded by compressedPointExt.
+func parsePointExt(b []byte) (*big.Int, *big.Int, error) {
+	if bytes.Equal(b, make([]byte, 33)) {
+		return new(big.Int), new(big.Int), nil
+	}
+
+	pubKey, err := btcec.ParsePubKey(b, btcec.S256())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pubKey.X, pubKey.Y, nil
+}
+
+// keyAggCoeff returns the coefficient the key is multiplied with in the
+// aggregate key of the keys. The second distinct key gets 1, saving a
+// multiplication (BIP 327).
+func keyAggCoeff(pubKeys [][]byte, pubKey []byte) *big.Int {
+	list := taggedHash("KeyAgg list", pubKeys...)
+
+	for _, k := range pubKeys[1:] {
+		if !bytes.Equal(k, pubKeys[0]) {
+			if bytes.Equal(k, pubKey) {
+				return big.NewInt(1)
+			}
+			break
+		}
+	}
+
+	a := new(big.Int).SetBytes(
+		taggedHash("KeyAgg coefficient", list, pubKey),
+	)
+	return a.Mod(a, curveN)
+}
+
+// newMuSig2KeyAgg aggregates the compressed keys, in the order given.
+func newMuSig2KeyAgg(pubKeys [][]byte) (*muSig2KeyAgg, error) {
+	curve := btcec.S256()
+
+	qx, qy := new(big.Int), new(big.Int)
+	for _, k := range pubKeys {
+		if len(k) != 33 {
+			return nil, ErrMuSig2Signers
+		}
+		pubKey, err := btcec.ParsePubKey(k, curve)
+		if err != nil {
+			return nil, err
+		}
+
+		a := keyAggCoeff(pubKeys, k)
+		px, py := curve.ScalarMult(pubKey.X, pubKey.Y, scalarBytes(a))
+		qx, qy = curve.Add(qx, qy, px, py)
+	}
+	if isInfinity(qx, qy) {
+		return nil, errors.New("aggregate key is infinite")
+	}
+
+	return &muSig2KeyAgg{
+		pubKeys: pubKeys,
+		qx:      qx,
+		qy:      qy,
+		gacc:    big.NewInt(1),
+		tacc:    new(big.Int),
+	}, nil
+}
+
+// applyTaprootTweak tweaks the aggregate key into the output key of a taproot
+// output without a script path (BIP 86), so the combined signature spends the
+// output by its key path.
+func (agg *muSig2KeyAgg) applyTaprootTweak() error {
+	curve := btcec.S256()
+
+	t := new(big.Int).SetBytes(taggedHash("TapTweak", scalarBytes(agg.qx)))
+	if t.Cmp(curveN) >= 0 {
+		return errors.New("taproot tweak out of range")
+	}
+
+	g := big.NewInt(1)
+	if !hasEvenY(agg.qy) {
+		g.Sub(curveN, g)
+	}
+
+	gx, gy := curve.ScalarMult(agg.qx, agg.qy, scalarBytes(g))
+	tx, ty := curve.ScalarBaseMult(scalarBytes(t))
+	agg.qx, agg.qy = curve.Add(gx, gy, tx, ty)
+	if isInfinity(agg.qx, agg.qy) {
+		return errors.New("tweaked key is infinite")
+	}
+
+	agg.gacc.Mul(agg.gacc, g)
+	agg.gacc.Mod(agg.gacc, curveN)
+	agg.tacc.Mul(agg.tacc, g)
+	agg.tacc.Add(agg.tacc, t)
+	agg.tacc.Mod(agg.tacc, curveN)
+
+	return nil
+}
+
+// parity returns 1, or n-1 when the aggregate key has an odd y coordinate.
+func (agg *muSig2KeyAgg) parity() *big.Int {
+	g := big.NewInt(1)
+	if !hasEvenY(agg.qy) {
+		g.Sub(curveN, g)
+	}
+
+	return g
+}
+
+// muSig2Nonces generates the two secret nonces of a signer and their public
+// nonces, from fresh randomness mixed with the private key (BIP 327).
+func muSig2Nonces(privKey *btcec.PrivateKey, pubKey,
+	aggKey []byte) ([]byte, []byte, error) {
+
+	var randBytes [32]byte
+	if _, err := rand.Read(randBytes[:]); err != nil {
+		return nil, nil, err
+	}
+
+	secNonce, err := muSig2NonceGen(
+		randBytes[:], scalarBytes(privKey.D), pubKey, aggKey, nil, nil,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return secNonce, muSig2PubNonce(secNonce), nil
+}
+
+// muSig2NonceGen derives the two secret nonces of BIP 327's NonceGen from the
+// 32 random bytes and the optional secret key, aggregate key, message and
+// extra input. A nil message is left out, while an empty one is included.
+func muSig2NonceGen(randBytes, secKey, pubKey, aggKey, msg,
+	extraIn []byte) ([]byte, error) {
+
+	seed := append([]byte(nil), randBytes...)
+	if secKey != nil {
+		seed = append([]byte(nil), secKey...)
+		for i, b := range taggedHash("MuSig/aux", randBytes) {
+			seed[i] ^= b
+		}
+	}
+
+	msgPrefixed := []byte{0x00}
+	if msg != nil {
+		var msgLen [8]byte
+		binary.BigEndian.PutUint64(msgLen[:], uint64(len(msg)))
+		msgPrefixed = append([]byte{0x01}, msgLen[:]...)
+		msgPrefixed = append(msgPrefixed, msg...)
+	}
+
+	var extraLen [4]byte
+	binary.BigEndian.PutUint32(extraLen[:], uint32(len(extraIn)))
+
+	var secNonce []byte
+	for i := byte(0); i < 2; i++ {
+		k := new(big.Int).SetBytes(taggedHash("MuSig/nonce",
+			seed, []byte{byte(len(pubKey))}, pubKey,
+			[]byte{byte(len(aggKey))}, aggKey, msgPrefixed,
+			extraLen[:], extraIn, []byte{i},
+		))
+		k.Mod(k, curveN)
+		if k.Sign() == 0 {
+			return nil, errors.New("invalid nonce")
+		}
+
+		secNonce = append(secNonce, scalarBytes(k)...)
+	}
+
+	return secNonce, nil
+}
+
+// muSig2PubNonce returns the public nonces of the two secret nonces.
+func muSig2PubNonce(secNonce []byte) []byte {
+	curve := btcec.S256()
+
+	var pubNonce []byte
+	for i := 0; i < 2; i++ {
+		rx, ry := curve.ScalarBaseMult(secNonce[i*32 : (i+1)*32])
+		pubNonce = append(pubNonce, compressedPoint(rx, ry)...)
+	}
+
+	return pubNonce
+}
+
+// muSig2NonceAgg sums the signers' public nonces into the aggregate nonce.
+func muSig2NonceAgg(nonces [][]byte) ([]byte, error) {
+	curve := btcec.S256()
+
+	r1x, r1y := new(big.Int), new(big.Int)
+	r2x, r2y := new(big.Int), new(big.Int)
+	for _, nonce := range nonces {
+		x1, y1, x2, y2, err := parsePubNonce(nonce)
+		if err != nil {
+			return nil, err
+		}
+		r1x, r1y = curve.Add(r1x, r1y, x1, y1)
+		r2x, r2y = curve.Add(r2x, r2y, x2, y2)
+	}
+
+	return append(
+		compressedPointExt(r1x, r1y), compressedPointExt(r2x, r2y)...,
+	), nil
+}
+
+// parsePubNonce decodes the two points of a signer's public nonce.
+func parsePubNonce(nonce []byte) (*big.Int, *big.Int, *big.Int, *big.Int,
+	error) {
+
+	if len(nonce) != 66 {
+		return nil, nil, nil, nil, ErrMuSig2Nonces
+	}
+
+	r1, err := btcec.ParsePubKey(nonce[:33], btcec.S256())
+	if err != nil {
+		return nil, nil, nil, nil, ErrMuSig2Nonces
+	}
+	r2, err := btcec.ParsePubKey(nonce[33:], btcec.S256())
+	if err != nil {
+		return nil, nil, nil, nil, ErrMuSig2Nonces
+	}
+
+	return r1.X, r1.Y, r2.X, r2.Y, nil
+}
+
+// muSig2Values are the values of BIP 327's GetSessionValues for an aggregate
+// nonce and message.
+type muSig2Values struct {
+	b, e   *big.Int
+	rx, ry *big.Int
+}
+
+// sessionValues computes the nonce coefficient, final nonce and challenge of
+// the aggregate nonce and message.
+func (agg *muSig2KeyAgg) sessionValues(aggNonce,
+	msg []byte) (*muSig2Values, error) {
+
+	if len(aggNonce) != 66 {
+		return nil, ErrMuSig2Nonces
+	}
+	r1x, r1y, err := parsePointExt(aggNonce[:33])
+	if err != nil {
+		return nil, ErrMuSig2Nonces
+	}
+	r2x, r2y, err := parsePointExt(aggNonce[33:])
+	if err != nil {
+		return nil, ErrMuSig2Nonces
+	}
+
+	curve := btcec.S256()
+	qxBytes := scalarBytes(agg.qx)
+
+	b := new(big.Int).SetBytes(
+		taggedHash("MuSig/noncecoef", aggNonce, qxBytes, msg),
+	)
+	b.Mod(b, curveN)
+
+	bx, by := curve.ScalarMult(r2x, r2y, scalarBytes(b))
+	rx, ry := curve.Add(r1x, r1y, bx, by)
+	if isInfinity(rx, ry) {
+		rx, ry = curve.Gx, curve.Gy
+	}
+
+	e := new(big.Int).SetBytes(
+		taggedHash("BIP0340/challenge", scalarBytes(rx), qxBytes, msg),
+	)
+	e.Mod(e, curveN)
+
+	return &muSig2Values{b: b, e: e, rx: rx, ry: ry}, nil
+}
+
+// partialSign creates the partial signature of the signer with the private
+// key and its two secret nonces over the message (BIP 327).
+func (agg *muSig2KeyAgg) partialSign(privKey *btcec.PrivateKey, secNonce,
+	aggNonce, msg []byte) ([]byte, error) {
+
+	k1 := new(big.Int).SetBytes(secNonce[:32])
+	k2 := new(big.Int).SetBytes(secNonce[32:64])
+	if k1.Sign() == 0 || k1.Cmp(curveN) >= 0 ||
+		k2.Sign() == 0 || k2.Cmp(curveN) >= 0 {
+
+		return nil, errors.New("secret nonce out of range")
+	}
+
+	pubKey := privKey.PubKey().SerializeCompressed()
+	found := false
+	for _, k := range agg.pubKeys {
+		if bytes.Equal(k, pubKey) {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("signer isn't part of the session")
+	}
+
+	v, err := agg.sessionValues(aggNonce, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !hasEvenY(v.ry) {
+		k1.Sub(curveN, k1)
+		k2.Sub(curveN, k2)
+	}
+
+	d := new(big.Int).Mul(agg.parity(), agg.gacc)
+	d.Mul(d, privKey.D)
+	d.Mod(d, curveN)
+
+	a := keyAggCoeff(agg.pubKeys, pubKey)
+
+	// s = k1 + b*k2 + e*a*d
+	s := new(big.Int).Mul(v.b, k2)
+	s.Add(s, k1)
+	ead := new(big.Int).Mul(v.e, a)
+	ead.Mul(ead, d)
+	s.Add(s, ead)
+	s.Mod(s, curveN)
+
+	return scalarBytes(s), nil
+}
+
+// partialVerify returns whether the partial signature over the message is
+// valid for the signer with the compressed key and public nonce (BIP 327).
+func (agg *muSig2KeyAgg) partialVerify(sig, pubNonce, pubKey, aggNonce,
+	msg []byte) bool {
+
+	s := new(big.Int).SetBytes(sig)
+	if len(sig) != 32 || s.Cmp(curveN) >= 0 {
+		return false
+	}
+
+	curve := btcec.S256()
+
+	r1x, r1y, r2x, r2y, err := parsePubNonce(pubNonce)
+	if err != nil {
+		return false
+	}
+	p, err := btcec.ParsePubKey(pubKey, curve)
+	if err != nil {
+		return false
+	}
+	v, err := agg.sessionValues(aggNonce, msg)
+	if err != nil {
+		return false
+	}
+
+	// Re = R1 + b*R2, negated along with the final nonce.
+	bx, by := curve.ScalarMult(r2x, r2y, scalarBytes(v.b))
+	rex, rey := curve.Add(r1x, r1y, bx, by)
+	if !hasEvenY(v.ry) && !isInfinity(rex, rey) {
+		rey.Sub(curveP, rey)
+	}
+
+	// s*G = Re + e*a*g*gacc*P
+	c := new(big.Int).Mul(v.e, keyAggCoeff(agg.pubKeys, pubKey))
+	c.Mul(c, agg.parity())
+	c.Mul(c, agg.gacc)
+	c.Mod(c, curveN)
+	px, py := curve.ScalarMult(p.X, p.Y, scalarBytes(c))
+	ex, ey := curve.Add(rex, rey, px, py)
+
+	sx, sy := curve.ScalarBaseMult(scalarBytes(s))
+
+	return sx.Cmp(ex) == 0 && sy.Cmp(ey) == 0
+}
+
+// aggregateSigs sums the partial signatures over the message into the final
+// signature, valid for the aggregate key.
+func (agg *muSig2KeyAgg) aggregateSigs(sigs [][]byte, aggNonce,
+	msg []byte) ([]byte, error) {
+
+	v, err := agg.sessionValues(aggNonce, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// s = s1 + ... + sn + e*g*tacc
+	s := new(big.Int)
+	for _, sig := range sigs {
+		si := new(big.Int).SetBytes(sig)
+		if len(sig) != 32 || si.Cmp(curveN) >= 0 {
+			return nil, ErrMuSig2InvalidSig
+		}
+		s.Add(s, si)
+	}
+	egt := new(big.Int).Mul(v.e, agg.parity())
+	egt.Mul(egt, agg.tacc)
+	s.Add(s, egt)
+	s.Mod(s, curveN)
+
+	return append(scalarBytes(v.rx), scalarBytes(s)...), nil
+}
+
+// MuSig2CreateSession opens a signing session for the key at the family and
+// index, together with the other signers' compressed keys. With taprootTweak
+// set, the combined key is the output key of a taproot output without a
+// script path whose internal key is the aggregate key. The public nonces of
+// other signers may be passed now, or later through MuSig2RegisterNonces. As
+// for DerivePrivKey, only families from MinAppKeyFamily onwards may be used.
+// Sessions expire after an hour.
+func MuSig2CreateSession(family, index int32, signers [][]byte,
+	taprootTweak bool, otherNonces [][]byte) (*MuSig2Session, error) {
+
+	keyRing, err := runningKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	keyLoc, err := keyLocator(family, index)
+	if err != nil {
+		return nil, err
+	}
+	if family < MinAppKeyFamily {
+		return nil, ErrReservedKeyFamily
+	}
+
+	privKey, err := keyRing.DerivePrivKey(keychain.KeyDescriptor{
+		KeyLocator: keyLoc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	localKey := privKey.PubKey().SerializeCompressed()
+
+	// The keys are sorted, so the signers agree on the aggregate key
+	// whatever order each passes them in.
+	pubKeys := make([][]byte, 0, len(signers)+1)
+	found := false
+	for _, signer := range signers {
+		if bytes.Equal(signer, localKey) {
+			found = true
+		}
+		pubKeys = append(pubKeys, signer)
+	}
+	if !found {
+		pubKeys = append(pubKeys, localKey)
+	}
+	if len(pubKeys) < 2 {
+		return nil, ErrMuSig2Signers
+	}
+	sort.Slice(pubKeys, func(i, j int) bool {
+		return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+	})
+
+	keyAgg, err := newMuSig2KeyAgg(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	session := &muSig2Session{
+		muSig2KeyAgg: *keyAgg,
+		keyLoc:       keyLoc,
+		localKey:     localKey,
+		created:      time.Now(),
+	}
+
+	result := &MuSig2Session{}
+	if taprootTweak {
+		result.TaprootInternalKey = hex.EncodeToString(
+			scalarBytes(session.qx),
+		)
+		if err := session.applyTaprootTweak(); err != nil {
+			return nil, err
+		}
+	}
+	result.CombinedKey = hex.EncodeToString(scalarBytes(session.qx))
+
+	secNonce, pubNonce, err := muSig2Nonces(
+		privKey, localKey, scalarBytes(session.qx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.secNonce = secNonce
+	session.nonces = [][]byte{pubNonce}
+	result.LocalPublicNonces = hex.EncodeToString(pubNonce)
+
+	if err := session.addNonces(otherNonces); err != nil {
+		return nil, err
+	}
+	result.HaveAllNonces = len(session.nonces) == len(pubKeys)
+
+	var id [32]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	result.SessionID = hex.EncodeToString(id[:])
+
+	muSig2Mtx.Lock()
+	for sessionID, s := range muSig2Sessions {
+		if time.Since(s.created) > muSig2SessionExpiry {
+			closeMuSig2Session(sessionID)
+		}
+	}
+	muSig2Sessions[result.SessionID] = session
+	muSig2Mtx.Unlock()
+
+	return result, nil
+}
+
+// addNonces adds the public nonces of other signers to the session.
+func (s *muSig2Session) addNonces(nonces [][]byte) error {
+	for _, nonce := range nonces {
+		if len(s.nonces) == len(s.pubKeys) {
+			return ErrMuSig2Nonces
+		}
+		for _, known := range s.nonces {
+			if bytes.Equal(known, nonce) {
+				return ErrMuSig2Nonces
+			}
+		}
+		if _, _, _, _, err := parsePubNonce(nonce); err != nil {
+			return err
+		}
+
+		s.nonces = append(s.nonces, nonce)
+	}
+
+	return nil
+}
+
+// lookupMuSig2Session returns the open session with the id, closing it if it
+// expired. The caller must hold muSig2Mtx.
+func lookupMuSig2Session(sessionID string) (*muSig2Session, error) {
+	session, ok := muSig2Sessions[sessionID]
+	if !ok {
+		return nil, ErrUnknownMuSig2Session
+	}
+	if time.Since(session.created) > muSig2SessionExpiry {
+		closeMuSig2Session(sessionID)
+		return nil, ErrUnknownMuSig2Session
+	}
+
+	return session, nil
+}
+
+// closeMuSig2Session wipes the secret nonce of the session and forgets it. The
+// caller must hold muSig2Mtx.
+func closeMuSig2Session(sessionID string) {
+	session := muSig2Sessions[sessionID]
+	for i := range session.secNonce {
+		session.secNonce[i] = 0
+	}
+	session.secNonce = nil
+
+	delete(muSig2Sessions, sessionID)
+}
+
+// MuSig2RegisterNonces adds the public nonces of other signers to the session,
+// returning whether the nonces of all signers are now known.
+func MuSig2RegisterNonces(sessionID string, nonces [][]byte) (bool, error) {
+	muSig2Mtx.Lock()
+	defer muSig2Mtx.Unlock()
+
+	session, err := lookupMuSig2Session(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if err := session.addNonces(nonces); err != nil {
+		return false, err
+	}
+
+	return len(session.nonces) == len(session.pubKeys), nil
+}
+
+// MuSig2Sign creates the local partial signature of the session over the 32
+// byte message digest, once the nonces of all signers are known. A session
+// signs only once, as signing twice with the same nonce leaks the private key.
+func MuSig2Sign(sessionID string, msg []byte) ([]byte, error) {
+	if len(msg) != 32 {
+		return nil, ErrMuSig2Message
+	}
+
+	keyRing, err := runningKeyRing()
+	if err != nil {
+		return nil, err
+	}
+
+	muSig2Mtx.Lock()
+	defer muSig2Mtx.Unlock()
+
+	session, err := lookupMuSig2Session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.secNonce == nil {
+		return nil, ErrMuSig2Signed
+	}
+	if len(session.nonces) != len(session.pubKeys) {
+		return nil, ErrMuSig2NoncesMissing
+	}
+
+	privKey, err := keyRing.DerivePrivKey(keychain.KeyDescriptor{
+		KeyLocator: session.keyLoc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aggNonce, err := muSig2NonceAgg(session.nonces)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wipe the secret nonce before anything else can go wrong, so it is
+	// never used twice.
+	secNonce := session.secNonce
+	session.secNonce = nil
+	defer func() {
+		for i := range secNonce {
+			secNonce[i] = 0
+		}
+	}()
+
+	sig, err := session.partialSign(privKey, secNonce, aggNonce, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	session.aggNonce = aggNonce
+	session.msg = msg
+	session.sigs = [][]byte{sig}
+	session.signedKeys = make(map[int]bool)
+	session.usedNonces = map[int]bool{0: true}
+	for i, k := range session.pubKeys {
+		if bytes.Equal(k, session.localKey) {
+			session.signedKeys[i] = true
+			break
+		}
+	}
+
+	return sig, nil
+}
+
+// verifyPartialSig checks the partial signature of another signer against the
+// signers and nonces without one yet, marking those it's valid for.
+func (s *muSig2Session) verifyPartialSig(sig []byte) bool {
+	for i, pubKey := range s.pubKeys {
+		if s.signedKeys[i] {
+			continue
+		}
+
+		for j, nonce := range s.nonces {
+			if s.usedNonces[j] {
+				continue
+			}
+
+			if s.partialVerify(sig, nonce, pubKey, s.aggNonce,
+				s.msg) {
+
+				s.signedKeys[i] = true
+				s.usedNonces[j] = true
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// MuSig2CombineSig adds the partial signatures of other signers to the
+// session's own, returning the final signature once all signers' are known,
+// or nil. Each partial signature is verified before it's added. The session
+// is closed once the final signature is returned.
+func MuSig2CombineSig(sessionID string, partialSigs [][]byte) ([]byte, error) {
+	muSig2Mtx.Lock()
+	defer muSig2Mtx.Unlock()
+
+	session, err := lookupMuSig2Session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.sigs == nil {
+		return nil, ErrMuSig2NotSigned
+	}
+
+	for _, sig := range partialSigs {
+		if len(session.sigs) == len(session.pubKeys) ||
+			!session.verifyPartialSig(sig) {
+
+			return nil, ErrMuSig2InvalidSig
+		}
+		session.sigs = append(session.sigs, sig)
+	}
+	if len(session.sigs) < len(session.pubKeys) {
+		return nil, nil
+	}
+
+	sig, err := session.aggregateSigs(
+		session.sigs, session.aggNonce, session.msg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !schnorrVerify(scalarBytes(session.qx), session.msg, sig) {
+		return nil, ErrMuSig2InvalidSig
+	}
+
+	closeMuSig2Session(sessionID)
+
+	return sig, nil
+}
+
+// MuSig2Cleanup closes the session, wiping its secret nonce.
+func MuSig2Cleanup(sessionID string) error {
+	muSig2Mtx.Lock()
+	defer muSig2Mtx.Unlock()
+
+	if _, err := lookupMuSig2Session(sessionID); err != nil {
+		return err
+	}
+	closeMuSig2Session(sessionID)
+
+	return nil
+}
@@ -0,0 +1,536 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// The test vectors of BIP 327.
+
+var muSig2KeyAggKeys = []string{
+	"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+	"03DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+	"023590A94E768F8E1815C2F24B4D80A8E3149316C3518CE7B7AD338368D038CA66",
+	"020000000000000000000000000000000000000000000000000000000000000005",
+	"02FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+	"04F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+}
+
+var muSig2SignKeys = []string{
+	"03935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9",
+	"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+	"02DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA661",
+	"020000000000000000000000000000000000000000000000000000000000000007",
+}
+
+var muSig2SignNonces = []string{
+	"0337C87821AFD50A8644D820A8F3E02E499C931865C2360FB43D0A0D20DAFE07EA" +
+		"0287BF891D2A6DEAEBADC909352AA9405D1428C15F4B75F04DAE642A95C2548480",
+	"0279BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798" +
+		"0279BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798",
+	"032DE2662628C90B03F5E720284EB52FF7D71F4284F627B68A853D78C78E1FFE93" +
+		"03E4C5524E83FFE1493B9077CF1CA6BEB2090C93D930321071AD40B2F44E599046",
+	"0237C87821AFD50A8644D820A8F3E02E499C931865C2360FB43D0A0D20DAFE07EA" +
+		"0387BF891D2A6DEAEBADC909352AA9405D1428C15F4B75F04DAE642A95C2548480",
+	"020000000000000000000000000000000000000000000000000000000000000009",
+}
+
+var muSig2SignAggNonces = []string{
+	"028465FCF0BBDBCF443AABCCE533D42B4B5A10966AC09A49655E8C42DAAB8FCD61" +
+		"037496A3CC86926D452CAFCFD55D25972CA1675D549310DE296BFF42F72EEEA8C9",
+	"000000000000000000000000000000000000000000000000000000000000000000" +
+		"000000000000000000000000000000000000000000000000000000000000000000",
+	"048465FCF0BBDBCF443AABCCE533D42B4B5A10966AC09A49655E8C42DAAB8FCD61" +
+		"037496A3CC86926D452CAFCFD55D25972CA1675D549310DE296BFF42F72EEEA8C9",
+	"028465FCF0BBDBCF443AABCCE533D42B4B5A10966AC09A49655E8C42DAAB8FCD61" +
+		"020000000000000000000000000000000000000000000000000000000000000009",
+	"028465FCF0BBDBCF443AABCCE533D42B4B5A10966AC09A49655E8C42DAAB8FCD61" +
+		"02FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+}
+
+const (
+	muSig2SignSecKey = "7FB9E0E687ADA1EEBF7ECFE2F21E73EB" +
+		"DB51A7D450948DFE8D76D7F2D1007671"
+
+	muSig2SignSecNonce = "508B81A611F100A6B2B6B29656590898" +
+		"AF488BCF2E1F55CF22E5CFB84421FE61" +
+		"FA27FD49B1D50085B481285E1CA205D5" +
+		"5C82CC1B31FF5CD54A489829355901F7"
+
+	muSig2SignMsg = "F95466D086770E689964664219266FE5" +
+		"ED215C92AE20BAB5C9D79ADDDDF3C0CF"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("unable to decode %v: %v", s, err)
+	}
+	return b
+}
+
+// pickHex decodes the strings of the list at the indices.
+func pickHex(t *testing.T, list []string, indices []int) [][]byte {
+	var picked [][]byte
+	for _, i := range indices {
+		picked = append(picked, decodeHex(t, list[i]))
+	}
+	return picked
+}
+
+func TestMuSig2KeyAgg(t *testing.T) {
+	valid := []struct {
+		keys     []int
+		expected string
+	}{
+		{
+			keys: []int{0, 1, 2},
+			expected: "90539EEDE565F5D054F32CC0C2201268" +
+				"89ED1E5D193BAF15AEF344FE59D4610C",
+		},
+		{
+			keys: []int{2, 1, 0},
+			expected: "6204DE8B083426DC6EAF9502D27024D5" +
+				"3FC826BF7D2012148A0575435DF54B2B",
+		},
+		{
+			keys: []int{0, 0, 0},
+			expected: "B436E3BAD62B8CD409969A224731C193" +
+				"D051162D8C5AE8B109306127DA3AA935",
+		},
+		{
+			keys: []int{0, 0, 1, 1},
+			expected: "69BC22BFA5D106306E48A20679DE1D73" +
+				"89386124D07571D0D872686028C26A3E",
+		},
+	}
+	for i, test := range valid {
+		keyAgg, err := newMuSig2KeyAgg(
+			pickHex(t, muSig2KeyAggKeys, test.keys),
+		)
+		if err != nil {
+			t.Fatalf("case %d: unable to aggregate: %v", i, err)
+		}
+
+		expected := decodeHex(t, test.expected)
+		if !bytes.Equal(scalarBytes(keyAgg.qx), expected) {
+			t.Fatalf("case %d: expected %x, got %x", i, expected,
+				scalarBytes(keyAgg.qx))
+		}
+	}
+
+	// An invalid key, one exceeding the field size, and one with a wrong
+	// prefix.
+	invalid := [][]int{{0, 3}, {0, 4}, {5, 0}}
+	for i, keys := range invalid {
+		_, err := newMuSig2KeyAgg(pickHex(t, muSig2KeyAggKeys, keys))
+		if err == nil {
+			t.Fatalf("case %d: invalid key accepted", i)
+		}
+	}
+}
+
+func TestMuSig2NonceGen(t *testing.T) {
+	const (
+		secKey = "02020202020202020202020202020202" +
+			"02020202020202020202020202020202"
+		pubKey = "024D4B6CD1361032CA9BD2AEB9D900AA" +
+			"4D45D9EAD80AC9423374C451A7254D07" +
+			"66"
+		aggKey = "07070707070707070707070707070707" +
+			"07070707070707070707070707070707"
+		extraIn = "08080808080808080808080808080808" +
+			"08080808080808080808080808080808"
+	)
+	rand := make([]byte, 32)
+
+	tests := []struct {
+		secKey, pubKey, aggKey string
+		msg, extraIn           *string
+		expected               string
+	}{
+		{
+			secKey: secKey,
+			pubKey: pubKey,
+			aggKey: aggKey,
+			msg: strPtr("01010101010101010101010101010101" +
+				"01010101010101010101010101010101"),
+			extraIn: strPtr(extraIn),
+			expected: "227243DCB40EF2A13A981DB188FA4337" +
+				"17B506BDFA14B1AE47D5DC027C9C3B9E" +
+				"F2370B2AD206E724243215137C863656" +
+				"99361126991E6FEC816845F837BDDAC3",
+		},
+		{
+			secKey:  secKey,
+			pubKey:  pubKey,
+			aggKey:  aggKey,
+			msg:     strPtr(""),
+			extraIn: strPtr(extraIn),
+			expected: "CD0F47FE471D6788FF3243F47345EA0A" +
+				"179AEF69476BE8348322EF39C2723318" +
+				"870C2065AFB52DEDF02BF4FDBF6D2F44" +
+				"2E608692F50C2374C08FFFE57042A61C",
+		},
+		{
+			secKey: secKey,
+			pubKey: pubKey,
+			aggKey: aggKey,
+			msg: strPtr("26262626262626262626262626262626" +
+				"26262626262626262626262626262626" +
+				"262626262626"),
+			extraIn: strPtr(extraIn),
+			expected: "011F8BC60EF061DEEF4D72A0A87200D9" +
+				"994B3F0CD9867910085C38D5366E3E6B" +
+				"9FF03BC0124E56B24069E91EC3F16237" +
+				"8983F194E8BD0ED89BE3059649EAE262",
+		},
+		{
+			pubKey: "02F9308A019258C31049344F85F89D52" +
+				"29B531C845836F99B08601F113BCE036" +
+				"F9",
+			expected: "890E83616A3BC4640AB9B6374F21C81F" +
+				"F89CDDDBAFAA7475AE2A102A92E3EDB2" +
+				"9FD7E874E23342813A60D96469482426" +
+				"46B7951CA046B4B36D7D6078506D3C94",
+		},
+	}
+	for i, test := range tests {
+		var secKey, msg, extraIn []byte
+		if test.secKey != "" {
+			secKey = decodeHex(t, test.secKey)
+		}
+		if test.msg != nil {
+			msg = decodeHex(t, *test.msg)
+		}
+		if test.extraIn != nil {
+			extraIn = decodeHex(t, *test.extraIn)
+		}
+		var aggKey []byte
+		if test.aggKey != "" {
+			aggKey = decodeHex(t, test.aggKey)
+		}
+
+		secNonce, err := muSig2NonceGen(
+			rand, secKey, decodeHex(t, test.pubKey), aggKey, msg,
+			extraIn,
+		)
+		if err != nil {
+			t.Fatalf("case %d: unable to generate nonce: %v", i,
+				err)
+		}
+
+		expected := decodeHex(t, test.expected)
+		if !bytes.Equal(secNonce, expected) {
+			t.Fatalf("case %d: expected %x, got %x", i, expected,
+				secNonce)
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestMuSig2Sign(t *testing.T) {
+	privKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), decodeHex(t, muSig2SignSecKey),
+	)
+	secNonce := decodeHex(t, muSig2SignSecNonce)
+	msg := decodeHex(t, muSig2SignMsg)
+
+	valid := []struct {
+		keys, nonces []int
+		aggNonce     int
+		signer       int
+		expected     string
+	}{
+		{
+			keys:     []int{0, 1, 2},
+			nonces:   []int{0, 1, 2},
+			aggNonce: 0,
+			signer:   0,
+			expected: "012ABBCB52B3016AC03AD82395A1A415" +
+				"C48B93DEF78718E62A7A90052FE224FB",
+		},
+		{
+			keys:     []int{1, 0, 2},
+			nonces:   []int{1, 0, 2},
+			aggNonce: 0,
+			signer:   1,
+			expected: "9FF2F7AAA856150CC8819254218D3ADE" +
+				"EB0535269051897724F9DB3789513A52",
+		},
+		{
+			keys:     []int{1, 2, 0},
+			nonces:   []int{1, 2, 0},
+			aggNonce: 0,
+			signer:   2,
+			expected: "FA23C359F6FAC4E7796BB93BC9F0532A" +
+				"95468C539BA20FF86D7C76ED92227900",
+		},
+
+		// Both halves of the aggregate nonce are infinite.
+		{
+			keys:     []int{0, 1},
+			nonces:   []int{0, 3},
+			aggNonce: 1,
+			signer:   0,
+			expected: "AE386064B26105404798F75DE2EB9AF5" +
+				"EDA5387B064B83D049CB7C5E08879531",
+		},
+	}
+	for i, test := range valid {
+		keyAgg, err := newMuSig2KeyAgg(
+			pickHex(t, muSig2SignKeys, test.keys),
+		)
+		if err != nil {
+			t.Fatalf("case %d: unable to aggregate: %v", i, err)
+		}
+
+		nonces := pickHex(t, muSig2SignNonces, test.nonces)
+		aggNonce, err := muSig2NonceAgg(nonces)
+		if err != nil {
+			t.Fatalf("case %d: unable to aggregate nonces: %v", i,
+				err)
+		}
+		expectedAggNonce := decodeHex(
+			t, muSig2SignAggNonces[test.aggNonce],
+		)
+		if !bytes.Equal(aggNonce, expectedAggNonce) {
+			t.Fatalf("case %d: expected aggregate nonce %x, got %x",
+				i, expectedAggNonce, aggNonce)
+		}
+
+		sig, err := keyAgg.partialSign(privKey, secNonce, aggNonce, msg)
+		if err != nil {
+			t.Fatalf("case %d: unable to sign: %v", i, err)
+		}
+		expected := decodeHex(t, test.expected)
+		if !bytes.Equal(sig, expected) {
+			t.Fatalf("case %d: expected %x, got %x", i, expected,
+				sig)
+		}
+
+		pubKey := keyAgg.pubKeys[test.signer]
+		nonce := nonces[test.signer]
+		if !keyAgg.partialVerify(sig, nonce, pubKey, aggNonce, msg) {
+			t.Fatalf("case %d: valid signature rejected", i)
+		}
+	}
+}
+
+func TestMuSig2SignErrors(t *testing.T) {
+	privKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), decodeHex(t, muSig2SignSecKey),
+	)
+	secNonce := decodeHex(t, muSig2SignSecNonce)
+	msg := decodeHex(t, muSig2SignMsg)
+
+	// The signer's key isn't part of the session.
+	keyAgg, err := newMuSig2KeyAgg(pickHex(t, muSig2SignKeys, []int{1, 2}))
+	if err != nil {
+		t.Fatalf("unable to aggregate: %v", err)
+	}
+	aggNonce := decodeHex(t, muSig2SignAggNonces[0])
+	_, err = keyAgg.partialSign(privKey, secNonce, aggNonce, msg)
+	if err == nil {
+		t.Fatalf("signed without being part of the session")
+	}
+
+	// Another signer's key is invalid.
+	_, err = newMuSig2KeyAgg(pickHex(t, muSig2SignKeys, []int{1, 0, 3}))
+	if err == nil {
+		t.Fatalf("invalid key accepted")
+	}
+
+	// The aggregate nonce has a wrong prefix, a half that isn't an x
+	// coordinate, or one exceeding the field size.
+	keyAgg, err = newMuSig2KeyAgg(
+		pickHex(t, muSig2SignKeys, []int{1, 2, 0}),
+	)
+	if err != nil {
+		t.Fatalf("unable to aggregate: %v", err)
+	}
+	for _, i := range []int{2, 3, 4} {
+		aggNonce := decodeHex(t, muSig2SignAggNonces[i])
+		_, err := keyAgg.partialSign(privKey, secNonce, aggNonce, msg)
+		if err == nil {
+			t.Fatalf("invalid aggregate nonce %d accepted", i)
+		}
+	}
+
+	// The secret nonce is zero, as it is once used.
+	_, err = keyAgg.partialSign(
+		privKey, make([]byte, 64), decodeHex(t, muSig2SignAggNonces[0]),
+		msg,
+	)
+	if err == nil {
+		t.Fatalf("zero secret nonce accepted")
+	}
+}
+
+func TestMuSig2PartialVerifyFails(t *testing.T) {
+	msg := decodeHex(t, muSig2SignMsg)
+
+	tests := []struct {
+		sig          string
+		keys, nonces []int
+		signer       int
+	}{
+		// The negation of a valid signature.
+		{
+			sig: "97AC833ADCB1AFA42EBF9E0725616F3C" +
+				"9A0D5B614F6FE283CEAAA37A8FFAF406",
+			keys:   []int{0, 1, 2},
+			nonces: []int{0, 1, 2},
+			signer: 0,
+		},
+
+		// A wrong signer.
+		{
+			sig: "68537CC5234E505BD14061F8DA9E90C2" +
+				"20A181855FD8BDB7F127BB12403B4D3B",
+			keys:   []int{0, 1, 2},
+			nonces: []int{0, 1, 2},
+			signer: 1,
+		},
+
+		// The signature exceeds the group size.
+		{
+			sig: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFE" +
+				"BAAEDCE6AF48A03BBFD25E8CD0364141",
+			keys:   []int{0, 1, 2},
+			nonces: []int{0, 1, 2},
+			signer: 0,
+		},
+
+		// An invalid public nonce.
+		{
+			sig: "68537CC5234E505BD14061F8DA9E90C2" +
+				"20A181855FD8BDB7F127BB12403B4D3B",
+			keys:   []int{0, 1, 2},
+			nonces: []int{4, 1, 2},
+			signer: 0,
+		},
+	}
+	for i, test := range tests {
+		keyAgg, err := newMuSig2KeyAgg(
+			pickHex(t, muSig2SignKeys, test.keys),
+		)
+		if err != nil {
+			t.Fatalf("case %d: unable to aggregate: %v", i, err)
+		}
+
+		nonces := pickHex(t, muSig2SignNonces, test.nonces)
+		aggNonce := decodeHex(t, muSig2SignAggNonces[0])
+		if keyAgg.partialVerify(decodeHex(t, test.sig),
+			nonces[test.signer], keyAgg.pubKeys[test.signer],
+			aggNonce, msg) {
+
+			t.Fatalf("case %d: invalid signature accepted", i)
+		}
+	}
+}
+
+// TestMuSig2TaprootSession runs a session between two signers for a taproot
+// output, verifying the partial signatures and the combined one.
+func TestMuSig2TaprootSession(t *testing.T) {
+	var privKeys []*btcec.PrivateKey
+	var pubKeys [][]byte
+	for i := 0; i < 2; i++ {
+		privKey, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Fatalf("unable to create key: %v", err)
+		}
+		privKeys = append(privKeys, privKey)
+		pubKeys = append(
+			pubKeys, privKey.PubKey().SerializeCompressed(),
+		)
+	}
+
+	keyAgg, err := newMuSig2KeyAgg(pubKeys)
+	if err != nil {
+		t.Fatalf("unable to aggregate: %v", err)
+	}
+	if err := keyAgg.applyTaprootTweak(); err != nil {
+		t.Fatalf("unable to tweak: %v", err)
+	}
+
+	var secNonces, nonces [][]byte
+	for i, privKey := range privKeys {
+		secNonce, pubNonce, err := muSig2Nonces(
+			privKey, pubKeys[i], scalarBytes(keyAgg.qx),
+		)
+		if err != nil {
+			t.Fatalf("unable to create nonces: %v", err)
+		}
+		secNonces = append(secNonces, secNonce)
+		nonces = append(nonces, pubNonce)
+	}
+	aggNonce, err := muSig2NonceAgg(nonces)
+	if err != nil {
+		t.Fatalf("unable to aggregate nonces: %v", err)
+	}
+
+	msg := bytes.Repeat([]byte{0x42}, 32)
+	var sigs [][]byte
+	for i, privKey := range privKeys {
+		sig, err := keyAgg.partialSign(
+			privKey, secNonces[i], aggNonce, msg,
+		)
+		if err != nil {
+			t.Fatalf("unable to sign: %v", err)
+		}
+		if !keyAgg.partialVerify(sig, nonces[i], pubKeys[i], aggNonce,
+			msg) {
+
+			t.Fatalf("partial signature %d rejected", i)
+		}
+		if keyAgg.partialVerify(sig, nonces[1-i], pubKeys[1-i],
+			aggNonce, msg) {
+
+			t.Fatalf("partial signature %d accepted for the "+
+				"other signer", i)
+		}
+		sigs = append(sigs, sig)
+	}
+
+	sig, err := keyAgg.aggregateSigs(sigs, aggNonce, msg)
+	if err != nil {
+		t.Fatalf("unable to aggregate signatures: %v", err)
+	}
+	if !schnorrVerify(scalarBytes(keyAgg.qx), msg, sig) {
+		t.Fatalf("combined signature rejected")
+	}
+}
+
+// TestMuSig2SessionExpiry checks that expired sessions are closed, wiping
+// their secret nonce.
+func TestMuSig2SessionExpiry(t *testing.T) {
+	secNonce := bytes.Repeat([]byte{1}, 64)
+	session := &muSig2Session{
+		created:  time.Now().Add(-muSig2SessionExpiry - time.Minute),
+		secNonce: secNonce,
+	}
+
+	muSig2Mtx.Lock()
+	muSig2Sessions["expired"] = session
+	_, err := lookupMuSig2Session("expired")
+	_, open := muSig2Sessions["expired"]
+	muSig2Mtx.Unlock()
+
+	if err != ErrUnknownMuSig2Session {
+		t.Fatalf("expected ErrUnknownMuSig2Session, got %v", err)
+	}
+	if open {
+		t.Fatalf("expired session still open")
+	}
+	if !bytes.Equal(secNonce, make([]byte, 64)) {
+		t.Fatalf("secret nonce of the expired session not wiped")
+	}
+}
@@ -0,0 +1,42 @@
+package lnd
+
+import (
+	"encoding/hex"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// NodeAliases returns the aliases the nodes announced, by their hex encoded
+// public keys, read from the graph alone. Nodes missing from the graph, or
+// that announced no alias, are left out.
+func NodeAliases(pubKeys []*btcec.PublicKey) (map[string]string, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	graph := d.chanDB.ChannelGraph()
+	aliases := make(map[string]string, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		node, err := graph.FetchLightningNode(pubKey)
+		switch {
+		case err == channeldb.ErrGraphNodeNotFound:
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if node.Alias != "" {
+			pubKeyHex := hex.EncodeToString(
+				pubKey.SerializeCompressed(),
+			)
+			aliases[pubKeyHex] = node.Alias
+		}
+	}
+
+	return aliases, nil
+}
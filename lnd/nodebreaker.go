@@ -0,0 +1,211 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// NodeFailures are the consecutive HTLC failures blamed on a node.
+type NodeFailures struct {
+	PubKey string `json:"pub_key"`
+
+	// ConsecutiveFailures is the number of HTLCs that failed at the node
+	// since one last went through it.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+
+	// LastFailure is the unix time of the last failure.
+	LastFailure int64 `json:"last_failure"`
+
+	// ExcludedUntil is the unix time until which the node is excluded
+	// from the routes of payments, or zero if it isn't.
+	ExcludedUntil int64 `json:"excluded_until"`
+}
+
+// nodeFailures are the failures of a node the breaker remembers.
+type nodeFailures struct {
+	count         int
+	last          time.Time
+	excludedUntil time.Time
+}
+
+// nodeBreaker counts the consecutive HTLC failures of each node. Once a node
+// fails threshold times in a row, it's excluded from the routes of payments
+// for cooldown, after which it's given another chance. Failures older than
+// cooldown are forgotten, so a node that fails now and then is never
+// excluded. A threshold of zero disables the breaker.
+type nodeBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mtx   sync.Mutex
+	nodes map[routing.Vertex]*nodeFailures
+}
+
+// newNodeBreaker returns a breaker with the threshold and cooldown.
+func newNodeBreaker(threshold int, cooldown time.Duration) *nodeBreaker {
+	return &nodeBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		nodes:     make(map[routing.Vertex]*nodeFailures),
+	}
+}
+
+// enabled returns true if the breaker excludes nodes.
+func (b *nodeBreaker) enabled() bool {
+	return b.threshold > 0
+}
+
+// recordAttempt records the outcome of an HTLC sent along the path of nodes,
+// the destination last. A success clears the failures of all the nodes of the
+// path. A failure is blamed on the node unable to forward it, or on the next
+// one if the node couldn't reach it. Failures of the destination are its
+// choice, and aren't blamed on it.
+func (b *nodeBreaker) recordAttempt(path []*btcec.PublicKey, err error) {
+	if !b.enabled() || len(path) == 0 {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if err == nil {
+		for _, node := range path {
+			delete(b.nodes, routing.NewVertex(node))
+		}
+		return
+	}
+
+	fErr, ok := err.(*htlcswitch.ForwardingError)
+	if !ok || fErr.ErrorSource == nil || fErr.FailureMessage == nil {
+		return
+	}
+
+	// The node returning the failure is this one if it isn't in the path.
+	source := -1
+	for i, node := range path {
+		if node.IsEqual(fErr.ErrorSource) {
+			source = i
+			break
+		}
+	}
+
+	index := source
+	switch {
+	case fErr.FailureMessage.Code() == lnwire.CodeUnknownNextPeer:
+		index = source + 1
+
+	// Other failures of this node's own switch, such as a lack of
+	// balance, aren't the fault of the path.
+	case source == -1:
+		return
+	}
+	if index >= len(path)-1 {
+		return
+	}
+
+	now := time.Now()
+	vertex := routing.NewVertex(path[index])
+	failures, ok := b.nodes[vertex]
+	if !ok || now.Sub(failures.last) >= b.cooldown {
+		failures = &nodeFailures{}
+		b.nodes[vertex] = failures
+	}
+	failures.count++
+	failures.last = now
+
+	if failures.count >= b.threshold && now.After(failures.excludedUntil) {
+		failures.excludedUntil = now.Add(b.cooldown)
+		ltndLog.Infof("Excluding node %x from payments for %v after "+
+			"%d consecutive failures", vertex[:], b.cooldown,
+			failures.count)
+	}
+}
+
+// excluded returns the nodes currently excluded from the routes of payments,
+// forgetting those whose failures are older than the cooldown.
+func (b *nodeBreaker) excluded() map[routing.Vertex]struct{} {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	excluded := make(map[routing.Vertex]struct{})
+	for vertex, failures := range b.nodes {
+		if now.Sub(failures.last) >= b.cooldown &&
+			now.After(failures.excludedUntil) {
+
+			delete(b.nodes, vertex)
+			continue
+		}
+		if now.Before(failures.excludedUntil) {
+			excluded[vertex] = struct{}{}
+		}
+	}
+
+	return excluded
+}
+
+// NodeBreakerFailures returns the nodes with consecutive HTLC failures, the
+// most failing first. It's empty if the node breaker is disabled.
+func NodeBreakerFailures() ([]*NodeFailures, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+	b := d.server.nodeBreaker
+
+	// Stale failures are forgotten first.
+	b.excluded()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	nodes := make([]*NodeFailures, 0, len(b.nodes))
+	for vertex, failures := range b.nodes {
+		node := &NodeFailures{
+			PubKey:              hex.EncodeToString(vertex[:]),
+			ConsecutiveFailures: failures.count,
+			LastFailure:         failures.last.Unix(),
+		}
+		if time.Now().Before(failures.excludedUntil) {
+			node.ExcludedUntil = failures.excludedUntil.Unix()
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		x, y := nodes[i], nodes[j]
+		if x.ConsecutiveFailures != y.ConsecutiveFailures {
+			return x.ConsecutiveFailures > y.ConsecutiveFailures
+		}
+		return x.PubKey < y.PubKey
+	})
+
+	return nodes, nil
+}
+
+// ResetNodeBreaker forgets the failures of all nodes, ending their exclusion.
+func ResetNodeBreaker() error {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return ErrDaemonNotRunning
+	}
+	b := d.server.nodeBreaker
+
+	b.mtx.Lock()
+	b.nodes = make(map[routing.Vertex]*nodeFailures)
+	b.mtx.Unlock()
+
+	return nil
+}
@@ -0,0 +1,526 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/zpay32"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+// outboxRetryInterval is how often queued payments are retried while peers
+// stay connected, besides whenever a peer connects.
+const outboxRetryInterval = time.Minute
+
+// The states of a queued payment, published with EventOutbox. Payments leave
+// the outbox once they reach any state but OutboxQueued and OutboxSending.
+const (
+	OutboxQueued    = "QUEUED"
+	OutboxSending   = "SENDING"
+	OutboxSucceeded = "SUCCEEDED"
+	OutboxFailed    = "FAILED"
+	OutboxExpired   = "EXPIRED"
+	OutboxCancelled = "CANCELLED"
+)
+
+var (
+	// ErrOutboxDisabled is returned when queueing a payment without the
+	// node running with outbox.
+	ErrOutboxDisabled = errors.New("queueing payments requires outbox")
+
+	// ErrPaymentQueued is returned when queueing a payment whose hash is
+	// already in the outbox.
+	ErrPaymentQueued = errors.New("a payment with this hash is already " +
+		"queued")
+
+	// ErrPaymentNotQueued is returned when cancelling a payment that isn't
+	// in the outbox.
+	ErrPaymentNotQueued = errors.New("payment not queued")
+
+	// ErrPaymentSending is returned when cancelling a queued payment that
+	// is being sent.
+	ErrPaymentSending = errors.New("queued payment is being sent")
+)
+
+// QueuedPayment is a payment in the outbox, waiting to be sent once the node
+// has peers to send it through.
+type QueuedPayment struct {
+	PaymentHash    string `json:"payment_hash"`
+	PaymentRequest string `json:"payment_request"`
+
+	// Amount is the amount in satoshis paid to a payment request without
+	// one.
+	Amount int64  `json:"amount,omitempty"`
+	State  string `json:"state"`
+
+	// Attempts is the number of times the payment was tried, and
+	// LastError why the last attempt failed.
+	Attempts   int    `json:"attempts"`
+	LastError  string `json:"last_error,omitempty"`
+	QueuedTime int64  `json:"queued_time"`
+
+	// PaymentPreimage is set once the payment succeeded.
+	PaymentPreimage string `json:"payment_preimage,omitempty"`
+}
+
+var (
+	// outboxBucketKey is the top level bucket of the wallet database
+	// mapping the hashes of queued payments to their JSON encoded
+	// QueuedPayment.
+	outboxBucketKey = []byte("lndmobile-outbox")
+
+	// outboxMtx guards the outbox bucket, and outboxSending.
+	outboxMtx sync.Mutex
+
+	// outboxSending holds the hashes of the queued payments being sent.
+	outboxSending = make(map[string]struct{})
+
+	// outboxKick wakes the outbox up to send a newly queued payment.
+	outboxKick = make(chan struct{}, 1)
+)
+
+// putQueuedPayment stores the queued payment, replacing any earlier one.
+func putQueuedPayment(db walletdb.DB, payment *QueuedPayment) error {
+	paymentJSON, err := json.Marshal(payment)
+	if err != nil {
+		return err
+	}
+
+	return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		bucket := tx.ReadWriteBucket(outboxBucketKey)
+		if bucket == nil {
+			var err error
+			bucket, err = tx.CreateTopLevelBucket(outboxBucketKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bucket.Put([]byte(payment.PaymentHash), paymentJSON)
+	})
+}
+
+// deleteQueuedPayment removes the payment with the hex encoded hash from the
+// outbox.
+func deleteQueuedPayment(db walletdb.DB, paymentHash string) error {
+	return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		bucket := tx.ReadWriteBucket(outboxBucketKey)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(paymentHash))
+	})
+}
+
+// fetchQueuedPayment returns the payment with the hex encoded hash from the
+// outbox, or nil if it isn't queued.
+func fetchQueuedPayment(db walletdb.DB, paymentHash string) (*QueuedPayment,
+	error) {
+
+	var payment *QueuedPayment
+	err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket(outboxBucketKey)
+		if bucket == nil {
+			return nil
+		}
+
+		v := bucket.Get([]byte(paymentHash))
+		if v == nil {
+			return nil
+		}
+		payment = &QueuedPayment{}
+		return json.Unmarshal(v, payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// fetchQueuedPayments returns all payments in the outbox.
+func fetchQueuedPayments(db walletdb.DB) ([]*QueuedPayment, error) {
+	var payments []*QueuedPayment
+	err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket(outboxBucketKey)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, v []byte) error {
+			payment := &QueuedPayment{}
+			if err := json.Unmarshal(v, payment); err != nil {
+				return err
+			}
+			payments = append(payments, payment)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+// QueuePayment adds the payment request to the outbox, to be paid through
+// SendPaymentV2 as soon as the node has a peer connected, and retried
+// whenever another connects for as long as it fails for want of a route,
+// until the request expires. amt is the amount paid to requests without one.
+// The state of the payment is published with EventOutbox as it changes.
+func QueuePayment(payReqString string, amt btcutil.Amount) (*QueuedPayment,
+	error) {
+
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Outbox {
+		return nil, ErrOutboxDisabled
+	}
+
+	payReq, err := zpay32.Decode(payReqString, activeNetParams.Params)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePayReqExpiry(payReq); err != nil {
+		return nil, err
+	}
+	if payReq.MilliSat == nil && amt <= 0 {
+		return nil, errors.New("amount must be set for payment " +
+			"requests without one")
+	}
+	if payReq.MilliSat != nil {
+		amt = 0
+	}
+
+	payment := &QueuedPayment{
+		PaymentHash:    hex.EncodeToString(payReq.PaymentHash[:]),
+		PaymentRequest: payReqString,
+		Amount:         int64(amt),
+		State:          OutboxQueued,
+		QueuedTime:     time.Now().Unix(),
+	}
+
+	outboxMtx.Lock()
+	defer outboxMtx.Unlock()
+
+	queued, err := fetchQueuedPayment(w.Database(), payment.PaymentHash)
+	if err != nil {
+		return nil, err
+	}
+	if queued != nil {
+		return nil, ErrPaymentQueued
+	}
+
+	if err := putQueuedPayment(w.Database(), payment); err != nil {
+		return nil, err
+	}
+	publishEvent(EventOutbox, payment)
+
+	select {
+	case outboxKick <- struct{}{}:
+	default:
+	}
+
+	return payment, nil
+}
+
+// ListQueuedPayments returns the payments waiting in the outbox.
+func ListQueuedPayments() ([]*QueuedPayment, error) {
+	w, err := runningWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	outboxMtx.Lock()
+	defer outboxMtx.Unlock()
+
+	return fetchQueuedPayments(w.Database())
+}
+
+// CancelQueuedPayment removes the payment with the hash from the outbox,
+// unless it's being sent.
+func CancelQueuedPayment(paymentHash [32]byte) error {
+	w, err := runningWallet()
+	if err != nil {
+		return err
+	}
+	hash := hex.EncodeToString(paymentHash[:])
+
+	outboxMtx.Lock()
+	defer outboxMtx.Unlock()
+
+	if _, ok := outboxSending[hash]; ok {
+		return ErrPaymentSending
+	}
+
+	payment, err := fetchQueuedPayment(w.Database(), hash)
+	if err != nil {
+		return err
+	}
+	if payment == nil {
+		return ErrPaymentNotQueued
+	}
+
+	if err := deleteQueuedPayment(w.Database(), hash); err != nil {
+		return err
+	}
+	payment.State = OutboxCancelled
+	publishEvent(EventOutbox, payment)
+
+	return nil
+}
+
+// earlierOutcome returns the status a queued payment reached when sent by an
+// earlier attempt, such as one cut short by the app being killed, or nil if it
+// can be sent again.
+func earlierOutcome(d *daemon, db walletdb.DB,
+	payment *QueuedPayment) (*PaymentStatus, error) {
+
+	var paymentHash [32]byte
+	hash, err := hex.DecodeString(payment.PaymentHash)
+	if err != nil {
+		return nil, err
+	}
+	copy(paymentHash[:], hash)
+
+	paymentsMtx.Lock()
+	_, inFlight := inFlightPayments[paymentHash]
+	paymentsMtx.Unlock()
+	if inFlight {
+		return &PaymentStatus{State: PaymentInFlight}, nil
+	}
+
+	statuses, err := fetchPaymentStatuses(db, &paymentHash)
+	if err != nil || len(statuses) == 0 {
+		return nil, err
+	}
+
+	status := statuses[0]
+	if status.State == PaymentInFlight {
+		if err := resolveStoredPayment(d.chanDB, status); err != nil {
+			return nil, err
+		}
+	}
+	if status.State == PaymentFailed {
+		return nil, nil
+	}
+
+	return status, nil
+}
+
+// errOutboxStopped is returned by sendOutboxPayment if the daemon stops while
+// the payment is being sent.
+var errOutboxStopped = errors.New("outbox stopped")
+
+// sendOutboxPayment sends the payment through SendPaymentV2, returning
+// errOutboxStopped as soon as the daemon stops rather than waiting for the
+// payment to resolve. It's then left SENDING in the outbox, and its outcome
+// is found from its stored status once the node restarts.
+func sendOutboxPayment(d *daemon,
+	req *lnrpc.SendRequest) (*PaymentStatus, error) {
+
+	type result struct {
+		status *PaymentStatus
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		defer RecoverPanic("LTND")
+
+		status, err := SendPaymentV2(
+			req, 0, nil, func(*PaymentStatus) {},
+		)
+		results <- result{status, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.status, r.err
+	case <-d.quit:
+		return nil, errOutboxStopped
+	}
+}
+
+// sendQueuedPayment tries the queued payment once, removing it from the outbox
+// unless it's to be retried.
+func sendQueuedPayment(d *daemon, db walletdb.DB,
+	payment *QueuedPayment) error {
+
+	// A payment sent before, but not known to have failed, mustn't be
+	// sent again.
+	earlier, err := earlierOutcome(d, db, payment)
+	if err != nil {
+		return err
+	}
+	if earlier != nil && earlier.State == PaymentInFlight {
+		return nil
+	}
+
+	payReq, err := zpay32.Decode(payment.PaymentRequest,
+		activeNetParams.Params)
+	if err != nil {
+		return err
+	}
+
+	var (
+		status  *PaymentStatus
+		sendErr error
+	)
+	switch {
+	case earlier != nil:
+		status = earlier
+
+	case validatePayReqExpiry(payReq) != nil:
+		payment.State = OutboxExpired
+
+	default:
+		// The payment may have been cancelled since it was fetched.
+		outboxMtx.Lock()
+		queued, err := fetchQueuedPayment(db, payment.PaymentHash)
+		if err == nil && queued != nil {
+			outboxSending[payment.PaymentHash] = struct{}{}
+			payment.State = OutboxSending
+			payment.Attempts++
+			err = putQueuedPayment(db, payment)
+		}
+		outboxMtx.Unlock()
+		if err != nil || queued == nil {
+			return err
+		}
+		publishEvent(EventOutbox, payment)
+
+		req := &lnrpc.SendRequest{
+			PaymentRequest: payment.PaymentRequest,
+			Amt:            payment.Amount,
+		}
+		status, sendErr = sendOutboxPayment(d, req)
+		if sendErr == errOutboxStopped {
+			outboxMtx.Lock()
+			delete(outboxSending, payment.PaymentHash)
+			outboxMtx.Unlock()
+			return nil
+		}
+	}
+
+	switch {
+	// The payment is being paid already, or the node stopped, so it's
+	// left for later.
+	case sendErr == ErrPaymentInFlight, sendErr == ErrDaemonNotRunning:
+		payment.State = OutboxQueued
+
+	case sendErr != nil:
+		payment.State = OutboxFailed
+		payment.LastError = sendErr.Error()
+
+	// The payment request expired.
+	case status == nil:
+
+	case status.State == PaymentSucceeded:
+		payment.State = OutboxSucceeded
+		payment.PaymentPreimage = status.PaymentPreimage
+
+	// Only payments that found no way to the destination are retried.
+	case status.FailureDetail != nil &&
+		(status.FailureDetail.Reason == FailureReasonNoRoute ||
+			status.FailureDetail.Reason == FailureReasonTimeout):
+
+		payment.State = OutboxQueued
+		payment.LastError = status.FailureReason
+
+	default:
+		payment.State = OutboxFailed
+		payment.LastError = status.FailureReason
+	}
+
+	outboxMtx.Lock()
+	defer outboxMtx.Unlock()
+
+	delete(outboxSending, payment.PaymentHash)
+	if payment.State == OutboxQueued {
+		err = putQueuedPayment(db, payment)
+	} else {
+		err = deleteQueuedPayment(db, payment.PaymentHash)
+	}
+	if err != nil {
+		return err
+	}
+	publishEvent(EventOutbox, payment)
+
+	return nil
+}
+
+// sendQueuedPayments tries each payment in the outbox in turn, provided a
+// peer is connected to send them through.
+func (d *daemon) sendQueuedPayments() error {
+	if len(d.server.Peers()) == 0 {
+		return nil
+	}
+
+	db := d.internalWallet().Database()
+
+	outboxMtx.Lock()
+	payments, err := fetchQueuedPayments(db)
+	outboxMtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, payment := range payments {
+		select {
+		case <-d.quit:
+			return nil
+		default:
+		}
+
+		if err := sendQueuedPayment(d, db, payment); err != nil {
+			return fmt.Errorf("unable to send queued payment "+
+				"%v: %v", payment.PaymentHash, err)
+		}
+	}
+
+	return nil
+}
+
+// runOutbox sends the queued payments once the server started, again whenever
+// a peer connects or a payment is queued, and every outboxRetryInterval, until
+// the daemon is stopped.
+func (d *daemon) runOutbox() {
+	defer d.wg.Done()
+	defer RecoverPanic("LTND")
+
+	client := SubscribeEvents()
+	defer client.Cancel()
+
+	ticker := time.NewTicker(outboxRetryInterval)
+	defer ticker.Stop()
+
+	send := true
+	for {
+		if send {
+			err := d.sendQueuedPayments()
+			if err != nil {
+				ltndLog.Errorf("Unable to send queued "+
+					"payments: %v", err)
+			}
+		}
+
+		send = true
+		select {
+		case event := <-client.Events:
+			send = event.Type == EventPeerConnected
+		case <-outboxKick:
+		case <-ticker.C:
+		case <-d.quit:
+			return
+		}
+	}
+}
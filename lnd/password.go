@@ -0,0 +1,105 @@
+package lnd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// The stages of ChangePassword, in the order they're reported.
+const (
+	// PasswordStageWallet re-encrypts the private keys of the wallet.
+	PasswordStageWallet = "wallet"
+
+	// PasswordStageMacaroons re-encrypts the root keys of the macaroon
+	// database, if there is one.
+	PasswordStageMacaroons = "macaroons"
+
+	// PasswordStageRestart restarts the daemon with the new password.
+	PasswordStageRestart = "restart"
+)
+
+// ErrEmptyPassword is returned by ChangePassword if the new password is
+// empty.
+var ErrEmptyPassword = errors.New("the new password must not be empty")
+
+// ErrPasswordChanged is returned by ChangePassword if the password was
+// changed, but the daemon couldn't be restarted with it. The daemon is left
+// stopped, and has to be started with the new password.
+type ErrPasswordChanged struct {
+	Err error
+}
+
+func (e *ErrPasswordChanged) Error() string {
+	return fmt.Sprintf("password changed, but unable to restart the "+
+		"daemon: %v", e.Err)
+}
+
+// ChangePassword changes the password the wallet and the macaroon database
+// are encrypted with. If oldPw is empty, the default password is assumed. As
+// the macaroon database can't be re-keyed while it is open, the daemon is
+// restarted with the new password in the process, and no other daemon can be
+// started or stopped in between. Progress is reported as the number of items
+// completed out of the total within each stage, while the daemon is locked,
+// so progress must not call back into it.
+//
+// Should re-keying the macaroon database fail after the wallet's password was
+// changed, the macaroons are removed so they're generated anew on restart,
+// rather than leaving a database that can't be unlocked.
+func ChangePassword(oldPw, newPw []byte,
+	progress func(stage string, completed, total int64)) error {
+
+	if len(newPw) == 0 {
+		return ErrEmptyPassword
+	}
+	if len(oldPw) == 0 {
+		oldPw = defaultWalletPw
+	}
+
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	d := activeDaemon
+	if d == nil {
+		return ErrDaemonNotRunning
+	}
+
+	progress(PasswordStageWallet, 0, 1)
+	err := d.internalWallet().ChangePrivatePassphrase(oldPw, newPw)
+	if err != nil {
+		return err
+	}
+	progress(PasswordStageWallet, 1, 1)
+
+	if err := stopDaemon(); err != nil {
+		return &ErrPasswordChanged{Err: err}
+	}
+
+	// The macaroon database is re-keyed even while macaroons are
+	// disabled, so a later run with them enabled can unlock it.
+	dbPath := filepath.Join(macaroonDatabaseDir, macaroonDBFilename)
+	if fileExists(dbPath) {
+		err := rekeyMacaroons(oldPw, newPw, func(completed,
+			total int64) {
+
+			progress(PasswordStageMacaroons, completed, total)
+		})
+		if err != nil {
+			ltndLog.Errorf("Unable to re-key macaroon database, "+
+				"removing macaroons: %v", err)
+
+			if err := removeMacaroons(); err != nil {
+				return &ErrPasswordChanged{Err: err}
+			}
+		}
+	}
+
+	progress(PasswordStageRestart, 0, 1)
+	err = startDaemon(d.seed, newPw, d.dataDir, d.args)
+	if err != nil {
+		return &ErrPasswordChanged{Err: err}
+	}
+	progress(PasswordStageRestart, 1, 1)
+
+	return nil
+}
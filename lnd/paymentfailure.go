@@ -0,0 +1,204 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// The reasons a payment failed for.
+const (
+	// FailureReasonNoRoute is a payment for which no route could be found,
+	// or all routes tried failed along the way.
+	FailureReasonNoRoute = "NO_ROUTE"
+
+	// FailureReasonInsufficientBalance is a payment exceeding what the
+	// channels of this node can send.
+	FailureReasonInsufficientBalance = "INSUFFICIENT_BALANCE"
+
+	// FailureReasonIncorrectPaymentDetails is a payment the destination
+	// rejected, for an unknown payment hash, a wrong amount, or a time
+	// lock it doesn't accept.
+	FailureReasonIncorrectPaymentDetails = "INCORRECT_PAYMENT_DETAILS"
+
+	// FailureReasonTimeout is a payment whose routes weren't all tried
+	// before its timeout.
+	FailureReasonTimeout = "TIMEOUT"
+
+	// FailureReasonError is a payment that failed for any other error.
+	FailureReasonError = "ERROR"
+)
+
+// PaymentFailure is why a payment failed.
+type PaymentFailure struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+
+	// FailureCode is the wire failure code of the last HTLC attempt, such
+	// as TemporaryChannelFailure, and FailureSource the node returning it.
+	// FailureSourceIndex is the position of that node along the route,
+	// this node being 0 and the destination the number of hops. ChanID is
+	// the channel the node couldn't forward over, if its failure told.
+	FailureCode        string `json:"failure_code,omitempty"`
+	FailureSource      string `json:"failure_source,omitempty"`
+	FailureSourceIndex int    `json:"failure_source_index"`
+	ChanID             uint64 `json:"chan_id,omitempty"`
+
+	// code is the failure code of the last HTLC attempt, or CodeNone if
+	// it failed without one.
+	code lnwire.FailCode
+}
+
+// paymentTimeoutError is returned by sendRestrictedPayment once its timeout
+// passed, as the router does.
+type paymentTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *paymentTimeoutError) Error() string {
+	return fmt.Sprintf("payment attempt not completed before timeout of "+
+		"%v", e.timeout)
+}
+
+// failureChannelUpdate returns the channel update the failure carries, if
+// any.
+func failureChannelUpdate(msg lnwire.FailureMessage) *lnwire.ChannelUpdate {
+	switch m := msg.(type) {
+	case *lnwire.FailTemporaryChannelFailure:
+		return m.Update
+	case *lnwire.FailAmountBelowMinimum:
+		return &m.Update
+	case *lnwire.FailFeeInsufficient:
+		return &m.Update
+	case *lnwire.FailIncorrectCltvExpiry:
+		return &m.Update
+	case *lnwire.FailExpiryTooSoon:
+		return &m.Update
+	case *lnwire.FailChannelDisabled:
+		return &m.Update
+	}
+
+	return nil
+}
+
+// htlcFailure returns the failure of an HTLC sent along the path of nodes, the
+// destination last, leaving its reason to classifyPaymentFailure.
+func htlcFailure(err error, path []*btcec.PublicKey) *PaymentFailure {
+	failure := &PaymentFailure{Message: err.Error()}
+
+	fErr, ok := err.(*htlcswitch.ForwardingError)
+	if !ok || fErr.FailureMessage == nil {
+		return failure
+	}
+	failure.code = fErr.FailureMessage.Code()
+	failure.FailureCode = failure.code.String()
+
+	if update := failureChannelUpdate(fErr.FailureMessage); update != nil {
+		failure.ChanID = update.ShortChannelID.ToUint64()
+	}
+
+	// Failures of the switch of this node come from its own key, which
+	// isn't in the path.
+	if fErr.ErrorSource != nil {
+		failure.FailureSource = hex.EncodeToString(
+			fErr.ErrorSource.SerializeCompressed(),
+		)
+		for i, node := range path {
+			if node.IsEqual(fErr.ErrorSource) {
+				failure.FailureSourceIndex = i + 1
+				break
+			}
+		}
+	}
+
+	return failure
+}
+
+// localBalance returns the sum of the local balances of the open channels.
+func localBalance(chanDB *channeldb.DB) (lnwire.MilliSatoshi, error) {
+	channels, err := chanDB.FetchAllChannels()
+	if err != nil {
+		return 0, err
+	}
+
+	var balance lnwire.MilliSatoshi
+	for _, channel := range channels {
+		if !channel.IsPending {
+			balance += channel.LocalCommitment.LocalBalance
+		}
+	}
+
+	return balance, nil
+}
+
+// classifyPaymentFailure returns why the payment of amt to the destination
+// failed with sendErr, given the failure of its last HTLC attempt, if any
+// was made.
+func classifyPaymentFailure(d *daemon, sendErr error, last *PaymentFailure,
+	dest *btcec.PublicKey, amt lnwire.MilliSatoshi) *PaymentFailure {
+
+	failure := &PaymentFailure{}
+	if last != nil {
+		*failure = *last
+	}
+	failure.Reason = FailureReasonError
+	failure.Message = sendErr.Error()
+
+	_, restrictedTimeout := sendErr.(*paymentTimeoutError)
+	destHex := hex.EncodeToString(dest.SerializeCompressed())
+	selfHex := hex.EncodeToString(
+		d.server.identityPriv.PubKey().SerializeCompressed(),
+	)
+
+	switch {
+	case routing.IsError(sendErr, routing.ErrPaymentAttemptTimeout) ||
+		restrictedTimeout:
+
+		failure.Reason = FailureReasonTimeout
+
+	case failure.FailureSource == destHex:
+		switch failure.code {
+		case lnwire.CodeUnknownPaymentHash,
+			lnwire.CodeIncorrectPaymentAmount,
+			lnwire.CodeFinalIncorrectCltvExpiry,
+			lnwire.CodeFinalIncorrectHtlcAmount,
+			lnwire.CodeFinalExpiryTooSoon:
+
+			failure.Reason = FailureReasonIncorrectPaymentDetails
+		}
+
+	// The switch of this node fails HTLCs its links lack the bandwidth
+	// for.
+	case failure.FailureSource == selfHex &&
+		failure.code == lnwire.CodeTemporaryChannelFailure:
+
+		failure.Reason = FailureReasonInsufficientBalance
+
+	// An HTLC failed along the way leaves no route, as does the router
+	// once it runs out of routes, wrapping the failure of the last one
+	// tried. Malformed onions are errors of this node though.
+	case failure.code != lnwire.CodeNone &&
+		failure.code&lnwire.FlagBadOnion == 0,
+		sendErr == ErrNoPath,
+		routing.IsError(sendErr, routing.ErrNoPathFound,
+			routing.ErrNoRouteFound,
+			routing.ErrInsufficientCapacity,
+			routing.ErrMaxHopsExceeded,
+			routing.ErrTargetNotInNetwork):
+
+		failure.Reason = FailureReasonNoRoute
+
+		balance, err := localBalance(d.chanDB)
+		if err == nil && balance < amt {
+			failure.Reason = FailureReasonInsufficientBalance
+		}
+	}
+
+	return failure
+}
@@ -0,0 +1,493 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+// The states of a payment sent through SendPaymentV2, and of each of its HTLC
+// attempts.
+const (
+	PaymentInFlight  = "IN_FLIGHT"
+	PaymentSucceeded = "SUCCEEDED"
+	PaymentFailed    = "FAILED"
+)
+
+// ErrPaymentInFlight is returned when sending a payment whose hash is already
+// being paid.
+var ErrPaymentInFlight = errors.New("a payment with this hash is already " +
+	"in flight")
+
+// ErrPaymentSucceeded is returned when sending a payment whose hash was
+// already paid through SendPaymentV2.
+var ErrPaymentSucceeded = errors.New("a payment with this hash already " +
+	"succeeded")
+
+// HTLCAttempt is an HTLC sent by the router to pay a payment, over one route.
+type HTLCAttempt struct {
+	AttemptID int    `json:"attempt_id"`
+	State     string `json:"state"`
+
+	// Path holds the public keys of the nodes the HTLC is routed through,
+	// the destination last.
+	Path []string `json:"path"`
+
+	// AmountMsat is the amount of the HTLC, fees included, and TimeLock
+	// its absolute time lock.
+	AmountMsat int64  `json:"amount_msat"`
+	TimeLock   uint32 `json:"time_lock"`
+
+	AttemptTime int64 `json:"attempt_time"`
+	ResolveTime int64 `json:"resolve_time,omitempty"`
+
+	// Failure is the failure code returned for a failed HTLC, such as
+	// TemporaryChannelFailure, or the error if the HTLC failed without
+	// one. FailureSource is the node that returned the failure code.
+	Failure       string `json:"failure,omitempty"`
+	FailureSource string `json:"failure_source,omitempty"`
+}
+
+// PaymentStatus is the state of a payment sent through SendPaymentV2, along
+// with all HTLC attempts made so far.
+type PaymentStatus struct {
+	PaymentHash string `json:"payment_hash"`
+	State       string `json:"state"`
+	AmountMsat  int64  `json:"amount_msat"`
+
+	// PaymentPreimage, Route and FeeMsat are set once the payment
+	// succeeded, and FailureReason and FailureDetail once it failed.
+	PaymentPreimage string          `json:"payment_preimage,omitempty"`
+	Route           *lnrpc.Route    `json:"route,omitempty"`
+	FeeMsat         int64           `json:"fee_msat"`
+	FailureReason   string          `json:"failure_reason,omitempty"`
+	FailureDetail   *PaymentFailure `json:"failure_detail,omitempty"`
+
+	Htlcs []*HTLCAttempt `json:"htlcs"`
+}
+
+// trackedPayment is a payment in flight, whose HTLC attempts are recorded as
+// the router makes them.
+type trackedPayment struct {
+	status PaymentStatus
+
+	// subscribers are called with every update of the payment, by
+	// subscription id. The one of the sender is always 0.
+	subscribers map[uint64]func(*PaymentStatus)
+	nextID      uint64
+
+	// lastFailure is the failure of the last HTLC attempt that failed.
+	lastFailure *PaymentFailure
+
+	// done is closed once the payment succeeded or failed.
+	done chan struct{}
+
+	// version counts the updates of the payment. delivered is the last
+	// one handed to the subscribers, and is guarded by deliverMtx rather
+	// than paymentsMtx.
+	version    uint64
+	deliverMtx sync.Mutex
+	delivered  uint64
+}
+
+// paymentUpdate is a snapshot of a payment, to be handed to its subscribers
+// once paymentsMtx is released, so they are free to call back into the
+// payments.
+type paymentUpdate struct {
+	p           *trackedPayment
+	version     uint64
+	status      *PaymentStatus
+	subscribers []func(*PaymentStatus)
+}
+
+// notify records an update of the payment, and returns it for its current
+// subscribers. The caller must hold paymentsMtx, and deliver the update once
+// it released it.
+func (p *trackedPayment) notify() *paymentUpdate {
+	p.version++
+	u := &paymentUpdate{
+		p:       p,
+		version: p.version,
+		status:  p.snapshot(),
+	}
+	for _, update := range p.subscribers {
+		u.subscribers = append(u.subscribers, update)
+	}
+
+	return u
+}
+
+// deliver hands the update to its subscribers, one update at a time. An update
+// is dropped if a later one was delivered already, as it holds all an earlier
+// one does. paymentsMtx must not be held.
+func (u *paymentUpdate) deliver() {
+	u.p.deliverMtx.Lock()
+	defer u.p.deliverMtx.Unlock()
+
+	if u.version < u.p.delivered {
+		return
+	}
+	u.p.delivered = u.version
+
+	for _, update := range u.subscribers {
+		update(u.status)
+	}
+}
+
+// snapshot returns a copy of the status of the payment that later attempts
+// leave untouched. The caller must hold paymentsMtx.
+func (p *trackedPayment) snapshot() *PaymentStatus {
+	status := p.status
+	status.Htlcs = make([]*HTLCAttempt, len(p.status.Htlcs))
+	for i, htlc := range p.status.Htlcs {
+		attempt := *htlc
+		status.Htlcs[i] = &attempt
+	}
+
+	return &status
+}
+
+var (
+	// paymentsBucketKey is the top level bucket of the wallet database
+	// mapping the hashes of payments sent through SendPaymentV2 to their
+	// JSON encoded status.
+	paymentsBucketKey = []byte("lndmobile-payments")
+
+	// paymentsMtx guards inFlightPayments, and the payments within.
+	paymentsMtx sync.Mutex
+
+	// inFlightPayments are the payments sent through SendPaymentV2 that
+	// haven't succeeded or failed yet, by payment hash.
+	inFlightPayments = make(map[[32]byte]*trackedPayment)
+)
+
+// checkResend returns why a payment to the hash mustn't be sent, if it is
+// being paid, or an earlier send stored it as in flight or succeeded. Paying a
+// hash again would only hand the preimage to whoever holds it, while the HTLC
+// of a payment left in flight by an earlier run may still be outstanding, as
+// the switch only refuses duplicate circuits, not duplicate hashes. It must be
+// called with paymentsMtx held.
+func checkResend(chanDB *channeldb.DB, db walletdb.DB,
+	paymentHash [32]byte) error {
+
+	if _, ok := inFlightPayments[paymentHash]; ok {
+		return ErrPaymentInFlight
+	}
+
+	stored, err := fetchPaymentStatuses(db, &paymentHash)
+	if err != nil || len(stored) == 0 {
+		return err
+	}
+
+	status := stored[0]
+	if status.State == PaymentInFlight {
+		if err := resolveStoredPayment(chanDB, status); err != nil {
+			return err
+		}
+
+		// Keep the outcome, so it's known without resolving it
+		// again.
+		if status.State != PaymentInFlight {
+			if err := putPaymentStatus(db, status); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch status.State {
+	case PaymentInFlight:
+		return ErrPaymentInFlight
+	case PaymentSucceeded:
+		return ErrPaymentSucceeded
+	}
+
+	return nil
+}
+
+// putPaymentStatus stores the status of the payment, replacing any earlier
+// one.
+func putPaymentStatus(db walletdb.DB, status *PaymentStatus) error {
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		bucket := tx.ReadWriteBucket(paymentsBucketKey)
+		if bucket == nil {
+			var err error
+			bucket, err = tx.CreateTopLevelBucket(paymentsBucketKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		return bucket.Put([]byte(status.PaymentHash), statusJSON)
+	})
+}
+
+// fetchPaymentStatuses returns the stored status of the payment with the hash,
+// or of all payments if paymentHash is nil.
+func fetchPaymentStatuses(db walletdb.DB,
+	paymentHash *[32]byte) ([]*PaymentStatus, error) {
+
+	var statuses []*PaymentStatus
+	err := walletdb.View(db, func(tx walletdb.ReadTx) error {
+		bucket := tx.ReadBucket(paymentsBucketKey)
+		if bucket == nil {
+			return nil
+		}
+
+		decode := func(_, v []byte) error {
+			status := &PaymentStatus{}
+			if err := json.Unmarshal(v, status); err != nil {
+				return err
+			}
+			statuses = append(statuses, status)
+			return nil
+		}
+
+		if paymentHash == nil {
+			return bucket.ForEach(decode)
+		}
+
+		key := []byte(hex.EncodeToString(paymentHash[:]))
+		if v := bucket.Get(key); v != nil {
+			return decode(key, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// attemptFailure returns the failure code of a failed HTLC, and the node that
+// returned it, if any.
+func attemptFailure(err error) (string, string) {
+	fErr, ok := err.(*htlcswitch.ForwardingError)
+	if !ok || fErr.FailureMessage == nil {
+		return err.Error(), ""
+	}
+
+	var source string
+	if fErr.ErrorSource != nil {
+		source = hex.EncodeToString(
+			fErr.ErrorSource.SerializeCompressed(),
+		)
+	}
+
+	return fErr.FailureMessage.Code().String(), source
+}
+
+// sendToSwitch sends an HTLC of the router through the switch, recording the
+// attempt if it pays a payment sent through SendPaymentV2.
+func (s *server) sendToSwitch(firstHopPub [33]byte,
+	htlcAdd *lnwire.UpdateAddHTLC,
+	circuit *sphinx.Circuit) ([32]byte, error) {
+
+	paymentHash := htlcAdd.PaymentHash
+
+	paymentsMtx.Lock()
+	p, tracked := inFlightPayments[paymentHash]
+	var attempt *HTLCAttempt
+	if tracked {
+		attempt = &HTLCAttempt{
+			AttemptID:   len(p.status.Htlcs),
+			State:       PaymentInFlight,
+			Path:        make([]string, len(circuit.PaymentPath)),
+			AmountMsat:  int64(htlcAdd.Amount),
+			TimeLock:    htlcAdd.Expiry,
+			AttemptTime: time.Now().Unix(),
+		}
+		for i, pub := range circuit.PaymentPath {
+			attempt.Path[i] = hex.EncodeToString(
+				pub.SerializeCompressed(),
+			)
+		}
+		p.status.Htlcs = append(p.status.Htlcs, attempt)
+		u := p.notify()
+		paymentsMtx.Unlock()
+		u.deliver()
+	} else {
+		paymentsMtx.Unlock()
+	}
+
+	// Using the created circuit, initialize the error decrypter so we can
+	// parse+decode any failures incurred by this payment within the
+	// switch.
+	errorDecryptor := &htlcswitch.SphinxErrorDecrypter{
+		OnionErrorDecrypter: sphinx.NewOnionErrorDecrypter(circuit),
+	}
+
+	preimage, err := s.htlcSwitch.SendHTLC(
+		firstHopPub, htlcAdd, errorDecryptor,
+	)
+	s.nodeBreaker.recordAttempt(circuit.PaymentPath, err)
+
+	if tracked {
+		paymentsMtx.Lock()
+		attempt.ResolveTime = time.Now().Unix()
+		if err == nil {
+			attempt.State = PaymentSucceeded
+		} else {
+			attempt.State = PaymentFailed
+			attempt.Failure, attempt.FailureSource = attemptFailure(
+				err,
+			)
+			p.lastFailure = htlcFailure(err, circuit.PaymentPath)
+		}
+		u := p.notify()
+		paymentsMtx.Unlock()
+		u.deliver()
+	}
+
+	return preimage, err
+}
+
+// SendPaymentV2 sends the payment described by the request through the router,
+// as SendPaymentSync does, calling update with the state of the payment once
+// it starts, as each HTLC attempt starts and resolves, and once it succeeded
+// or failed, which is also returned. The router tries routes for up to
+// timeout, or its default if zero, and why the payment failed, if it did, is
+// given by its FailureDetail. Payments with restrictions, or while the node
+// breaker excludes nodes, are instead sent over routes found here, as the
+// router can't keep to them, and the switch may still pay out through any
+// channel with the peer of an outgoing channel. A hash that was paid already
+// is refused with ErrPaymentSucceeded, and one still being paid, including by
+// an earlier run of the node whose HTLC may be outstanding, with
+// ErrPaymentInFlight. update is called one at a time, outside of any lock of
+// the payments, and must not block for long, as the payment waits on it.
+func SendPaymentV2(req *lnrpc.SendRequest, timeout time.Duration,
+	restrictions *PaymentRestrictions,
+	update func(*PaymentStatus)) (*PaymentStatus, error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+	r := d.rpcServer
+
+	if !r.server.Started() {
+		return nil, errors.New("chain backend is still syncing, " +
+			"server not active yet")
+	}
+
+	payment, err := r.paymentIntent(req)
+	if err != nil {
+		return nil, err
+	}
+	if payment.Amount == 0 || payment.Amount > maxPaymentMSat {
+		return nil, fmt.Errorf("invalid payment amount: %v",
+			payment.Amount)
+	}
+	payment.PayAttemptTimeout = timeout
+
+	p := &trackedPayment{
+		status: PaymentStatus{
+			PaymentHash: hex.EncodeToString(payment.PaymentHash[:]),
+			State:       PaymentInFlight,
+			AmountMsat:  int64(payment.Amount),
+		},
+		subscribers: map[uint64]func(*PaymentStatus){0: update},
+		nextID:      1,
+		done:        make(chan struct{}),
+	}
+	db := d.internalWallet().Database()
+
+	paymentsMtx.Lock()
+	err = checkResend(d.chanDB, db, payment.PaymentHash)
+	if err != nil {
+		paymentsMtx.Unlock()
+		return nil, err
+	}
+
+	// The payment is stored before any HTLC is sent, so it can still be
+	// tracked should the app be killed before it resolves.
+	status := p.snapshot()
+	if err := putPaymentStatus(db, status); err != nil {
+		paymentsMtx.Unlock()
+		return nil, err
+	}
+	inFlightPayments[payment.PaymentHash] = p
+	u := p.notify()
+	paymentsMtx.Unlock()
+	u.deliver()
+
+	var (
+		preimage [32]byte
+		route    *routing.Route
+		sendErr  error
+	)
+
+	// The router can't be told of the nodes the breaker excluded either,
+	// so payments avoiding them are sent as restricted ones.
+	excluded := d.server.nodeBreaker.excluded()
+	delete(excluded, routing.NewVertex(payment.Target))
+	if restrictions.restricts() || len(excluded) > 0 {
+		if restrictions == nil {
+			restrictions = &PaymentRestrictions{}
+		}
+		preimage, route, sendErr = sendRestrictedPayment(
+			d, payment, restrictions, excluded,
+		)
+	} else {
+		preimage, route, sendErr = d.server.chanRouter.SendPayment(
+			payment,
+		)
+	}
+	if sendErr == nil {
+		err := r.savePayment(route, payment.Amount, preimage[:])
+		if err != nil {
+			ltndLog.Errorf("Unable to save payment %x: %v",
+				payment.PaymentHash[:], err)
+		}
+	}
+
+	paymentsMtx.Lock()
+	delete(inFlightPayments, payment.PaymentHash)
+
+	if sendErr != nil {
+		p.status.State = PaymentFailed
+		p.status.FailureReason = sendErr.Error()
+		p.status.FailureDetail = classifyPaymentFailure(
+			d, sendErr, p.lastFailure, payment.Target,
+			payment.Amount,
+		)
+	} else {
+		p.status.State = PaymentSucceeded
+		p.status.PaymentPreimage = hex.EncodeToString(preimage[:])
+		p.status.Route = marshallRoute(route)
+		p.status.FeeMsat = int64(route.TotalFees)
+	}
+
+	u = p.notify()
+	if err := putPaymentStatus(db, u.status); err != nil {
+		ltndLog.Errorf("Unable to store payment %x: %v",
+			payment.PaymentHash[:], err)
+	}
+	paymentsMtx.Unlock()
+
+	// The final update reaches the subscribers before TrackPayment
+	// returns.
+	u.deliver()
+	close(p.done)
+
+	return u.status, nil
+}
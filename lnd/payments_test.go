@@ -0,0 +1,191 @@
+package lnd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/shachain"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+// TestPaymentUpdateDelivery checks that updates reach the subscribers once
+// paymentsMtx is released, and that an update taken before a later one that
+// was delivered already is dropped.
+func TestPaymentUpdateDelivery(t *testing.T) {
+	var states []string
+	p := &trackedPayment{
+		status: PaymentStatus{State: PaymentInFlight},
+		subscribers: map[uint64]func(*PaymentStatus){
+			0: func(status *PaymentStatus) {
+				// Subscribers may call back into the
+				// payments.
+				paymentsMtx.Lock()
+				paymentsMtx.Unlock()
+
+				states = append(states, status.State)
+			},
+		},
+		nextID: 1,
+		done:   make(chan struct{}),
+	}
+
+	paymentsMtx.Lock()
+	stale := p.notify()
+	p.status.State = PaymentSucceeded
+	final := p.notify()
+	paymentsMtx.Unlock()
+
+	final.deliver()
+	stale.deliver()
+
+	if len(states) != 1 || states[0] != PaymentSucceeded {
+		t.Fatalf("expected only the final update, got %v", states)
+	}
+
+	// The update holds a copy of the payment, which later changes leave
+	// untouched.
+	paymentsMtx.Lock()
+	p.status.Htlcs = append(p.status.Htlcs, &HTLCAttempt{})
+	paymentsMtx.Unlock()
+	if len(final.status.Htlcs) != 0 {
+		t.Fatalf("update changed along with the payment")
+	}
+}
+
+// addPendingHTLC stores an open channel holding an outgoing HTLC paying the
+// hash.
+func addPendingHTLC(t *testing.T, chanDB *channeldb.DB, paymentHash [32]byte) {
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create key: %v", err)
+	}
+	key := keychain.KeyDescriptor{PubKey: priv.PubKey()}
+	chanCfg := channeldb.ChannelConfig{
+		MultiSigKey:         key,
+		RevocationBasePoint: key,
+		PaymentBasePoint:    key,
+		DelayBasePoint:      key,
+		HtlcBasePoint:       key,
+	}
+
+	producer, err := shachain.NewRevocationProducerFromBytes(
+		make([]byte, 32),
+	)
+	if err != nil {
+		t.Fatalf("unable to create revocation producer: %v", err)
+	}
+
+	commitTx := wire.NewMsgTx(2)
+	commitTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	commitTx.AddTxOut(wire.NewTxOut(1000, []byte{0x00}))
+
+	chanID := lnwire.NewShortChanIDFromInt(1)
+	channel := &channeldb.OpenChannel{
+		ChanType:      channeldb.SingleFunder,
+		ShortChanID:   chanID,
+		IsInitiator:   true,
+		IdentityPub:   priv.PubKey(),
+		Capacity:      100000,
+		LocalChanCfg:  chanCfg,
+		RemoteChanCfg: chanCfg,
+		LocalCommitment: channeldb.ChannelCommitment{
+			CommitTx:  commitTx,
+			CommitSig: []byte{1},
+			Htlcs: []channeldb.HTLC{{
+				RHash:     paymentHash,
+				Amt:       1000,
+				OnionBlob: make([]byte, lnwire.OnionPacketSize),
+			}},
+		},
+		RemoteCommitment: channeldb.ChannelCommitment{
+			CommitTx:  commitTx,
+			CommitSig: []byte{1},
+		},
+		RemoteCurrentRevocation: priv.PubKey(),
+		RemoteNextRevocation:    priv.PubKey(),
+		RevocationProducer:      producer,
+		RevocationStore:         shachain.NewRevocationStore(),
+		Db:                      chanDB,
+		Packager:                channeldb.NewChannelPackager(chanID),
+		FundingTxn:              commitTx,
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9735}
+	if err := channel.SyncPending(addr, 0); err != nil {
+		t.Fatalf("unable to store channel: %v", err)
+	}
+}
+
+// TestCheckResend checks that a payment left in flight by an earlier run is
+// refused while its HTLC may still be outstanding, and once its preimage was
+// learned.
+func TestCheckResend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkresend")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	chanDB, err := channeldb.Open(dir)
+	if err != nil {
+		t.Fatalf("unable to open channel db: %v", err)
+	}
+	defer chanDB.Close()
+
+	db, err := walletdb.Create("bdb", filepath.Join(dir, "wallet.db"))
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	defer db.Close()
+
+	preimage := []byte{1}
+	paymentHash := sha256.Sum256(preimage)
+	err = putPaymentStatus(db, &PaymentStatus{
+		PaymentHash: hex.EncodeToString(paymentHash[:]),
+		State:       PaymentInFlight,
+	})
+	if err != nil {
+		t.Fatalf("unable to store payment: %v", err)
+	}
+
+	addPendingHTLC(t, chanDB, paymentHash)
+
+	paymentsMtx.Lock()
+	err = checkResend(chanDB, db, paymentHash)
+	paymentsMtx.Unlock()
+	if err != ErrPaymentInFlight {
+		t.Fatalf("expected payment in flight, got %v", err)
+	}
+
+	// Once the preimage is learned, the payment succeeded.
+	err = chanDB.NewWitnessCache().AddWitness(
+		channeldb.Sha256HashWitness, preimage,
+	)
+	if err != nil {
+		t.Fatalf("unable to add preimage: %v", err)
+	}
+
+	paymentsMtx.Lock()
+	err = checkResend(chanDB, db, paymentHash)
+	paymentsMtx.Unlock()
+	if err != ErrPaymentSucceeded {
+		t.Fatalf("expected payment succeeded, got %v", err)
+	}
+
+	stored, err := fetchPaymentStatuses(db, &paymentHash)
+	if err != nil {
+		t.Fatalf("unable to fetch payment: %v", err)
+	}
+	if len(stored) != 1 || stored[0].State != PaymentSucceeded {
+		t.Fatalf("resolved payment not stored")
+	}
+}
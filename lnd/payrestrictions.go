@@ -0,0 +1,187 @@
+package lnd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// restrictedPayTimeout is how long routes of a restricted payment are tried
+// for when no timeout is given, as with the router.
+const restrictedPayTimeout = 60 * time.Second
+
+// PaymentRestrictions limit the routes a payment sent through SendPaymentV2
+// may take, such as to keep it to the channels with an LSP.
+type PaymentRestrictions struct {
+	// OutgoingChanIDs are the channels of this node the payment may leave
+	// through, or any of them if empty.
+	OutgoingChanIDs []uint64
+
+	// LastHop is the node the payment must reach its destination from,
+	// or nil for any.
+	LastHop *btcec.PublicKey
+}
+
+// restricts returns true if the restrictions rule out any route.
+func (r *PaymentRestrictions) restricts() bool {
+	return r != nil && (len(r.OutgoingChanIDs) > 0 || r.LastHop != nil)
+}
+
+// sendRestrictedPayment sends the payment as the router does, but only over
+// routes keeping to the restrictions, which the router here can't be told of.
+// The cheapest of them is tried until the payment succeeds, the destination
+// fails it, or its timeout passes, avoiding the channels that failed earlier
+// attempts, and the excluded nodes.
+func sendRestrictedPayment(d *daemon, payment *routing.LightningPayment,
+	restrictions *PaymentRestrictions,
+	excluded map[routing.Vertex]struct{}) ([32]byte, *routing.Route,
+	error) {
+
+	s := d.server
+	graph := d.chanDB.ChannelGraph()
+	self := s.identityPriv.PubKey()
+
+	if restrictions.LastHop != nil &&
+		restrictions.LastHop.IsEqual(payment.Target) {
+
+		return [32]byte{}, nil, errors.New("last hop must not be the " +
+			"destination")
+	}
+
+	// Keeping the payment to the outgoing channels is done by ignoring
+	// all other channels of this node.
+	ignoredEdges := make(map[uint64]struct{})
+	if len(restrictions.OutgoingChanIDs) > 0 {
+		channels, err := d.chanDB.FetchAllChannels()
+		if err != nil {
+			return [32]byte{}, nil, err
+		}
+
+		outgoing := make(map[uint64]struct{})
+		for _, channel := range channels {
+			chanID := channel.ShortChanID.ToUint64()
+			outgoing[chanID] = struct{}{}
+			ignoredEdges[chanID] = struct{}{}
+		}
+		for _, chanID := range restrictions.OutgoingChanIDs {
+			if _, ok := outgoing[chanID]; !ok {
+				return [32]byte{}, nil, fmt.Errorf("channel "+
+					"%v isn't an open channel of this node",
+					lnwire.NewShortChanIDFromInt(chanID))
+			}
+			delete(ignoredEdges, chanID)
+		}
+	}
+
+	// The path to the last hop mustn't pass the destination already. The
+	// last hop itself is kept even if excluded.
+	target := payment.Target
+	ignoredNodes := make(map[routing.Vertex]struct{})
+	for vertex := range excluded {
+		ignoredNodes[vertex] = struct{}{}
+	}
+	if restrictions.LastHop != nil {
+		target = restrictions.LastHop
+		delete(ignoredNodes, routing.NewVertex(target))
+		ignoredNodes[routing.NewVertex(payment.Target)] = struct{}{}
+	}
+
+	finalCLTVDelta := uint16(routing.DefaultFinalCLTVDelta)
+	if payment.FinalCLTVDelta != nil {
+		finalCLTVDelta = *payment.FinalCLTVDelta
+	}
+
+	timeout := payment.PayAttemptTimeout
+	if timeout == 0 {
+		timeout = restrictedPayTimeout
+	}
+	timeoutChan := time.After(timeout)
+
+	var sendErr error
+	for {
+		select {
+		case <-timeoutChan:
+			return [32]byte{}, nil, &paymentTimeoutError{timeout}
+
+		case <-s.quit:
+			return [32]byte{}, nil, ErrDaemonNotRunning
+
+		default:
+		}
+
+		// A last hop of this node itself leaves only the channel to
+		// the destination.
+		var (
+			path []*routing.ChannelHop
+			err  error
+		)
+		if !target.IsEqual(self) {
+			path, err = findGraphPath(
+				graph, self, target, payment.Amount,
+				ignoredNodes, ignoredEdges,
+			)
+		}
+		if err == nil && restrictions.LastHop != nil {
+			var hop *routing.ChannelHop
+			hop, err = channelBetween(
+				graph, restrictions.LastHop, payment.Target,
+				payment.Amount, ignoredEdges,
+			)
+			if err == nil && hop == nil {
+				err = ErrNoPath
+			}
+			path = append(path, hop)
+		}
+		if err != nil {
+			if sendErr != nil {
+				return [32]byte{}, nil, fmt.Errorf("unable to "+
+					"route payment to destination: %v",
+					sendErr)
+			}
+			return [32]byte{}, nil, err
+		}
+
+		_, height, err := d.cc.chainIO.GetBestBlock()
+		if err != nil {
+			return [32]byte{}, nil, err
+		}
+
+		route, err := buildRoute(
+			payment.Amount, path, uint32(height), finalCLTVDelta,
+		)
+		if err != nil {
+			return [32]byte{}, nil, err
+		}
+
+		preimage, err := sendToRoute(s, route, payment.PaymentHash)
+		if err == nil {
+			return preimage, route, nil
+		}
+		sendErr = err
+
+		// Failures of the destination, or of unknown channels, end
+		// the payment. Otherwise the failed channel is avoided.
+		fErr, ok := err.(*htlcswitch.ForwardingError)
+		if !ok || fErr.ErrorSource == nil ||
+			fErr.ErrorSource.IsEqual(payment.Target) {
+
+			return [32]byte{}, nil, err
+		}
+
+		var chanID uint64
+		if fErr.ErrorSource.IsEqual(self) {
+			chanID, ok = route.Hops[0].Channel.ChannelID, true
+		} else {
+			chanID, ok = failedChannel(route, fErr.ErrorSource)
+		}
+		if !ok {
+			return [32]byte{}, nil, err
+		}
+		ignoredEdges[chanID] = struct{}{}
+	}
+}
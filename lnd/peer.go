@@ -1282,8 +1282,22 @@ func (p *peer) ChannelSnapshots() []*channeldb.ChannelSnapshot {
 }
 
 // genDeliveryScript returns a new script to be used to send our funds to in
-// the case of a cooperative channel close negotiation.
-func (p *peer) genDeliveryScript() ([]byte, error) {
+// the case of a cooperative channel close negotiation. A close address set
+// by the app for the channel takes precedence.
+func (p *peer) genDeliveryScript(chanPoint *wire.OutPoint) ([]byte, error) {
+	w := p.server.cc.wallet.WalletController.(*fundingWallet)
+	closeScript, err := closeDeliveryScript(
+		w.InternalWallet().Database(), chanPoint,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if closeScript != nil {
+		peerLog.Infof("Delivery script for channel close of %v set "+
+			"by the app: %x", chanPoint, closeScript)
+		return closeScript, nil
+	}
+
 	deliveryAddr, err := p.server.cc.wallet.NewAddress(
 		lnwallet.WitnessPubKey, false,
 	)
@@ -1535,7 +1549,7 @@ func (p *peer) fetchActiveChanCloser(chanID lnwire.ChannelID) (*channelCloser, e
 
 		// We'll create a valid closing state machine in order to
 		// respond to the initiated cooperative channel closure.
-		deliveryAddr, err := p.genDeliveryScript()
+		deliveryAddr, err := p.genDeliveryScript(channel.ChannelPoint())
 		if err != nil {
 			peerLog.Errorf("unable to gen delivery script: %v", err)
 
@@ -1622,7 +1636,7 @@ func (p *peer) handleLocalCloseReq(req *htlcswitch.ChanClose) {
 		// First, we'll fetch a fresh delivery address that we'll use
 		// to send the funds to in the case of a successful
 		// negotiation.
-		deliveryAddr, err := p.genDeliveryScript()
+		deliveryAddr, err := p.genDeliveryScript(req.ChanPoint)
 		if err != nil {
 			peerLog.Errorf(err.Error())
 			req.Err <- err
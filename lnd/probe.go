@@ -0,0 +1,116 @@
+package lnd
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcutil"
+)
+
+// maxProbeRoutes is the number of routes Probe tries.
+const maxProbeRoutes = 3
+
+// ProbedRoute is a route tried by Probe.
+type ProbedRoute struct {
+	Route *lnrpc.Route `json:"route"`
+
+	// Reachable is true if the HTLC reached the destination, which failed
+	// it for its unknown payment hash. Otherwise, Failure is why it was
+	// failed, and FailureSource the node failing it, if known.
+	Reachable     bool   `json:"reachable"`
+	Failure       string `json:"failure,omitempty"`
+	FailureSource string `json:"failure_source,omitempty"`
+}
+
+// ProbeResult is the outcome of Probe.
+type ProbeResult struct {
+	// Reachable is true if the amount reached the destination over any
+	// route, and FeeMsat is the fee of the cheapest one that did.
+	Reachable bool  `json:"reachable"`
+	FeeMsat   int64 `json:"fee_msat"`
+
+	// SuccessProbability is the share of the routes tried that reached
+	// the destination.
+	SuccessProbability float64 `json:"success_probability"`
+
+	Routes []*ProbedRoute `json:"routes"`
+}
+
+// Probe tests whether amt can be paid to the destination, by sending it over
+// up to three of the cheapest routes with a payment hash nobody knows the
+// preimage of, so the HTLCs are failed back by the destination, or by the node
+// unable to forward them, and nothing is paid. A destination that can't be
+// routed to at all returns the error of the router.
+func Probe(dest *btcec.PublicKey, amt btcutil.Amount) (*ProbeResult, error) {
+	amtMSat := lnwire.NewMSatFromSatoshis(amt)
+	if amtMSat <= 0 || amtMSat > maxPaymentMSat {
+		return nil, fmt.Errorf("invalid probe amount: %v", amt)
+	}
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+	s := d.server
+
+	routes, err := s.chanRouter.FindRoutes(dest, amtMSat, maxProbeRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	var paymentHash [32]byte
+	if _, err := rand.Read(paymentHash[:]); err != nil {
+		return nil, err
+	}
+
+	result := &ProbeResult{}
+	var reached int
+	for _, route := range routes {
+		probed := &ProbedRoute{Route: marshallRoute(route)}
+		result.Routes = append(result.Routes, probed)
+
+		_, err := sendToRoute(s, route, paymentHash)
+		if err == nil {
+			// Nobody can settle the hash, so this never happens.
+			return nil, fmt.Errorf("probe with hash %x settled",
+				paymentHash[:])
+		}
+
+		fErr, ok := err.(*htlcswitch.ForwardingError)
+		if ok && fErr.ErrorSource != nil && fErr.ErrorSource.IsEqual(dest) {
+			switch fErr.FailureMessage.(type) {
+			case *lnwire.FailUnknownPaymentHash,
+				*lnwire.FailIncorrectPaymentAmount:
+
+				probed.Reachable = true
+			}
+		}
+		if !probed.Reachable {
+			probed.Failure, probed.FailureSource = attemptFailure(
+				err,
+			)
+			continue
+		}
+
+		fee := int64(route.TotalFees)
+		if reached == 0 || fee < result.FeeMsat {
+			result.FeeMsat = fee
+		}
+		reached++
+	}
+
+	result.Reachable = reached > 0
+	if len(routes) > 0 {
+		result.SuccessProbability = float64(reached) /
+			float64(len(routes))
+	}
+
+	return result, nil
+}
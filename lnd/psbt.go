@@ -0,0 +1,389 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/roasbeef/btcd/wire"
+)
+
+// psbtMagic prefixes every serialized PSBT (BIP 174).
+var psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// maxPsbtValue is the largest key or value read from a PSBT.
+const maxPsbtValue = 4 * 1024 * 1024
+
+// The key types of the PSBT fields the wallet reads or writes. Fields of
+// other types are kept as they are.
+const (
+	psbtGlobalUnsignedTx = 0x00
+
+	psbtInNonWitnessUtxo     = 0x00
+	psbtInWitnessUtxo        = 0x01
+	psbtInPartialSig         = 0x02
+	psbtInSighashType        = 0x03
+	psbtInRedeemScript       = 0x04
+	psbtInWitnessScript      = 0x05
+	psbtInBip32Derivation    = 0x06
+	psbtInFinalScriptSig     = 0x07
+	psbtInFinalScriptWitness = 0x08
+)
+
+// ErrInvalidPsbt is returned for a PSBT that can't be decoded, or that can't
+// be processed as requested.
+type ErrInvalidPsbt struct {
+	Reason string
+}
+
+func (e *ErrInvalidPsbt) Error() string {
+	return "invalid psbt: " + e.Reason
+}
+
+// invalidPsbt returns an ErrInvalidPsbt with the formatted reason.
+func invalidPsbt(format string, a ...interface{}) error {
+	return &ErrInvalidPsbt{Reason: fmt.Sprintf(format, a...)}
+}
+
+// psbtKV is a single key-value pair of a PSBT map. The first byte of the key
+// is its type.
+type psbtKV struct {
+	key   []byte
+	value []byte
+}
+
+// psbtMap is one of the key-value maps of a PSBT, in the order read.
+type psbtMap []psbtKV
+
+// get returns the value of the field of the key type without key data, or nil
+// if there is none.
+func (m psbtMap) get(keyType byte) []byte {
+	for _, kv := range m {
+		if len(kv.key) == 1 && kv.key[0] == keyType {
+			return kv.value
+		}
+	}
+
+	return nil
+}
+
+// has returns whether the map holds a field of the key type.
+func (m psbtMap) has(keyType byte) bool {
+	for _, kv := range m {
+		if kv.key[0] == keyType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// all returns the fields of the key type.
+func (m psbtMap) all(keyType byte) []psbtKV {
+	var kvs []psbtKV
+	for _, kv := range m {
+		if kv.key[0] == keyType {
+			kvs = append(kvs, kv)
+		}
+	}
+
+	return kvs
+}
+
+// set stores the value under the key, replacing any previous value.
+func (m *psbtMap) set(key, value []byte) {
+	for i, kv := range *m {
+		if bytes.Equal(kv.key, key) {
+			(*m)[i].value = value
+			return
+		}
+	}
+
+	*m = append(*m, psbtKV{key: key, value: value})
+}
+
+// remove deletes the fields of the key types.
+func (m *psbtMap) remove(keyTypes ...byte) {
+	kept := (*m)[:0]
+	for _, kv := range *m {
+		removed := false
+		for _, keyType := range keyTypes {
+			if kv.key[0] == keyType {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, kv)
+		}
+	}
+
+	*m = kept
+}
+
+// psbtPacket is a decoded PSBT. Fields the wallet doesn't understand are kept
+// as they are, so they survive a round trip through it.
+type psbtPacket struct {
+	tx      *wire.MsgTx
+	global  psbtMap
+	inputs  []psbtMap
+	outputs []psbtMap
+}
+
+// newPsbt returns a PSBT for the unsigned transaction, with empty input and
+// output maps.
+func newPsbt(tx *wire.MsgTx) *psbtPacket {
+	return &psbtPacket{
+		tx:      tx,
+		inputs:  make([]psbtMap, len(tx.TxIn)),
+		outputs: make([]psbtMap, len(tx.TxOut)),
+	}
+}
+
+// readPsbtMap reads key-value pairs until the separator ending the map.
+func readPsbtMap(r io.Reader) (psbtMap, error) {
+	var m psbtMap
+	seen := make(map[string]struct{})
+	for {
+		keyLen, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, err
+		}
+		if keyLen == 0 {
+			return m, nil
+		}
+		if keyLen > maxPsbtValue {
+			return nil, invalidPsbt("key too long")
+		}
+
+		key := make([]byte, keyLen)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[string(key)]; ok {
+			return nil, invalidPsbt("duplicate key %x", key)
+		}
+		seen[string(key)] = struct{}{}
+
+		value, err := wire.ReadVarBytes(r, 0, maxPsbtValue, "value")
+		if err != nil {
+			return nil, err
+		}
+
+		m = append(m, psbtKV{key: key, value: value})
+	}
+}
+
+// decodePsbt decodes a serialized PSBT.
+func decodePsbt(b []byte) (*psbtPacket, error) {
+	if !bytes.HasPrefix(b, psbtMagic) {
+		return nil, invalidPsbt("missing magic bytes")
+	}
+	r := bytes.NewReader(b[len(psbtMagic):])
+
+	global, err := readPsbtMap(r)
+	if err != nil {
+		return nil, invalidPsbt("%v", err)
+	}
+	txBytes := global.get(psbtGlobalUnsignedTx)
+	if txBytes == nil {
+		return nil, invalidPsbt("missing unsigned transaction")
+	}
+
+	tx := wire.NewMsgTx(2)
+	if err := tx.DeserializeNoWitness(bytes.NewReader(txBytes)); err != nil {
+		return nil, invalidPsbt("%v", err)
+	}
+	for _, txIn := range tx.TxIn {
+		if len(txIn.SignatureScript) != 0 || len(txIn.Witness) != 0 {
+			return nil, invalidPsbt("unsigned transaction has " +
+				"signatures")
+		}
+	}
+
+	p := &psbtPacket{tx: tx}
+	for _, kv := range global {
+		if !bytes.Equal(kv.key, []byte{psbtGlobalUnsignedTx}) {
+			p.global = append(p.global, kv)
+		}
+	}
+	for range tx.TxIn {
+		m, err := readPsbtMap(r)
+		if err != nil {
+			return nil, invalidPsbt("%v", err)
+		}
+		p.inputs = append(p.inputs, m)
+	}
+	for range tx.TxOut {
+		m, err := readPsbtMap(r)
+		if err != nil {
+			return nil, invalidPsbt("%v", err)
+		}
+		p.outputs = append(p.outputs, m)
+	}
+
+	return p, nil
+}
+
+// writePsbtMap writes the key-value pairs of the map, followed by the
+// separator ending it.
+func writePsbtMap(w io.Writer, m psbtMap) error {
+	for _, kv := range m {
+		if err := wire.WriteVarBytes(w, 0, kv.key); err != nil {
+			return err
+		}
+		if err := wire.WriteVarBytes(w, 0, kv.value); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0x00})
+	return err
+}
+
+// encode serializes the PSBT.
+func (p *psbtPacket) encode() ([]byte, error) {
+	var txBuf bytes.Buffer
+	if err := p.tx.SerializeNoWitness(&txBuf); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(psbtMagic)
+
+	global := append(psbtMap{{
+		key:   []byte{psbtGlobalUnsignedTx},
+		value: txBuf.Bytes(),
+	}}, p.global...)
+	if err := writePsbtMap(&buf, global); err != nil {
+		return nil, err
+	}
+	for _, m := range p.inputs {
+		if err := writePsbtMap(&buf, m); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range p.outputs {
+		if err := writePsbtMap(&buf, m); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// spentOutput returns the output spent by the input at the index, taken from
+// its witness or non-witness UTXO field, or nil if it has neither.
+func (p *psbtPacket) spentOutput(index int) (*wire.TxOut, error) {
+	m := p.inputs[index]
+
+	if utxo := m.get(psbtInWitnessUtxo); utxo != nil {
+		if len(utxo) < 8 {
+			return nil, invalidPsbt("malformed witness utxo of "+
+				"input %d", index)
+		}
+		value := int64(binary.LittleEndian.Uint64(utxo[:8]))
+		pkScript, err := wire.ReadVarBytes(
+			bytes.NewReader(utxo[8:]), 0, maxPsbtValue, "pkScript",
+		)
+		if err != nil {
+			return nil, invalidPsbt("malformed witness utxo of "+
+				"input %d", index)
+		}
+
+		return wire.NewTxOut(value, pkScript), nil
+	}
+
+	if utxo := m.get(psbtInNonWitnessUtxo); utxo != nil {
+		prevTx := wire.NewMsgTx(2)
+		if err := prevTx.Deserialize(bytes.NewReader(utxo)); err != nil {
+			return nil, invalidPsbt("malformed non-witness utxo "+
+				"of input %d", index)
+		}
+
+		op := p.tx.TxIn[index].PreviousOutPoint
+		if prevTx.TxHash() != op.Hash ||
+			int(op.Index) >= len(prevTx.TxOut) {
+
+			return nil, invalidPsbt("non-witness utxo of input %d "+
+				"doesn't match its outpoint", index)
+		}
+
+		return prevTx.TxOut[op.Index], nil
+	}
+
+	return nil, nil
+}
+
+// setWitnessUtxo stores the output spent by the input at the index.
+func (p *psbtPacket) setWitnessUtxo(index int, output *wire.TxOut) error {
+	var buf bytes.Buffer
+	var value [8]byte
+	binary.LittleEndian.PutUint64(value[:], uint64(output.Value))
+	buf.Write(value[:])
+	if err := wire.WriteVarBytes(&buf, 0, output.PkScript); err != nil {
+		return err
+	}
+
+	p.inputs[index].set([]byte{psbtInWitnessUtxo}, buf.Bytes())
+	return nil
+}
+
+// finalized returns whether the input at the index holds its final signature
+// script or witness.
+func (p *psbtPacket) finalized(index int) bool {
+	m := p.inputs[index]
+	return m.has(psbtInFinalScriptSig) || m.has(psbtInFinalScriptWitness)
+}
+
+// extract returns the signed transaction held by the PSBT, all of whose inputs
+// must be finalized.
+func (p *psbtPacket) extract() (*wire.MsgTx, error) {
+	tx := p.tx.Copy()
+	for i, txIn := range tx.TxIn {
+		if !p.finalized(i) {
+			return nil, invalidPsbt("input %d isn't finalized", i)
+		}
+
+		txIn.SignatureScript = p.inputs[i].get(psbtInFinalScriptSig)
+
+		witness := p.inputs[i].get(psbtInFinalScriptWitness)
+		if witness == nil {
+			continue
+		}
+		r := bytes.NewReader(witness)
+		count, err := wire.ReadVarInt(r, 0)
+		if err != nil || count > maxPsbtValue {
+			return nil, invalidPsbt("malformed witness of input %d",
+				i)
+		}
+		for j := uint64(0); j < count; j++ {
+			item, err := wire.ReadVarBytes(
+				r, 0, maxPsbtValue, "witness item",
+			)
+			if err != nil {
+				return nil, invalidPsbt("malformed witness of "+
+					"input %d", i)
+			}
+			txIn.Witness = append(txIn.Witness, item)
+		}
+	}
+
+	return tx, nil
+}
+
+// serializeWitness encodes the witness stack as a final script witness field.
+func serializeWitness(witness wire.TxWitness) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(witness))); err != nil {
+		return nil, err
+	}
+	for _, item := range witness {
+		if err := wire.WriteVarBytes(&buf, 0, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,532 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	base "github.com/roasbeef/btcwallet/wallet"
+)
+
+// FundedPsbt is a PSBT funded by the wallet. The PSBT is base64 encoded when
+// marshalled to JSON. The fee is in satoshis.
+type FundedPsbt struct {
+	Psbt            []byte   `json:"psbt"`
+	ChangeIndex     int32    `json:"change_index"`
+	Fee             int64    `json:"fee"`
+	LockedOutpoints []string `json:"locked_outpoints"`
+}
+
+// SignedPsbt is a PSBT the wallet added its signatures to, along with the
+// indexes of the inputs it signed.
+type SignedPsbt struct {
+	Psbt         []byte  `json:"psbt"`
+	SignedInputs []int32 `json:"signed_inputs"`
+}
+
+// FinalizedPsbt is a PSBT whose inputs are all finalized, along with the
+// signed transaction extracted from it.
+type FinalizedPsbt struct {
+	Psbt  []byte `json:"psbt"`
+	RawTx string `json:"raw_tx"`
+	Txid  string `json:"txid"`
+}
+
+// PsbtTemplate returns an unfunded PSBT paying the amounts, in satoshis, to
+// the addresses, ordered by address.
+func PsbtTemplate(addrToAmount map[string]int64) ([]byte, error) {
+	addrs := make([]string, 0, len(addrToAmount))
+	for addr := range addrToAmount {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	tx := wire.NewMsgTx(2)
+	for _, address := range addrs {
+		addr, err := btcutil.DecodeAddress(
+			address, activeNetParams.Params,
+		)
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.AddTxOut(wire.NewTxOut(addrToAmount[address], pkScript))
+	}
+
+	return newPsbt(tx).encode()
+}
+
+// FundPsbt funds the outputs of the PSBT from the default account, at
+// satPerByte satoshis per vbyte or a rate estimated to confirm within six
+// blocks if it is zero, adding change after its outputs. If the PSBT already
+// has inputs, those outputs of the default account are spent in full and no
+// others are. Otherwise the coins are picked as the coin control asks, which
+// may be nil for the defaults. The change is as it asks in either case.
+//
+// The spent outputs are locked, so nothing else spends them until the PSBT is
+// published by FinalizePsbt or abandoned through ReleasePsbt. Locks don't
+// survive a restart of the daemon.
+func FundPsbt(packet []byte, satPerByte int64,
+	c *CoinControl) (*FundedPsbt, error) {
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	f, feeRate, err := bumpFeeParams(satPerByte)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decodePsbt(packet)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.tx.TxOut) == 0 {
+		return nil, invalidPsbt("no outputs to fund")
+	}
+	if err := checkDust(p.tx.TxOut); err != nil {
+		return nil, err
+	}
+
+	// Hold off sends, which would otherwise pick the same coins.
+	f.sendMtx.Lock()
+	defer f.sendMtx.Unlock()
+
+	var selected []*lnwallet.Utxo
+	if len(p.tx.TxIn) > 0 {
+		coins, err := f.defaultUtxos(1)
+		if err != nil {
+			return nil, err
+		}
+
+		pinned := make([]wire.OutPoint, 0, len(p.tx.TxIn))
+		for _, txIn := range p.tx.TxIn {
+			pinned = append(pinned, txIn.PreviousOutPoint)
+		}
+		selected, err = pinCoins(coins, pinned)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		coins, err := f.coins(1, c)
+		if err != nil {
+			return nil, err
+		}
+
+		selected, _, err = selectCoins(
+			coins, p.tx.TxOut, feeRate,
+			c.strategy() == CoinSelectPrivacy,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, coin := range selected {
+			p.tx.AddTxIn(&wire.TxIn{
+				PreviousOutPoint: coin.OutPoint,
+				Sequence:         rbfSequence,
+			})
+			p.inputs = append(p.inputs, nil)
+		}
+	}
+
+	var (
+		weight lnwallet.TxWeightEstimator
+		target btcutil.Amount
+		total  btcutil.Amount
+	)
+	for _, output := range p.tx.TxOut {
+		addOutputWeight(&weight, output.PkScript)
+		target += btcutil.Amount(output.Value)
+	}
+	weight.AddP2WKHOutput()
+	for _, coin := range selected {
+		if coin.AddressType == lnwallet.WitnessPubKey {
+			weight.AddP2WKHInput()
+		} else {
+			weight.AddNestedP2WKHInput()
+		}
+		total += coin.Value
+	}
+
+	fee := feeRate.FeeForVSize(int64(weight.VSize()))
+	if total < target+fee {
+		return nil, insufficientCoinsError{
+			needed:    target + fee,
+			available: total,
+		}
+	}
+
+	// Change too small to be relayed is left to the miners.
+	changeIndex := int32(-1)
+	change := total - target - fee
+	if change >= walletDustLimit(c.changeScriptSize()) {
+		changeAddr, err := f.changeAddress(c)
+		if err != nil {
+			return nil, err
+		}
+		changeScript, err := txscript.PayToAddrScript(changeAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		changeIndex = int32(len(p.tx.TxOut))
+		p.tx.AddTxOut(wire.NewTxOut(int64(change), changeScript))
+		p.outputs = append(p.outputs, nil)
+	} else {
+		fee += change
+	}
+
+	// Include the transactions being spent, which hardware wallets
+	// require to verify the amounts they sign for.
+	w := f.InternalWallet()
+	for i, coin := range selected {
+		output := wire.NewTxOut(int64(coin.Value), coin.PkScript)
+		if err := p.setWitnessUtxo(i, output); err != nil {
+			return nil, err
+		}
+
+		prevTx, err := base.UnstableAPI(w).TxDetails(
+			&coin.OutPoint.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if prevTx == nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := prevTx.MsgTx.Serialize(&buf); err != nil {
+			return nil, err
+		}
+		nonWitnessKey := []byte{psbtInNonWitnessUtxo}
+		p.inputs[i].set(nonWitnessKey, buf.Bytes())
+	}
+
+	packet, err = p.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	locked := make([]string, 0, len(selected))
+	for _, coin := range selected {
+		w.LockOutpoint(coin.OutPoint)
+		locked = append(locked, coin.OutPoint.String())
+	}
+
+	return &FundedPsbt{
+		Psbt:            packet,
+		ChangeIndex:     changeIndex,
+		Fee:             int64(fee),
+		LockedOutpoints: locked,
+	}, nil
+}
+
+// walletOutput returns the output at the outpoint if the wallet knows the
+// transaction holding it, or nil if it doesn't.
+func walletOutput(w *base.Wallet, op wire.OutPoint) (*wire.TxOut, error) {
+	details, err := base.UnstableAPI(w).TxDetails(&op.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if details == nil || int(op.Index) >= len(details.MsgTx.TxOut) {
+		return nil, nil
+	}
+
+	return details.MsgTx.TxOut[op.Index], nil
+}
+
+// sameOutput returns whether the outputs pay the same amount to the same
+// script.
+func sameOutput(a, b *wire.TxOut) bool {
+	return a.Value == b.Value && bytes.Equal(a.PkScript, b.PkScript)
+}
+
+// signPsbt adds the wallet's signature, or the external signer's, to every
+// input they can sign that isn't finalized yet, returning the indexes of the
+// inputs signed.
+func (f *fundingWallet) signPsbt(p *psbtPacket) ([]int32, error) {
+	sigHashes := txscript.NewTxSigHashes(p.tx)
+
+	signed := []int32{}
+	for i, txIn := range p.tx.TxIn {
+		if p.finalized(i) {
+			continue
+		}
+
+		// Outputs of transactions the wallet knows are taken from the
+		// wallet rather than the PSBT, which could misstate the amount
+		// the wallet signs for.
+		output, err := walletOutput(
+			f.InternalWallet(), txIn.PreviousOutPoint,
+		)
+		if err != nil {
+			return nil, err
+		}
+		psbtOutput, err := p.spentOutput(i)
+		if err != nil {
+			return nil, err
+		}
+		known := output != nil
+		switch {
+		case known && psbtOutput != nil &&
+			!sameOutput(psbtOutput, output):
+
+			return nil, invalidPsbt("input %d doesn't match the "+
+				"output it spends", i)
+
+		case !known && psbtOutput == nil:
+			continue
+
+		case !known:
+			output = psbtOutput
+		}
+
+		hashType := txscript.SigHashAll
+		if v := p.inputs[i].get(psbtInSighashType); v != nil {
+			if len(v) != 4 {
+				return nil, invalidPsbt("malformed sighash "+
+					"type of input %d", i)
+			}
+			hashType = txscript.SigHashType(
+				binary.LittleEndian.Uint32(v),
+			)
+		}
+		if !validSigHashType(hashType) {
+			return nil, invalidPsbt("unsupported sighash type %v "+
+				"of input %d", hashType, i)
+		}
+
+		signDesc := &lnwallet.SignDescriptor{
+			Output:     output,
+			HashType:   hashType,
+			SigHashes:  sigHashes,
+			InputIndex: i,
+		}
+		inputScript, err := f.ComputeInputScript(p.tx, signDesc)
+		if err != nil {
+			return nil, err
+		}
+
+		// Inputs that aren't the wallet's may be signed through the
+		// external signer, or are left to other signers.
+		if inputScript == nil || len(inputScript.Witness) != 2 {
+			ok, err := signPsbtExternal(
+				p, i, output, hashType, sigHashes,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				signed = append(signed, int32(i))
+			}
+			continue
+		}
+
+		// An output paying the wallet in a transaction it doesn't
+		// know is only vouched for by the PSBT.
+		if !known {
+			return nil, invalidPsbt("input %d spends an output of "+
+				"the wallet it doesn't know of", i)
+		}
+
+		sig, pubKey := inputScript.Witness[0], inputScript.Witness[1]
+		sigKey := append([]byte{psbtInPartialSig}, pubKey...)
+		p.inputs[i].set(sigKey, sig)
+		if err := p.setWitnessUtxo(i, output); err != nil {
+			return nil, err
+		}
+
+		if len(inputScript.ScriptSig) != 0 {
+			pushes, err := txscript.PushedData(inputScript.ScriptSig)
+			if err != nil || len(pushes) != 1 {
+				return nil, invalidPsbt("unexpected signature "+
+					"script of input %d", i)
+			}
+			p.inputs[i].set([]byte{psbtInRedeemScript}, pushes[0])
+		}
+
+		signed = append(signed, int32(i))
+	}
+
+	return signed, nil
+}
+
+// finalizePsbtInput builds the final signature script and witness of the
+// input at the index from its partial signature. Only single key inputs,
+// native or nested segwit, can be finalized.
+func finalizePsbtInput(p *psbtPacket, index int) error {
+	output, err := p.spentOutput(index)
+	if err != nil {
+		return err
+	}
+	if output == nil {
+		return invalidPsbt("input %d is missing the output it spends",
+			index)
+	}
+
+	sigs := p.inputs[index].all(psbtInPartialSig)
+	if len(sigs) != 1 {
+		return invalidPsbt("input %d needs a single signature, has %d",
+			index, len(sigs))
+	}
+	witness := wire.TxWitness{sigs[0].value, sigs[0].key[1:]}
+
+	m := &p.inputs[index]
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(output.PkScript):
+		// The witness alone spends a native segwit output.
+
+	case txscript.IsPayToScriptHash(output.PkScript):
+		redeemScript := m.get(psbtInRedeemScript)
+		if !txscript.IsPayToWitnessPubKeyHash(redeemScript) {
+			return invalidPsbt("input %d isn't nested segwit",
+				index)
+		}
+
+		sigScript, err := txscript.NewScriptBuilder().
+			AddData(redeemScript).Script()
+		if err != nil {
+			return err
+		}
+		m.set([]byte{psbtInFinalScriptSig}, sigScript)
+
+	default:
+		return invalidPsbt("input %d has an unsupported script type",
+			index)
+	}
+
+	witnessBytes, err := serializeWitness(witness)
+	if err != nil {
+		return err
+	}
+	m.set([]byte{psbtInFinalScriptWitness}, witnessBytes)
+
+	// The finalizer clears what is no longer needed (BIP 174).
+	m.remove(
+		psbtInPartialSig, psbtInSighashType, psbtInRedeemScript,
+		psbtInWitnessScript, psbtInBip32Derivation,
+	)
+
+	return nil
+}
+
+// SignPsbt adds the wallet's signatures to the inputs of the PSBT spending its
+// outputs, along with those of the external signer for inputs carrying the
+// derivation of one of its keys. The others are left to be signed elsewhere.
+// The wallet signs for its outputs as recorded in its transactions, and only
+// with the standard sighash types.
+func SignPsbt(packet []byte) (*SignedPsbt, error) {
+	f, err := runningFundingWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decodePsbt(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := f.signPsbt(p)
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err = p.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedPsbt{Psbt: packet, SignedInputs: signed}, nil
+}
+
+// FinalizePsbt signs the inputs of the PSBT spending the wallet's outputs,
+// finalizes every input and extracts the signed transaction, publishing it if
+// requested. All inputs the wallet can't sign itself must already carry
+// their signature.
+func FinalizePsbt(packet []byte, publish bool) (*FinalizedPsbt, error) {
+	f, err := runningFundingWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decodePsbt(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.signPsbt(p); err != nil {
+		return nil, err
+	}
+	for i := range p.tx.TxIn {
+		if p.finalized(i) {
+			continue
+		}
+		if err := finalizePsbtInput(p, i); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := p.extract()
+	if err != nil {
+		return nil, err
+	}
+
+	if publish {
+		if err := f.PublishTransaction(tx); err != nil {
+			return nil, err
+		}
+
+		w := f.InternalWallet()
+		for _, txIn := range tx.TxIn {
+			w.UnlockOutpoint(txIn.PreviousOutPoint)
+		}
+	}
+
+	packet, err = p.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+
+	return &FinalizedPsbt{
+		Psbt:  packet,
+		RawTx: hex.EncodeToString(buf.Bytes()),
+		Txid:  tx.TxHash().String(),
+	}, nil
+}
+
+// ReleasePsbt unlocks the inputs of a PSBT funded by FundPsbt that won't be
+// published, so they may be spent again.
+func ReleasePsbt(packet []byte) error {
+	w, err := runningWallet()
+	if err != nil {
+		return err
+	}
+
+	p, err := decodePsbt(packet)
+	if err != nil {
+		return err
+	}
+
+	for _, txIn := range p.tx.TxIn {
+		w.UnlockOutpoint(txIn.PreviousOutPoint)
+	}
+
+	return nil
+}
@@ -0,0 +1,609 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/bbolt"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcutil"
+)
+
+const (
+	// rapidSyncTimeout is how long fetching a snapshot may take.
+	rapidSyncTimeout = 2 * time.Minute
+
+	// maxRapidSyncSnapshot is the largest snapshot fetched, in bytes.
+	maxRapidSyncSnapshot = 64 << 20
+
+	// rapidSyncBackdate is how far before the snapshot the updates it
+	// holds are dated, so any update gossiped by the peers since wins
+	// over them.
+	rapidSyncBackdate = 7 * 24 * time.Hour
+)
+
+var (
+	// rapidSyncBucketKey is the top level bucket of the channel database
+	// holding the time of the last snapshot applied under
+	// rapidSyncTimestampKey. It lives next to the graph, so a graph
+	// deleted along with the database is synced from scratch.
+	rapidSyncBucketKey    = []byte("lndmobile-rgs")
+	rapidSyncTimestampKey = []byte("timestamp")
+
+	// rapidSyncPrefix starts a rapid gossip sync snapshot of version 1.
+	rapidSyncPrefix = []byte{'L', 'D', 'K', 1}
+)
+
+// ErrInvalidSnapshot is returned for a rapid gossip sync snapshot that can't
+// be applied.
+type ErrInvalidSnapshot struct {
+	Reason string
+}
+
+func (e *ErrInvalidSnapshot) Error() string {
+	return "invalid gossip snapshot: " + e.Reason
+}
+
+// invalidSnapshot returns an ErrInvalidSnapshot with the formatted reason.
+func invalidSnapshot(format string, a ...interface{}) error {
+	return &ErrInvalidSnapshot{Reason: fmt.Sprintf(format, a...)}
+}
+
+// RapidSyncResult is the outcome of RapidGossipSync.
+type RapidSyncResult struct {
+	// Timestamp is the time the snapshot applied was taken at, which the
+	// next sync fetches the changes since.
+	Timestamp uint32 `json:"timestamp"`
+
+	// Channels is the number of channels added to the graph, and Updates
+	// the number of channel policies updated.
+	Channels int `json:"channels"`
+	Updates  int `json:"updates"`
+}
+
+// snapshotChannel is a channel announced in a snapshot.
+type snapshotChannel struct {
+	chanID   uint64
+	features []byte
+	node1    [33]byte
+	node2    [33]byte
+}
+
+// snapshotUpdate is a channel policy update of a snapshot, along with the
+// largest HTLC the channel forwards in its direction.
+type snapshotUpdate struct {
+	policy      *channeldb.ChannelEdgePolicy
+	maxHTLC     lnwire.MilliSatoshi
+	incremental bool
+	fields      byte
+}
+
+// The flags of a snapshot update telling which fields it holds, next to the
+// direction and disabled flags of a channel update.
+const (
+	rapidSyncIncremental  = 1 << 7
+	rapidSyncCltvDelta    = 1 << 6
+	rapidSyncMinHTLC      = 1 << 5
+	rapidSyncFeeBase      = 1 << 4
+	rapidSyncFeeRate      = 1 << 3
+	rapidSyncMaxHTLC      = 1 << 2
+	rapidSyncChannelFlags = 0x03
+)
+
+// RapidGossipSync fetches the snapshot of the channel graph served by the rapid
+// gossip sync server at serverURL, such as
+// https://rapidsync.lightningdevkit.org/testnet/snapshot, and adds the
+// channels and policies it holds to the graph. After the first sync, only the
+// changes since the previous one are fetched. This fills the graph in seconds
+// rather than the hours gossip with peers takes, without validating the
+// channels on chain, which the snapshot leaves out along with their
+// signatures. As channels added this way can't be watched for closing on
+// chain, they're pruned once their last update is two weeks old, which the
+// snapshot dates a week back.
+func RapidGossipSync(serverURL string) (*RapidSyncResult, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	since, err := d.rapidSyncTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(serverURL, "/") + "/" +
+		strconv.FormatUint(uint64(since), 10)
+	snapshot, _, err := fetchSnapshot(url)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := d.applySnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.setRapidSyncTimestamp(result.Timestamp); err != nil {
+		return nil, err
+	}
+
+	ltndLog.Infof("Rapid gossip sync added %d channels and %d policy "+
+		"updates", result.Channels, result.Updates)
+
+	return result, nil
+}
+
+// rapidSyncTimestamp returns the timestamp of the last snapshot applied, or
+// zero if none was.
+func (d *daemon) rapidSyncTimestamp() (uint32, error) {
+	var timestamp uint32
+	err := d.chanDB.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(rapidSyncBucketKey)
+		if bucket == nil {
+			return nil
+		}
+		if v := bucket.Get(rapidSyncTimestampKey); len(v) == 4 {
+			timestamp = binary.BigEndian.Uint32(v)
+		}
+		return nil
+	})
+
+	return timestamp, err
+}
+
+// setRapidSyncTimestamp stores the timestamp of the last snapshot applied, for
+// the next sync to only fetch what changed since.
+func (d *daemon) setRapidSyncTimestamp(timestamp uint32) error {
+	return d.chanDB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(rapidSyncBucketKey)
+		if err != nil {
+			return err
+		}
+
+		var v [4]byte
+		binary.BigEndian.PutUint32(v[:], timestamp)
+		return bucket.Put(rapidSyncTimestampKey, v[:])
+	})
+}
+
+// fetchSnapshot fetches the snapshot at url, dialing through the configured
+// network so it honors the Tor settings, along with the headers of the
+// response.
+func fetchSnapshot(url string) ([]byte, http.Header, error) {
+	client := &http.Client{
+		Timeout: rapidSyncTimeout,
+		Transport: &http.Transport{
+			Dial: cfg.net.Dial,
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	snapshot, err := ioutil.ReadAll(
+		&io.LimitedReader{R: resp.Body, N: maxRapidSyncSnapshot},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, resp.Header, nil
+}
+
+// applySnapshot adds the channels and policies of the snapshot to the graph.
+// The snapshot is parsed in full first, so a malformed one changes nothing.
+func (d *daemon) applySnapshot(snapshot []byte) (*RapidSyncResult, error) {
+	r := bytes.NewReader(snapshot)
+
+	var (
+		prefix    [4]byte
+		chainHash chainhash.Hash
+		timestamp uint32
+		nodeCount uint32
+	)
+	err := readElements(r, &prefix, &chainHash, &timestamp, &nodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(prefix[:], rapidSyncPrefix) {
+		return nil, invalidSnapshot("unknown prefix %x", prefix)
+	}
+	if chainHash != *activeNetParams.GenesisHash {
+		return nil, invalidSnapshot("snapshot of chain %v", chainHash)
+	}
+	if int64(nodeCount)*33 > int64(r.Len()) {
+		return nil, invalidSnapshot("%d nodes", nodeCount)
+	}
+
+	nodes := make([][33]byte, nodeCount)
+	for i := range nodes {
+		if err := readElements(r, &nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	channels, err := readSnapshotChannels(r, nodes)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := readSnapshotUpdates(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// The snapshot leaves out the capacity of channels, so the largest
+	// HTLC they forward stands in for it in pathfinding.
+	capacities := make(map[uint64]btcutil.Amount)
+	for _, update := range updates {
+		chanID := update.policy.ChannelID
+		amt := update.maxHTLC.ToSatoshis()
+		if amt > capacities[chanID] {
+			capacities[chanID] = amt
+		}
+	}
+
+	result := &RapidSyncResult{Timestamp: timestamp}
+	graph := d.chanDB.ChannelGraph()
+	for _, channel := range channels {
+		added, err := addSnapshotChannel(
+			graph, channel, capacities[channel.chanID],
+		)
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			result.Channels++
+		}
+	}
+
+	lastUpdate := time.Unix(int64(timestamp), 0).Add(-rapidSyncBackdate)
+	for _, update := range updates {
+		update.policy.LastUpdate = lastUpdate
+
+		applied, err := applySnapshotUpdate(graph, update)
+		if err != nil {
+			return nil, err
+		}
+		if applied {
+			result.Updates++
+		}
+	}
+
+	return result, nil
+}
+
+// readSnapshotChannels reads the channels announced in a snapshot between the
+// nodes it lists.
+func readSnapshotChannels(r *bytes.Reader,
+	nodes [][33]byte) ([]*snapshotChannel, error) {
+
+	var count uint32
+	if err := readElements(r, &count); err != nil {
+		return nil, err
+	}
+
+	var (
+		channels []*snapshotChannel
+		chanID   uint64
+	)
+	for i := uint32(0); i < count; i++ {
+		var featuresLen uint16
+		if err := readElements(r, &featuresLen); err != nil {
+			return nil, err
+		}
+		features := make([]byte, featuresLen)
+		if _, err := io.ReadFull(r, features); err != nil {
+			return nil, err
+		}
+
+		// Channels are sorted, each id given as the increment over the
+		// previous one.
+		delta, err := readBigSize(r)
+		if err != nil {
+			return nil, err
+		}
+		chanID += delta
+
+		index1, err := readBigSize(r)
+		if err != nil {
+			return nil, err
+		}
+		index2, err := readBigSize(r)
+		if err != nil {
+			return nil, err
+		}
+		numNodes := uint64(len(nodes))
+		if index1 >= numNodes || index2 >= numNodes {
+			return nil, invalidSnapshot("unknown node of channel "+
+				"%v", lnwire.NewShortChanIDFromInt(chanID))
+		}
+
+		channels = append(channels, &snapshotChannel{
+			chanID:   chanID,
+			features: features,
+			node1:    nodes[index1],
+			node2:    nodes[index2],
+		})
+	}
+
+	return channels, nil
+}
+
+// readSnapshotUpdates reads the channel policy updates of a snapshot. Fields
+// left out of updates that aren't incremental take the defaults the snapshot
+// gives, and those of incremental ones keep their value in the graph.
+func readSnapshotUpdates(r *bytes.Reader) ([]*snapshotUpdate, error) {
+	var count uint32
+	if err := readElements(r, &count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	var (
+		cltvDelta uint16
+		minHTLC   uint64
+		feeBase   uint32
+		feeRate   uint32
+		maxHTLC   uint64
+	)
+	err := readElements(
+		r, &cltvDelta, &minHTLC, &feeBase, &feeRate, &maxHTLC,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		updates []*snapshotUpdate
+		chanID  uint64
+	)
+	for i := uint32(0); i < count; i++ {
+		delta, err := readBigSize(r)
+		if err != nil {
+			return nil, err
+		}
+		chanID += delta
+
+		var flags byte
+		if err := readElements(r, &flags); err != nil {
+			return nil, err
+		}
+
+		update := &snapshotUpdate{
+			policy: &channeldb.ChannelEdgePolicy{
+				ChannelID: chanID,
+				Flags: lnwire.ChanUpdateFlag(
+					flags & rapidSyncChannelFlags,
+				),
+				TimeLockDelta: cltvDelta,
+				MinHTLC:       lnwire.MilliSatoshi(minHTLC),
+				FeeBaseMSat:   lnwire.MilliSatoshi(feeBase),
+			},
+			incremental: flags&rapidSyncIncremental != 0,
+			fields:      flags,
+		}
+		if !update.incremental {
+			update.maxHTLC = lnwire.MilliSatoshi(maxHTLC)
+		}
+		policy := update.policy
+		policy.FeeProportionalMillionths = lnwire.MilliSatoshi(feeRate)
+
+		if flags&rapidSyncCltvDelta != 0 {
+			err := readElements(r, &policy.TimeLockDelta)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if flags&rapidSyncMinHTLC != 0 {
+			var v uint64
+			if err := readElements(r, &v); err != nil {
+				return nil, err
+			}
+			policy.MinHTLC = lnwire.MilliSatoshi(v)
+		}
+		if flags&rapidSyncFeeBase != 0 {
+			var v uint32
+			if err := readElements(r, &v); err != nil {
+				return nil, err
+			}
+			policy.FeeBaseMSat = lnwire.MilliSatoshi(v)
+		}
+		if flags&rapidSyncFeeRate != 0 {
+			var v uint32
+			if err := readElements(r, &v); err != nil {
+				return nil, err
+			}
+			policy.FeeProportionalMillionths =
+				lnwire.MilliSatoshi(v)
+		}
+		if flags&rapidSyncMaxHTLC != 0 {
+			var v uint64
+			if err := readElements(r, &v); err != nil {
+				return nil, err
+			}
+			update.maxHTLC = lnwire.MilliSatoshi(v)
+		}
+
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
+// addSnapshotChannel adds the channel to the graph, along with any of its
+// nodes the graph doesn't know yet, returning false if it was known already.
+func addSnapshotChannel(graph *channeldb.ChannelGraph,
+	channel *snapshotChannel, capacity btcutil.Amount) (bool, error) {
+
+	for _, node := range [][33]byte{channel.node1, channel.node2} {
+		_, exists, err := graph.HasLightningNode(node)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			continue
+		}
+
+		err = graph.AddLightningNode(&channeldb.LightningNode{
+			PubKeyBytes:          node,
+			HaveNodeAnnouncement: false,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// The funding outpoint isn't part of the snapshot, so the channel is
+	// indexed under one made up from its id, which is never spent.
+	shortChanID := lnwire.NewShortChanIDFromInt(channel.chanID)
+	var chanPoint chainhash.Hash
+	binary.BigEndian.PutUint64(chanPoint[:8], channel.chanID)
+
+	edge := &channeldb.ChannelEdgeInfo{
+		ChannelID:     channel.chanID,
+		ChainHash:     *activeNetParams.GenesisHash,
+		NodeKey1Bytes: channel.node1,
+		NodeKey2Bytes: channel.node2,
+		Features:      channel.features,
+		Capacity:      capacity,
+	}
+	edge.ChannelPoint.Hash = chanPoint
+	edge.ChannelPoint.Index = uint32(shortChanID.TxPosition)
+
+	err := graph.AddChannelEdge(edge)
+	switch {
+	case err == channeldb.ErrEdgeAlreadyExist:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// applySnapshotUpdate stores the policy update in the graph, returning false
+// if the channel isn't known, or the graph holds a newer policy.
+func applySnapshotUpdate(graph *channeldb.ChannelGraph,
+	update *snapshotUpdate) (bool, error) {
+
+	policy := update.policy
+	_, policy1, policy2, err := graph.FetchChannelEdgesByID(
+		policy.ChannelID,
+	)
+	switch {
+	case err == channeldb.ErrEdgeNotFound,
+		err == channeldb.ErrGraphNoEdgesFound:
+
+		return false, nil
+
+	case err != nil:
+		return false, err
+	}
+
+	known := policy1
+	if policy.Flags&lnwire.ChanUpdateDirection != 0 {
+		known = policy2
+	}
+	if known != nil && !known.LastUpdate.Before(policy.LastUpdate) {
+		return false, nil
+	}
+
+	// An incremental update only holds the fields that changed.
+	if update.incremental {
+		if known == nil {
+			return false, nil
+		}
+		if update.fields&rapidSyncCltvDelta == 0 {
+			policy.TimeLockDelta = known.TimeLockDelta
+		}
+		if update.fields&rapidSyncMinHTLC == 0 {
+			policy.MinHTLC = known.MinHTLC
+		}
+		if update.fields&rapidSyncFeeBase == 0 {
+			policy.FeeBaseMSat = known.FeeBaseMSat
+		}
+		if update.fields&rapidSyncFeeRate == 0 {
+			policy.FeeProportionalMillionths =
+				known.FeeProportionalMillionths
+		}
+	}
+
+	if err := graph.UpdateEdgePolicy(policy); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// readElements reads the big endian encoded fixed size elements from r.
+func readElements(r io.Reader, elements ...interface{}) error {
+	for _, element := range elements {
+		err := binary.Read(r, binary.BigEndian, element)
+		if err != nil {
+			return invalidSnapshot("truncated: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// readBigSize reads a BigSize encoded integer from r.
+func readBigSize(r io.Reader) (uint64, error) {
+	var discriminant uint8
+	if err := readElements(r, &discriminant); err != nil {
+		return 0, err
+	}
+
+	switch discriminant {
+	case 0xfd:
+		var v uint16
+		if err := readElements(r, &v); err != nil {
+			return 0, err
+		}
+		if v < 0xfd {
+			return 0, invalidSnapshot("non-canonical BigSize")
+		}
+		return uint64(v), nil
+
+	case 0xfe:
+		var v uint32
+		if err := readElements(r, &v); err != nil {
+			return 0, err
+		}
+		if v <= 0xffff {
+			return 0, invalidSnapshot("non-canonical BigSize")
+		}
+		return uint64(v), nil
+
+	case 0xff:
+		var v uint64
+		if err := readElements(r, &v); err != nil {
+			return 0, err
+		}
+		if v <= 0xffffffff {
+			return 0, invalidSnapshot("non-canonical BigSize")
+		}
+		return v, nil
+	}
+
+	return uint64(discriminant), nil
+}
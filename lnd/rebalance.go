@@ -0,0 +1,278 @@
+package lnd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+)
+
+const (
+	// maxRebalanceAttempts is the number of routes Rebalance tries before
+	// giving up.
+	maxRebalanceAttempts = 5
+
+	// rebalanceCLTVSlack is the number of blocks added to the time lock
+	// the node requires to settle a payment, so blocks found while the
+	// payment is in flight don't fail it.
+	rebalanceCLTVSlack = 3
+)
+
+// RebalanceAttempt is a route tried by Rebalance.
+type RebalanceAttempt struct {
+	Route *lnrpc.Route `json:"route"`
+
+	// Error is why the payment failed over the route, and FailureSource
+	// the node reporting the failure, if any.
+	Error         string `json:"error,omitempty"`
+	FailureSource string `json:"failure_source,omitempty"`
+}
+
+// RebalanceResult is the outcome of Rebalance.
+type RebalanceResult struct {
+	Succeeded       bool   `json:"succeeded"`
+	PaymentHash     string `json:"payment_hash"`
+	PaymentPreimage string `json:"payment_preimage,omitempty"`
+	Amount          int64  `json:"amount"`
+
+	// FeeMsat is the fee paid to the nodes along the route, in
+	// millisatoshis.
+	FeeMsat int64 `json:"fee_msat"`
+
+	// Error is why the rebalance failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	Attempts []*RebalanceAttempt `json:"attempts"`
+}
+
+// fetchOpenChannel returns the open channel at the channel point.
+func fetchOpenChannel(chanDB *channeldb.DB,
+	chanPoint wire.OutPoint) (*channeldb.OpenChannel, error) {
+
+	channels, err := chanDB.FetchAllChannels()
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if channel.FundingOutpoint == chanPoint {
+			return channel, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find channel %v", chanPoint)
+}
+
+// failedChannel returns the channel the node reporting a failure of the route
+// was unable to forward over.
+func failedChannel(route *routing.Route,
+	source *btcec.PublicKey) (uint64, bool) {
+
+	sourceVertex := routing.NewVertex(source)
+	for i, hop := range route.Hops[:len(route.Hops)-1] {
+		if routing.Vertex(hop.Channel.Node.PubKeyBytes) == sourceVertex {
+			return route.Hops[i+1].Channel.ChannelID, true
+		}
+	}
+
+	return 0, false
+}
+
+// Rebalance moves amt of the local balance of the outgoing channel to the
+// incoming one, by paying an invoice of this node out through the outgoing
+// channel, across the network, and back in through the incoming channel. It
+// tries up to five routes, avoiding channels that failed, paying no more than
+// feeLimit in fees, and deletes the invoice should all of them fail. The lnd
+// here can't split a payment into parts, so amt must fit a single route.
+// Should the peer of the outgoing channel have several channels with this
+// node, the switch may pay out through any of them.
+func Rebalance(outgoing, incoming wire.OutPoint, amt,
+	feeLimit btcutil.Amount) (*RebalanceResult, error) {
+
+	if outgoing == incoming {
+		return nil, errors.New("outgoing and incoming channels must " +
+			"differ")
+	}
+
+	amtMSat := lnwire.NewMSatFromSatoshis(amt)
+	if amtMSat <= 0 || amtMSat > maxPaymentMSat {
+		return nil, fmt.Errorf("invalid rebalance amount: %v", amt)
+	}
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+	s := d.server
+
+	outChan, err := fetchOpenChannel(d.chanDB, outgoing)
+	if err != nil {
+		return nil, err
+	}
+	inChan, err := fetchOpenChannel(d.chanDB, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	// The route leaves through our policy for the outgoing channel, and
+	// comes back through the peer's policy for the incoming one, which it
+	// must have sent us.
+	self := s.identityPriv.PubKey()
+	graph := d.chanDB.ChannelGraph()
+	firstHop, err := channelPolicy(
+		graph, outChan.ShortChanID.ToUint64(), self,
+	)
+	if err != nil {
+		return nil, err
+	}
+	lastHop, err := channelPolicy(
+		graph, inChan.ShortChanID.ToUint64(), inChan.IdentityPub,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// When settling the payment, the link of the incoming channel
+	// requires the time lock delta of our policy for it.
+	ourInPolicy, err := channelPolicy(
+		graph, inChan.ShortChanID.ToUint64(), self,
+	)
+	if err != nil {
+		return nil, err
+	}
+	finalCLTVDelta := ourInPolicy.TimeLockDelta + rebalanceCLTVSlack
+
+	var preimage [32]byte
+	if _, err := rand.Read(preimage[:]); err != nil {
+		return nil, err
+	}
+	paymentHash := sha256.Sum256(preimage[:])
+
+	// All attempts pay the same invoice, which is deleted should none of
+	// them succeed, so failed rebalances don't pile up in the database.
+	result := &RebalanceResult{
+		PaymentHash: hex.EncodeToString(paymentHash[:]),
+		Amount:      int64(amt),
+	}
+	err = s.invoices.AddInvoice(&channeldb.Invoice{
+		Memo:         []byte("rebalance"),
+		CreationDate: time.Now(),
+		Terms: channeldb.ContractTerm{
+			Value:           amtMSat,
+			PaymentPreimage: preimage,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if result.Succeeded {
+			return
+		}
+
+		err := d.deleteUnsettledInvoice(paymentHash)
+		if err != nil {
+			ltndLog.Errorf("Unable to delete rebalance invoice: %v",
+				err)
+		}
+	}()
+
+	_, height, err := d.cc.chainIO.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	ignoredNodes := map[routing.Vertex]struct{}{
+		routing.NewVertex(self): {},
+	}
+	ignoredEdges := make(map[uint64]struct{})
+	for i := 0; i < maxRebalanceAttempts; i++ {
+		// Both channels may be with the same peer, which then just
+		// forwards the payment back.
+		var path []*routing.ChannelHop
+		if !outChan.IdentityPub.IsEqual(inChan.IdentityPub) {
+			path, err = findGraphPath(
+				graph, outChan.IdentityPub, inChan.IdentityPub,
+				amtMSat, ignoredNodes, ignoredEdges,
+			)
+			if err != nil {
+				result.Error = err.Error()
+				break
+			}
+		}
+		path = append([]*routing.ChannelHop{firstHop}, path...)
+		path = append(path, lastHop)
+
+		route, err := buildRoute(
+			amtMSat, path, uint32(height), finalCLTVDelta,
+		)
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+
+		attempt := &RebalanceAttempt{Route: marshallRoute(route)}
+		result.Attempts = append(result.Attempts, attempt)
+
+		if route.TotalFees > lnwire.NewMSatFromSatoshis(feeLimit) {
+			attempt.Error = fmt.Sprintf("fee of %v exceeds the "+
+				"limit of %v", route.TotalFees.ToSatoshis(),
+				feeLimit)
+			result.Error = attempt.Error
+			break
+		}
+
+		_, err = sendToRoute(s, route, paymentHash)
+		if err == nil {
+			result.Succeeded = true
+			result.Error = ""
+			result.PaymentPreimage = hex.EncodeToString(
+				preimage[:],
+			)
+			result.FeeMsat = int64(route.TotalFees)
+
+			err := d.rpcServer.savePayment(
+				route, amtMSat, preimage[:],
+			)
+			if err != nil {
+				ltndLog.Errorf("Unable to save rebalance "+
+					"payment: %v", err)
+			}
+			break
+		}
+
+		attempt.Error = err.Error()
+		result.Error = attempt.Error
+
+		// Retry, avoiding the channel the payment failed at, unless
+		// it's one of the two being rebalanced.
+		fErr, ok := err.(*htlcswitch.ForwardingError)
+		if !ok {
+			break
+		}
+		attempt.FailureSource = hex.EncodeToString(
+			fErr.ErrorSource.SerializeCompressed(),
+		)
+
+		chanID, ok := failedChannel(route, fErr.ErrorSource)
+		if !ok || chanID == lastHop.ChannelID {
+			break
+		}
+		ignoredEdges[chanID] = struct{}{}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,457 @@
+package lnd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/lightninglabs/neutrino"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcd/rpcclient"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcwallet/chain"
+	"github.com/roasbeef/btcwallet/waddrmgr"
+	base "github.com/roasbeef/btcwallet/wallet"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+// recoveryPollInterval is how often RecoverWallet checks whether the wallet
+// has caught up with the transactions that were found.
+const recoveryPollInterval = time.Second
+
+var (
+	// ErrInvalidRecoveryWindow is returned by RecoverWallet if the
+	// recovery window isn't positive.
+	ErrInvalidRecoveryWindow = errors.New("the recovery window must be " +
+		"positive")
+
+	// ErrRecoveryInProgress is returned by RecoverWallet if a recovery is
+	// already running.
+	ErrRecoveryInProgress = errors.New("wallet recovery already in " +
+		"progress")
+)
+
+// recovering is set while a recovery is running.
+var recovering int32
+
+// RecoveryProgress reports the progress of RecoverWallet.
+type RecoveryProgress struct {
+	// ScannedHeight is the height of the last block scanned.
+	ScannedHeight int32 `json:"scanned_height"`
+
+	// BestHeight is the height the scan ends at.
+	BestHeight int32 `json:"best_height"`
+
+	// Round is the number of times the recovery window was extended, as
+	// every time funds are found within the last addresses derived, the
+	// chain is scanned again for the next window of addresses.
+	Round int `json:"round"`
+
+	// FoundAddresses is the number of addresses that received funds.
+	FoundAddresses int `json:"found_addresses"`
+
+	// RecoveredBalance is the balance found so far, in satoshis.
+	RecoveredBalance int64 `json:"recovered_balance"`
+
+	// Finished is true once the wallet has recorded everything found.
+	Finished bool `json:"finished"`
+}
+
+// recoveryBranch is the external or internal branch of the default account of
+// a key scope being recovered. Addresses are derived by their index for the
+// scan, without the wallet handing them out, so the addresses that turn up
+// nothing are never used up.
+type recoveryBranch struct {
+	scope waddrmgr.KeyScope
+
+	// branch is 0 for external addresses and 1 for change.
+	branch uint32
+
+	// next is the index of the address the wallet hands out next, horizon
+	// the index past the last address derived for the scan, and found the
+	// index past the last address found to have received funds.
+	next    uint32
+	horizon uint32
+	found   uint32
+}
+
+// recoveryAddr is an address derived for the scan.
+type recoveryAddr struct {
+	branch *recoveryBranch
+	index  uint32
+}
+
+// recoveryScan tracks the outputs paying to the addresses being recovered,
+// across a scan of the chain.
+type recoveryScan struct {
+	addrs map[string]recoveryAddr
+	found map[string]struct{}
+	utxos map[wire.OutPoint]int64
+}
+
+// processTx accounts the outputs the transaction pays to the watched
+// addresses, and removes the outputs it spends.
+func (s *recoveryScan) processTx(tx *btcutil.Tx) {
+	for _, txIn := range tx.MsgTx().TxIn {
+		delete(s.utxos, txIn.PreviousOutPoint)
+	}
+
+	for i, txOut := range tx.MsgTx().TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			txOut.PkScript, activeNetParams.Params,
+		)
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			addrStr := addr.EncodeAddress()
+			derived, ok := s.addrs[addrStr]
+			if !ok {
+				continue
+			}
+
+			if derived.index >= derived.branch.found {
+				derived.branch.found = derived.index + 1
+			}
+			s.found[addrStr] = struct{}{}
+			s.utxos[wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(i),
+			}] = txOut.Value
+		}
+	}
+}
+
+// balance returns the value of the unspent outputs found.
+func (s *recoveryScan) balance() int64 {
+	var balance int64
+	for _, value := range s.utxos {
+		balance += value
+	}
+
+	return balance
+}
+
+// recoveryBranches returns the external and internal branches of the default
+// account within every default key scope, starting at the addresses the
+// wallet hands out next.
+func recoveryBranches(w *base.Wallet) ([]*recoveryBranch, error) {
+	var branches []*recoveryBranch
+	err := walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		for _, scope := range waddrmgr.DefaultKeyScopes {
+			manager, err := w.Manager.FetchScopedKeyManager(scope)
+			if err != nil {
+				return err
+			}
+			props, err := manager.AccountProperties(
+				ns, waddrmgr.DefaultAccountNum,
+			)
+			if err != nil {
+				return err
+			}
+
+			counts := []uint32{
+				props.ExternalKeyCount, props.InternalKeyCount,
+			}
+			for branch, count := range counts {
+				branches = append(branches, &recoveryBranch{
+					scope:   scope,
+					branch:  uint32(branch),
+					next:    count,
+					horizon: count,
+					found:   count,
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return branches, nil
+}
+
+// deriveRecoveryAddrs derives the next window of addresses of every branch,
+// adding them to those the scan watches.
+func deriveRecoveryAddrs(w *base.Wallet, branches []*recoveryBranch,
+	window int, scan *recoveryScan) ([]btcutil.Address, error) {
+
+	var addrs []btcutil.Address
+	err := walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(waddrmgrNamespaceKey)
+		for _, b := range branches {
+			manager, err := w.Manager.FetchScopedKeyManager(b.scope)
+			if err != nil {
+				return err
+			}
+
+			end := b.horizon + uint32(window)
+			for ; b.horizon < end; b.horizon++ {
+				path := waddrmgr.DerivationPath{
+					Account: waddrmgr.DefaultAccountNum,
+					Branch:  b.branch,
+					Index:   b.horizon,
+				}
+				addr, err := manager.DeriveFromKeyPath(ns, path)
+				if err != nil {
+					return err
+				}
+
+				addrStr := addr.Address().EncodeAddress()
+				scan.addrs[addrStr] = recoveryAddr{
+					branch: b,
+					index:  b.horizon,
+				}
+				addrs = append(addrs, addr.Address())
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return addrs, nil
+}
+
+// useRecoveredAddrs has the wallet hand out every address of the branches up
+// to the last one found to have received funds, so it watches them from now
+// on, and never hands them out again.
+func useRecoveredAddrs(w *base.Wallet, branches []*recoveryBranch) error {
+	db := w.Database()
+	return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(waddrmgrNamespaceKey)
+		for _, b := range branches {
+			if b.found <= b.next {
+				continue
+			}
+
+			manager, err := w.Manager.FetchScopedKeyManager(b.scope)
+			if err != nil {
+				return err
+			}
+
+			count := b.found - b.next
+			if b.branch == 0 {
+				_, err = manager.NextExternalAddresses(
+					ns, waddrmgr.DefaultAccountNum, count,
+				)
+			} else {
+				_, err = manager.NextInternalAddresses(
+					ns, waddrmgr.DefaultAccountNum, count,
+				)
+			}
+			if err != nil {
+				return err
+			}
+			b.next = b.found
+		}
+
+		return nil
+	})
+}
+
+// RecoverWallet scans the chain from startHeight for funds sent to addresses
+// of the wallet's seed, which a wallet restored from a seed doesn't know of
+// yet. The first recoveryWindow external and internal addresses of every key
+// scope past those the wallet handed out are derived, and whenever funds are
+// found on them, the window is extended and the chain scanned again. As
+// btcwallet's recovery window, the addresses are only derived for the scan:
+// the wallet is then made to hand out those up to the last one that received
+// funds, and rescanned from startHeight, so it records the transactions found.
+// Progress is published on the event bus.
+func RecoverWallet(recoveryWindow int, startHeight int32) error {
+
+	if recoveryWindow <= 0 {
+		return ErrInvalidRecoveryWindow
+	}
+
+	if !atomic.CompareAndSwapInt32(&recovering, 0, 1) {
+		return ErrRecoveryInProgress
+	}
+	defer atomic.StoreInt32(&recovering, 0)
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return ErrDaemonNotRunning
+	}
+
+	w := d.internalWallet()
+
+	chainClient, ok := w.ChainClient().(*chain.NeutrinoClient)
+	if !ok {
+		return fmt.Errorf("unable to recover using chain backend %T",
+			w.ChainClient())
+	}
+
+	bestHash, bestHeight, err := d.cc.chainIO.GetBestBlock()
+	if err != nil {
+		return err
+	}
+	if startHeight < 0 || startHeight > bestHeight {
+		return fmt.Errorf("start height %d is beyond the best block %d",
+			startHeight, bestHeight)
+	}
+
+	branches, err := recoveryBranches(w)
+	if err != nil {
+		return err
+	}
+
+	scan := &recoveryScan{addrs: make(map[string]recoveryAddr)}
+	var watchAddrs []btcutil.Address
+	for round := 1; ; round++ {
+		newAddrs, err := deriveRecoveryAddrs(
+			w, branches, recoveryWindow, scan,
+		)
+		if err != nil {
+			return err
+		}
+		watchAddrs = append(watchAddrs, newAddrs...)
+
+		// Every round scans for all addresses derived so far, so
+		// that spends of earlier outputs are accounted for.
+		foundBefore := len(scan.found)
+		scan.found = make(map[string]struct{})
+		scan.utxos = make(map[wire.OutPoint]int64)
+
+		onBlock := func(height int32, header *wire.BlockHeader,
+			txs []*btcutil.Tx) {
+
+			for _, tx := range txs {
+				scan.processTx(tx)
+			}
+
+			publishEvent(EventRecovery, &RecoveryProgress{
+				ScannedHeight:    height,
+				BestHeight:       bestHeight,
+				Round:            round,
+				FoundAddresses:   len(scan.found),
+				RecoveredBalance: scan.balance(),
+			})
+		}
+
+		err = chainClient.CS.Rescan(
+			neutrino.StartBlock(&waddrmgr.BlockStamp{
+				Height: startHeight,
+			}),
+			neutrino.EndBlock(&waddrmgr.BlockStamp{
+				Hash:   *bestHash,
+				Height: bestHeight,
+			}),
+			neutrino.WatchAddrs(watchAddrs...),
+			neutrino.QuitChan(d.quit),
+			neutrino.NotificationHandlers(
+				rpcclient.NotificationHandlers{
+					OnFilteredBlockConnected: onBlock,
+				},
+			),
+		)
+		if err != nil {
+			return err
+		}
+
+		// Stop once the last window derived turned up nothing.
+		if len(scan.found) == foundBefore {
+			break
+		}
+	}
+
+	if len(scan.found) > 0 {
+		if err := useRecoveredAddrs(w, branches); err != nil {
+			return err
+		}
+		if err := rescanWallet(d, w, startHeight); err != nil {
+			return err
+		}
+	}
+
+	balance, err := w.CalculateBalance(0)
+	if err != nil {
+		return err
+	}
+
+	publishEvent(EventRecovery, &RecoveryProgress{
+		ScannedHeight:    bestHeight,
+		BestHeight:       bestHeight,
+		FoundAddresses:   len(scan.found),
+		RecoveredBalance: int64(balance),
+		Finished:         true,
+	})
+
+	return nil
+}
+
+// rescanWallet rescans the chain from startHeight for all active addresses
+// and unspent outputs of the wallet, waiting for it to catch up again.
+func rescanWallet(d *daemon, w *base.Wallet, startHeight int32) error {
+	addrStrs, err := w.SortedActivePaymentAddresses()
+	if err != nil {
+		return err
+	}
+	addrs := make([]btcutil.Address, 0, len(addrStrs))
+	for _, addrStr := range addrStrs {
+		addr, err := btcutil.DecodeAddress(
+			addrStr, activeNetParams.Params,
+		)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	unspent, err := w.ListUnspent(0, math.MaxInt32, nil)
+	if err != nil {
+		return err
+	}
+	outPoints := make([]*wire.OutPoint, 0, len(unspent))
+	for _, utxo := range unspent {
+		hash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return err
+		}
+		outPoints = append(outPoints, wire.NewOutPoint(hash, utxo.Vout))
+	}
+
+	startHash, err := d.cc.chainIO.GetBlockHash(int64(startHeight))
+	if err != nil {
+		return err
+	}
+
+	// The wallet marks itself synced again once the rescan has caught up
+	// with the chain.
+	w.SetChainSynced(false)
+	err = <-w.SubmitRescan(&base.RescanJob{
+		Addrs:     addrs,
+		OutPoints: outPoints,
+		BlockStamp: waddrmgr.BlockStamp{
+			Hash:   *startHash,
+			Height: startHeight,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for !w.ChainSynced() {
+		select {
+		case <-time.After(recoveryPollInterval):
+		case <-d.quit:
+			return ErrDaemonNotRunning
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package lnd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+)
+
+// TestRecoveryScan checks that the scan records the outputs paying to the
+// derived addresses along with the last index found on their branch, and
+// forgets the outputs once spent.
+func TestRecoveryScan(t *testing.T) {
+	branch := &recoveryBranch{next: 2, horizon: 12, found: 2}
+
+	var addrs []btcutil.Address
+	scan := &recoveryScan{
+		addrs: make(map[string]recoveryAddr),
+		found: make(map[string]struct{}),
+		utxos: make(map[wire.OutPoint]int64),
+	}
+	for i := uint32(2); i < branch.horizon; i++ {
+		hash := bytes.Repeat([]byte{byte(i)}, 20)
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(
+			hash, activeNetParams.Params,
+		)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		addrs = append(addrs, addr)
+		scan.addrs[addr.EncodeAddress()] = recoveryAddr{
+			branch: branch,
+			index:  i,
+		}
+	}
+
+	pay := wire.NewMsgTx(2)
+	pay.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	for i, value := range []int64{1000, 2000} {
+		// Pay to the addresses at index 4 and 7.
+		pkScript, err := txscript.PayToAddrScript(addrs[2+i*3])
+		if err != nil {
+			t.Fatalf("unable to create script: %v", err)
+		}
+		pay.AddTxOut(wire.NewTxOut(value, pkScript))
+	}
+	scan.processTx(btcutil.NewTx(pay))
+
+	if branch.found != 8 {
+		t.Fatalf("expected found index 8, got %d", branch.found)
+	}
+	if branch.next != 2 {
+		t.Fatalf("scan handed out addresses")
+	}
+	if len(scan.found) != 2 || scan.balance() != 3000 {
+		t.Fatalf("expected 2 addresses holding 3000, got %d holding %d",
+			len(scan.found), scan.balance())
+	}
+
+	spend := wire.NewMsgTx(2)
+	spend.AddTxIn(wire.NewTxIn(
+		&wire.OutPoint{Hash: pay.TxHash(), Index: 1}, nil, nil,
+	))
+	scan.processTx(btcutil.NewTx(spend))
+
+	if scan.balance() != 1000 {
+		t.Fatalf("expected 1000 left, got %d", scan.balance())
+	}
+}
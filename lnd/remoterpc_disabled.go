@@ -0,0 +1,21 @@
+// +build noremoterpc
+
+package lnd
+
+import "errors"
+
+// ErrRemoteRPCUnavailable is returned when pairing a remote app with a build
+// that doesn't include the TLS gRPC listeners.
+var ErrRemoteRPCUnavailable = errors.New("this build doesn't support " +
+	"remote RPC access")
+
+// startRPCListeners is a no-op, as the TLS gRPC listeners were stripped from
+// this build through the noremoterpc build tag.
+func (d *daemon) startRPCListeners() error {
+	return nil
+}
+
+// LndConnectURI always fails, as remote apps can't connect to this build.
+func LndConnectURI(host string, perms []string) (string, error) {
+	return "", ErrRemoteRPCUnavailable
+}
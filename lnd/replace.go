@@ -0,0 +1,288 @@
+package lnd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+	base "github.com/roasbeef/btcwallet/wallet"
+	"github.com/roasbeef/btcwallet/walletdb"
+	"github.com/roasbeef/btcwallet/wtxmgr"
+)
+
+// rbfSequence is the sequence of the inputs of the transactions the wallet
+// publishes, signalling they may be replaced by a higher fee version (BIP 125).
+const rbfSequence = wire.MaxTxInSequenceNum - 2
+
+// ErrNotReplaceable is returned by ReplaceTransaction for a transaction that
+// isn't an unconfirmed transaction of the wallet signalling replaceability.
+var ErrNotReplaceable = errors.New("transaction isn't a replaceable, " +
+	"unconfirmed transaction of the wallet")
+
+// ErrNoChange is returned by ReplaceTransaction for a transaction without a
+// change output to pay the higher fee from.
+var ErrNoChange = errors.New("transaction has no change output to pay the " +
+	"higher fee from")
+
+// Replacement describes a transaction that replaced an unconfirmed one at a
+// higher fee. Fees are in satoshis.
+type Replacement struct {
+	Txid         string `json:"txid"`
+	ReplacedTxid string `json:"replaced_txid"`
+	Fee          int64  `json:"fee"`
+	ReplacedFee  int64  `json:"replaced_fee"`
+	VSize        int64  `json:"vsize"`
+}
+
+// walletCoin returns the wallet output at the outpoint as a coin that may be
+// signed for.
+func walletCoin(f *fundingWallet, op wire.OutPoint) (*lnwallet.Utxo, error) {
+	output, err := f.FetchInputInfo(&op)
+	if err != nil {
+		return nil, err
+	}
+
+	coin := &lnwallet.Utxo{
+		Value:    btcutil.Amount(output.Value),
+		PkScript: output.PkScript,
+		OutPoint: op,
+	}
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(output.PkScript):
+		coin.AddressType = lnwallet.WitnessPubKey
+	case txscript.IsPayToScriptHash(output.PkScript):
+		coin.AddressType = lnwallet.NestedWitnessPubKey
+	default:
+		return nil, ErrNotReplaceable
+	}
+
+	return coin, nil
+}
+
+// ReplaceTransaction replaces the unconfirmed transaction of the wallet with
+// one paying satPerByte satoshis per vbyte, or a rate estimated to confirm
+// within six blocks if it is zero. The replacement spends the same inputs to
+// the same outputs, paying the higher fee out of the change. The replaced
+// transaction leaves the wallet as the replacement is recorded. Only
+// transactions published by SendCoins, which signal replaceability, can be
+// replaced.
+func ReplaceTransaction(txid chainhash.Hash,
+	satPerByte int64) (*Replacement, error) {
+
+	f, feeRate, err := bumpFeeParams(satPerByte)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold off sends, so the replaced inputs can't be spent meanwhile.
+	f.sendMtx.Lock()
+	defer f.sendMtx.Unlock()
+
+	details, err := base.UnstableAPI(f.InternalWallet()).TxDetails(&txid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only unconfirmed transactions spending nothing but the wallet's
+	// outputs can be replaced, as the fee of any other isn't known.
+	switch {
+	case details == nil, details.Block.Height != -1,
+		len(details.Debits) != len(details.MsgTx.TxIn):
+
+		return nil, ErrNotReplaceable
+	}
+
+	signalled := false
+	for _, txIn := range details.MsgTx.TxIn {
+		if txIn.Sequence < wire.MaxTxInSequenceNum-1 {
+			signalled = true
+		}
+	}
+	if !signalled {
+		return nil, ErrNotReplaceable
+	}
+
+	var replacedFee btcutil.Amount
+	for _, debit := range details.Debits {
+		replacedFee += debit.Amount
+	}
+	for _, txOut := range details.MsgTx.TxOut {
+		replacedFee -= btcutil.Amount(txOut.Value)
+	}
+
+	changeIndex := -1
+	for _, credit := range details.Credits {
+		if credit.Change {
+			changeIndex = int(credit.Index)
+			break
+		}
+	}
+	if changeIndex == -1 {
+		return nil, ErrNoChange
+	}
+
+	var weight lnwallet.TxWeightEstimator
+	tx := wire.NewMsgTx(details.MsgTx.Version)
+	inputs := make(map[wire.OutPoint]*lnwallet.Utxo)
+	for _, txIn := range details.MsgTx.TxIn {
+		coin, err := walletCoin(f, txIn.PreviousOutPoint)
+		if err != nil {
+			return nil, err
+		}
+		if coin.AddressType == lnwallet.WitnessPubKey {
+			weight.AddP2WKHInput()
+		} else {
+			weight.AddNestedP2WKHInput()
+		}
+
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: coin.OutPoint,
+			Sequence:         rbfSequence,
+		})
+		inputs[coin.OutPoint] = coin
+	}
+	for _, txOut := range details.MsgTx.TxOut {
+		addOutputWeight(&weight, txOut.PkScript)
+		tx.AddTxOut(wire.NewTxOut(txOut.Value, txOut.PkScript))
+	}
+
+	// The replacement must pay more than the replaced transaction, and
+	// at least the minimum relay fee on top of it.
+	vsize := int64(weight.VSize())
+	fee := feeRate.FeeForVSize(vsize)
+	if fee <= replacedFee {
+		return nil, ErrFeeRateTooLow
+	}
+	if minFee := replacedFee + btcutil.Amount(vsize); fee < minFee {
+		fee = minFee
+	}
+
+	// Pay the higher fee out of the change, leaving change too small to
+	// be relayed to the miners.
+	change := tx.TxOut[changeIndex]
+	extraFee := fee - replacedFee
+	switch {
+	case btcutil.Amount(change.Value) < extraFee:
+		return nil, insufficientCoinsError{
+			needed:    extraFee,
+			available: btcutil.Amount(change.Value),
+		}
+
+	case btcutil.Amount(change.Value)-extraFee <
+		walletDustLimit(len(change.PkScript)):
+
+		fee = replacedFee + btcutil.Amount(change.Value)
+		tx.TxOut = append(
+			tx.TxOut[:changeIndex], tx.TxOut[changeIndex+1:]...,
+		)
+
+	default:
+		change.Value -= int64(extraFee)
+	}
+
+	if err := f.signTx(tx, inputs); err != nil {
+		return nil, err
+	}
+
+	// The outputs of the wallet are those of the replaced transaction,
+	// less the change if it was dropped.
+	dropped := len(tx.TxOut) < len(details.MsgTx.TxOut)
+	credits := make([]wtxmgr.CreditRecord, 0, len(details.Credits))
+	for _, credit := range details.Credits {
+		switch {
+		case dropped && int(credit.Index) == changeIndex:
+			continue
+		case dropped && int(credit.Index) > changeIndex:
+			credit.Index--
+		}
+		credits = append(credits, credit)
+	}
+
+	if err := publishReplacement(f, details, tx, credits); err != nil {
+		return nil, err
+	}
+
+	return &Replacement{
+		Txid:         tx.TxHash().String(),
+		ReplacedTxid: txid.String(),
+		Fee:          int64(fee),
+		ReplacedFee:  int64(replacedFee),
+		VSize:        vsize,
+	}, nil
+}
+
+// wtxmgrNamespaceKey is the bucket of the wallet database btcwallet keeps its
+// transaction store in.
+var wtxmgrNamespaceKey = []byte("wtxmgr")
+
+// swapUnminedTx replaces the unmined transaction old with rec in the wallet's
+// transaction store within a single database transaction, so the outputs both
+// spend or create are never counted twice. rec is recorded with the passed
+// credits, which the store otherwise only learns of once it confirms.
+func swapUnminedTx(db walletdb.DB, store *wtxmgr.Store,
+	old, rec *wtxmgr.TxRecord, credits []wtxmgr.CreditRecord) error {
+
+	return walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		if err := store.RemoveUnminedTx(ns, old); err != nil {
+			return err
+		}
+		if err := store.InsertTx(ns, rec, nil); err != nil {
+			return err
+		}
+
+		for _, credit := range credits {
+			err := store.AddCredit(
+				ns, rec, nil, credit.Index, credit.Change,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// publishReplacement records the replacement in place of the replaced
+// transaction, then broadcasts it. Should the broadcast fail, the replaced
+// transaction is restored.
+func publishReplacement(f *fundingWallet, replaced *wtxmgr.TxDetails,
+	tx *wire.MsgTx, credits []wtxmgr.CreditRecord) error {
+
+	w := f.InternalWallet()
+	chainClient := w.ChainClient()
+	if chainClient == nil {
+		return errors.New("wallet isn't connected to a chain backend")
+	}
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	err = swapUnminedTx(
+		w.Database(), w.TxStore, &replaced.TxRecord, rec, credits,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := chainClient.SendRawTransaction(tx, false); err != nil {
+		restoreErr := swapUnminedTx(
+			w.Database(), w.TxStore, rec, &replaced.TxRecord,
+			replaced.Credits,
+		)
+		if restoreErr != nil {
+			ltndLog.Errorf("Unable to restore replaced "+
+				"transaction %v: %v", replaced.Hash,
+				restoreErr)
+		}
+		return err
+	}
+
+	return nil
+}
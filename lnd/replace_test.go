@@ -0,0 +1,128 @@
+package lnd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/roasbeef/btcd/chaincfg"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcwallet/walletdb"
+	_ "github.com/roasbeef/btcwallet/walletdb/bdb"
+	"github.com/roasbeef/btcwallet/wtxmgr"
+)
+
+// testTxRecord returns the record of a transaction spending the outpoint to
+// outputs of the given values.
+func testTxRecord(t *testing.T, spends wire.OutPoint,
+	values ...int64) *wtxmgr.TxRecord {
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&spends, nil, nil))
+	for _, value := range values {
+		tx.AddTxOut(wire.NewTxOut(value, []byte{0x00, 0x14}))
+	}
+
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(tx, time.Now())
+	if err != nil {
+		t.Fatalf("unable to create tx record: %v", err)
+	}
+	return rec
+}
+
+// TestSwapUnminedTx checks that a replaced transaction leaves the wallet's
+// transaction store, along with its outputs, as its replacement is recorded.
+func TestSwapUnminedTx(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swapunmined")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := walletdb.Create("bdb", filepath.Join(dir, "wallet.db"))
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	defer db.Close()
+
+	var store *wtxmgr.Store
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns, err := tx.CreateTopLevelBucket(wtxmgrNamespaceKey)
+		if err != nil {
+			return err
+		}
+		if err := wtxmgr.Create(ns); err != nil {
+			return err
+		}
+		store, err = wtxmgr.Open(ns, &chaincfg.TestNet3Params)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to create store: %v", err)
+	}
+
+	// The parent pays the wallet, the replaced transaction spends its
+	// output paying 5000 with 4000 change, and the replacement pays a
+	// higher fee out of the change.
+	parent := testTxRecord(t, wire.OutPoint{Index: 7}, 10000)
+	replaced := testTxRecord(t, wire.OutPoint{Hash: parent.Hash}, 5000,
+		4000)
+	replacement := testTxRecord(t, wire.OutPoint{Hash: parent.Hash},
+		5000, 3000)
+
+	err = walletdb.Update(db, func(tx walletdb.ReadWriteTx) error {
+		ns := tx.ReadWriteBucket(wtxmgrNamespaceKey)
+		for _, rec := range []*wtxmgr.TxRecord{parent, replaced} {
+			if err := store.InsertTx(ns, rec, nil); err != nil {
+				return err
+			}
+		}
+		err := store.AddCredit(ns, parent, nil, 0, false)
+		if err != nil {
+			return err
+		}
+		return store.AddCredit(ns, replaced, nil, 1, true)
+	})
+	if err != nil {
+		t.Fatalf("unable to insert transactions: %v", err)
+	}
+
+	credits := []wtxmgr.CreditRecord{{Index: 1, Change: true}}
+	err = swapUnminedTx(db, store, replaced, replacement, credits)
+	if err != nil {
+		t.Fatalf("unable to swap transactions: %v", err)
+	}
+
+	err = walletdb.View(db, func(tx walletdb.ReadTx) error {
+		ns := tx.ReadBucket(wtxmgrNamespaceKey)
+
+		details, err := store.TxDetails(ns, &replaced.Hash)
+		if err != nil {
+			return err
+		}
+		if details != nil {
+			t.Fatalf("replaced transaction still in the store")
+		}
+
+		unspent, err := store.UnspentOutputs(ns)
+		if err != nil {
+			return err
+		}
+		if len(unspent) != 1 {
+			t.Fatalf("expected a single unspent output, got %d",
+				len(unspent))
+		}
+		op := wire.OutPoint{Hash: replacement.Hash, Index: 1}
+		if unspent[0].OutPoint != op || unspent[0].Amount != 3000 {
+			t.Fatalf("expected 3000 at %v, got %v at %v", op,
+				unspent[0].Amount, unspent[0].OutPoint)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to read store: %v", err)
+	}
+}
@@ -0,0 +1,350 @@
+package lnd
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/bbolt"
+	"github.com/lightningnetwork/lightning-onion"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// The router here only pays invoices along paths of its own choosing. The
+// functions below build routes along given channels, and send payments over
+// them, for payments the router can't make, such as circular ones.
+
+// ErrNoPath is returned when no path through the graph can carry a payment.
+var ErrNoPath = errors.New("unable to find a path through the graph")
+
+// pathVertex is a node on the heap of findGraphPath, along with its distance
+// from the source.
+type pathVertex struct {
+	node *channeldb.LightningNode
+	dist int64
+}
+
+// pathHeap is a min-heap of nodes by their distance from the source.
+type pathHeap []pathVertex
+
+func (h pathHeap) Len() int            { return len(h) }
+func (h pathHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h pathHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pathHeap) Push(x interface{}) { *h = append(*h, x.(pathVertex)) }
+func (h *pathHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// pathEdgeWeight is the weight of forwarding amt over the edge, which, as with
+// the router, favours lower fees, and then shorter time locks.
+func pathEdgeWeight(amt lnwire.MilliSatoshi,
+	policy *channeldb.ChannelEdgePolicy) int64 {
+
+	fee := int64(forwardingFee(amt, policy))
+	return fee*fee + 1 + int64(policy.TimeLockDelta)
+}
+
+// forwardingFee is the fee the node publishing the policy charges to forward
+// amt over its channel.
+func forwardingFee(amt lnwire.MilliSatoshi,
+	policy *channeldb.ChannelEdgePolicy) lnwire.MilliSatoshi {
+
+	return policy.FeeBaseMSat +
+		(amt*policy.FeeProportionalMillionths)/1000000
+}
+
+// findGraphPath finds the cheapest path of channels from the source to the
+// target node able to carry amt, skipping the ignored nodes and channels. Each
+// hop of the path holds the policy of the node forwarding over it.
+func findGraphPath(graph *channeldb.ChannelGraph, source,
+	target *btcec.PublicKey, amt lnwire.MilliSatoshi,
+	ignoredNodes map[routing.Vertex]struct{},
+	ignoredEdges map[uint64]struct{}) ([]*routing.ChannelHop, error) {
+
+	tx, err := graph.Database().Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sourceNode, err := graph.FetchLightningNode(source)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceVertex := routing.NewVertex(source)
+	targetVertex := routing.NewVertex(target)
+
+	type prevHop struct {
+		hop  *routing.ChannelHop
+		from routing.Vertex
+	}
+
+	dist := map[routing.Vertex]int64{sourceVertex: 0}
+	prev := make(map[routing.Vertex]prevHop)
+	visited := make(map[routing.Vertex]struct{})
+
+	nodeHeap := &pathHeap{{node: sourceNode}}
+	for nodeHeap.Len() != 0 {
+		best := heap.Pop(nodeHeap).(pathVertex)
+		pivot := routing.Vertex(best.node.PubKeyBytes)
+		if _, ok := visited[pivot]; ok {
+			continue
+		}
+		visited[pivot] = struct{}{}
+
+		if pivot == targetVertex {
+			break
+		}
+
+		err := best.node.ForEachChannel(tx, func(_ *bolt.Tx,
+			info *channeldb.ChannelEdgeInfo,
+			outEdge, _ *channeldb.ChannelEdgePolicy) error {
+
+			v := routing.Vertex(outEdge.Node.PubKeyBytes)
+
+			flags := outEdge.Flags
+			if flags&lnwire.ChanUpdateDisabled != 0 {
+				return nil
+			}
+			if _, ok := ignoredNodes[v]; ok {
+				return nil
+			}
+			if _, ok := ignoredEdges[outEdge.ChannelID]; ok {
+				return nil
+			}
+			if info.Capacity < amt.ToSatoshis() ||
+				amt < outEdge.MinHTLC {
+
+				return nil
+			}
+
+			tempDist := best.dist + pathEdgeWeight(amt, outEdge)
+			if d, ok := dist[v]; ok && d <= tempDist {
+				return nil
+			}
+
+			dist[v] = tempDist
+			prev[v] = prevHop{
+				hop: &routing.ChannelHop{
+					ChannelEdgePolicy: outEdge,
+					Capacity:          info.Capacity,
+					Chain:             info.ChainHash,
+				},
+				from: pivot,
+			}
+			heap.Push(nodeHeap, pathVertex{
+				node: outEdge.Node,
+				dist: tempDist,
+			})
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := prev[targetVertex]; !ok {
+		return nil, ErrNoPath
+	}
+
+	var path []*routing.ChannelHop
+	for v := targetVertex; v != sourceVertex; v = prev[v].from {
+		path = append([]*routing.ChannelHop{prev[v].hop}, path...)
+	}
+
+	return path, nil
+}
+
+// channelPolicy returns the hop over the channel forwarded by the node, with
+// the node's policy for it.
+func channelPolicy(graph *channeldb.ChannelGraph, chanID uint64,
+	from *btcec.PublicKey) (*routing.ChannelHop, error) {
+
+	info, policy1, policy2, err := graph.FetchChannelEdgesByID(chanID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := policy1
+	if !bytes.Equal(info.NodeKey1Bytes[:], from.SerializeCompressed()) {
+		policy = policy2
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("no policy known for channel %v",
+			lnwire.NewShortChanIDFromInt(chanID))
+	}
+
+	return &routing.ChannelHop{
+		ChannelEdgePolicy: policy,
+		Capacity:          info.Capacity,
+		Chain:             info.ChainHash,
+	}, nil
+}
+
+// channelBetween returns the hop over the cheapest channel the node can
+// forward amt over to the peer, other than the ignored ones, with the node's
+// policy for it, or nil if there's none.
+func channelBetween(graph *channeldb.ChannelGraph, from,
+	to *btcec.PublicKey, amt lnwire.MilliSatoshi,
+	ignoredEdges map[uint64]struct{}) (*routing.ChannelHop, error) {
+
+	node, err := graph.FetchLightningNode(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toVertex := routing.NewVertex(to)
+
+	var (
+		best       *routing.ChannelHop
+		bestWeight int64
+	)
+	err = node.ForEachChannel(nil, func(_ *bolt.Tx,
+		info *channeldb.ChannelEdgeInfo,
+		outEdge, _ *channeldb.ChannelEdgePolicy) error {
+
+		if routing.Vertex(outEdge.Node.PubKeyBytes) != toVertex {
+			return nil
+		}
+		if _, ok := ignoredEdges[outEdge.ChannelID]; ok {
+			return nil
+		}
+		if outEdge.Flags&lnwire.ChanUpdateDisabled != 0 ||
+			info.Capacity < amt.ToSatoshis() ||
+			amt < outEdge.MinHTLC {
+
+			return nil
+		}
+
+		weight := pathEdgeWeight(amt, outEdge)
+		if best == nil || weight < bestWeight {
+			best = &routing.ChannelHop{
+				ChannelEdgePolicy: outEdge,
+				Capacity:          info.Capacity,
+				Chain:             info.ChainHash,
+			}
+			bestWeight = weight
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return best, nil
+}
+
+// buildRoute returns the route paying amt over the path, whose first hop
+// leaves this node, to the last node of the path, with finalCLTVDelta blocks
+// for it to settle in. Each node forwards for the fee and time lock delta of
+// its policy for the next hop.
+func buildRoute(amt lnwire.MilliSatoshi, path []*routing.ChannelHop,
+	height uint32, finalCLTVDelta uint16) (*routing.Route, error) {
+
+	if len(path) == 0 {
+		return nil, errors.New("empty path")
+	}
+	if len(path) > routing.HopLimit {
+		return nil, fmt.Errorf("path of %v hops exceeds the limit of %v",
+			len(path), routing.HopLimit)
+	}
+
+	route := &routing.Route{
+		Hops: make([]*routing.Hop, len(path)),
+	}
+
+	// Walk back from the last hop, adding the fee and time lock delta of
+	// every node forwarding the payment.
+	amtToForward := amt
+	timeLock := height + uint32(finalCLTVDelta)
+	for i := len(path) - 1; i >= 0; i-- {
+		hop := &routing.Hop{
+			Channel:          path[i],
+			AmtToForward:     amtToForward,
+			OutgoingTimeLock: timeLock,
+		}
+		if i < len(path)-1 {
+			policy := path[i+1].ChannelEdgePolicy
+			hop.Fee = forwardingFee(amtToForward, policy)
+			timeLock += uint32(policy.TimeLockDelta)
+		}
+		amtToForward += hop.Fee
+
+		if amtToForward.ToSatoshis() > path[i].Capacity {
+			return nil, fmt.Errorf("channel %v has insufficient "+
+				"capacity for the payment: need %v, have %v",
+				lnwire.NewShortChanIDFromInt(path[i].ChannelID),
+				amtToForward.ToSatoshis(), path[i].Capacity)
+		}
+
+		route.TotalFees += hop.Fee
+		route.Hops[i] = hop
+	}
+	route.TotalAmount = amtToForward
+	route.TotalTimeLock = timeLock
+
+	return route, nil
+}
+
+// sendToRoute sends the payment with the hash over the route, returning the
+// preimage once it settles. A payment failed along the route returns a
+// *htlcswitch.ForwardingError.
+func sendToRoute(s *server, route *routing.Route,
+	paymentHash [32]byte) ([32]byte, error) {
+
+	nodes := make([]*btcec.PublicKey, len(route.Hops))
+	for i, hop := range route.Hops {
+		pub, err := btcec.ParsePubKey(
+			hop.Channel.Node.PubKeyBytes[:], btcec.S256(),
+		)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		nodes[i] = pub
+	}
+
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	packet, err := sphinx.NewOnionPacket(
+		nodes, sessionKey, route.ToHopPayloads(), paymentHash[:],
+	)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	htlcAdd := &lnwire.UpdateAddHTLC{
+		Amount:      route.TotalAmount,
+		Expiry:      route.TotalTimeLock,
+		PaymentHash: paymentHash,
+	}
+
+	var onionBlob bytes.Buffer
+	if err := packet.Encode(&onionBlob); err != nil {
+		return [32]byte{}, err
+	}
+	copy(htlcAdd.OnionBlob[:], onionBlob.Bytes())
+
+	circuit := &sphinx.Circuit{
+		SessionKey:  sessionKey,
+		PaymentPath: nodes,
+	}
+
+	// Going through sendToSwitch records the attempt should the hash be
+	// of a payment sent through SendPaymentV2.
+	firstHop := route.Hops[0].Channel.Node.PubKeyBytes
+	return s.sendToSwitch(firstHop, htlcAdd, circuit)
+}
@@ -375,8 +375,10 @@ func addrPairsToOutputs(addrPairs map[string]int64) ([]*wire.TxOut, error) {
 
 // sendCoinsOnChain makes an on-chain transaction in or to send coins to one or
 // more addresses specified in the passed payment map. The payment map maps an
-// address to a specified output value to be sent to that address.
-func (r *rpcServer) sendCoinsOnChain(paymentMap map[string]int64,
+// address to a specified output value to be sent to that address. The coins
+// are selected as asked by the coin control passed with ctx, if any.
+func (r *rpcServer) sendCoinsOnChain(ctx context.Context,
+	paymentMap map[string]int64,
 	feeRate lnwallet.SatPerVByte) (*chainhash.Hash, error) {
 
 	outputs, err := addrPairsToOutputs(paymentMap)
@@ -384,7 +386,8 @@ func (r *rpcServer) sendCoinsOnChain(paymentMap map[string]int64,
 		return nil, err
 	}
 
-	return r.server.cc.wallet.SendOutputs(outputs, feeRate)
+	w := r.server.cc.wallet.WalletController.(*fundingWallet)
+	return w.sendOutputs(outputs, feeRate, coinControlFromContext(ctx))
 }
 
 // determineFeePerVSize will determine the fee in sat/vbyte that should be paid
@@ -443,7 +446,7 @@ func (r *rpcServer) SendCoins(ctx context.Context,
 		in.Addr, btcutil.Amount(in.Amount), int64(feeRate))
 
 	paymentMap := map[string]int64{in.Addr: in.Amount}
-	txid, err := r.sendCoinsOnChain(paymentMap, feeRate)
+	txid, err := r.sendCoinsOnChain(ctx, paymentMap, feeRate)
 	if err != nil {
 		return nil, err
 	}
@@ -470,7 +473,7 @@ func (r *rpcServer) SendMany(ctx context.Context,
 	rpcsLog.Infof("[sendmany] outputs=%v, sat/vbyte=%v",
 		spew.Sdump(in.AddrToAmount), int64(feeRate))
 
-	txid, err := r.sendCoinsOnChain(in.AddrToAmount, feeRate)
+	txid, err := r.sendCoinsOnChain(ctx, in.AddrToAmount, feeRate)
 	if err != nil {
 		return nil, err
 	}
@@ -723,14 +726,6 @@ func (r *rpcServer) OpenChannel(in *lnrpc.OpenChannelRequest,
 			"state must be below the local funding amount")
 	}
 
-	// Ensure that the user doesn't exceed the current soft-limit for
-	// channel size. If the funding amount is above the soft-limit, then
-	// we'll reject the request.
-	if localFundingAmt > maxFundingAmount {
-		return fmt.Errorf("funding amount is too large, the max "+
-			"channel size is: %v", maxFundingAmount)
-	}
-
 	// Restrict the size of the channel we'll actually open. At a later
 	// level, we'll ensure that the output we create after accounting for
 	// fees that a dust output isn't created.
@@ -767,6 +762,14 @@ func (r *rpcServer) OpenChannel(in *lnrpc.OpenChannelRequest,
 
 	nodePubKeyBytes = nodePubKey.SerializeCompressed()
 
+	// Ensure that the user doesn't exceed the maximum channel size, which
+	// is only above the protocol's soft-limit if the peer supports wumbo
+	// channels.
+	err = checkChanSize(r.server, nodePubKey, localFundingAmt)
+	if err != nil {
+		return err
+	}
+
 	// Based on the passed fee related parameters, we'll determine an
 	// appropriate fee rate for the funding transaction.
 	feeRate, err := determineFeePerVSize(
@@ -786,6 +789,7 @@ func (r *rpcServer) OpenChannel(in *lnrpc.OpenChannelRequest,
 		nodePubKey, localFundingAmt,
 		lnwire.NewMSatFromSatoshis(remoteInitialBalance),
 		minHtlc, feeRate, in.Private, remoteCsvDelay,
+		coinControlFromContext(updateStream.Context()),
 	)
 
 	var outpoint wire.OutPoint
@@ -825,6 +829,8 @@ out:
 
 				break out
 			}
+		case <-updateStream.Context().Done():
+			return updateStream.Context().Err()
 		case <-r.quit:
 			return nil
 		}
@@ -897,6 +903,10 @@ func (r *rpcServer) OpenChannelSync(ctx context.Context,
 		return nil, fmt.Errorf("channel is too small, the minimum channel "+
 			"size is: %v SAT", int64(minChanFundingSize))
 	}
+	err = checkChanSize(r.server, nodepubKey, localFundingAmt)
+	if err != nil {
+		return nil, err
+	}
 
 	// Based on the passed fee related parameters, we'll determine an
 	// appropriate fee rate for the funding transaction.
@@ -914,6 +924,7 @@ func (r *rpcServer) OpenChannelSync(ctx context.Context,
 		nodepubKey, localFundingAmt,
 		lnwire.NewMSatFromSatoshis(remoteInitialBalance),
 		minHtlc, feeRate, in.Private, remoteCsvDelay,
+		coinControlFromContext(ctx),
 	)
 
 	select {
@@ -1016,27 +1027,7 @@ func (r *rpcServer) CloseChannel(in *lnrpc.CloseChannelRequest,
 			return err
 		}
 
-		// As we're force closing this channel, as a precaution, we'll
-		// ensure that the switch doesn't continue to see this channel
-		// as eligible for forwarding HTLC's. If the peer is online,
-		// then we'll also purge all of its indexes.
-		remotePub := &channel.StateSnapshot().RemoteIdentity
-		if peer, err := r.server.FindPeer(remotePub); err == nil {
-			// TODO(roasbeef): actually get the active channel
-			// instead too?
-			//  * so only need to grab from database
-			peer.WipeChannel(channel.ChannelPoint())
-		} else {
-			chanID := lnwire.NewChanIDFromOutPoint(channel.ChannelPoint())
-			r.server.htlcSwitch.RemoveLink(chanID)
-		}
-
-		// With the necessary indexes cleaned up, we'll now force close
-		// the channel.
-		chainArbitrator := r.server.chainArb
-		closingTx, err := chainArbitrator.ForceCloseContract(
-			*chanPoint,
-		)
+		closingTx, err := r.forceCloseChannel(channel)
 		if err != nil {
 			rpcsLog.Errorf("unable to force close transaction: %v", err)
 			return err
@@ -1133,6 +1124,8 @@ out:
 					"txid(%v)", h)
 				break out
 			}
+		case <-updateStream.Context().Done():
+			return updateStream.Context().Err()
 		case <-r.quit:
 			return nil
 		}
@@ -1172,6 +1165,31 @@ func (r *rpcServer) fetchActiveChannel(chanPoint wire.OutPoint) (*lnwallet.Light
 	)
 }
 
+// forceCloseChannel broadcasts the latest commitment transaction of the
+// channel, handing it over to the chain arbitrator to resolve, and returns
+// the transaction.
+func (r *rpcServer) forceCloseChannel(
+	channel *lnwallet.LightningChannel) (*wire.MsgTx, error) {
+
+	// As we're force closing this channel, as a precaution, we'll ensure
+	// that the switch doesn't continue to see this channel as eligible
+	// for forwarding HTLC's. If the peer is online, then we'll also purge
+	// all of its indexes.
+	remotePub := &channel.StateSnapshot().RemoteIdentity
+	if peer, err := r.server.FindPeer(remotePub); err == nil {
+		// TODO(roasbeef): actually get the active channel instead too?
+		//  * so only need to grab from database
+		peer.WipeChannel(channel.ChannelPoint())
+	} else {
+		chanID := lnwire.NewChanIDFromOutPoint(channel.ChannelPoint())
+		r.server.htlcSwitch.RemoveLink(chanID)
+	}
+
+	// With the necessary indexes cleaned up, we'll now force close the
+	// channel.
+	return r.server.chainArb.ForceCloseContract(*channel.ChannelPoint())
+}
+
 // GetInfo returns general information concerning the lightning node including
 // its identity pubkey, alias, the chains it is connected to, and information
 // concerning the number of open+pending channels.
@@ -1534,36 +1552,9 @@ func (r *rpcServer) ListChannels(ctx context.Context,
 			continue
 		}
 
-		nodePub := dbChannel.IdentityPub
-		nodeID := hex.EncodeToString(nodePub.SerializeCompressed())
-		chanPoint := dbChannel.FundingOutpoint
-
-		// With the channel point known, retrieve the network channel
-		// ID from the database.
-		var chanID uint64
-		chanID, _ = graph.ChannelID(&chanPoint)
-
-		var peerOnline bool
-		if _, err := r.server.FindPeer(nodePub); err == nil {
-			peerOnline = true
-		}
-
-		channelID := lnwire.NewChanIDFromOutPoint(&chanPoint)
-		var linkActive bool
-		if link, err := r.server.htlcSwitch.GetLink(channelID); err == nil {
-			// A channel is only considered active if it is known
-			// by the switch *and* able to forward
-			// incoming/outgoing payments.
-			linkActive = link.EligibleToForward()
-		}
-
-		// Next, we'll determine whether we should add this channel to
-		// our list depending on the type of channels requested to us.
-		isActive := peerOnline && linkActive
-		isPublic := dbChannel.ChannelFlags&lnwire.FFAnnounceChannel != 0
-
 		// We'll only skip returning this channel if we were requested
 		// for a specific kind and this channel doesn't satisfy it.
+		isActive, isPublic := r.channelStatus(dbChannel)
 		switch {
 		case in.ActiveOnly && !isActive:
 			continue
@@ -1575,64 +1566,106 @@ func (r *rpcServer) ListChannels(ctx context.Context,
 			continue
 		}
 
-		// As this is required for display purposes, we'll calculate
-		// the weight of the commitment transaction. We also add on the
-		// estimated weight of the witness to calculate the weight of
-		// the transaction if it were to be immediately unilaterally
-		// broadcast.
-		localCommit := dbChannel.LocalCommitment
-		utx := btcutil.NewTx(localCommit.CommitTx)
-		commitBaseWeight := blockchain.GetTransactionWeight(utx)
-		commitWeight := commitBaseWeight + lnwallet.WitnessCommitmentTxWeight
+		resp.Channels = append(
+			resp.Channels, r.marshalChannel(graph, dbChannel),
+		)
+	}
 
-		localBalance := localCommit.LocalBalance
-		remoteBalance := localCommit.RemoteBalance
-
-		// As an artifact of our usage of mSAT internally, either party
-		// may end up in a state where they're holding a fractional
-		// amount of satoshis which can't be expressed within the
-		// actual commitment output. Since we round down when going
-		// from mSAT -> SAT, we may at any point be adding an
-		// additional SAT to miners fees. As a result, we display a
-		// commitment fee that accounts for this externally.
-		var sumOutputs btcutil.Amount
-		for _, txOut := range localCommit.CommitTx.TxOut {
-			sumOutputs += btcutil.Amount(txOut.Value)
-		}
-		externalCommitFee := dbChannel.Capacity - sumOutputs
-
-		channel := &lnrpc.Channel{
-			Active:                isActive,
-			Private:               !isPublic,
-			RemotePubkey:          nodeID,
-			ChannelPoint:          chanPoint.String(),
-			ChanId:                chanID,
-			Capacity:              int64(dbChannel.Capacity),
-			LocalBalance:          int64(localBalance.ToSatoshis()),
-			RemoteBalance:         int64(remoteBalance.ToSatoshis()),
-			CommitFee:             int64(externalCommitFee),
-			CommitWeight:          commitWeight,
-			FeePerKw:              int64(localCommit.FeePerKw),
-			TotalSatoshisSent:     int64(dbChannel.TotalMSatSent.ToSatoshis()),
-			TotalSatoshisReceived: int64(dbChannel.TotalMSatReceived.ToSatoshis()),
-			NumUpdates:            localCommit.CommitHeight,
-			PendingHtlcs:          make([]*lnrpc.HTLC, len(localCommit.Htlcs)),
-			CsvDelay:              uint32(dbChannel.LocalChanCfg.CsvDelay),
-		}
+	return resp, nil
+}
 
-		for i, htlc := range localCommit.Htlcs {
-			channel.PendingHtlcs[i] = &lnrpc.HTLC{
-				Incoming:         htlc.Incoming,
-				Amount:           int64(htlc.Amt.ToSatoshis()),
-				HashLock:         htlc.RHash[:],
-				ExpirationHeight: htlc.RefundTimeout,
-			}
-		}
+// channelStatus returns whether the open channel is active, with its peer
+// online and its link able to forward, and whether it is public.
+func (r *rpcServer) channelStatus(
+	dbChannel *channeldb.OpenChannel) (bool, bool) {
 
-		resp.Channels = append(resp.Channels, channel)
+	var peerOnline bool
+	if _, err := r.server.FindPeer(dbChannel.IdentityPub); err == nil {
+		peerOnline = true
 	}
 
-	return resp, nil
+	channelID := lnwire.NewChanIDFromOutPoint(&dbChannel.FundingOutpoint)
+	var linkActive bool
+	if link, err := r.server.htlcSwitch.GetLink(channelID); err == nil {
+		// A channel is only considered active if it is known by the
+		// switch *and* able to forward incoming/outgoing payments.
+		linkActive = link.EligibleToForward()
+	}
+
+	isActive := peerOnline && linkActive
+	isPublic := dbChannel.ChannelFlags&lnwire.FFAnnounceChannel != 0
+
+	return isActive, isPublic
+}
+
+// marshalChannel converts the open channel into its rpc form.
+func (r *rpcServer) marshalChannel(graph *channeldb.ChannelGraph,
+	dbChannel *channeldb.OpenChannel) *lnrpc.Channel {
+
+	nodePub := dbChannel.IdentityPub
+	nodeID := hex.EncodeToString(nodePub.SerializeCompressed())
+	chanPoint := dbChannel.FundingOutpoint
+
+	// With the channel point known, retrieve the network channel ID from
+	// the database.
+	var chanID uint64
+	chanID, _ = graph.ChannelID(&chanPoint)
+
+	isActive, isPublic := r.channelStatus(dbChannel)
+
+	// As this is required for display purposes, we'll calculate the
+	// weight of the commitment transaction. We also add on the estimated
+	// weight of the witness to calculate the weight of the transaction if
+	// it were to be immediately unilaterally broadcast.
+	localCommit := dbChannel.LocalCommitment
+	utx := btcutil.NewTx(localCommit.CommitTx)
+	commitBaseWeight := blockchain.GetTransactionWeight(utx)
+	commitWeight := commitBaseWeight + lnwallet.WitnessCommitmentTxWeight
+
+	localBalance := localCommit.LocalBalance
+	remoteBalance := localCommit.RemoteBalance
+
+	// As an artifact of our usage of mSAT internally, either party may end
+	// up in a state where they're holding a fractional amount of satoshis
+	// which can't be expressed within the actual commitment output. Since
+	// we round down when going from mSAT -> SAT, we may at any point be
+	// adding an additional SAT to miners fees. As a result, we display a
+	// commitment fee that accounts for this externally.
+	var sumOutputs btcutil.Amount
+	for _, txOut := range localCommit.CommitTx.TxOut {
+		sumOutputs += btcutil.Amount(txOut.Value)
+	}
+	externalCommitFee := dbChannel.Capacity - sumOutputs
+
+	channel := &lnrpc.Channel{
+		Active:                isActive,
+		Private:               !isPublic,
+		RemotePubkey:          nodeID,
+		ChannelPoint:          chanPoint.String(),
+		ChanId:                chanID,
+		Capacity:              int64(dbChannel.Capacity),
+		LocalBalance:          int64(localBalance.ToSatoshis()),
+		RemoteBalance:         int64(remoteBalance.ToSatoshis()),
+		CommitFee:             int64(externalCommitFee),
+		CommitWeight:          commitWeight,
+		FeePerKw:              int64(localCommit.FeePerKw),
+		TotalSatoshisSent:     int64(dbChannel.TotalMSatSent.ToSatoshis()),
+		TotalSatoshisReceived: int64(dbChannel.TotalMSatReceived.ToSatoshis()),
+		NumUpdates:            localCommit.CommitHeight,
+		PendingHtlcs:          make([]*lnrpc.HTLC, len(localCommit.Htlcs)),
+		CsvDelay:              uint32(dbChannel.LocalChanCfg.CsvDelay),
+	}
+
+	for i, htlc := range localCommit.Htlcs {
+		channel.PendingHtlcs[i] = &lnrpc.HTLC{
+			Incoming:         htlc.Incoming,
+			Amount:           int64(htlc.Amt.ToSatoshis()),
+			HashLock:         htlc.RHash[:],
+			ExpirationHeight: htlc.RefundTimeout,
+		}
+	}
+
+	return channel
 }
 
 // savePayment saves a successfully completed payment to the database for
@@ -1661,13 +1694,24 @@ func (r *rpcServer) savePayment(route *routing.Route, amount lnwire.MilliSatoshi
 	return r.server.chanDB.AddPayment(payment)
 }
 
+// ErrInvoiceExpired is returned when attempting to pay an expired payment
+// request.
+type ErrInvoiceExpired struct {
+	ValidUntil time.Time
+}
+
+// Error returns a human readable description of the error.
+func (e *ErrInvoiceExpired) Error() string {
+	return fmt.Sprintf("invoice expired. Valid until %v", e.ValidUntil)
+}
+
 // validatePayReqExpiry checks if the passed payment request has expired. In
 // the case it has expired, an error will be returned.
 func validatePayReqExpiry(payReq *zpay32.Invoice) error {
 	expiry := payReq.Expiry()
 	validUntil := payReq.Timestamp.Add(expiry)
 	if time.Now().After(validUntil) {
-		return fmt.Errorf("invoice expired. Valid until %v", validUntil)
+		return &ErrInvoiceExpired{validUntil}
 	}
 
 	return nil
@@ -1935,6 +1979,50 @@ func (r *rpcServer) SendPaymentSync(ctx context.Context,
 			"not active yet")
 	}
 
+	payment, err := r.paymentIntent(nextPayment)
+	if err != nil {
+		return nil, err
+	}
+	amtMSat := payment.Amount
+
+	// Currently, within the bootstrap phase of the network, we limit the
+	// largest payment size allotted to (2^32) - 1 mSAT or 4.29 million
+	// satoshis.
+	if amtMSat > maxPaymentMSat {
+		err := fmt.Errorf("payment of %v is too large, max payment "+
+			"allowed is %v", nextPayment.Amt, maxPaymentMSat.ToSatoshis())
+		return &lnrpc.SendResponse{
+			PaymentError: err.Error(),
+		}, nil
+	}
+
+	// Finally, send a payment request to the channel router. If the
+	// payment succeeds, then the returned route will be that was used
+	// successfully within the payment.
+	preImage, route, err := r.server.chanRouter.SendPayment(payment)
+	if err != nil {
+		return &lnrpc.SendResponse{
+			PaymentError: err.Error(),
+		}, nil
+	}
+
+	// With the payment completed successfully, we now ave the details of
+	// the completed payment to the database for historical record keeping.
+	if err := r.savePayment(route, amtMSat, preImage[:]); err != nil {
+		return nil, err
+	}
+
+	return &lnrpc.SendResponse{
+		PaymentPreimage: preImage[:],
+		PaymentRoute:    marshallRoute(route),
+	}, nil
+}
+
+// paymentIntent returns the payment described by the request, either by its
+// payment request, or by its destination, amount and payment hash.
+func (r *rpcServer) paymentIntent(
+	nextPayment *lnrpc.SendRequest) (*routing.LightningPayment, error) {
+
 	var (
 		destPub   *btcec.PublicKey
 		amtMSat   lnwire.MilliSatoshi
@@ -2004,20 +2092,6 @@ func (r *rpcServer) SendPaymentSync(ctx context.Context,
 		)
 	}
 
-	// Currently, within the bootstrap phase of the network, we limit the
-	// largest payment size allotted to (2^32) - 1 mSAT or 4.29 million
-	// satoshis.
-	if amtMSat > maxPaymentMSat {
-		err := fmt.Errorf("payment of %v is too large, max payment "+
-			"allowed is %v", nextPayment.Amt, maxPaymentMSat.ToSatoshis())
-		return &lnrpc.SendResponse{
-			PaymentError: err.Error(),
-		}, nil
-	}
-
-	// Finally, send a payment request to the channel router. If the
-	// payment succeeds, then the returned route will be that was used
-	// successfully within the payment.
 	payment := &routing.LightningPayment{
 		Target:      destPub,
 		Amount:      amtMSat,
@@ -2026,23 +2100,8 @@ func (r *rpcServer) SendPaymentSync(ctx context.Context,
 	if cltvDelta != 0 {
 		payment.FinalCLTVDelta = &cltvDelta
 	}
-	preImage, route, err := r.server.chanRouter.SendPayment(payment)
-	if err != nil {
-		return &lnrpc.SendResponse{
-			PaymentError: err.Error(),
-		}, nil
-	}
-
-	// With the payment completed successfully, we now ave the details of
-	// the completed payment to the database for historical record keeping.
-	if err := r.savePayment(route, amtMSat, preImage[:]); err != nil {
-		return nil, err
-	}
 
-	return &lnrpc.SendResponse{
-		PaymentPreimage: preImage[:],
-		PaymentRoute:    marshallRoute(route),
-	}, nil
+	return payment, nil
 }
 
 // AddInvoice attempts to add a new invoice to the invoice database. Any
@@ -2051,6 +2110,14 @@ func (r *rpcServer) SendPaymentSync(ctx context.Context,
 func (r *rpcServer) AddInvoice(ctx context.Context,
 	invoice *lnrpc.Invoice) (*lnrpc.AddInvoiceResponse, error) {
 
+	return r.addInvoice(invoice, nil)
+}
+
+// addInvoice adds the invoice as AddInvoice does, embedding the route hint in
+// its payment request if it isn't empty.
+func (r *rpcServer) addInvoice(invoice *lnrpc.Invoice,
+	routeHint []zpay32.ExtraRoutingInfo) (*lnrpc.AddInvoiceResponse, error) {
+
 	var paymentPreimage [32]byte
 
 	switch {
@@ -2178,6 +2245,12 @@ func (r *rpcServer) AddInvoice(ctx context.Context,
 		options = append(options, zpay32.CLTVExpiry(uint64(defaultDelta)))
 	}
 
+	// The route hint lets payers reach this node over its private
+	// channels.
+	if len(routeHint) > 0 {
+		options = append(options, zpay32.RoutingInfo(routeHint))
+	}
+
 	// Create and encode the payment request as a bech32 (zpay32) string.
 	creationDate := time.Now()
 	payReq, err := zpay32.NewInvoice(
@@ -2375,6 +2448,8 @@ func (r *rpcServer) SubscribeInvoices(req *lnrpc.InvoiceSubscription,
 			if err := updateStream.Send(rpcInvoice); err != nil {
 				return err
 			}
+		case <-updateStream.Context().Done():
+			return updateStream.Context().Err()
 		case <-r.quit:
 			return nil
 		}
@@ -2396,13 +2471,20 @@ func (r *rpcServer) SubscribeTransactions(req *lnrpc.GetTransactionsRequest,
 	for {
 		select {
 		case tx := <-txClient.ConfirmedTransactions():
+			var destAddresses []string
+			for _, destAddress := range tx.DestAddresses {
+				destAddresses = append(destAddresses, destAddress.EncodeAddress())
+			}
+
 			detail := &lnrpc.Transaction{
 				TxHash:           tx.Hash.String(),
 				Amount:           int64(tx.Value),
 				NumConfirmations: tx.NumConfirmations,
 				BlockHash:        tx.BlockHash.String(),
+				BlockHeight:      tx.BlockHeight,
 				TimeStamp:        tx.Timestamp,
 				TotalFees:        tx.TotalFees,
+				DestAddresses:    destAddresses,
 			}
 			if err := updateStream.Send(detail); err != nil {
 				return err
@@ -2417,6 +2499,8 @@ func (r *rpcServer) SubscribeTransactions(req *lnrpc.GetTransactionsRequest,
 			if err := updateStream.Send(detail); err != nil {
 				return err
 			}
+		case <-updateStream.Context().Done():
+			return updateStream.Context().Err()
 		case <-r.quit:
 			return nil
 		}
@@ -2478,23 +2562,7 @@ func (r *rpcServer) DescribeGraph(ctx context.Context,
 	// within the graph), collating their current state into the RPC
 	// response.
 	err := graph.ForEachNode(nil, func(_ *bolt.Tx, node *channeldb.LightningNode) error {
-		nodeAddrs := make([]*lnrpc.NodeAddress, 0)
-		for _, addr := range node.Addresses {
-			nodeAddr := &lnrpc.NodeAddress{
-				Network: addr.Network(),
-				Addr:    addr.String(),
-			}
-			nodeAddrs = append(nodeAddrs, nodeAddr)
-		}
-
-		nodeColor := fmt.Sprintf("#%02x%02x%02x", node.Color.R, node.Color.G, node.Color.B)
-		resp.Nodes = append(resp.Nodes, &lnrpc.LightningNode{
-			LastUpdate: uint32(node.LastUpdate.Unix()),
-			PubKey:     hex.EncodeToString(node.PubKeyBytes[:]),
-			Addresses:  nodeAddrs,
-			Alias:      node.Alias,
-			Color:      nodeColor,
-		})
+		resp.Nodes = append(resp.Nodes, marshalDbNode(node))
 
 		return nil
 	})
@@ -2519,6 +2587,27 @@ func (r *rpcServer) DescribeGraph(ctx context.Context,
 	return resp, nil
 }
 
+func marshalDbNode(node *channeldb.LightningNode) *lnrpc.LightningNode {
+	nodeAddrs := make([]*lnrpc.NodeAddress, 0)
+	for _, addr := range node.Addresses {
+		nodeAddr := &lnrpc.NodeAddress{
+			Network: addr.Network(),
+			Addr:    addr.String(),
+		}
+		nodeAddrs = append(nodeAddrs, nodeAddr)
+	}
+
+	nodeColor := fmt.Sprintf("#%02x%02x%02x", node.Color.R, node.Color.G,
+		node.Color.B)
+	return &lnrpc.LightningNode{
+		LastUpdate: uint32(node.LastUpdate.Unix()),
+		PubKey:     hex.EncodeToString(node.PubKeyBytes[:]),
+		Addresses:  nodeAddrs,
+		Alias:      node.Alias,
+		Color:      nodeColor,
+	}
+}
+
 func marshalDbEdge(edgeInfo *channeldb.ChannelEdgeInfo,
 	c1, c2 *channeldb.ChannelEdgePolicy) *lnrpc.ChannelEdge {
 
@@ -2852,12 +2941,14 @@ func (r *rpcServer) GetNetworkInfo(ctx context.Context,
 	return netInfo, nil
 }
 
-// StopDaemon will send a shutdown request to the interrupt handler, triggering
-// a graceful shutdown of the daemon.
+// StopDaemon will trigger a graceful shutdown of the daemon.
 func (r *rpcServer) StopDaemon(ctx context.Context,
 	_ *lnrpc.StopRequest) (*lnrpc.StopResponse, error) {
 
-	shutdownRequestChannel <- struct{}{}
+	// As lnd is embedded within the host app there's no interrupt handler
+	// to signal, so we'll stop the daemon directly. This is done in a
+	// goroutine as Stop waits for the rpcServer to exit.
+	go Stop()
 	return &lnrpc.StopResponse{}, nil
 }
 
@@ -2904,6 +2995,11 @@ func (r *rpcServer) SubscribeChannelGraph(req *lnrpc.GraphTopologySubscription,
 				return err
 			}
 
+		// The client has gone away, so we'll tear down the
+		// subscription.
+		case <-updateStream.Context().Done():
+			return updateStream.Context().Err()
+
 		// The server is quitting, so we'll exit immediately. Returning
 		// nil will close the clients read end of the stream.
 		case <-r.quit:
@@ -3303,8 +3399,9 @@ func (r *rpcServer) UpdateChannelPolicy(ctx context.Context,
 	// over RPC to the fixed point rate that we use within the protocol. We
 	// do this by multiplying the passed fee rate by the fee base. This
 	// gives us the fixed point, scaled by 1 million that's used within the
-	// protocol.
-	feeRateFixed := uint32(req.FeeRate * feeBase)
+	// protocol. The product is rounded, as truncating it would turn rates
+	// such as 7e-6 into 6 parts per million.
+	feeRateFixed := uint32(math.Round(req.FeeRate * feeBase))
 	baseFeeMsat := lnwire.MilliSatoshi(req.BaseFeeMsat)
 	feeSchema := routing.FeeSchema{
 		BaseFee: baseFeeMsat,
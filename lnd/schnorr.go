@@ -0,0 +1,365 @@
+package lnd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"math/bits"
+
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// The btcec version vendored here predates BIP 340, so the Schnorr signatures
+// and x-only keys of taproot are built on its curve arithmetic below.
+
+var (
+	// ErrInvalidSchnorrKey is returned for an x-only public key that
+	// isn't the x coordinate of a curve point.
+	ErrInvalidSchnorrKey = errors.New("invalid x-only public key")
+
+	// ErrInvalidSchnorrMessage is returned for a message to sign or
+	// verify that isn't 32 bytes, as BIP 340 signs message hashes.
+	ErrInvalidSchnorrMessage = errors.New("schnorr messages must be 32 " +
+		"bytes")
+
+	// errSchnorrSign is returned in the negligible case a signature can't
+	// be made with the chosen nonce.
+	errSchnorrSign = errors.New("unable to create schnorr signature")
+)
+
+// curveP is the order of the field of secp256k1.
+var curveP = btcec.S256().P
+
+// curveN is the order of the group of secp256k1.
+var curveN = btcec.S256().N
+
+// taggedHash is the tagged hash of BIP 340, SHA-256 prefixed by the hash of
+// the tag twice, so hashes for different purposes never collide.
+func taggedHash(tag string, msgs ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+
+	return h.Sum(nil)
+}
+
+// scalarBytes encodes the scalar as 32 big endian bytes.
+func scalarBytes(x *big.Int) []byte {
+	b := make([]byte, 32)
+	xb := x.Bytes()
+	copy(b[32-len(xb):], xb)
+
+	return b
+}
+
+// isInfinity returns whether the point, as returned by btcec's arithmetic, is
+// the point at infinity.
+func isInfinity(x, y *big.Int) bool {
+	return x.Sign() == 0 && y.Sign() == 0
+}
+
+// hasEvenY returns whether the y coordinate of the point is even.
+func hasEvenY(y *big.Int) bool {
+	return y.Bit(0) == 0
+}
+
+// liftX returns the point with the x coordinate and an even y coordinate.
+func liftX(xBytes []byte) (*big.Int, *big.Int, error) {
+	x := new(big.Int).SetBytes(xBytes)
+	if len(xBytes) != 32 || x.Cmp(curveP) >= 0 {
+		return nil, nil, ErrInvalidSchnorrKey
+	}
+
+	// y^2 = x^3 + 7, with the square root taken as c^((p+1)/4).
+	c := new(big.Int).Exp(x, big.NewInt(3), curveP)
+	c.Add(c, big.NewInt(7))
+	c.Mod(c, curveP)
+
+	exp := new(big.Int).Add(curveP, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(c, exp, curveP)
+	if new(big.Int).Exp(y, big.NewInt(2), curveP).Cmp(c) != 0 {
+		return nil, nil, ErrInvalidSchnorrKey
+	}
+
+	if !hasEvenY(y) {
+		y.Sub(curveP, y)
+	}
+
+	return x, y, nil
+}
+
+// xOnlyKey returns the 32 byte x-only encoding of the public key.
+func xOnlyKey(pubKey *btcec.PublicKey) []byte {
+	return scalarBytes(pubKey.X)
+}
+
+// scalar is an integer modulo the group order, as four 64 bit limbs, least
+// significant first. Unlike big.Int's, its arithmetic takes the same time
+// whatever the values, so private keys and nonces don't leak through timing.
+type scalar [4]uint64
+
+// scalarN is the order of the group as a scalar.
+var scalarN = scalar{
+	0xbfd25e8cd0364141, 0xbaaedce6af48a03b,
+	0xfffffffffffffffe, 0xffffffffffffffff,
+}
+
+// scalarFromBytes returns the 32 big endian bytes modulo the group order.
+func scalarFromBytes(b []byte) scalar {
+	var s scalar
+	for i := range s {
+		s[i] = binary.BigEndian.Uint64(b[24-8*i:])
+	}
+
+	// Any 256 bit integer is below twice the order.
+	return s.reduce(0)
+}
+
+// bytes encodes the scalar as 32 big endian bytes.
+func (s scalar) bytes() []byte {
+	b := make([]byte, 32)
+	for i, limb := range s {
+		binary.BigEndian.PutUint64(b[24-8*i:], limb)
+	}
+
+	return b
+}
+
+// isZero returns whether the scalar is zero.
+func (s scalar) isZero() bool {
+	return s[0]|s[1]|s[2]|s[3] == 0
+}
+
+// selectScalar returns a if the flag is 1, and b if it is 0.
+func selectScalar(flag uint64, a, b scalar) scalar {
+	mask := -flag
+	var r scalar
+	for i := range r {
+		r[i] = b[i] ^ (mask & (a[i] ^ b[i]))
+	}
+
+	return r
+}
+
+// reduce returns the scalar, with its 257th bit given by carry, less the group
+// order if it isn't below it. It must be below twice the order.
+func (s scalar) reduce(carry uint64) scalar {
+	var (
+		t      scalar
+		borrow uint64
+	)
+	for i := range t {
+		t[i], borrow = bits.Sub64(s[i], scalarN[i], borrow)
+	}
+
+	return selectScalar(carry|(borrow^1), t, s)
+}
+
+// add returns the sum of the scalars.
+func (s scalar) add(o scalar) scalar {
+	var (
+		r     scalar
+		carry uint64
+	)
+	for i := range r {
+		r[i], carry = bits.Add64(s[i], o[i], carry)
+	}
+
+	return r.reduce(carry)
+}
+
+// mul returns the product of the scalars, added up bit by bit so that it
+// takes the same steps whatever the bits are.
+func (s scalar) mul(o scalar) scalar {
+	var r scalar
+	for i := 255; i >= 0; i-- {
+		r = r.add(r)
+		bit := (o[i/64] >> uint(i%64)) & 1
+		r = selectScalar(bit, r.add(s), r)
+	}
+
+	return r
+}
+
+// negate returns the scalar negated if the flag is 1, and as is if it is 0.
+func (s scalar) negate(flag uint64) scalar {
+	var (
+		neg    scalar
+		borrow uint64
+	)
+	for i := range neg {
+		neg[i], borrow = bits.Sub64(scalarN[i], s[i], borrow)
+	}
+
+	// The order itself, negating zero, reduces back to zero.
+	return selectScalar(flag, neg.reduce(0), s)
+}
+
+// yParity returns 1 if the y coordinate of the point is odd, and 0 if it is
+// even.
+func yParity(y *big.Int) uint64 {
+	return uint64(y.Bit(0))
+}
+
+// schnorrSign signs the 32 byte message with the private key, returning a 64
+// byte BIP 340 signature valid for the key's x-only public key. aux is the 32
+// bytes of auxiliary randomness mixed into the nonce. The private key and the
+// nonce are only handled as scalars, which don't leak them through timing.
+func schnorrSign(privKey *btcec.PrivateKey, msg, aux []byte) ([]byte,
+	error) {
+
+	if len(msg) != 32 {
+		return nil, ErrInvalidSchnorrMessage
+	}
+
+	curve := btcec.S256()
+
+	d := scalarFromBytes(privKey.Serialize())
+	if d.isZero() {
+		return nil, errSchnorrSign
+	}
+	px, py := curve.ScalarBaseMult(d.bytes())
+	d = d.negate(yParity(py))
+	pxBytes := scalarBytes(px)
+
+	t := d.bytes()
+	for i, b := range taggedHash("BIP0340/aux", aux) {
+		t[i] ^= b
+	}
+
+	k := scalarFromBytes(taggedHash("BIP0340/nonce", t, pxBytes, msg))
+	if k.isZero() {
+		return nil, errSchnorrSign
+	}
+
+	rx, ry := curve.ScalarBaseMult(k.bytes())
+	k = k.negate(yParity(ry))
+	rxBytes := scalarBytes(rx)
+
+	e := scalarFromBytes(
+		taggedHash("BIP0340/challenge", rxBytes, pxBytes, msg),
+	)
+	s := e.mul(d).add(k)
+
+	sig := append(rxBytes, s.bytes()...)
+	if !schnorrVerify(pxBytes, msg, sig) {
+		return nil, errSchnorrSign
+	}
+
+	return sig, nil
+}
+
+// schnorrVerify returns whether the 64 byte BIP 340 signature over the message
+// is valid for the 32 byte x-only public key.
+func schnorrVerify(pubKey, msg, sig []byte) bool {
+	if len(sig) != 64 || len(msg) != 32 {
+		return false
+	}
+
+	curve := btcec.S256()
+
+	px, py, err := liftX(pubKey)
+	if err != nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(curveP) >= 0 || s.Cmp(curveN) >= 0 {
+		return false
+	}
+
+	e := new(big.Int).SetBytes(
+		taggedHash("BIP0340/challenge", sig[:32], pubKey, msg),
+	)
+	e.Mod(e, curveN)
+
+	// R = s*G - e*P
+	sx, sy := curve.ScalarBaseMult(sig[32:])
+	ex, ey := curve.ScalarMult(px, py, scalarBytes(e))
+	if !isInfinity(ex, ey) {
+		ey.Sub(curveP, ey)
+	}
+	rx, ry := curve.Add(sx, sy, ex, ey)
+
+	if isInfinity(rx, ry) || !hasEvenY(ry) {
+		return false
+	}
+
+	return bytes.Equal(scalarBytes(rx), sig[:32])
+}
+
+// SchnorrSignature is a BIP 340 signature made with a derived key. The
+// signature and the x-only public key it verifies against are hex encoded.
+type SchnorrSignature struct {
+	Signature string `json:"signature"`
+	PubKey    string `json:"x_only_pub_key"`
+}
+
+// SignSchnorr signs the 32 byte message, usually a hash, with the key at the
+// family and index, returning a BIP 340 signature for taproot based
+// protocols, such as discreet log contracts, without the private key leaving
+// the wallet. As for DerivePrivKey, only families from MinAppKeyFamily
+// onwards may be used, so lnd's own keys never sign arbitrary messages.
+func SignSchnorr(msg []byte, family, index int32) (*SchnorrSignature, error) {
+	keyRing, err := runningKeyRing()
+	if err != nil {
+		return nil, err
+	}
+	keyLoc, err := keyLocator(family, index)
+	if err != nil {
+		return nil, err
+	}
+	if family < MinAppKeyFamily {
+		return nil, ErrReservedKeyFamily
+	}
+
+	privKey, err := keyRing.DerivePrivKey(keychain.KeyDescriptor{
+		KeyLocator: keyLoc,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Fresh randomness mixed into the nonce guards against fault attacks,
+	// while the nonce never depends on it for security.
+	var aux [32]byte
+	if _, err := rand.Read(aux[:]); err != nil {
+		return nil, err
+	}
+
+	sig, err := schnorrSign(privKey, msg, aux[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &SchnorrSignature{
+		Signature: hex.EncodeToString(sig),
+		PubKey:    hex.EncodeToString(xOnlyKey(privKey.PubKey())),
+	}, nil
+}
+
+// VerifySchnorr returns whether the 64 byte BIP 340 signature over the 32 byte
+// message is valid for the 32 byte x-only public key. It doesn't need the
+// wallet.
+func VerifySchnorr(msg, sig, pubKey []byte) (bool, error) {
+	if len(msg) != 32 {
+		return false, ErrInvalidSchnorrMessage
+	}
+	if _, _, err := liftX(pubKey); err != nil {
+		return false, err
+	}
+
+	return schnorrVerify(pubKey, msg, sig), nil
+}
@@ -0,0 +1,297 @@
+package lnd
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/roasbeef/btcd/btcec"
+)
+
+// The test vectors of BIP 340 for 32 byte messages. Vectors without a secret
+// key are verified only.
+var schnorrVectors = []struct {
+	secKey string
+	pubKey string
+	aux    string
+	msg    string
+	sig    string
+	valid  bool
+}{
+	{
+		secKey: "00000000000000000000000000000000" +
+			"00000000000000000000000000000003",
+		pubKey: "F9308A019258C31049344F85F89D5229" +
+			"B531C845836F99B08601F113BCE036F9",
+		aux: "00000000000000000000000000000000" +
+			"00000000000000000000000000000000",
+		msg: "00000000000000000000000000000000" +
+			"00000000000000000000000000000000",
+		sig: "E907831F80848D1069A5371B40241036" +
+			"4BDF1C5F8307B0084C55F1CE2DCA8215" +
+			"25F66A4A85EA8B71E482A74F382D2CE5" +
+			"EBEEE8FDB2172F477DF4900D310536C0",
+		valid: true,
+	},
+	{
+		secKey: "B7E151628AED2A6ABF7158809CF4F3C7" +
+			"62E7160F38B4DA56A784D9045190CFEF",
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		aux: "00000000000000000000000000000000" +
+			"00000000000000000000000000000001",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "6896BD60EEAE296DB48A229FF71DFE07" +
+			"1BDE413E6D43F917DC8DCF8C78DE3341" +
+			"8906D11AC976ABCCB20B091292BFF4EA" +
+			"897EFCB639EA871CFA95F6DE339E4B0A",
+		valid: true,
+	},
+	{
+		secKey: "C90FDAA22168C234C4C6628B80DC1CD1" +
+			"29024E088A67CC74020BBEA63B14E5C9",
+		pubKey: "DD308AFEC5777E13121FA72B9CC1B7CC" +
+			"0139715309B086C960E18FD969774EB8",
+		aux: "C87AA53824B4D7AE2EB035A2B5BBBCCC" +
+			"080E76CDC6D1692C4B0B62D798E6D906",
+		msg: "7E2D58D8B3BCDF1ABADEC7829054F90D" +
+			"DA9805AAB56C77333024B9D0A508B75C",
+		sig: "5831AAEED7B44BB74E5EAB94BA9D4294" +
+			"C49BCF2A60728D8B4C200F50DD313C1B" +
+			"AB745879A5AD954A72C45A91C3A51D3C" +
+			"7ADEA98D82F8481E0E1E03674A6F3FB7",
+		valid: true,
+	},
+	{
+		// The test fails if the message is reduced modulo p or n.
+		secKey: "0B432B2677937381AEF05BB02A66ECD0" +
+			"12773062CF3FA2549E44F58ED2401710",
+		pubKey: "25D1DFF95105F5253C4022F628A996AD" +
+			"3A0D95FBF21D468A1B33F8C160D8F517",
+		aux: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF" +
+			"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+		msg: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF" +
+			"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF",
+		sig: "7EB0509757E246F19449885651611CB9" +
+			"65ECC1A187DD51B64FDA1EDC9637D5EC" +
+			"97582B9CB13DB3933705B32BA982AF5A" +
+			"F25FD78881EBB32771FC5922EFC66EA3",
+		valid: true,
+	},
+	{
+		pubKey: "D69C3509BB99E412E68B0FE8544E7283" +
+			"7DFA30746D8BE2AA65975F29D22DC7B9",
+		msg: "4DF3C3F68FCC83B27E9D42C90431A724" +
+			"99F17875C81A599B566C9889B9696703",
+		sig: "00000000000000000000003B78CE563F" +
+			"89A0ED9414F5AA28AD0D96D6795F9C63" +
+			"76AFB1548AF603B3EB45C9F8207DEE10" +
+			"60CB71C04E80F593060B07D28308D7F4",
+		valid: true,
+	},
+	{
+		// The public key isn't on the curve.
+		pubKey: "EEFDEA4CDB677750A420FEE807EACF21" +
+			"EB9898AE79B9768766E4FAA04A2D4A34",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "6CFF5C3BA86C69EA4B7376F31A9BCB4F" +
+			"74C1976089B2D9963DA2E5543E177769" +
+			"69E89B4C5564D00349106B8497785DD7" +
+			"D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+	{
+		// R has an odd y coordinate.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "FFF97BD5755EEEA420453A14355235D3" +
+			"82F6472F8568A18B2F057A1460297556" +
+			"3CC27944640AC607CD107AE10923D9EF" +
+			"7A73C643E166BE5EBEAFA34B1AC553E2",
+	},
+	{
+		// The message is negated.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "1FA62E331EDBC21C394792D2AB1100A7" +
+			"B432B013DF3F6FF4F99FCB33E0E1515F" +
+			"28890B3EDB6E7189B630448B515CE4F8" +
+			"622A954CFE545735AAEA5134FCCDB2BD",
+	},
+	{
+		// s is negated.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "6CFF5C3BA86C69EA4B7376F31A9BCB4F" +
+			"74C1976089B2D9963DA2E5543E177769" +
+			"961764B3AA9B2FFCB6EF947B6887A226" +
+			"E8D7C93E00C5ED0C1834FF0D0C2E6DA6",
+	},
+	{
+		// s*G - e*P is the point at infinity, with r 0.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "00000000000000000000000000000000" +
+			"00000000000000000000000000000000" +
+			"123DDA8328AF9C23A94C1FEECFD123BA" +
+			"4FB73476F0D594DCB65C6425BD186051",
+	},
+	{
+		// s*G - e*P is the point at infinity, with r 1.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "00000000000000000000000000000000" +
+			"00000000000000000000000000000001" +
+			"7615FBAF5AE28864013C099742DEADB4" +
+			"DBA87F11AC6754F93780D5A1837CF197",
+	},
+	{
+		// r isn't the x coordinate of a curve point.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "4A298DACAE57395A15D0795DDBFD1DCB" +
+			"564DA82B0F269BC70A74F8220429BA1D" +
+			"69E89B4C5564D00349106B8497785DD7" +
+			"D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+	{
+		// r is the field size.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF" +
+			"FFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F" +
+			"69E89B4C5564D00349106B8497785DD7" +
+			"D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+	{
+		// s is the curve order.
+		pubKey: "DFF1D77F2A671C5F36183726DB2341BE" +
+			"58FEAE1DA2DECED843240F7B502BA659",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "6CFF5C3BA86C69EA4B7376F31A9BCB4F" +
+			"74C1976089B2D9963DA2E5543E177769" +
+			"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFE" +
+			"BAAEDCE6AF48A03BBFD25E8CD0364141",
+	},
+	{
+		// The public key exceeds the field size.
+		pubKey: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF" +
+			"FFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+		msg: "243F6A8885A308D313198A2E03707344" +
+			"A4093822299F31D0082EFA98EC4E6C89",
+		sig: "6CFF5C3BA86C69EA4B7376F31A9BCB4F" +
+			"74C1976089B2D9963DA2E5543E177769" +
+			"69E89B4C5564D00349106B8497785DD7" +
+			"D1D713A8AE82B32FA79D5F7FC407D39B",
+	},
+}
+
+func TestSchnorrVectors(t *testing.T) {
+	for i, v := range schnorrVectors {
+		pubKey := decodeHex(t, v.pubKey)
+		msg := decodeHex(t, v.msg)
+		sig := decodeHex(t, v.sig)
+
+		if v.secKey != "" {
+			privKey, _ := btcec.PrivKeyFromBytes(
+				btcec.S256(), decodeHex(t, v.secKey),
+			)
+			if !bytes.Equal(xOnlyKey(privKey.PubKey()), pubKey) {
+				t.Fatalf("vector %d: wrong public key", i)
+			}
+
+			signed, err := schnorrSign(
+				privKey, msg, decodeHex(t, v.aux),
+			)
+			if err != nil {
+				t.Fatalf("vector %d: unable to sign: %v", i, err)
+			}
+			if !bytes.Equal(signed, sig) {
+				t.Fatalf("vector %d: expected signature %x, "+
+					"got %x", i, sig, signed)
+			}
+		}
+
+		if schnorrVerify(pubKey, msg, sig) != v.valid {
+			t.Fatalf("vector %d: expected valid %v", i, v.valid)
+		}
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(
+		btcec.S256(), decodeHex(t, schnorrVectors[1].secKey),
+	)
+	_, err := schnorrSign(privKey, make([]byte, 31), make([]byte, 32))
+	if err != ErrInvalidSchnorrMessage {
+		t.Fatalf("expected error for a short message, got %v", err)
+	}
+}
+
+// TestScalarArithmetic checks the constant time scalars against big.Int.
+func TestScalarArithmetic(t *testing.T) {
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(2),
+		new(big.Int).Sub(curveN, big.NewInt(1)),
+		new(big.Int).Rsh(curveN, 1),
+		new(big.Int).SetBytes(decodeHex(t, schnorrVectors[1].secKey)),
+		new(big.Int).SetBytes(decodeHex(t, schnorrVectors[2].secKey)),
+	}
+
+	for _, a := range values {
+		sa := scalarFromBytes(scalarBytes(a))
+
+		neg := new(big.Int).Sub(curveN, a)
+		neg.Mod(neg, curveN)
+		if !bytes.Equal(sa.negate(1).bytes(), scalarBytes(neg)) {
+			t.Fatalf("wrong negation of %x", a)
+		}
+		if !bytes.Equal(sa.negate(0).bytes(), scalarBytes(a)) {
+			t.Fatalf("%x changed without negation", a)
+		}
+
+		for _, b := range values {
+			sb := scalarFromBytes(scalarBytes(b))
+
+			sum := new(big.Int).Add(a, b)
+			sum.Mod(sum, curveN)
+			if !bytes.Equal(sa.add(sb).bytes(), scalarBytes(sum)) {
+				t.Fatalf("wrong sum of %x and %x", a, b)
+			}
+
+			product := new(big.Int).Mul(a, b)
+			product.Mod(product, curveN)
+			if !bytes.Equal(sa.mul(sb).bytes(), scalarBytes(product)) {
+				t.Fatalf("wrong product of %x and %x", a, b)
+			}
+		}
+	}
+
+	// 256 bit integers at or above the order are reduced.
+	above := decodeHex(t, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"+
+		"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF")
+	reduced := new(big.Int).SetBytes(above)
+	reduced.Mod(reduced, curveN)
+	if !bytes.Equal(scalarFromBytes(above).bytes(), scalarBytes(reduced)) {
+		t.Fatalf("256 bit integer not reduced")
+	}
+	if !scalarFromBytes(scalarBytes(curveN)).isZero() {
+		t.Fatalf("order not reduced to zero")
+	}
+}
@@ -0,0 +1,99 @@
+package lnd
+
+import (
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+)
+
+// SweepAll describes a transaction draining the wallet. Amounts are in
+// satoshis.
+type SweepAll struct {
+	Txid      string `json:"txid"`
+	Amount    int64  `json:"amount"`
+	Fee       int64  `json:"fee"`
+	NumInputs int    `json:"num_inputs"`
+}
+
+// SendAll pays every confirmed output of the default account to the address,
+// in a single transaction without change. The fee, at satPerByte satoshis per
+// vbyte or a rate estimated to confirm within six blocks if it is zero, is
+// taken from the amount sent. Outputs locked for a pending channel funding
+// are left out.
+func SendAll(address string, satPerByte int64) (*SweepAll, error) {
+	f, feeRate, err := bumpFeeParams(satPerByte)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := btcutil.DecodeAddress(address, activeNetParams.Params)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold off other sends, which would otherwise race for the same
+	// outputs.
+	f.sendMtx.Lock()
+	defer f.sendMtx.Unlock()
+
+	coins, err := f.defaultUtxos(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		weight lnwallet.TxWeightEstimator
+		total  btcutil.Amount
+	)
+	tx := wire.NewMsgTx(2)
+	inputs := make(map[wire.OutPoint]*lnwallet.Utxo, len(coins))
+	for _, coin := range coins {
+		switch coin.AddressType {
+		case lnwallet.WitnessPubKey:
+			weight.AddP2WKHInput()
+		case lnwallet.NestedWitnessPubKey:
+			weight.AddNestedP2WKHInput()
+		default:
+			continue
+		}
+
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: coin.OutPoint,
+			Sequence:         rbfSequence,
+		})
+		inputs[coin.OutPoint] = coin
+		total += coin.Value
+	}
+	addOutputWeight(&weight, pkScript)
+
+	// Whatever is left after the fee must be worth relaying.
+	fee := feeRate.FeeForVSize(int64(weight.VSize()))
+	dustLimit := walletDustLimit(len(pkScript))
+	if total-fee < dustLimit {
+		return nil, insufficientCoinsError{
+			needed:    fee + dustLimit,
+			available: total,
+		}
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(total-fee), pkScript))
+
+	if err := f.signTx(tx, inputs); err != nil {
+		return nil, err
+	}
+
+	if err := f.PublishTransaction(tx); err != nil {
+		return nil, err
+	}
+
+	return &SweepAll{
+		Txid:      tx.TxHash().String(),
+		Amount:    int64(total - fee),
+		Fee:       int64(fee),
+		NumInputs: len(tx.TxIn),
+	}, nil
+}
@@ -0,0 +1,292 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcutil"
+)
+
+// ErrInvalidRoute is returned for a route passed to SendToRoute that can't be
+// sent over.
+type ErrInvalidRoute struct {
+	Reason string
+}
+
+func (e *ErrInvalidRoute) Error() string {
+	return "invalid route: " + e.Reason
+}
+
+// invalidRoute returns an ErrInvalidRoute with the formatted reason.
+func invalidRoute(format string, a ...interface{}) error {
+	return &ErrInvalidRoute{Reason: fmt.Sprintf(format, a...)}
+}
+
+// RouteHop is a hop of a PaymentRoute, over a channel to the next node.
+type RouteHop struct {
+	ChanID uint64 `json:"chan_id"`
+
+	// PubKey is the node the hop leads to.
+	PubKey string `json:"pub_key"`
+
+	// AmtToForwardMsat is the amount the node forwards over its next
+	// hop, or is paid if it's the last, and Expiry the time lock it uses.
+	// FeeMsat is what it keeps for forwarding.
+	AmtToForwardMsat int64  `json:"amt_to_forward_msat"`
+	FeeMsat          int64  `json:"fee_msat"`
+	Expiry           uint32 `json:"expiry"`
+}
+
+// PaymentRoute is a route built by BuildRoute and sent over by SendToRoute.
+// Unlike the routes of lnrpc, it holds the nodes of its hops and amounts in
+// millisatoshis, so it can be sent over as is.
+type PaymentRoute struct {
+	TotalTimeLock uint32      `json:"total_time_lock"`
+	TotalAmtMsat  int64       `json:"total_amt_msat"`
+	TotalFeesMsat int64       `json:"total_fees_msat"`
+	Hops          []*RouteHop `json:"hops"`
+}
+
+// SendToRouteResult is the outcome of SendToRoute.
+type SendToRouteResult struct {
+	Succeeded       bool   `json:"succeeded"`
+	PaymentPreimage string `json:"payment_preimage,omitempty"`
+
+	// Failure is why the payment failed, and FailureSource the node
+	// failing it, if known. FailureDetail breaks the failure down further.
+	Failure       string          `json:"failure,omitempty"`
+	FailureSource string          `json:"failure_source,omitempty"`
+	FailureDetail *PaymentFailure `json:"failure_detail,omitempty"`
+}
+
+// newPaymentRoute converts the route into its PaymentRoute form.
+func newPaymentRoute(route *routing.Route) *PaymentRoute {
+	r := &PaymentRoute{
+		TotalTimeLock: route.TotalTimeLock,
+		TotalAmtMsat:  int64(route.TotalAmount),
+		TotalFeesMsat: int64(route.TotalFees),
+		Hops:          make([]*RouteHop, len(route.Hops)),
+	}
+	for i, hop := range route.Hops {
+		r.Hops[i] = &RouteHop{
+			ChanID: hop.Channel.ChannelID,
+			PubKey: hex.EncodeToString(
+				hop.Channel.Node.PubKeyBytes[:],
+			),
+			AmtToForwardMsat: int64(hop.AmtToForward),
+			FeeMsat:          int64(hop.Fee),
+			Expiry:           hop.OutgoingTimeLock,
+		}
+	}
+
+	return r
+}
+
+// toRoute converts the route back into the form the switch sends over. The
+// amounts and time locks are taken as they are, without checking them against
+// the policies of the channels, so routes can be tweaked by hand.
+func (r *PaymentRoute) toRoute(
+	graph *channeldb.ChannelGraph) (*routing.Route, error) {
+
+	if len(r.Hops) == 0 || len(r.Hops) > routing.HopLimit {
+		return nil, invalidRoute("must have between 1 and %v hops",
+			routing.HopLimit)
+	}
+	if r.TotalAmtMsat <= 0 || r.TotalFeesMsat < 0 {
+		return nil, invalidRoute("invalid amounts")
+	}
+
+	route := &routing.Route{
+		TotalTimeLock: r.TotalTimeLock,
+		TotalAmount:   lnwire.MilliSatoshi(r.TotalAmtMsat),
+		TotalFees:     lnwire.MilliSatoshi(r.TotalFeesMsat),
+		Hops:          make([]*routing.Hop, len(r.Hops)),
+	}
+	for i, hop := range r.Hops {
+		if hop.AmtToForwardMsat <= 0 || hop.FeeMsat < 0 {
+			return nil, invalidRoute("invalid amounts of hop %v", i)
+		}
+
+		pubKey, err := hex.DecodeString(hop.PubKey)
+		if err == nil {
+			_, err = btcec.ParsePubKey(pubKey, btcec.S256())
+		}
+		if err != nil {
+			return nil, invalidRoute("invalid public key of hop "+
+				"%v: %v", i, err)
+		}
+
+		// The capacity is only known for channels in the graph, but
+		// isn't needed to send.
+		var capacity btcutil.Amount
+		info, _, _, err := graph.FetchChannelEdgesByID(hop.ChanID)
+		switch {
+		case err == nil:
+			capacity = info.Capacity
+		case !edgeNotFound(err):
+			return nil, err
+		}
+
+		policy := &channeldb.ChannelEdgePolicy{
+			ChannelID: hop.ChanID,
+			Node:      &channeldb.LightningNode{},
+		}
+		copy(policy.Node.PubKeyBytes[:], pubKey)
+
+		route.Hops[i] = &routing.Hop{
+			Channel: &routing.ChannelHop{
+				ChannelEdgePolicy: policy,
+				Capacity:          capacity,
+			},
+			OutgoingTimeLock: hop.Expiry,
+			AmtToForward: lnwire.MilliSatoshi(
+				hop.AmtToForwardMsat,
+			),
+			Fee: lnwire.MilliSatoshi(hop.FeeMsat),
+		}
+	}
+
+	return route, nil
+}
+
+// BuildRoute returns the route paying amt to the last of the nodes, through
+// the others in turn, with finalCLTVDelta blocks for it to settle in. Between
+// each two nodes, the channel that forwards the payment for the lowest fee is
+// taken, unless outgoingChan, the channel leaving this node, is set. The lnd
+// here lets the switch pay out through any channel with the first node
+// though.
+func BuildRoute(amt btcutil.Amount, nodes []*btcec.PublicKey,
+	outgoingChan uint64, finalCLTVDelta uint16) (*PaymentRoute, error) {
+
+	amtMSat := lnwire.NewMSatFromSatoshis(amt)
+	if amtMSat <= 0 || amtMSat > maxPaymentMSat {
+		return nil, fmt.Errorf("invalid route amount: %v", amt)
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("route must have at least one node")
+	}
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	graph := d.chanDB.ChannelGraph()
+	self := d.server.identityPriv.PubKey()
+
+	path := make([]*routing.ChannelHop, len(nodes))
+	for i, node := range nodes {
+		from := self
+		if i > 0 {
+			from = nodes[i-1]
+		}
+
+		var (
+			hop *routing.ChannelHop
+			err error
+		)
+		if i == 0 && outgoingChan != 0 {
+			hop, err = channelPolicy(graph, outgoingChan, self)
+			if err == nil && !bytes.Equal(
+				hop.Node.PubKeyBytes[:],
+				node.SerializeCompressed(),
+			) {
+				chanID := lnwire.NewShortChanIDFromInt(
+					outgoingChan,
+				)
+				err = fmt.Errorf("channel %v isn't with the "+
+					"first node", chanID)
+			}
+		} else {
+			hop, err = channelBetween(
+				graph, from, node, amtMSat, nil,
+			)
+			if err == nil && hop == nil {
+				err = fmt.Errorf("no channel from %x to %x "+
+					"able to forward %v",
+					from.SerializeCompressed(),
+					node.SerializeCompressed(), amtMSat)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		path[i] = hop
+	}
+
+	_, height, err := d.cc.chainIO.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	route, err := buildRoute(amtMSat, path, uint32(height), finalCLTVDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaymentRoute(route), nil
+}
+
+// SendToRoute sends the payment with the hash over the route, as it is, and
+// records it once it settles. A payment that failed along the route is
+// returned with why, rather than as an error.
+func SendToRoute(paymentHash [32]byte,
+	paymentRoute *PaymentRoute) (*SendToRouteResult, error) {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	route, err := paymentRoute.toRoute(d.chanDB.ChannelGraph())
+	if err != nil {
+		return nil, err
+	}
+
+	lastHop := route.Hops[len(route.Hops)-1]
+
+	preimage, sendErr := sendToRoute(d.server, route, paymentHash)
+	if sendErr != nil {
+		result := &SendToRouteResult{}
+		result.Failure, result.FailureSource = attemptFailure(sendErr)
+
+		path := make([]*btcec.PublicKey, len(route.Hops))
+		for i, hop := range route.Hops {
+			path[i], err = btcec.ParsePubKey(
+				hop.Channel.Node.PubKeyBytes[:], btcec.S256(),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.FailureDetail = classifyPaymentFailure(
+			d, sendErr, htlcFailure(sendErr, path),
+			path[len(path)-1], lastHop.AmtToForward,
+		)
+
+		return result, nil
+	}
+
+	err = d.rpcServer.savePayment(route, lastHop.AmtToForward, preimage[:])
+	if err != nil {
+		ltndLog.Errorf("Unable to save payment %x: %v",
+			paymentHash[:], err)
+	}
+
+	return &SendToRouteResult{
+		Succeeded:       true,
+		PaymentPreimage: hex.EncodeToString(preimage[:]),
+	}, nil
+}
@@ -128,6 +128,10 @@ type server struct {
 	// changed since last start.
 	currentNodeAnn *lnwire.NodeAnnouncement
 
+	// nodeBreaker excludes nodes failing payments over and over from
+	// their routes.
+	nodeBreaker *nodeBreaker
+
 	quit chan struct{}
 
 	wg sync.WaitGroup
@@ -190,6 +194,9 @@ func newServer(listenAddrs []string, chanDB *channeldb.DB, cc *chainControl,
 
 		globalFeatures: lnwire.NewFeatureVector(globalFeatures,
 			lnwire.GlobalFeatures),
+		nodeBreaker: newNodeBreaker(
+			cfg.NodeBreaker.Threshold, cfg.NodeBreaker.Cooldown,
+		),
 		quit: make(chan struct{}),
 	}
 
@@ -326,22 +333,10 @@ func newServer(listenAddrs []string, chanDB *channeldb.DB, cc *chainControl,
 		return nil, err
 	}
 	s.chanRouter, err = routing.New(routing.Config{
-		Graph:     chanGraph,
-		Chain:     cc.chainIO,
-		ChainView: cc.chainView,
-		SendToSwitch: func(firstHopPub [33]byte,
-			htlcAdd *lnwire.UpdateAddHTLC,
-			circuit *sphinx.Circuit) ([32]byte, error) {
-
-			// Using the created circuit, initialize the error
-			// decrypter so we can parse+decode any failures
-			// incurred by this payment within the switch.
-			errorDecryptor := &htlcswitch.SphinxErrorDecrypter{
-				OnionErrorDecrypter: sphinx.NewOnionErrorDecrypter(circuit),
-			}
-
-			return s.htlcSwitch.SendHTLC(firstHopPub, htlcAdd, errorDecryptor)
-		},
+		Graph:              chanGraph,
+		Chain:              cc.chainIO,
+		ChainView:          cc.chainView,
+		SendToSwitch:       s.sendToSwitch,
 		ChannelPruneExpiry: time.Duration(time.Hour * 24 * 14),
 		GraphPruneInterval: time.Duration(time.Hour),
 	})
@@ -1389,6 +1384,12 @@ func (s *server) peerConnected(conn net.Conn, connReq *connmgr.ConnReq,
 		localFeatures.Set(lnwire.InitialRoutingSync)
 	}
 
+	// Signal support for large channels if we're willing to open and
+	// accept them.
+	if cfg.WumboChannels {
+		localFeatures.Set(wumboChannelsOptional)
+	}
+
 	// Now that we've established a connection, create a peer, and it to
 	// the set of currently active peers.
 	p, err := newPeer(conn, connReq, s, peerAddr, inbound, localFeatures)
@@ -1462,7 +1463,13 @@ func (s *server) InboundPeerConnected(conn net.Conn) {
 	switch err {
 	case ErrPeerNotFound:
 		// We were unable to locate an existing connection with the
-		// target peer, proceed to connect.
+		// target peer, proceed to connect if within the limits.
+		if !s.admitInboundPeer(nodePub) {
+			srvrLog.Infof("Refusing inbound connection from %v, "+
+				"peer limit reached", conn.RemoteAddr())
+			conn.Close()
+			return
+		}
 
 	case nil:
 		// We already have a connection with the incoming peer. If the
@@ -1685,6 +1692,12 @@ func (s *server) addPeer(p *peer) {
 		close(con)
 	}
 	delete(s.peerConnectedListeners, pubStr)
+
+	if err := markPeerSeen(s.chanDB, p.addr.IdentityKey); err != nil {
+		srvrLog.Errorf("unable to mark peer %v seen: %v", p, err)
+	}
+
+	publishEvent(EventPeerConnected, newPeerEvent(p))
 }
 
 // removePeer removes the passed peer from the server's state of all active
@@ -1705,6 +1718,10 @@ func (s *server) removePeer(p *peer) {
 		s.connMgr.Remove(p.connReq.ID())
 	}
 
+	if err := markPeerSeen(s.chanDB, p.addr.IdentityKey); err != nil {
+		srvrLog.Errorf("unable to mark peer %v seen: %v", p, err)
+	}
+
 	// Ignore deleting peers if we're shutting down.
 	if s.Stopped() {
 		return
@@ -1719,6 +1736,8 @@ func (s *server) removePeer(p *peer) {
 	} else {
 		delete(s.outboundPeers, pubStr)
 	}
+
+	publishEvent(EventPeerDisconnected, newPeerEvent(p))
 }
 
 // openChanReq is a message sent to the server in order to request the
@@ -1742,6 +1761,10 @@ type openChanReq struct {
 
 	remoteCsvDelay uint16
 
+	// coinControl picks the coins funding the channel and its change, or
+	// is nil for the defaults.
+	coinControl *CoinControl
+
 	// TODO(roasbeef): add ability to specify channel constraints as well
 
 	updates chan *lnrpc.OpenStatusUpdate
@@ -1866,7 +1889,8 @@ func (s *server) DisconnectPeer(pubKey *btcec.PublicKey) error {
 func (s *server) OpenChannel(nodeKey *btcec.PublicKey,
 	localAmt btcutil.Amount, pushAmt, minHtlc lnwire.MilliSatoshi,
 	fundingFeePerVSize lnwallet.SatPerVByte, private bool,
-	remoteCsvDelay uint16) (chan *lnrpc.OpenStatusUpdate, chan error) {
+	remoteCsvDelay uint16,
+	coinControl *CoinControl) (chan *lnrpc.OpenStatusUpdate, chan error) {
 
 	updateChan := make(chan *lnrpc.OpenStatusUpdate, 1)
 	errChan := make(chan error, 1)
@@ -1921,6 +1945,7 @@ func (s *server) OpenChannel(nodeKey *btcec.PublicKey,
 		private:            private,
 		minHtlc:            minHtlc,
 		remoteCsvDelay:     remoteCsvDelay,
+		coinControl:        coinControl,
 		updates:            updateChan,
 		err:                errChan,
 	}
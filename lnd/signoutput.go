@@ -0,0 +1,77 @@
+package lnd
+
+import (
+	"errors"
+
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/txscript"
+	"github.com/roasbeef/btcd/wire"
+)
+
+// ErrInvalidSignDescriptor is returned by SignOutputRaw for a descriptor that
+// doesn't describe an input of the transaction.
+var ErrInvalidSignDescriptor = errors.New("invalid sign descriptor")
+
+// runningSigner returns the signer of the running daemon.
+func runningSigner() (lnwallet.Signer, error) {
+	daemonMtx.Lock()
+	defer daemonMtx.Unlock()
+
+	if activeDaemon == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return activeDaemon.cc.signer, nil
+}
+
+// validSigHashType returns whether the sighash type is one of the standard
+// ones.
+func validSigHashType(hashType txscript.SigHashType) bool {
+	switch hashType &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashAll, txscript.SigHashNone, txscript.SigHashSingle:
+		return true
+	}
+
+	return false
+}
+
+// SignOutputRaw signs the segwit input of the transaction at the descriptor's
+// input index with the key at the family and index, tweaked by the
+// descriptor's single tweak if it has one, for custom script protocols such
+// as submarine swaps. The descriptor's witness script is the script the
+// signature commits to, which for a key hash output is its pay to key hash
+// script. The DER encoded signature is returned without its sighash flag.
+// Only families from MinAppKeyFamily onwards may be used, so lnd's own keys
+// never sign arbitrary transactions.
+func SignOutputRaw(tx *wire.MsgTx, signDesc *lnwallet.SignDescriptor,
+	family, index int32) ([]byte, error) {
+
+	signer, err := runningSigner()
+	if err != nil {
+		return nil, err
+	}
+	keyLoc, err := keyLocator(family, index)
+	if err != nil {
+		return nil, err
+	}
+	if family < MinAppKeyFamily {
+		return nil, ErrReservedKeyFamily
+	}
+
+	if signDesc.InputIndex < 0 || signDesc.InputIndex >= len(tx.TxIn) ||
+		signDesc.Output == nil || len(signDesc.WitnessScript) == 0 ||
+		!validSigHashType(signDesc.HashType) {
+
+		return nil, ErrInvalidSignDescriptor
+	}
+	if signDesc.SingleTweak != nil && len(signDesc.SingleTweak) != 32 {
+		return nil, ErrInvalidSignDescriptor
+	}
+
+	desc := *signDesc
+	desc.KeyDesc = keychain.KeyDescriptor{KeyLocator: keyLoc}
+	desc.SigHashes = txscript.NewTxSigHashes(tx)
+
+	return signer.SignOutputRaw(tx, &desc)
+}
@@ -0,0 +1,72 @@
+package lnd
+
+import (
+	"crypto/sha256"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// SubscribeSingleInvoice calls update with the invoice with the hash as it is
+// now, and again once it's settled, returning once it was, or quit is closed.
+// Invoices here are only ever open or settled: there are no hold invoices to
+// be accepted, nor a way to cancel an invoice.
+func SubscribeSingleInvoice(paymentHash [32]byte, quit <-chan struct{},
+	update func(*lnrpc.Invoice) error) error {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return ErrDaemonNotRunning
+	}
+	invoices := d.server.invoices
+
+	// Subscribing before looking the invoice up means a settle in between
+	// isn't missed. Added invoices are sent over the subscription too, and
+	// are drained along with the settled ones.
+	invoiceClient := invoices.SubscribeNotifications()
+	defer invoiceClient.Cancel()
+
+	invoice, err := invoices.LookupInvoice(paymentHash)
+	if err != nil {
+		return err
+	}
+	rpcInvoice, err := createRPCInvoice(&invoice)
+	if err != nil {
+		return err
+	}
+	if err := update(rpcInvoice); err != nil {
+		return err
+	}
+
+	for !invoice.Terms.Settled {
+		select {
+		case <-invoiceClient.NewInvoices:
+			continue
+
+		case settled := <-invoiceClient.SettledInvoices:
+			preimage := settled.Terms.PaymentPreimage
+			if sha256.Sum256(preimage[:]) != paymentHash {
+				continue
+			}
+			invoice = *settled
+
+		case <-quit:
+			return nil
+
+		case <-d.server.quit:
+			return ErrDaemonNotRunning
+		}
+
+		rpcInvoice, err := createRPCInvoice(&invoice)
+		if err != nil {
+			return err
+		}
+		if err := update(rpcInvoice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
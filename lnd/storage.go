@@ -0,0 +1,255 @@
+package lnd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lndDirname is the directory within the app's data directory that holds all
+// of lnd's files.
+const lndDirname = "Lnd"
+
+// StorageUsage breaks down the disk space, in bytes, used by lnd's files
+// within an app's data directory.
+type StorageUsage struct {
+	ChannelDB     int64 `json:"channel_db"`
+	WalletDB      int64 `json:"wallet_db"`
+	NeutrinoDB    int64 `json:"neutrino_db"`
+	BlockHeaders  int64 `json:"block_headers"`
+	FilterHeaders int64 `json:"filter_headers"`
+	Macaroons     int64 `json:"macaroons"`
+	Logs          int64 `json:"logs"`
+	Other         int64 `json:"other"`
+	Total         int64 `json:"total"`
+}
+
+// add accounts the file at the given path, relative to lnd's directory, to
+// the component it belongs to.
+func (u *StorageUsage) add(relPath string, size int64) {
+	u.Total += size
+
+	logPrefix := defaultLogDirname + string(filepath.Separator)
+	switch name := filepath.Base(relPath); {
+	case strings.HasPrefix(relPath, logPrefix):
+		u.Logs += size
+	case name == "channel.db":
+		u.ChannelDB += size
+	case name == "wallet.db":
+		u.WalletDB += size
+	case name == "neutrino.db":
+		u.NeutrinoDB += size
+	case name == "block_headers.bin":
+		u.BlockHeaders += size
+	case name == "reg_filter_headers.bin":
+		u.FilterHeaders += size
+	case name == "macaroons.db" || filepath.Ext(name) == ".macaroon":
+		u.Macaroons += size
+	default:
+		u.Other += size
+	}
+}
+
+// DiskUsage reports the disk space used by lnd within the passed app data
+// directory, across all networks. It doesn't require lnd to be running.
+func DiskUsage(dataDir string) (*StorageUsage, error) {
+	lndDir := filepath.Join(dataDir, lndDirname)
+
+	usage := &StorageUsage{}
+	err := filepath.Walk(lndDir, func(path string, info os.FileInfo,
+		err error) error {
+
+		switch {
+		// Nothing has been stored yet.
+		case err != nil && os.IsNotExist(err) && path == lndDir:
+			return filepath.SkipDir
+
+		case err != nil:
+			return err
+
+		case info.IsDir():
+			return nil
+		}
+
+		relPath, err := filepath.Rel(lndDir, path)
+		if err != nil {
+			return err
+		}
+		usage.add(relPath, info.Size())
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// ErrMigrateWhileRunning is returned by MigrateDataDir if lnd is running, as
+// its databases can't be moved while they're open.
+var ErrMigrateWhileRunning = errors.New("lnd must be stopped before " +
+	"migrating its data directory")
+
+// copyFile copies the file at src to dst, returning the sha256 digest of the
+// bytes read from src. Copied bytes are reported through progress.
+func copyFile(src, dst string, mode os.FileMode,
+	progress func(n int64)) ([]byte, error) {
+
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	buf := make([]byte, 1<<20)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				return nil, err
+			}
+			hash.Write(buf[:n])
+			progress(int64(n))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Ensure the copy has hit the disk before we verify it.
+	if err := out.Sync(); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// fileDigest returns the sha256 digest of the file at path.
+func fileDigest(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// MigrateDataDir moves lnd's files from the app data directory oldDir to
+// newDir. Every file is verified after being copied, and if anything fails
+// the partial copy is removed, leaving oldDir untouched. The old files are
+// only removed once the entire copy has been verified. Progress is reported
+// as the number of bytes copied so far, out of the total.
+func MigrateDataDir(oldDir, newDir string,
+	progress func(copied, total int64)) error {
+
+	if Running() {
+		return ErrMigrateWhileRunning
+	}
+
+	srcDir := filepath.Join(oldDir, lndDirname)
+	dstDir := filepath.Join(newDir, lndDirname)
+
+	if _, err := os.Stat(srcDir); err != nil {
+		return fmt.Errorf("unable to find lnd data in %v: %v", oldDir,
+			err)
+	}
+	if _, err := os.Stat(dstDir); !os.IsNotExist(err) {
+		return fmt.Errorf("lnd data already exists in %v", newDir)
+	}
+
+	usage, err := DiskUsage(oldDir)
+	if err != nil {
+		return err
+	}
+
+	var copied int64
+	reportProgress := func(n int64) {
+		copied += n
+		progress(copied, usage.Total)
+	}
+
+	digests := make(map[string][]byte)
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo,
+		err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, relPath)
+
+		if info.IsDir() {
+			return os.MkdirAll(dst, info.Mode().Perm())
+		}
+
+		digest, err := copyFile(path, dst, info.Mode().Perm(),
+			reportProgress)
+		if err != nil {
+			return err
+		}
+		digests[dst] = digest
+
+		return nil
+	})
+
+	// With everything copied, read the copies back to verify them.
+	if err == nil {
+		for dst, digest := range digests {
+			var copyDigest []byte
+			copyDigest, err = fileDigest(dst)
+			if err != nil {
+				break
+			}
+
+			if !bytes.Equal(digest, copyDigest) {
+				err = fmt.Errorf("verification of %v failed",
+					dst)
+				break
+			}
+		}
+	}
+
+	// Roll back the partial copy on failure.
+	if err != nil {
+		ltndLog.Errorf("Unable to migrate data directory: %v", err)
+
+		if rmErr := os.RemoveAll(dstDir); rmErr != nil {
+			ltndLog.Errorf("Unable to remove partial copy %v: %v",
+				dstDir, rmErr)
+		}
+		return err
+	}
+
+	if err := os.RemoveAll(srcDir); err != nil {
+		return fmt.Errorf("data was migrated to %v, but the old copy "+
+			"couldn't be removed: %v", newDir, err)
+	}
+
+	return nil
+}
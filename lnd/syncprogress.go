@@ -0,0 +1,134 @@
+package lnd
+
+import "time"
+
+// syncProgressInterval is how often the sync progress is checked for changes
+// by SubscribeSyncProgress.
+const syncProgressInterval = time.Second
+
+// SyncProgress is the progress of the chain backend syncing the chain.
+type SyncProgress struct {
+	// HeaderHeight is the height of the block headers downloaded, and
+	// FilterHeaderHeight that of the headers of the compact filters.
+	HeaderHeight       int32 `json:"header_height"`
+	FilterHeaderHeight int32 `json:"filter_header_height"`
+
+	// FilterHeight is the height the wallet matched the compact filters
+	// of the blocks against its addresses up to.
+	FilterHeight int32 `json:"filter_height"`
+
+	// PeerHeight is the best height the peers announced, and
+	// BlocksRemaining the number of blocks the wallet still has to catch
+	// up with it.
+	PeerHeight      int32 `json:"peer_height"`
+	BlocksRemaining int32 `json:"blocks_remaining"`
+
+	NumPeers int32 `json:"num_peers"`
+	Synced   bool  `json:"synced"`
+}
+
+// SyncProgressState returns the current sync progress of the chain backend of
+// the running daemon.
+func SyncProgressState() (*SyncProgress, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return d.syncProgress()
+}
+
+// SubscribeSyncProgress calls update with the sync progress of the chain
+// backend now and whenever it changes, until quit is closed. It returns
+// ErrDaemonNotRunning if the daemon stops first.
+func SubscribeSyncProgress(quit <-chan struct{},
+	update func(*SyncProgress)) error {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return ErrDaemonNotRunning
+	}
+
+	ticker := time.NewTicker(syncProgressInterval)
+	defer ticker.Stop()
+
+	var last SyncProgress
+	for first := true; ; first = false {
+		progress, err := d.syncProgress()
+		if err != nil {
+			return err
+		}
+		if first || *progress != last {
+			update(progress)
+			last = *progress
+		}
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return nil
+		case <-d.quit:
+			return ErrDaemonNotRunning
+		}
+	}
+}
+
+// syncProgress returns the sync progress of the chain backend. Without the
+// neutrino light client, all heights are those of the best block, and no
+// peers are counted.
+func (d *daemon) syncProgress() (*SyncProgress, error) {
+	synced, _, err := d.cc.wallet.IsSynced()
+	if err != nil {
+		return nil, err
+	}
+	_, bestHeight, err := d.cc.chainIO.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	syncedTo := d.internalWallet().Manager.SyncedTo()
+
+	progress := &SyncProgress{
+		HeaderHeight:       bestHeight,
+		FilterHeaderHeight: bestHeight,
+		FilterHeight:       syncedTo.Height,
+		PeerHeight:         bestHeight,
+		Synced:             synced,
+	}
+
+	if svc := d.cc.lightClient; svc != nil {
+		_, height, err := svc.BlockHeaders.ChainTip()
+		if err != nil {
+			return nil, err
+		}
+		progress.HeaderHeight = int32(height)
+
+		_, height, err = svc.RegFilterHeaders.ChainTip()
+		if err != nil {
+			return nil, err
+		}
+		progress.FilterHeaderHeight = int32(height)
+
+		peers := svc.Peers()
+		progress.NumPeers = int32(len(peers))
+		progress.PeerHeight = progress.HeaderHeight
+		for _, peer := range peers {
+			if peer.LastBlock() > progress.PeerHeight {
+				progress.PeerHeight = peer.LastBlock()
+			}
+		}
+	}
+
+	if progress.PeerHeight > progress.FilterHeight {
+		progress.BlocksRemaining = progress.PeerHeight -
+			progress.FilterHeight
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,241 @@
+// +build !noremoterpc
+
+package lnd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// Make certificate valid for 14 months.
+	autogenCertValidity = 14 /*months*/ * 30 /*days*/ * 24 * time.Hour
+)
+
+var (
+	// End of ASN.1 time.
+	endOfTime = time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	// Max serial number.
+	serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 128)
+
+	/*
+	 * These cipher suites fit the following criteria:
+	 * - Don't use outdated algorithms like SHA-1 and 3DES
+	 * - Don't use ECB mode or other insecure symmetric methods
+	 * - Included in the TLS v1.2 suite
+	 * - Are available in the Go 1.7.6 standard library (more are
+	 *   available in 1.8.3 and will be added after lnd no longer
+	 *   supports 1.7, including suites that support CBC mode)
+	**/
+	tlsCipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	}
+)
+
+// startRPCListeners serves the rpcServer over TLS on the configured RPC
+// listeners, so remote apps can be paired with the node. There are none unless
+// configured, and as external clients must authenticate, the listeners are
+// only brought up if macaroons are enabled.
+func (d *daemon) startRPCListeners() error {
+	if d.macaroonService == nil || len(cfg.RPCListeners) == 0 {
+		return nil
+	}
+
+	// Ensure we create TLS key and certificate if they don't exist
+	if !fileExists(cfg.TLSCertPath) && !fileExists(cfg.TLSKeyPath) {
+		if err := genCertPair(cfg.TLSCertPath, cfg.TLSKeyPath); err != nil {
+			return err
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertPath, cfg.TLSKeyPath)
+	if err != nil {
+		return err
+	}
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		CipherSuites: tlsCipherSuites,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	d.tlsGrpcServer = grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConf)),
+		grpc.UnaryInterceptor(d.macaroonService.
+			UnaryServerInterceptor(permissions)),
+		grpc.StreamInterceptor(d.macaroonService.
+			StreamServerInterceptor(permissions)),
+	)
+	lnrpc.RegisterLightningServer(d.tlsGrpcServer, d.rpcServer)
+
+	// The listeners are closed when the gRPC server is stopped.
+	for _, listener := range cfg.RPCListeners {
+		lis, err := net.Listen("tcp", listener)
+		if err != nil {
+			ltndLog.Errorf("RPC server unable to listen on %s",
+				listener)
+			return err
+		}
+		d.rpcListeners = append(d.rpcListeners, lis.Addr())
+
+		go func() {
+			defer RecoverPanic("RPCS")
+
+			rpcsLog.Infof("RPC server listening on %s", lis.Addr())
+			d.tlsGrpcServer.Serve(lis)
+		}()
+	}
+
+	return nil
+}
+
+// genCertPair generates a key/cert pair to the paths provided. The
+// auto-generated certificates should *not* be used in production for public
+// access as they're self-signed and don't necessarily contain all of the
+// desired hostnames for the service. For production/public use, consider a
+// real PKI.
+//
+// This function is adapted from https://github.com/btcsuite/btcd and
+// https://github.com/btcsuite/btcutil
+func genCertPair(certFile, keyFile string) error {
+	rpcsLog.Infof("Generating TLS certificates...")
+
+	org := "lnd autogenerated cert"
+	now := time.Now()
+	validUntil := now.Add(autogenCertValidity)
+
+	// Check that the certificate validity isn't past the ASN.1 end of time.
+	if validUntil.After(endOfTime) {
+		validUntil = endOfTime
+	}
+
+	// Generate a serial number that's below the serialNumberLimit.
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %s", err)
+	}
+
+	// Collect the host's IP addresses, including loopback, in a slice.
+	ipAddresses := []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}
+
+	// addIP appends an IP address only if it isn't already in the slice.
+	addIP := func(ipAddr net.IP) {
+		for _, ip := range ipAddresses {
+			if bytes.Equal(ip, ipAddr) {
+				return
+			}
+		}
+		ipAddresses = append(ipAddresses, ipAddr)
+	}
+
+	// Add all the interface IPs that aren't already in the slice.
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+	for _, a := range addrs {
+		ipAddr, _, err := net.ParseCIDR(a.String())
+		if err == nil {
+			addIP(ipAddr)
+		}
+	}
+
+	// Add extra IP to the slice.
+	ipAddr := net.ParseIP(cfg.TLSExtraIP)
+	if ipAddr != nil {
+		addIP(ipAddr)
+	}
+
+	// Collect the host's names into a slice.
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	dnsNames := []string{host}
+	if host != "localhost" {
+		dnsNames = append(dnsNames, "localhost")
+	}
+	if cfg.TLSExtraDomain != "" {
+		dnsNames = append(dnsNames, cfg.TLSExtraDomain)
+	}
+
+	// Generate a private key for the certificate.
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	// Construct the certificate template.
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{org},
+			CommonName:   host,
+		},
+		NotBefore: now.Add(-time.Hour * 24),
+		NotAfter:  validUntil,
+
+		KeyUsage: x509.KeyUsageKeyEncipherment |
+			x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:                  true, // so can sign self.
+		BasicConstraintsValid: true,
+
+		DNSNames:    dnsNames,
+		IPAddresses: ipAddresses,
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template,
+		&template, &priv.PublicKey, priv)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %v", err)
+	}
+
+	certBuf := &bytes.Buffer{}
+	err = pem.Encode(certBuf, &pem.Block{Type: "CERTIFICATE",
+		Bytes: derBytes})
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate: %v", err)
+	}
+
+	keybytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return fmt.Errorf("unable to encode privkey: %v", err)
+	}
+	keyBuf := &bytes.Buffer{}
+	err = pem.Encode(keyBuf, &pem.Block{Type: "EC PRIVATE KEY",
+		Bytes: keybytes})
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %v", err)
+	}
+
+	// Write cert and key files.
+	if err = ioutil.WriteFile(certFile, certBuf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(keyFile, keyBuf.Bytes(), 0600); err != nil {
+		os.Remove(certFile)
+		return err
+	}
+
+	rpcsLog.Infof("Done generating TLS certificates")
+	return nil
+}
@@ -0,0 +1,265 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+// paymentPollInterval is how often the channels are checked for the HTLC of a
+// payment sent before the node last started, besides whenever a preimage is
+// learned.
+const paymentPollInterval = 10 * time.Second
+
+// ErrPaymentNotFound is returned when tracking a payment that wasn't sent
+// through SendPaymentV2.
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// paymentHTLCPending returns true if the payment with the hash may still have
+// an HTLC outstanding: either one of the open channels holds an outgoing HTLC
+// with the hash, or a force closed channel is still being resolved on chain,
+// which may hold one.
+func paymentHTLCPending(chanDB *channeldb.DB, paymentHash [32]byte) (bool,
+	error) {
+
+	channels, err := chanDB.FetchAllChannels()
+	if err != nil {
+		return false, err
+	}
+	for _, channel := range channels {
+		for _, htlc := range channel.LocalCommitment.Htlcs {
+			if !htlc.Incoming && htlc.RHash == paymentHash {
+				return true, nil
+			}
+		}
+	}
+
+	closeSummaries, err := chanDB.FetchClosedChannels(true)
+	if err != nil {
+		return false, err
+	}
+	for _, summary := range closeSummaries {
+		if summary.CloseType != channeldb.CooperativeClose {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// resolveStoredPayment updates the status of a payment left in flight by an
+// earlier run of the node from what the node knows of it now. The payment
+// succeeded if its preimage was learned, is still in flight while its HTLC may
+// be outstanding, and failed otherwise.
+func resolveStoredPayment(chanDB *channeldb.DB, status *PaymentStatus) error {
+	var paymentHash [32]byte
+	hash, err := hex.DecodeString(status.PaymentHash)
+	if err != nil {
+		return err
+	}
+	copy(paymentHash[:], hash)
+
+	// The link learns the preimage before it removes the HTLC from the
+	// channel, so looking for the HTLC first means a payment settling in
+	// between isn't taken as failed.
+	pending, err := paymentHTLCPending(chanDB, paymentHash)
+	if err != nil {
+		return err
+	}
+
+	preimage, err := chanDB.NewWitnessCache().LookupWitness(
+		channeldb.Sha256HashWitness, paymentHash[:],
+	)
+	switch {
+	case err == nil:
+		status.State = PaymentSucceeded
+		status.PaymentPreimage = hex.EncodeToString(preimage)
+
+	case err != channeldb.ErrNoWitnesses:
+		return err
+
+	case pending:
+		return nil
+
+	default:
+		status.State = PaymentFailed
+		status.FailureReason = "payment failed while the node was " +
+			"stopped"
+		status.FailureDetail = &PaymentFailure{
+			Reason:  FailureReasonError,
+			Message: status.FailureReason,
+		}
+	}
+
+	// Attempts in flight when the node stopped share the fate of the
+	// payment.
+	now := time.Now().Unix()
+	for _, htlc := range status.Htlcs {
+		if htlc.State == PaymentInFlight {
+			htlc.State = status.State
+			htlc.ResolveTime = now
+		}
+	}
+
+	return nil
+}
+
+// trackStoredPayment calls update with the status of a payment stored by an
+// earlier run of the node, and again each time it changes, until it succeeded
+// or failed, quit is closed, or the node stops.
+func trackStoredPayment(d *daemon, db walletdb.DB, status *PaymentStatus,
+	quit <-chan struct{}, update func(*PaymentStatus)) error {
+
+	preimages := d.server.witnessBeacon.SubscribeUpdates()
+	defer preimages.CancelSubscription()
+
+	ticker := time.NewTicker(paymentPollInterval)
+	defer ticker.Stop()
+
+	update(status)
+	for status.State == PaymentInFlight {
+		select {
+		case <-preimages.WitnessUpdates:
+		case <-ticker.C:
+		case <-quit:
+			return nil
+		case <-d.server.quit:
+			return ErrDaemonNotRunning
+		}
+
+		if err := resolveStoredPayment(d.chanDB, status); err != nil {
+			return err
+		}
+		if status.State == PaymentInFlight {
+			continue
+		}
+
+		if err := putPaymentStatus(db, status); err != nil {
+			return err
+		}
+		update(status)
+	}
+
+	return nil
+}
+
+// TrackPayment calls update with the state of the payment with the hash, sent
+// through SendPaymentV2, and again as it progresses, until it succeeded or
+// failed, or quit is closed. Payments sent since the node started are followed
+// attempt by attempt. Those left in flight by an earlier run, such as when the
+// app was killed while paying, are resolved from the channels: they succeeded
+// once the preimage is learned, and failed once no HTLC of theirs can remain.
+// Their route and fee are unknown, and they aren't added to ListPayments.
+func TrackPayment(paymentHash [32]byte, quit <-chan struct{},
+	update func(*PaymentStatus)) error {
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return ErrDaemonNotRunning
+	}
+
+	paymentsMtx.Lock()
+	if p, ok := inFlightPayments[paymentHash]; ok {
+		id := p.nextID
+		p.nextID++
+		p.subscribers[id] = update
+		u := &paymentUpdate{
+			p:           p,
+			version:     p.version,
+			status:      p.snapshot(),
+			subscribers: []func(*PaymentStatus){update},
+		}
+		paymentsMtx.Unlock()
+		u.deliver()
+
+		select {
+		case <-p.done:
+		case <-quit:
+		}
+
+		paymentsMtx.Lock()
+		delete(p.subscribers, id)
+		paymentsMtx.Unlock()
+
+		return nil
+	}
+	paymentsMtx.Unlock()
+
+	db := d.internalWallet().Database()
+	statuses, err := fetchPaymentStatuses(db, &paymentHash)
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		return ErrPaymentNotFound
+	}
+
+	if err := resolveStoredPayment(d.chanDB, statuses[0]); err != nil {
+		return err
+	}
+
+	return trackStoredPayment(d, db, statuses[0], quit, update)
+}
+
+// TrackPayments tracks every payment sent through SendPaymentV2 that is in
+// flight, as TrackPayment does, until all of them succeeded or failed, or quit
+// is closed. update is called one at a time.
+func TrackPayments(quit <-chan struct{}, update func(*PaymentStatus)) error {
+	w, err := runningWallet()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := fetchPaymentStatuses(w.Database(), nil)
+	if err != nil {
+		return err
+	}
+
+	var (
+		updateMtx sync.Mutex
+		wg        sync.WaitGroup
+		errs      = make(chan error, len(statuses))
+	)
+	for _, status := range statuses {
+		if status.State != PaymentInFlight {
+			continue
+		}
+
+		var paymentHash [32]byte
+		hash, err := hex.DecodeString(status.PaymentHash)
+		if err != nil {
+			return err
+		}
+		copy(paymentHash[:], hash)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errs <- TrackPayment(paymentHash, quit,
+				func(status *PaymentStatus) {
+					updateMtx.Lock()
+					update(status)
+					updateMtx.Unlock()
+				},
+			)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1026,6 +1026,7 @@ func (u *utxoNursery) populateSweepTx(txVSize int64, classHeight uint32,
 	if err != nil {
 		return nil, err
 	}
+	feePerVSize = sweepFeeRate(feePerVSize)
 	txFee := feePerVSize.FeeForVSize(txVSize)
 
 	// Sweep as much possible, after subtracting txn fees.
@@ -1130,6 +1131,12 @@ func (u *utxoNursery) sweepMatureOutputs(classHeight uint32, finalTx *wire.MsgTx
 		return err
 	}
 
+	publishEvent(EventSweep, &SweepEvent{
+		TxHash:     finalTx.TxHash().String(),
+		NumOutputs: len(kgtnOutputs),
+		Height:     classHeight,
+	})
+
 	return u.registerSweepConf(finalTx, kgtnOutputs, classHeight)
 }
 
@@ -0,0 +1,348 @@
+package lnd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/torsvc"
+	"github.com/roasbeef/btcd/btcec"
+)
+
+const (
+	// webFeeSignatureHeader is the response header holding the hex encoded
+	// DER signature of the fee source's public key over the SHA-256 of
+	// the response body.
+	webFeeSignatureHeader = "X-Signature"
+
+	// webFeeTimeout bounds a single request to the fee source.
+	webFeeTimeout = 30 * time.Second
+
+	// maxWebFeeResponse is the largest response body that is read from
+	// the fee source.
+	maxWebFeeResponse = 64 * 1024
+
+	// maxWebFeeRate caps the rates of the fee source, so a compromised
+	// source can't make the wallet burn its balance on fees.
+	maxWebFeeRate = lnwallet.SatPerVByte(1000)
+)
+
+// webFeeTargets maps the named rates of the mempool.space recommended fees
+// endpoint to the confirmation targets they are meant for.
+var webFeeTargets = map[string]uint32{
+	"fastestFee":  1,
+	"halfHourFee": 3,
+	"hourFee":     6,
+	"economyFee":  144,
+	"minimumFee":  1008,
+}
+
+// errWebFeeSignature is returned for a response of the fee source that isn't
+// signed by its configured public key.
+var errWebFeeSignature = errors.New("fee estimates aren't signed by the " +
+	"configured public key")
+
+// webFeeRate is a fee rate of the fee source, along with the confirmation
+// target it is meant for.
+type webFeeRate struct {
+	target uint32
+	rate   lnwallet.SatPerVByte
+}
+
+// webFeeEstimator is a FeeEstimator pulling its estimates from an HTTPS
+// source, for backends such as neutrino that can't estimate fees themselves.
+// Both the mempool.space recommended fees format and the Esplora fee estimates
+// format, mapping confirmation targets to rates, are understood. The estimates
+// are refreshed in the background, and the fallback rate is used until the
+// first ones arrive or once they've gone stale.
+type webFeeEstimator struct {
+	url      string
+	pubKey   *btcec.PublicKey
+	cacheTTL time.Duration
+	fallback lnwallet.SatPerVByte
+	client   *http.Client
+
+	mtx     sync.RWMutex
+	rates   []webFeeRate
+	updated time.Time
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// A compile-time assertion to ensure webFeeEstimator meets the FeeEstimator
+// interface.
+var _ lnwallet.FeeEstimator = (*webFeeEstimator)(nil)
+
+// validateWebFeeConfig checks the fee source is an HTTPS URL on one of the
+// allowed hosts, unless its responses are required to be signed, returning
+// the parsed public key if one is set.
+func validateWebFeeConfig(c *webFeeConfig) (*btcec.PublicKey, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("webfee.url must be an https URL")
+	}
+
+	var pubKey *btcec.PublicKey
+	if c.PubKey != "" {
+		keyBytes, err := hex.DecodeString(c.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webfee.pubkey: %v", err)
+		}
+		pubKey, err = btcec.ParsePubKey(keyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid webfee.pubkey: %v", err)
+		}
+	}
+
+	allowed := false
+	for _, host := range c.AllowHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed && pubKey == nil {
+		return nil, fmt.Errorf("webfee.url host %v isn't allowed, add "+
+			"it with webfee.allowhost or set webfee.pubkey",
+			u.Hostname())
+	}
+
+	if c.CacheTTL < time.Minute {
+		return nil, fmt.Errorf("webfee.cachettl must be at least a " +
+			"minute")
+	}
+	if c.FallbackFeeRate < 1 {
+		return nil, fmt.Errorf("webfee.fallbackfeerate must be positive")
+	}
+
+	return pubKey, nil
+}
+
+// newWebFeeEstimator returns a fee estimator for the configured source,
+// dialing through the passed network so it honors the Tor settings.
+func newWebFeeEstimator(c *webFeeConfig,
+	netCfg torsvc.Net) (*webFeeEstimator, error) {
+
+	pubKey, err := validateWebFeeConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: webFeeTimeout,
+		Transport: &http.Transport{
+			Dial: netCfg.Dial,
+		},
+		// A redirect could lead off the allowed hosts.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &webFeeEstimator{
+		url:      c.URL,
+		pubKey:   pubKey,
+		cacheTTL: c.CacheTTL,
+		fallback: lnwallet.SatPerVByte(c.FallbackFeeRate),
+		client:   client,
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Start fetches the estimates in the background, so a slow source doesn't hold
+// up startup. The fallback rate is used until they arrive.
+//
+// NOTE: This is part of the FeeEstimator interface.
+func (w *webFeeEstimator) Start() error {
+	w.wg.Add(1)
+	go w.refresher()
+
+	return nil
+}
+
+// Stop stops refreshing the estimates.
+//
+// NOTE: This is part of the FeeEstimator interface.
+func (w *webFeeEstimator) Stop() error {
+	close(w.quit)
+	w.wg.Wait()
+
+	return nil
+}
+
+// EstimateFeePerVSize returns the rate of the fee source for the slowest
+// target that still confirms within numBlocks, or for its fastest target if
+// none does.
+//
+// NOTE: This is part of the FeeEstimator interface.
+func (w *webFeeEstimator) EstimateFeePerVSize(
+	numBlocks uint32) (lnwallet.SatPerVByte, error) {
+
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	// Estimates that failed to refresh for several periods in a row are
+	// no better than the fallback.
+	if len(w.rates) == 0 || time.Since(w.updated) > 3*w.cacheTTL {
+		return w.fallback, nil
+	}
+
+	rate := w.rates[0].rate
+	for _, r := range w.rates {
+		if r.target > numBlocks {
+			break
+		}
+		rate = r.rate
+	}
+
+	return rate, nil
+}
+
+// refresher fetches the estimates, then refreshes them every time their cache
+// expires, until the estimator is stopped. It must be run as a goroutine.
+func (w *webFeeEstimator) refresher() {
+	defer w.wg.Done()
+
+	if err := w.refresh(); err != nil {
+		ltndLog.Warnf("Unable to fetch fee estimates from %v, using "+
+			"%v sat/vbyte until they can be: %v", w.url,
+			int64(w.fallback), err)
+	}
+
+	ticker := time.NewTicker(w.cacheTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.refresh(); err != nil {
+				ltndLog.Warnf("Unable to refresh fee estimates "+
+					"from %v: %v", w.url, err)
+			}
+
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// refresh fetches the estimates of the fee source, replacing the cached ones.
+func (w *webFeeEstimator) refresh() error {
+	resp, err := w.client.Get(w.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(
+		&io.LimitedReader{R: resp.Body, N: maxWebFeeResponse},
+	)
+	if err != nil {
+		return err
+	}
+
+	if w.pubKey != nil {
+		sig := resp.Header.Get(webFeeSignatureHeader)
+		if err := w.verify(body, sig); err != nil {
+			return err
+		}
+	}
+
+	rates, err := parseWebFeeRates(body)
+	if err != nil {
+		return err
+	}
+
+	w.mtx.Lock()
+	w.rates = rates
+	w.updated = time.Now()
+	w.mtx.Unlock()
+
+	ltndLog.Debugf("Fetched fee estimates from %v: %v", w.url, rates)
+
+	return nil
+}
+
+// verify checks the hex encoded signature is a signature of the fee source's
+// public key over the SHA-256 of the body.
+func (w *webFeeEstimator) verify(body []byte, signature string) error {
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return errWebFeeSignature
+	}
+	sig, err := btcec.ParseDERSignature(sigBytes, btcec.S256())
+	if err != nil {
+		return errWebFeeSignature
+	}
+
+	digest := sha256.Sum256(body)
+	if !sig.Verify(digest[:], w.pubKey) {
+		return errWebFeeSignature
+	}
+
+	return nil
+}
+
+// parseWebFeeRates parses fee estimates in sat/vbyte, either in the
+// mempool.space recommended fees format or in the Esplora format keyed by
+// confirmation target. The rates are returned ordered by target, with each
+// rate at least 1 sat/vbyte and at most maxWebFeeRate.
+func parseWebFeeRates(body []byte) ([]webFeeRate, error) {
+	var estimates map[string]float64
+	if err := json.Unmarshal(body, &estimates); err != nil {
+		return nil, fmt.Errorf("unable to decode fee estimates: %v", err)
+	}
+
+	var rates []webFeeRate
+	for key, value := range estimates {
+		target, ok := webFeeTargets[key]
+		if !ok {
+			n, err := strconv.ParseUint(key, 10, 32)
+			if err != nil || n == 0 {
+				continue
+			}
+			target = uint32(n)
+		}
+
+		// Round fractional rates up, so they don't underpay.
+		rate := lnwallet.SatPerVByte(math.Ceil(value))
+		switch {
+		case rate < 1:
+			rate = 1
+		case rate > maxWebFeeRate:
+			rate = maxWebFeeRate
+		}
+
+		rates = append(rates, webFeeRate{target: target, rate: rate})
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("no fee estimates in response")
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].target < rates[j].target
+	})
+
+	return rates, nil
+}
@@ -0,0 +1,55 @@
+package lnd
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcutil"
+)
+
+const (
+	// wumboChannelsRequired and wumboChannelsOptional are the feature bits
+	// of option_support_large_channel (BOLT 9), signalling support for
+	// channels above maxFundingAmount.
+	wumboChannelsRequired lnwire.FeatureBit = 18
+	wumboChannelsOptional lnwire.FeatureBit = 19
+)
+
+// maxChanSize returns the largest channel that may be opened with, or
+// accepted from, the peer. Channels only exceed maxFundingAmount if both
+// sides signal wumbo channels.
+func maxChanSize(peer *peer) btcutil.Amount {
+	maxSize := btcutil.Amount(cfg.MaxChanSize)
+	if maxSize <= maxFundingAmount {
+		return maxSize
+	}
+
+	if peer == nil || peer.remoteLocalFeatures == nil {
+		return maxFundingAmount
+	}
+	features := peer.remoteLocalFeatures
+	if !features.IsSet(wumboChannelsRequired) &&
+		!features.IsSet(wumboChannelsOptional) {
+
+		return maxFundingAmount
+	}
+
+	return maxSize
+}
+
+// checkChanSize returns an error if a channel of the amount exceeds the
+// largest one that may be opened with the peer.
+func checkChanSize(s *server, peerKey *btcec.PublicKey,
+	amt btcutil.Amount) error {
+
+	// Before the peer is connected its features are unknown, so it is
+	// taken not to support wumbo channels.
+	p, _ := s.FindPeer(peerKey)
+	if maxSize := maxChanSize(p); amt > maxSize {
+		return fmt.Errorf("funding amount is too large, the max "+
+			"channel size is: %v", maxSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package lnd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/roasbeef/btcd/chaincfg"
+	"github.com/roasbeef/btcd/chaincfg/chainhash"
+	"github.com/roasbeef/btcutil"
+	"github.com/roasbeef/btcutil/base58"
+	"github.com/roasbeef/btcutil/hdkeychain"
+	"github.com/roasbeef/btcwallet/waddrmgr"
+	base "github.com/roasbeef/btcwallet/wallet"
+	"github.com/roasbeef/btcwallet/walletdb"
+)
+
+// waddrmgrNamespaceKey is the namespace the address manager of btcwallet
+// stores its state under.
+var waddrmgrNamespaceKey = []byte("waddrmgr")
+
+var (
+	// ErrSeedUnavailable is returned by ExportAccountXpubs if the daemon
+	// wasn't started with the wallet's seed.
+	ErrSeedUnavailable = errors.New("the wallet's seed is required to " +
+		"export its account keys")
+
+	// ErrSeedMismatch is returned by ExportAccountXpubs if the seed the
+	// daemon was started with doesn't belong to the wallet.
+	ErrSeedMismatch = errors.New("the seed doesn't match the wallet")
+)
+
+// slip132Versions maps the key scopes to the SLIP-0132 version bytes of their
+// extended public keys on mainnet and testnet, which wallets such as Electrum
+// use to tell the address type apart.
+var slip132Versions = map[waddrmgr.KeyScope]map[string][4]byte{
+	waddrmgr.KeyScopeBIP0049Plus: {
+		chaincfg.MainNetParams.Name:  {0x04, 0x9d, 0x7c, 0xb2}, // ypub
+		chaincfg.TestNet3Params.Name: {0x04, 0x4a, 0x52, 0x62}, // upub
+	},
+	waddrmgr.KeyScopeBIP0084: {
+		chaincfg.MainNetParams.Name:  {0x04, 0xb2, 0x47, 0x46}, // zpub
+		chaincfg.TestNet3Params.Name: {0x04, 0x5f, 0x1c, 0xf6}, // vpub
+	},
+}
+
+// addressTypes names the type of the external addresses of every key scope.
+var addressTypes = map[waddrmgr.KeyScope]string{
+	waddrmgr.KeyScopeBIP0049Plus: "np2wkh",
+	waddrmgr.KeyScopeBIP0084:     "p2wkh",
+	waddrmgr.KeyScopeBIP0044:     "p2pkh",
+}
+
+// AccountXpub is the extended public key of an account, from which software
+// holding no private keys can derive all of the account's addresses.
+type AccountXpub struct {
+	Name           string `json:"name"`
+	DerivationPath string `json:"derivation_path"`
+	AddressType    string `json:"address_type"`
+	Xpub           string `json:"xpub"`
+
+	// Slip132 is the key serialized with the SLIP-0132 version of its
+	// address type, such as zpub, if there is one for the network.
+	Slip132 string `json:"slip132,omitempty"`
+}
+
+// AccountXpubs holds the extended public keys of all accounts of the wallet,
+// along with the fingerprint of the master key they're derived from.
+type AccountXpubs struct {
+	MasterFingerprint string         `json:"master_fingerprint"`
+	Accounts          []*AccountXpub `json:"accounts"`
+}
+
+// withVersion re-serializes the extended key with the given version bytes.
+func withVersion(xpub string, version [4]byte) (string, error) {
+	decoded := base58.Decode(xpub)
+	if len(decoded) < 8 {
+		return "", fmt.Errorf("malformed extended key")
+	}
+
+	payload := append([]byte(nil), decoded[:len(decoded)-4]...)
+	copy(payload, version[:])
+	checksum := chainhash.DoubleHashB(payload)[:4]
+
+	return base58.Encode(append(payload, checksum...)), nil
+}
+
+// deriveAccountKey derives the extended public key of the account within the
+// key scope from the master key.
+func deriveAccountKey(master *hdkeychain.ExtendedKey, scope waddrmgr.KeyScope,
+	account uint32) (*hdkeychain.ExtendedKey, error) {
+
+	key := master
+	for _, index := range []uint32{scope.Purpose, scope.Coin, account} {
+		var err error
+		key, err = key.Child(hdkeychain.HardenedKeyStart + index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key.Neuter()
+}
+
+// checkAccountKey verifies that the first external address derived from the
+// account key is the wallet's own, ensuring the key belongs to the wallet.
+func checkAccountKey(w *base.Wallet, accountKey *hdkeychain.ExtendedKey,
+	scope waddrmgr.KeyScope, account uint32) error {
+
+	external, err := accountKey.Child(0)
+	if err != nil {
+		return err
+	}
+	first, err := external.Child(0)
+	if err != nil {
+		return err
+	}
+	pubKey, err := first.ECPubKey()
+	if err != nil {
+		return err
+	}
+
+	manager, err := w.Manager.FetchScopedKeyManager(scope)
+	if err != nil {
+		return err
+	}
+
+	var walletPubKey []byte
+	err = walletdb.View(w.Database(), func(tx walletdb.ReadTx) error {
+		addr, err := manager.DeriveFromKeyPath(
+			tx.ReadBucket(waddrmgrNamespaceKey),
+			waddrmgr.DerivationPath{Account: account},
+		)
+		if err != nil {
+			return err
+		}
+
+		pubKeyAddr, ok := addr.(waddrmgr.ManagedPubKeyAddress)
+		if !ok {
+			return fmt.Errorf("unexpected address %T", addr)
+		}
+		walletPubKey = pubKeyAddr.PubKey().SerializeCompressed()
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(pubKey.SerializeCompressed(), walletPubKey) {
+		return ErrSeedMismatch
+	}
+
+	return nil
+}
+
+// ExportAccountXpubs returns the extended public keys of the default account
+// within every key scope, followed by those of the accounts created through
+// CreateAccount. As btcwallet doesn't expose its account keys, they're
+// derived from the seed the daemon was started with, after verifying that it
+// belongs to the wallet.
+func ExportAccountXpubs() (*AccountXpubs, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+	if len(d.seed) == 0 {
+		return nil, ErrSeedUnavailable
+	}
+
+	params := activeNetParams.Params
+	master, err := hdkeychain.NewMaster(d.seed, params)
+	if err != nil {
+		return nil, err
+	}
+	masterPub, err := master.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := btcutil.Hash160(masterPub.SerializeCompressed())[:4]
+
+	w := d.internalWallet()
+
+	type scopedAccount struct {
+		scope  waddrmgr.KeyScope
+		number uint32
+		name   string
+	}
+	var accounts []scopedAccount
+	for _, scope := range waddrmgr.DefaultKeyScopes {
+		accounts = append(accounts, scopedAccount{
+			scope:  scope,
+			number: waddrmgr.DefaultAccountNum,
+			name:   "default",
+		})
+	}
+
+	result, err := w.Accounts(accountKeyScope)
+	if err != nil {
+		return nil, err
+	}
+	for _, props := range result.Accounts {
+		if props.AccountNumber == waddrmgr.DefaultAccountNum ||
+			props.AccountNumber == waddrmgr.ImportedAddrAccount {
+
+			continue
+		}
+
+		accounts = append(accounts, scopedAccount{
+			scope:  accountKeyScope,
+			number: props.AccountNumber,
+			name:   props.AccountName,
+		})
+	}
+
+	xpubs := &AccountXpubs{
+		MasterFingerprint: hex.EncodeToString(fingerprint),
+	}
+	for i, account := range accounts {
+		accountKey, err := deriveAccountKey(
+			master, account.scope, account.number,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Checking the first account suffices to tell whether the
+		// seed is the wallet's.
+		if i == 0 {
+			err := checkAccountKey(
+				w, accountKey, account.scope, account.number,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		xpub := &AccountXpub{
+			Name: account.name,
+			DerivationPath: fmt.Sprintf("m/%d'/%d'/%d'",
+				account.scope.Purpose, account.scope.Coin,
+				account.number),
+			AddressType: addressTypes[account.scope],
+			Xpub:        accountKey.String(),
+		}
+
+		version, ok := slip132Versions[account.scope][params.Name]
+		if ok {
+			xpub.Slip132, err = withVersion(xpub.Xpub, version)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		xpubs.Accounts = append(xpubs.Accounts, xpub)
+	}
+
+	return xpubs, nil
+}
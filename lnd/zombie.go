@@ -0,0 +1,185 @@
+package lnd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/roasbeef/btcd/btcec"
+	"github.com/roasbeef/btcd/wire"
+	"github.com/roasbeef/btcutil"
+)
+
+// ZombieChannel is an open channel whose peer has been offline for long enough
+// that its funds are better recovered by force closing it.
+type ZombieChannel struct {
+	ChanPoint    string `json:"chan_point"`
+	RemotePubkey string `json:"remote_pubkey"`
+
+	// LastSeen is the unix time the peer was last connected or
+	// disconnected.
+	LastSeen int64 `json:"last_seen"`
+
+	Capacity     int64 `json:"capacity"`
+	LocalBalance int64 `json:"local_balance"`
+
+	// CsvDelay is the number of blocks the local balance stays time locked
+	// once the commitment transaction confirms.
+	CsvDelay uint16 `json:"csv_delay"`
+
+	// SweepFee is the estimated fee of sweeping the local balance back to
+	// the wallet, and RecoverableAmount what is left of it after the fee.
+	SweepFee          int64 `json:"sweep_fee"`
+	RecoverableAmount int64 `json:"recoverable_amount"`
+
+	chanPoint wire.OutPoint
+}
+
+// markPeerSeen sets the last time the peer was seen to now, if the node has
+// channels with it.
+func markPeerSeen(db *channeldb.DB, pub *btcec.PublicKey) error {
+	node, err := db.FetchLinkNode(pub)
+	switch {
+	case err == channeldb.ErrNodeNotFound,
+		err == channeldb.ErrLinkNodesNotFound:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	// Link nodes read from the database can't be written back, so a new
+	// one, seen now, takes its place.
+	seen := db.NewLinkNode(node.Network, node.IdentityPub, nil)
+	seen.Addresses = node.Addresses
+
+	return seen.Sync()
+}
+
+// zombieChannels returns the open channels of the daemon whose peers are
+// offline, and were last seen over threshold ago, with those offline longest
+// first.
+func zombieChannels(d *daemon,
+	threshold time.Duration) ([]*ZombieChannel, error) {
+
+	dbChannels, err := d.chanDB.FetchAllChannels()
+	if err != nil {
+		return nil, err
+	}
+
+	feeRate, err := d.cc.feeEstimator.EstimateFeePerVSize(6)
+	if err != nil {
+		return nil, err
+	}
+	feeRate = sweepFeeRate(feeRate)
+
+	// The local balance is swept by a transaction with a single time
+	// locked input, paying to the wallet.
+	var weightEstimate lnwallet.TxWeightEstimator
+	weightEstimate.AddWitnessInput(lnwallet.ToLocalTimeoutWitnessSize)
+	weightEstimate.AddP2WKHOutput()
+	sweepFee := feeRate.FeeForVSize(int64(weightEstimate.VSize()))
+
+	cutoff := time.Now().Add(-threshold)
+
+	var zombies []*ZombieChannel
+	for _, dbChannel := range dbChannels {
+		remotePub := dbChannel.IdentityPub
+		if _, err := d.server.FindPeer(remotePub); err == nil {
+			continue
+		}
+
+		node, err := d.chanDB.FetchLinkNode(remotePub)
+		if err != nil {
+			return nil, err
+		}
+		if node.LastSeen.After(cutoff) {
+			continue
+		}
+
+		// Without a local output, there is nothing to recover.
+		localCommit := dbChannel.LocalCommitment
+		localBalance := localCommit.LocalBalance.ToSatoshis()
+		var recoverable btcutil.Amount
+		if localBalance >= dbChannel.LocalChanCfg.DustLimit &&
+			localBalance > sweepFee {
+
+			recoverable = localBalance - sweepFee
+		}
+
+		zombies = append(zombies, &ZombieChannel{
+			ChanPoint: dbChannel.FundingOutpoint.String(),
+			RemotePubkey: hex.EncodeToString(
+				remotePub.SerializeCompressed()),
+			LastSeen:          node.LastSeen.Unix(),
+			Capacity:          int64(dbChannel.Capacity),
+			LocalBalance:      int64(localBalance),
+			CsvDelay:          dbChannel.LocalChanCfg.CsvDelay,
+			SweepFee:          int64(sweepFee),
+			RecoverableAmount: int64(recoverable),
+			chanPoint:         dbChannel.FundingOutpoint,
+		})
+	}
+
+	sort.Slice(zombies, func(i, j int) bool {
+		return zombies[i].LastSeen < zombies[j].LastSeen
+	})
+
+	return zombies, nil
+}
+
+// ZombieChannels returns the open channels whose peers are offline, and were
+// last seen over threshold ago, along with an estimate of the funds force
+// closing each recovers, with those offline longest first. Peers are seen when
+// they connect or disconnect.
+func ZombieChannels(threshold time.Duration) ([]*ZombieChannel, error) {
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	return zombieChannels(d, threshold)
+}
+
+// CloseZombieChannels force closes up to maxChannels of the channels returned
+// by ZombieChannels, those offline longest first, sweeping their time locked
+// outputs at satPerByte or more as ForceCloseAll does, and returns the outcome
+// for each. Calling it again closes the next batch.
+func CloseZombieChannels(threshold time.Duration, maxChannels int,
+	satPerByte int64) ([]*ForceCloseResult, error) {
+
+	if maxChannels <= 0 {
+		return nil, fmt.Errorf("invalid batch size: %v", maxChannels)
+	}
+	if satPerByte < 0 {
+		return nil, fmt.Errorf("negative fee rate: %v", satPerByte)
+	}
+
+	daemonMtx.Lock()
+	d := activeDaemon
+	daemonMtx.Unlock()
+
+	if d == nil {
+		return nil, ErrDaemonNotRunning
+	}
+
+	zombies, err := zombieChannels(d, threshold)
+	if err != nil {
+		return nil, err
+	}
+	if len(zombies) > maxChannels {
+		zombies = zombies[:maxChannels]
+	}
+
+	chanPoints := make([]wire.OutPoint, 0, len(zombies))
+	for _, zombie := range zombies {
+		chanPoints = append(chanPoints, zombie.chanPoint)
+	}
+
+	return forceCloseChannels(d, chanPoints, satPerByte), nil
+}